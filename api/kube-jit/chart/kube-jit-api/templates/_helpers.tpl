@@ -70,9 +70,17 @@ host
 ca
 insecure
 tokenSecret
+tokenRequest
 type
 projectID
 region
+roleARN
+kubeconfigSecret
+kubeconfigFile
+context
+platformApproverTeams
+adminTeams
+labels
 {{- end -}}
 
 {{/*
@@ -90,6 +98,27 @@ name
 id
 {{- end -}}
 
+{{/*
+Allowed service principals configMap keys
+*/}}
+{{- define "allowedServicePrincipalKeys" -}}
+name
+clientID
+clientSecretName
+scopes
+{{- end -}}
+
+{{/*
+Allowed API roles configMap keys
+*/}}
+{{- define "allowedApiRoleKeys" -}}
+name
+permissions
+clusters
+roles
+teams
+{{- end -}}
+
 {{/*
 Used for configMap key validation
 */}}
@@ -112,7 +141,7 @@ Used for configMap key validation
 Check if a value is in a list of allowed values.
 */}}
 {{- define "isValidType" -}}
-{{- $validTypes := list "gke" "generic" -}}
+{{- $validTypes := list "gke" "aks" "eks" "kubeconfig" "generic" -}}
 {{- $type := . -}}
 {{- if has $type $validTypes -}}
 true
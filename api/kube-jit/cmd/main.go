@@ -5,17 +5,23 @@
 package main
 
 import (
+	"context"
 	"encoding/gob"
 	"flag"
 	"kube-jit/internal/db"
 	"kube-jit/internal/handlers"
+	"kube-jit/internal/metrics"
 	"kube-jit/internal/middleware"
 	"kube-jit/internal/routes"
+	"kube-jit/internal/tracing"
 	"kube-jit/pkg/k8s"
+	"kube-jit/pkg/sessioncookie"
 	"kube-jit/pkg/utils"
+	"net/http"
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
@@ -23,6 +29,7 @@ import (
 
 	ginzap "github.com/gin-contrib/zap"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 var logger *zap.Logger
@@ -43,6 +50,8 @@ func main() {
 
 	// Optional: allow zap to bind flags if you want CLI overrides
 	zapCfg.Level = zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	validateConfigPath := flag.String("validate-config", "", "validate the given apiConfig.yaml and exit, with a non-zero code if it is invalid")
+	dryRun := flag.Bool("dry-run", false, "with --validate-config, also read the secrets of the config and check every cluster is reachable")
 	flag.Parse()
 
 	var err error
@@ -63,17 +72,78 @@ func main() {
 	middleware.InitLogger(logger)
 	k8s.InitLogger(logger)
 	utils.InitLogger(logger)
+	sessioncookie.InitLogger(logger)
+
+	// Team IDs of the configuration are qualified with their provider when several providers are enabled
+	k8s.SetIdentityProviders(handlers.ProviderNames())
+
+	// Only check the config file and exit, e.g. before updating the configMap
+	if *validateConfigPath != "" {
+		if !k8s.ValidateConfigFile(context.Background(), os.Stdout, *validateConfigPath, *dryRun) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Initialize OpenTelemetry tracing, spans are exported only if an OTLP endpoint is configured
+	shutdownTracer, err := tracing.InitTracer(context.Background())
+	if err != nil {
+		logger.Fatal("Failed to initialize tracing", zap.Error(err))
+	}
+	defer func() {
+		if err := shutdownTracer(context.Background()); err != nil {
+			logger.Warn("Failed to shutdown tracing", zap.Error(err))
+		}
+	}()
 
 	// Initialize Kubernetes client and cache
 	k8s.InitK8sConfig()
 
+	// Refresh the clients of clusters when the labelled secrets of their tokens or kubeconfigs change,
+	// WATCH_SECRETS_ENABLED=true needs list and watch on the secrets of the namespace
+	if utils.GetEnv("WATCH_SECRETS_ENABLED", "true") == "true" {
+		go k8s.WatchSecrets(context.Background())
+	}
+
+	// Register the clusters of JitCluster resources, JIT_CLUSTERS_ENABLED=true needs the CRD of the chart
+	if utils.GetEnv("JIT_CLUSTERS_ENABLED", "false") == "true" {
+		go k8s.WatchJitClusters(context.Background())
+	}
+
+	// Apply changes to apiConfig.yaml without a restart, CONFIG_RELOAD_INTERVAL=0 disables it
+	if interval := utils.GetEnvDuration("CONFIG_RELOAD_INTERVAL", 30*time.Second); interval > 0 {
+		go k8s.WatchConfig(context.Background(), interval)
+	}
+
+	// Probe the clusters for GET /clusters/status, CLUSTER_PROBE_INTERVAL=0 disables it
+	if interval := utils.GetEnvDuration("CLUSTER_PROBE_INTERVAL", time.Minute); interval > 0 {
+		go k8s.RunClusterProber(context.Background(), interval)
+	}
+
+	// Drop the expired groups of users from the cache
+	go handlers.SweepUserGroupCache(context.Background())
+
 	// Initialize database
 	db.InitDB()
 
+	// Keep sessions server side when SESSION_STORE=database, so they can be revoked
+	switch sessionStore := utils.GetEnv("SESSION_STORE", "cookie"); sessionStore {
+	case "cookie":
+	case "database":
+		db.EnableSessionStore()
+	default:
+		logger.Fatal("Invalid SESSION_STORE, expected cookie or database", zap.String("sessionStore", sessionStore))
+	}
+
 	r := gin.New()
 
+	// Trace every request except health checks
+	r.Use(otelgin.Middleware(tracing.ServiceName, otelgin.WithFilter(func(req *http.Request) bool {
+		return !strings.HasPrefix(req.URL.Path, "/kube-jit-api/healthz")
+	})))
+
 	// Skip only authenticated routes and healthz (not oauth, client_id, build-sha, logout)
-	rxAuthenticated := regexp.MustCompile(`^/kube-jit-api/(healthz|approving-groups|roles-and-clusters|github/profile|google/profile|azure/profile|submit-request|history|approvals|approve-reject|permissions|admin/clean-expired)$`)
+	rxAuthenticated := regexp.MustCompile(`^/kube-jit-api/(healthz|healthz/config|approving-groups|roles-and-clusters|github/profile|google/profile|azure/profile|gitlab/profile|oidc/profile|submit-request|history|approvals|approve-reject|permissions|admin/clean-expired|admin/sessions|admin/sessions/revoke|clusters/status|tokens|tokens/revoke)$`)
 	r.Use(ginzap.GinzapWithConfig(logger, &ginzap.Config{
 		UTC:             true,
 		TimeFormat:      time.RFC3339,
@@ -87,9 +157,25 @@ func main() {
 	// Setup routes
 	routes.SetupRoutes(r)
 
+	// Serve the metrics on their own port, kept off the public listener, METRICS_PORT=0 disables them
+	if metricsPort := utils.GetEnv("METRICS_PORT", "9090"); metricsPort != "0" {
+		go serveMetrics(metricsPort)
+	}
+
 	port := utils.MustGetEnv("LISTEN_PORT")
 	logger.Info("Starting server", zap.String("port", port))
 	if err := r.Run(":" + port); err != nil {
 		logger.Fatal("Failed to start server", zap.Error(err))
 	}
 }
+
+// serveMetrics serves GET /metrics on port until the server fails
+func serveMetrics(port string) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics.Handler())
+	server := &http.Server{Addr: ":" + port, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	logger.Info("Starting metrics server", zap.String("port", port))
+	if err := server.ListenAndServe(); err != nil {
+		logger.Error("Metrics server stopped", zap.Error(err))
+	}
+}
@@ -17,7 +17,7 @@ const docTemplate = `{
     "paths": {
         "/admin/clean-expired": {
             "post": {
-                "description": "Deletes JIT requests where endDate is in the past and status is \"Requested\" (not Approved or Rejected). Admin only.\nRequires one or more cookies named kube_jit_session_\u003cnumber\u003e (e.g., kube_jit_session_0, kube_jit_session_1).\nPass split cookies in the Cookie header, for example:\n-H \"Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}\"\nNote: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.",
+                "description": "Deletes JIT requests where endDate is in the past and status is \"Requested\" (not Approved or Rejected). Requires the admin permission.\nRequires one or more cookies named kube_jit_session_\u003cnumber\u003e (e.g., kube_jit_session_0, kube_jit_session_1).\nPass split cookies in the Cookie header, for example:\n-H \"Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}\"\nNote: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.",
                 "consumes": [
                     "application/json"
                 ],
@@ -44,8 +44,8 @@ const docTemplate = `{
                             "$ref": "#/definitions/handlers.CleanExpiredResponse"
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized: admin only",
+                    "403": {
+                        "description": "Forbidden: missing permission admin",
                         "schema": {
                             "$ref": "#/definitions/models.SimpleMessageResponse"
                         }
@@ -59,9 +59,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/approvals": {
+        "/admin/sessions": {
             "get": {
-                "description": "Returns the pending JIT requests for the authenticated user's approver groups.\nRequires one or more cookies named kube_jit_session_\u003cnumber\u003e (e.g., kube_jit_session_0, kube_jit_session_1).\nPass split cookies in the Cookie header, for example:\n-H \"Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}\"\nNote: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.",
+                "description": "Returns the active server-side sessions, optionally only those of a user. Requires the admin permission and SESSION_STORE=database.\nRequires one or more cookies named kube_jit_session_\u003cnumber\u003e (e.g., kube_jit_session_0, kube_jit_session_1).\nPass split cookies in the Cookie header, for example:\n-H \"Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}\"\nNote: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.",
                 "consumes": [
                     "application/json"
                 ],
@@ -69,9 +69,9 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "records"
+                    "admin"
                 ],
-                "summary": "Get pending JIT requests for approver groups",
+                "summary": "List active server-side sessions",
                 "parameters": [
                     {
                         "type": "string",
@@ -79,23 +79,38 @@ const docTemplate = `{
                         "name": "Cookie",
                         "in": "header",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only list the sessions of this user",
+                        "name": "userID",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "List of pending requests",
+                        "description": "Active sessions, most recently used first",
                         "schema": {
-                            "$ref": "#/definitions/handlers.PendingApprovalsResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Session"
+                            }
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized: no approver groups in session",
+                    "400": {
+                        "description": "Server-side sessions are not enabled",
+                        "schema": {
+                            "$ref": "#/definitions/models.SimpleMessageResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden: missing permission admin",
                         "schema": {
                             "$ref": "#/definitions/models.SimpleMessageResponse"
                         }
                     },
                     "500": {
-                        "description": "Failed to fetch pending requests",
+                        "description": "Failed to list sessions",
                         "schema": {
                             "$ref": "#/definitions/models.SimpleMessageResponse"
                         }
@@ -103,9 +118,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/approve-reject": {
+        "/admin/sessions/revoke": {
             "post": {
-                "description": "Approves or rejects pending JIT access requests. Admins and platform approvers can approve/reject multiple requests at once. Non-admins can approve/reject individual namespaces.\nRequires one or more cookies named kube_jit_session_\u003cnumber\u003e (e.g., kube_jit_session_0, kube_jit_session_1).\nPass split cookies in the Cookie header, for example:\n-H \"Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}\"\nNote: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.",
+                "description": "Revokes one session by ID, or every active session of a user, logging them out on their next request. Requires the admin permission and SESSION_STORE=database.\nRequires one or more cookies named kube_jit_session_\u003cnumber\u003e (e.g., kube_jit_session_0, kube_jit_session_1).\nPass split cookies in the Cookie header, for example:\n-H \"Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}\"\nNote: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.",
                 "consumes": [
                     "application/json"
                 ],
@@ -113,9 +128,9 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "request"
+                    "admin"
                 ],
-                "summary": "Approve or reject JIT access requests",
+                "summary": "Revoke server-side sessions",
                 "parameters": [
                     {
                         "type": "string",
@@ -125,36 +140,36 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "description": "Approval/rejection payload (admins/platform approvers use AdminApproveRequest, non-admins use UserApproveRequest)",
+                        "description": "User ID or session ID to revoke",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handlers.AdminApproveRequest"
+                            "$ref": "#/definitions/handlers.RevokeSessionsRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Requests processed successfully",
+                        "description": "Sessions revoked",
                         "schema": {
-                            "$ref": "#/definitions/models.SimpleMessageResponse"
+                            "$ref": "#/definitions/handlers.RevokeSessionsResponse"
                         }
                     },
                     "400": {
-                        "description": "Invalid request format",
+                        "description": "Invalid request or server-side sessions are not enabled",
                         "schema": {
                             "$ref": "#/definitions/models.SimpleMessageResponse"
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized: no approver groups in session",
+                    "403": {
+                        "description": "Forbidden: missing permission admin",
                         "schema": {
                             "$ref": "#/definitions/models.SimpleMessageResponse"
                         }
                     },
                     "500": {
-                        "description": "Failed to process requests",
+                        "description": "Failed to revoke sessions",
                         "schema": {
                             "$ref": "#/definitions/models.SimpleMessageResponse"
                         }
@@ -162,9 +177,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/approving-groups": {
+        "/approvals": {
             "get": {
-                "description": "Returns the list of platform approving groups for the authenticated user.\nRequires one or more cookies named kube_jit_session_\u003cnumber\u003e (e.g., kube_jit_session_0, kube_jit_session_1).\nPass split cookies in the Cookie header, for example:\n-H \"Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}\"\nNote: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.",
+                "description": "Returns the pending JIT requests for the authenticated user's approver groups.\nUsers with the requests:approve permission for some clusters or roles also get every pending request for them.\nRequires one or more cookies named kube_jit_session_\u003cnumber\u003e (e.g., kube_jit_session_0, kube_jit_session_1).\nPass split cookies in the Cookie header, for example:\n-H \"Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}\"\nNote: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.\nScripts can use an API token with the approve scope instead: -H \"Authorization: Bearer ${token}\"",
                 "consumes": [
                     "application/json"
                 ],
@@ -174,7 +189,7 @@ const docTemplate = `{
                 "tags": [
                     "records"
                 ],
-                "summary": "Get platform approving groups",
+                "summary": "Get pending JIT requests for approver groups",
                 "parameters": [
                     {
                         "type": "string",
@@ -186,16 +201,19 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "List of pending requests",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.Team"
-                            }
+                            "$ref": "#/definitions/handlers.PendingApprovalsResponse"
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized: no token in session data",
+                    "403": {
+                        "description": "Forbidden: missing permission requests:approve",
+                        "schema": {
+                            "$ref": "#/definitions/models.SimpleMessageResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch pending requests",
                         "schema": {
                             "$ref": "#/definitions/models.SimpleMessageResponse"
                         }
@@ -203,9 +221,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/azure/profile": {
-            "get": {
-                "description": "Returns the normalized Azure user profile for the authenticated user.\nRequires one or more cookies named kube_jit_session_\u003cnumber\u003e (e.g., kube_jit_session_0, kube_jit_session_1).\nPass split cookies in the Cookie header, for example:\n-H \"Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}\"\nNote: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.",
+        "/approve-reject": {
+            "post": {
+                "description": "Approves or rejects pending JIT access requests. Admins and platform approvers can approve/reject multiple requests at once. Non-admins can approve/reject individual namespaces.\nUsers with the requests:approve permission for the cluster and role of a request approve/reject all of its namespaces.\nApproving the namespaces of a JitGroup in a request of a bundle approves them in its other pending requests too, rejections are not shared.\nRequires one or more cookies named kube_jit_session_\u003cnumber\u003e (e.g., kube_jit_session_0, kube_jit_session_1).\nPass split cookies in the Cookie header, for example:\n-H \"Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}\"\nNote: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.\nScripts can use an API token with the approve scope instead: -H \"Authorization: Bearer ${token}\"",
                 "consumes": [
                     "application/json"
                 ],
@@ -213,9 +231,9 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "azure"
+                    "request"
                 ],
-                "summary": "Get the logged in user's Azure profile",
+                "summary": "Approve or reject JIT access requests",
                 "parameters": [
                     {
                         "type": "string",
@@ -223,23 +241,85 @@ const docTemplate = `{
                         "name": "Cookie",
                         "in": "header",
                         "required": true
+                    },
+                    {
+                        "description": "Approval/rejection payload (admins/platform approvers use AdminApproveRequest, non-admins use UserApproveRequest)",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.AdminApproveRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "Requests processed successfully",
                         "schema": {
-                            "$ref": "#/definitions/models.NormalizedUserData"
+                            "$ref": "#/definitions/models.SimpleMessageResponse"
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized: no token in session data",
+                    "400": {
+                        "description": "Invalid request format",
+                        "schema": {
+                            "$ref": "#/definitions/models.SimpleMessageResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden: missing permission requests:approve",
                         "schema": {
                             "$ref": "#/definitions/models.SimpleMessageResponse"
                         }
                     },
                     "500": {
-                        "description": "Internal server error",
+                        "description": "Failed to process requests",
+                        "schema": {
+                            "$ref": "#/definitions/models.SimpleMessageResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Cluster unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/models.SimpleMessageResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/approving-groups": {
+            "get": {
+                "description": "Returns the list of platform approving groups for the authenticated user.\nRequires one or more cookies named kube_jit_session_\u003cnumber\u003e (e.g., kube_jit_session_0, kube_jit_session_1).\nPass split cookies in the Cookie header, for example:\n-H \"Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}\"\nNote: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "records"
+                ],
+                "summary": "Get platform approving groups",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session cookies (multiple allowed, names: kube_jit_session_0, kube_jit_session_1, etc.)",
+                        "name": "Cookie",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Team"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized: no token in session data",
                         "schema": {
                             "$ref": "#/definitions/models.SimpleMessageResponse"
                         }
@@ -272,7 +352,7 @@ const docTemplate = `{
         },
         "/client_id": {
             "get": {
-                "description": "Returns the OAuth client_id, provider, redirect URI, and auth URL for the frontend to initiate login.",
+                "description": "Returns the OAuth client_id, provider, redirect URI, and auth URL for the frontend to initiate login.\nWhen several providers are enabled, each one is listed in providers; the top level fields describe the first.",
                 "consumes": [
                     "application/json"
                 ],
@@ -293,9 +373,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/github/profile": {
+        "/clusters/status": {
             "get": {
-                "description": "Returns the normalized GitHub user profile for the authenticated user.\nRequires one or more cookies named kube_jit_session_\u003cnumber\u003e (e.g., kube_jit_session_0, kube_jit_session_1).\nPass split cookies in the Cookie header, for example:\n-H \"Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}\"\nNote: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.",
+                "description": "Returns, for every cluster, the outcome of the last background probe: whether its API server is reachable,\nwhen the token of the API's client expires, whether the JitRequest and JitGroupCache CRDs are installed,\nthe operator version and how fresh the JitGroupCache is. Requires the admin permission.\nClusters are probed every CLUSTER_PROBE_INTERVAL, checkedAt is empty until a cluster is first probed.\nRequires one or more cookies named kube_jit_session_\u003cnumber\u003e (e.g., kube_jit_session_0, kube_jit_session_1).\nPass split cookies in the Cookie header, for example:\n-H \"Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}\"\nNote: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.",
                 "consumes": [
                     "application/json"
                 ],
@@ -303,9 +383,9 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "github"
+                    "admin"
                 ],
-                "summary": "Get the logged in user's GitHub profile",
+                "summary": "Status of the clusters",
                 "parameters": [
                     {
                         "type": "string",
@@ -317,19 +397,16 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/models.NormalizedUserData"
-                        }
-                    },
-                    "401": {
-                        "description": "Unauthorized: no token in session data",
+                        "description": "Status of every cluster, in the order of the configuration",
                         "schema": {
-                            "$ref": "#/definitions/models.SimpleMessageResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/k8s.ClusterStatus"
+                            }
                         }
                     },
-                    "500": {
-                        "description": "Internal server error",
+                    "403": {
+                        "description": "Forbidden: missing permission admin",
                         "schema": {
                             "$ref": "#/definitions/models.SimpleMessageResponse"
                         }
@@ -337,9 +414,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/google/profile": {
+        "/healthz": {
             "get": {
-                "description": "Returns the normalized Google user profile for the authenticated user.\nRequires one or more cookies named kube_jit_session_\u003cnumber\u003e (e.g., kube_jit_session_0, kube_jit_session_1).\nPass split cookies in the Cookie header, for example:\n-H \"Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}\"\nNote: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.",
+                "description": "Returns a simple status message to verify the API is running.",
                 "consumes": [
                     "application/json"
                 ],
@@ -347,33 +424,12 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "google"
-                ],
-                "summary": "Get the logged in user's Google profile",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Session cookies (multiple allowed, names: kube_jit_session_0, kube_jit_session_1, etc.)",
-                        "name": "Cookie",
-                        "in": "header",
-                        "required": true
-                    }
+                    "health"
                 ],
+                "summary": "Health check endpoint",
                 "responses": {
                     "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/models.NormalizedUserData"
-                        }
-                    },
-                    "401": {
-                        "description": "Unauthorized: no token in session data",
-                        "schema": {
-                            "$ref": "#/definitions/models.SimpleMessageResponse"
-                        }
-                    },
-                    "500": {
-                        "description": "Internal server error",
+                        "description": "API is healthy",
                         "schema": {
                             "$ref": "#/definitions/models.SimpleMessageResponse"
                         }
@@ -381,9 +437,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/healthz": {
+        "/healthz/config": {
             "get": {
-                "description": "Returns a simple status message to verify the API is running.",
+                "description": "Returns the version and hash of the apiConfig.yaml in use, and the error of the last reload if it failed.\nA failed reload keeps the previous configuration, the error is cleared by the next successful reload.",
                 "consumes": [
                     "application/json"
                 ],
@@ -393,12 +449,12 @@ const docTemplate = `{
                 "tags": [
                     "health"
                 ],
-                "summary": "Health check endpoint",
+                "summary": "Configuration status",
                 "responses": {
                     "200": {
-                        "description": "API is healthy",
+                        "description": "Configuration in use",
                         "schema": {
-                            "$ref": "#/definitions/models.SimpleMessageResponse"
+                            "$ref": "#/definitions/k8s.ConfigStatus"
                         }
                     }
                 }
@@ -406,7 +462,7 @@ const docTemplate = `{
         },
         "/history": {
             "get": {
-                "description": "Returns the latest JIT requests for a user with optional limit and date range.\nRequires one or more cookies named kube_jit_session_\u003cnumber\u003e (e.g., kube_jit_session_0, kube_jit_session_1).\nPass split cookies in the Cookie header, for example:\n-H \"Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}\"\nNote: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies:\nLogin required to test via browser, else test via curl",
+                "description": "Returns the latest JIT requests for a user with optional limit and date range.\nUsers with the history:read permission see the requests of all users, limited to the clusters and roles of their API roles.\nRequires one or more cookies named kube_jit_session_\u003cnumber\u003e (e.g., kube_jit_session_0, kube_jit_session_1).\nPass split cookies in the Cookie header, for example:\n-H \"Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}\"\nNote: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies:\nLogin required to test via browser, else test via curl\nScripts can use an API token with the history scope instead: -H \"Authorization: Bearer ${token}\"\nService principals with the history scope only see the requests they submitted.",
                 "consumes": [
                     "application/json"
                 ],
@@ -517,7 +573,7 @@ const docTemplate = `{
         },
         "/logout": {
             "post": {
-                "description": "Clears all session cookies with the session prefix and logs the user out.",
+                "description": "Clears all session cookies with the session prefix and logs the user out, revoking the server-side session if enabled.",
                 "consumes": [
                     "application/json"
                 ],
@@ -538,9 +594,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/oauth/azure/callback": {
+        "/oauth/oidc/callback": {
             "get": {
-                "description": "Handles the Azure OAuth callback, exchanges the code for an access token, fetches user info, sets session data, and returns normalized user data and expiration time.",
+                "description": "Handles the OIDC callback, validates state, exchanges the code with the PKCE verifier, verifies the ID token signature against the issuer JWKS, maps the groups claim to teams, sets session data, and returns normalized user data and expiration time.",
                 "consumes": [
                     "application/json"
                 ],
@@ -548,16 +604,23 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "azure"
+                    "oidc"
                 ],
-                "summary": "Azure OAuth callback",
+                "summary": "OIDC OAuth callback",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Azure OAuth authorization code",
+                        "description": "OIDC authorization code",
                         "name": "code",
                         "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "OIDC state returned by the identity provider",
+                        "name": "state",
+                        "in": "query",
+                        "required": true
                     }
                 ],
                 "responses": {
@@ -568,13 +631,19 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Missing or invalid code",
+                        "description": "Missing or invalid code or state",
+                        "schema": {
+                            "$ref": "#/definitions/models.SimpleMessageResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid ID token",
                         "schema": {
                             "$ref": "#/definitions/models.SimpleMessageResponse"
                         }
                     },
                     "403": {
-                        "description": "Unauthorized domain",
+                        "description": "Unauthorized user",
                         "schema": {
                             "$ref": "#/definitions/models.SimpleMessageResponse"
                         }
@@ -588,49 +657,93 @@ const docTemplate = `{
                 }
             }
         },
-        "/oauth/github/callback": {
+        "/oauth/oidc/login": {
             "get": {
-                "description": "Handles the GitHub OAuth callback, exchanges the code for an access token, fetches user info, sets session data, and returns normalized user data and expiration time.",
-                "consumes": [
+                "description": "Generates state, nonce and a PKCE verifier, stores them in a short-lived signed cookie and returns the authorization URL to redirect the user to.",
+                "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "oidc"
+                ],
+                "summary": "Start OIDC login",
+                "responses": {
+                    "200": {
+                        "description": "Authorization URL",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.OidcLoginResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.SimpleMessageResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/oauth/token": {
+            "post": {
+                "description": "OAuth 2.0 client credentials grant for the service principals configured in apiConfig.yaml.\nSend the client ID and secret with HTTP basic auth, or as the client_id and client_secret form fields.\nThe token is valid for 15 minutes, send it as \"Authorization: Bearer \u003ctoken\u003e\" on the routes of its scopes.\nScopes: submit (submit requests on behalf of a user, see onBehalfOf), history (read the requests submitted by the principal).",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "github"
+                    "tokens"
                 ],
-                "summary": "GitHub OAuth callback",
+                "summary": "Get an access token for a service principal",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "GitHub OAuth authorization code",
-                        "name": "code",
-                        "in": "query",
+                        "description": "Must be client_credentials",
+                        "name": "grant_type",
+                        "in": "formData",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Client ID, unless sent with basic auth",
+                        "name": "client_id",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Client secret, unless sent with basic auth",
+                        "name": "client_secret",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Space separated scopes, defaults to all scopes of the service principal",
+                        "name": "scope",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Normalized user data and expiration time",
+                        "description": "Access token",
                         "schema": {
-                            "$ref": "#/definitions/models.LoginResponse"
+                            "$ref": "#/definitions/handlers.ServiceTokenResponse"
                         }
                     },
                     "400": {
-                        "description": "Missing or invalid code",
+                        "description": "unsupported_grant_type or invalid_scope",
                         "schema": {
                             "$ref": "#/definitions/models.SimpleMessageResponse"
                         }
                     },
-                    "403": {
-                        "description": "Unauthorized org",
+                    "401": {
+                        "description": "invalid_client",
                         "schema": {
                             "$ref": "#/definitions/models.SimpleMessageResponse"
                         }
                     },
                     "500": {
-                        "description": "Internal server error",
+                        "description": "server_error",
                         "schema": {
                             "$ref": "#/definitions/models.SimpleMessageResponse"
                         }
@@ -638,9 +751,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/oauth/google/callback": {
+        "/oauth/{provider}/callback": {
             "get": {
-                "description": "Handles the Google OAuth callback, exchanges the code for an access token, fetches user info, sets session data, and returns normalized user data and expiration time.",
+                "description": "Handles the OAuth callback of an enabled provider (github, gitlab, google, azure), exchanges the code for an access token, fetches user info, checks the user is allowed, sets session data, and returns normalized user data and expiration time.",
                 "consumes": [
                     "application/json"
                 ],
@@ -648,13 +761,26 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "google"
+                    "auth"
                 ],
-                "summary": "Google OAuth callback",
+                "summary": "OAuth callback",
                 "parameters": [
+                    {
+                        "enum": [
+                            "github",
+                            "gitlab",
+                            "google",
+                            "azure"
+                        ],
+                        "type": "string",
+                        "description": "Identity provider",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
                     {
                         "type": "string",
-                        "description": "Google OAuth authorization code",
+                        "description": "OAuth authorization code",
                         "name": "code",
                         "in": "query",
                         "required": true
@@ -674,7 +800,7 @@ const docTemplate = `{
                         }
                     },
                     "403": {
-                        "description": "Unauthorized domain",
+                        "description": "Unauthorized domain, org or group",
                         "schema": {
                             "$ref": "#/definitions/models.SimpleMessageResponse"
                         }
@@ -690,7 +816,7 @@ const docTemplate = `{
         },
         "/permissions": {
             "post": {
-                "description": "Returns the user's permissions and group memberships for the specified provider (GitHub, Google, Azure).\nRequires one or more cookies named kube_jit_session_\u003cnumber\u003e (e.g., kube_jit_session_0, kube_jit_session_1).\nPass split cookies in the Cookie header, for example:\n-H \"Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}\"\nNote: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.",
+                "description": "Returns the user's permissions and group memberships for the provider the user logged in with (GitHub, Google, Azure, OIDC).\nThe permissions are cached in the session and evaluated again once older than PERMISSIONS_TTL or when the configuration changes.\nRequires one or more cookies named kube_jit_session_\u003cnumber\u003e (e.g., kube_jit_session_0, kube_jit_session_1).\nPass split cookies in the Cookie header, for example:\n-H \"Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}\"\nNote: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.\nScripts can use an API token with any scope instead: -H \"Authorization: Bearer ${token}\"",
                 "consumes": [
                     "application/json"
                 ],
@@ -749,7 +875,7 @@ const docTemplate = `{
         },
         "/roles-and-clusters": {
             "get": {
-                "description": "Returns the list of clusters and roles available to the user.\nRequires one or more cookies named kube_jit_session_\u003cnumber\u003e (e.g., kube_jit_session_0, kube_jit_session_1).\nPass split cookies in the Cookie header, for example:\n-H \"Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}\"\nNote: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.",
+                "description": "Returns the list of clusters and roles available to the user.\nclusterHealth reports the clusters whose client could not be created, requests for them fail until their retry succeeds.\nRequires one or more cookies named kube_jit_session_\u003cnumber\u003e (e.g., kube_jit_session_0, kube_jit_session_1).\nPass split cookies in the Cookie header, for example:\n-H \"Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}\"\nNote: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.\nScripts can use an API token with the submit scope instead: -H \"Authorization: Bearer ${token}\"",
                 "consumes": [
                     "application/json"
                 ],
@@ -787,7 +913,7 @@ const docTemplate = `{
         },
         "/submit-request": {
             "post": {
-                "description": "Creates a new JIT access request for the authenticated user.\nRequires one or more cookies named kube_jit_session_\u003cnumber\u003e (e.g., kube_jit_session_0, kube_jit_session_1).\nPass split cookies in the Cookie header, for example:\n-H \"Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}\"\nNote: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.",
+                "description": "Creates a new JIT access request for the authenticated user.\nRequires one or more cookies named kube_jit_session_\u003cnumber\u003e (e.g., kube_jit_session_0, kube_jit_session_1).\nPass split cookies in the Cookie header, for example:\n-H \"Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}\"\nNote: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.\nScripts can use an API token with the submit scope instead: -H \"Authorization: Bearer ${token}\"\nService principals submit on behalf of a user with onBehalfOf, the user is recorded as the requestor and the principal as submittedBy.\nWith clusterSelector instead of cluster, a request is submitted for every cluster whose labels match it, linked by their bundleID.\nThe namespaces must exist on every matching cluster, and approvals of the namespaces of a JitGroup are shared by the requests of a bundle.",
                 "consumes": [
                     "application/json"
                 ],
@@ -824,7 +950,7 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Invalid request data",
+                        "description": "Invalid request data, onBehalfOf or clusterSelector",
                         "schema": {
                             "$ref": "#/definitions/models.SimpleMessageResponse"
                         }
@@ -840,29 +966,256 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/models.SimpleMessageResponse"
                         }
+                    },
+                    "503": {
+                        "description": "Cluster unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/models.SimpleMessageResponse"
+                        }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "handlers.AdminApproveRequest": {
-            "type": "object",
-            "properties": {
-                "approverID": {
-                    "type": "string"
-                },
-                "approverName": {
-                    "type": "string"
-                },
-                "requests": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/models.RequestData"
-                    }
-                },
-                "status": {
-                    "type": "string"
+        },
+        "/tokens": {
+            "get": {
+                "description": "Returns the active API tokens of the logged in user. Users with the admin permission can list the tokens of another user with userID.\nRequires one or more cookies named kube_jit_session_\u003cnumber\u003e (e.g., kube_jit_session_0, kube_jit_session_1).\nPass split cookies in the Cookie header, for example:\n-H \"Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}\"\nNote: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tokens"
+                ],
+                "summary": "List personal API tokens",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session cookies (multiple allowed, names: kube_jit_session_0, kube_jit_session_1, etc.)",
+                        "name": "Cookie",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "List the tokens of this user, admin only",
+                        "name": "userID",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Active tokens, newest first",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.APIToken"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden: missing permission admin",
+                        "schema": {
+                            "$ref": "#/definitions/models.SimpleMessageResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to list tokens",
+                        "schema": {
+                            "$ref": "#/definitions/models.SimpleMessageResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Creates a scoped, expiring API token for the logged in user, to be sent as \"Authorization: Bearer \u003ctoken\u003e\" by scripts and CI.\nScopes: submit (submit requests), approve (list, approve and reject requests), history (read the request history).\nThe token acts with the user's groups, matched against the current configuration on every request. The groups are checked again every PERMISSIONS_TTL from the groups cached by the user's sessions. While the user does not use the web app, the token keeps the user's own permissions, and the approve routes fail with 403 until the user signs in again to refresh the groups.\nThe token is only returned once. Tokens cannot be used to create, list or revoke tokens.\nRequires one or more cookies named kube_jit_session_\u003cnumber\u003e (e.g., kube_jit_session_0, kube_jit_session_1).\nPass split cookies in the Cookie header, for example:\n-H \"Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}\"\nNote: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tokens"
+                ],
+                "summary": "Create a personal API token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session cookies (multiple allowed, names: kube_jit_session_0, kube_jit_session_1, etc.)",
+                        "name": "Cookie",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Token name, scopes and lifetime in days (default 30, max 90)",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CreateTokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Created token",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CreateTokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/models.SimpleMessageResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to create token",
+                        "schema": {
+                            "$ref": "#/definitions/models.SimpleMessageResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/tokens/revoke": {
+            "post": {
+                "description": "Revokes one of the logged in user's API tokens. Users with the admin permission can revoke any token.\nRequires one or more cookies named kube_jit_session_\u003cnumber\u003e (e.g., kube_jit_session_0, kube_jit_session_1).\nPass split cookies in the Cookie header, for example:\n-H \"Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}\"\nNote: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tokens"
+                ],
+                "summary": "Revoke a personal API token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session cookies (multiple allowed, names: kube_jit_session_0, kube_jit_session_1, etc.)",
+                        "name": "Cookie",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "ID of the token to revoke",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RevokeTokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Token revoked",
+                        "schema": {
+                            "$ref": "#/definitions/models.SimpleMessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/models.SimpleMessageResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Token not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.SimpleMessageResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to revoke token",
+                        "schema": {
+                            "$ref": "#/definitions/models.SimpleMessageResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/{provider}/profile": {
+            "get": {
+                "description": "Returns the normalized user profile from an enabled provider (github, gitlab, google, azure, oidc) for the authenticated user.\nRequires one or more cookies named kube_jit_session_\u003cnumber\u003e (e.g., kube_jit_session_0, kube_jit_session_1).\nPass split cookies in the Cookie header, for example:\n-H \"Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}\"\nNote: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Get the logged in user's profile",
+                "parameters": [
+                    {
+                        "enum": [
+                            "github",
+                            "gitlab",
+                            "google",
+                            "azure",
+                            "oidc"
+                        ],
+                        "type": "string",
+                        "description": "Identity provider",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Session cookies (multiple allowed, names: kube_jit_session_0, kube_jit_session_1, etc.)",
+                        "name": "Cookie",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.NormalizedUserData"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized: no token in session data",
+                        "schema": {
+                            "$ref": "#/definitions/models.SimpleMessageResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.SimpleMessageResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "handlers.AdminApproveRequest": {
+            "type": "object",
+            "properties": {
+                "approverID": {
+                    "type": "string"
+                },
+                "approverName": {
+                    "type": "string"
+                },
+                "requests": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.RequestData"
+                    }
+                },
+                "status": {
+                    "type": "string"
                 }
             }
         },
@@ -888,6 +1241,23 @@ const docTemplate = `{
         "handlers.ClustersAndRolesResponse": {
             "type": "object",
             "properties": {
+                "clusterHealth": {
+                    "description": "in the order of Clusters",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/k8s.ClusterHealth"
+                    }
+                },
+                "clusterLabels": {
+                    "description": "labels of the clusters having some, matched by clusterSelector",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "object",
+                        "additionalProperties": {
+                            "type": "string"
+                        }
+                    }
+                },
                 "clusters": {
                     "type": "array",
                     "items": {
@@ -914,12 +1284,25 @@ const docTemplate = `{
         "handlers.CommonPermissionsResponse": {
             "type": "object",
             "properties": {
+                "adminClusters": {
+                    "description": "clusters whose admin teams the user is in",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
                 "adminGroups": {
                     "type": "array",
                     "items": {
                         "$ref": "#/definitions/models.Team"
                     }
                 },
+                "apiRoles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
                 "approverGroups": {
                     "type": "array",
                     "items": {
@@ -935,6 +1318,13 @@ const docTemplate = `{
                 "isPlatformApprover": {
                     "type": "boolean"
                 },
+                "platformApproverClusters": {
+                    "description": "clusters whose platform approver teams the user is in",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
                 "platformApproverGroups": {
                     "type": "array",
                     "items": {
@@ -943,7 +1333,68 @@ const docTemplate = `{
                 }
             }
         },
+        "handlers.CreateTokenRequest": {
+            "type": "object",
+            "required": [
+                "name",
+                "scopes"
+            ],
+            "properties": {
+                "expiresInDays": {
+                    "type": "integer",
+                    "example": 30
+                },
+                "name": {
+                    "type": "string",
+                    "example": "ci-pipeline"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "submit",
+                        "history"
+                    ]
+                }
+            }
+        },
+        "handlers.CreateTokenResponse": {
+            "type": "object",
+            "properties": {
+                "apiToken": {
+                    "$ref": "#/definitions/models.APIToken"
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
         "handlers.OauthClientIdResponse": {
+            "type": "object",
+            "properties": {
+                "auth_url": {
+                    "type": "string"
+                },
+                "client_id": {
+                    "type": "string"
+                },
+                "provider": {
+                    "type": "string"
+                },
+                "providers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.OauthProviderConfig"
+                    }
+                },
+                "redirect_uri": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.OauthProviderConfig": {
             "type": "object",
             "properties": {
                 "auth_url": {
@@ -960,6 +1411,31 @@ const docTemplate = `{
                 }
             }
         },
+        "handlers.OidcLoginResponse": {
+            "type": "object",
+            "properties": {
+                "auth_url": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.OnBehalfOf": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "jane@example.com"
+                },
+                "userID": {
+                    "type": "string",
+                    "example": "12345"
+                },
+                "username": {
+                    "type": "string",
+                    "example": "Jane Doe"
+                }
+            }
+        },
         "handlers.PendingApprovalsResponse": {
             "type": "object",
             "properties": {
@@ -986,6 +1462,10 @@ const docTemplate = `{
                         "type": "boolean"
                     }
                 },
+                "bundleID": {
+                    "description": "shared by the requests submitted with a cluster selector",
+                    "type": "string"
+                },
                 "clusterName": {
                     "type": "string"
                 },
@@ -1036,12 +1516,70 @@ const docTemplate = `{
                 }
             }
         },
+        "handlers.RevokeSessionsRequest": {
+            "type": "object",
+            "properties": {
+                "sessionID": {
+                    "type": "string"
+                },
+                "userID": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RevokeSessionsResponse": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                },
+                "revoked": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.RevokeTokenRequest": {
+            "type": "object",
+            "required": [
+                "id"
+            ],
+            "properties": {
+                "id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.ServiceTokenResponse": {
+            "type": "object",
+            "properties": {
+                "access_token": {
+                    "type": "string"
+                },
+                "expires_in": {
+                    "type": "integer",
+                    "example": 900
+                },
+                "scope": {
+                    "type": "string",
+                    "example": "submit history"
+                },
+                "token_type": {
+                    "type": "string",
+                    "example": "Bearer"
+                }
+            }
+        },
         "handlers.SubmitRequestPayload": {
             "type": "object",
             "properties": {
                 "cluster": {
                     "$ref": "#/definitions/models.Cluster"
                 },
+                "clusterSelector": {
+                    "description": "ClusterSelector replaces cluster with a label selector, submitting a request for each matching cluster",
+                    "type": "string",
+                    "example": "env=prod"
+                },
                 "endDate": {
                     "type": "string"
                 },
@@ -1054,6 +1592,14 @@ const docTemplate = `{
                         "type": "string"
                     }
                 },
+                "onBehalfOf": {
+                    "description": "required for service principals, not allowed for users",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/handlers.OnBehalfOf"
+                        }
+                    ]
+                },
                 "requestorId": {
                     "type": "string"
                 },
@@ -1074,6 +1620,161 @@ const docTemplate = `{
                 }
             }
         },
+        "k8s.ClusterHealth": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "failed to get GKE cluster details: permission denied"
+                },
+                "healthy": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "name": {
+                    "type": "string",
+                    "example": "prod"
+                },
+                "retryAt": {
+                    "description": "when the client is created again, for unhealthy clusters",
+                    "type": "string"
+                }
+            }
+        },
+        "k8s.ClusterStatus": {
+            "type": "object",
+            "properties": {
+                "checkedAt": {
+                    "description": "empty until the cluster is first probed",
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string",
+                    "example": "the server has asked for the client to provide credentials"
+                },
+                "jitGroupCache": {
+                    "$ref": "#/definitions/k8s.JitGroupCacheFreshness"
+                },
+                "jitGroupCacheCRD": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "jitRequestCRD": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "name": {
+                    "type": "string",
+                    "example": "prod"
+                },
+                "operatorVersion": {
+                    "description": "from the app.kubernetes.io/version label of the CRDs",
+                    "type": "string",
+                    "example": "1.4.0"
+                },
+                "reachable": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "serverVersion": {
+                    "type": "string",
+                    "example": "v1.30.1"
+                },
+                "tokenExpiresAt": {
+                    "description": "of the cached client, it is refreshed when it expires",
+                    "type": "string"
+                }
+            }
+        },
+        "k8s.ConfigStatus": {
+            "type": "object",
+            "properties": {
+                "clusters": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "hash": {
+                    "type": "string",
+                    "example": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+                },
+                "lastError": {
+                    "type": "string",
+                    "example": "duplicate cluster name prod"
+                },
+                "lastErrorAt": {
+                    "type": "string"
+                },
+                "loadedAt": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "k8s.JitGroupCacheFreshness": {
+            "type": "object",
+            "properties": {
+                "groups": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "inSync": {
+                    "description": "false while the API still serves groups it cached before the last write",
+                    "type": "boolean",
+                    "example": true
+                },
+                "updatedAt": {
+                    "description": "last write to the object",
+                    "type": "string"
+                }
+            }
+        },
+        "models.APIToken": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "lastUsedAt": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "prefix": {
+                    "type": "string"
+                },
+                "provider": {
+                    "type": "string"
+                },
+                "revokedAt": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "userID": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
         "models.Cluster": {
             "type": "object",
             "properties": {
@@ -1163,6 +1864,10 @@ const docTemplate = `{
                         "type": "string"
                     }
                 },
+                "bundleID": {
+                    "description": "BundleID is shared by the requests submitted together for the clusters matching a selector, empty otherwise",
+                    "type": "string"
+                },
                 "clusterName": {
                     "type": "string"
                 },
@@ -1196,6 +1901,10 @@ const docTemplate = `{
                 "status": {
                     "type": "string"
                 },
+                "submittedBy": {
+                    "description": "service principal that submitted the request on behalf of the user",
+                    "type": "string"
+                },
                 "userID": {
                     "type": "string"
                 },
@@ -1237,6 +1946,10 @@ const docTemplate = `{
                         "type": "string"
                     }
                 },
+                "bundleID": {
+                    "description": "BundleID is shared by the requests submitted together for the clusters matching a selector, empty otherwise",
+                    "type": "string"
+                },
                 "clusterName": {
                     "type": "string"
                 },
@@ -1276,6 +1989,10 @@ const docTemplate = `{
                 "status": {
                     "type": "string"
                 },
+                "submittedBy": {
+                    "description": "service principal that submitted the request on behalf of the user",
+                    "type": "string"
+                },
                 "userID": {
                     "type": "string"
                 },
@@ -1298,6 +2015,44 @@ const docTemplate = `{
                 }
             }
         },
+        "models.Session": {
+            "type": "object",
+            "properties": {
+                "clientIP": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "lastSeenAt": {
+                    "type": "string"
+                },
+                "provider": {
+                    "type": "string"
+                },
+                "revokedAt": {
+                    "type": "string"
+                },
+                "userAgent": {
+                    "type": "string"
+                },
+                "userID": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
         "models.SimpleMessageResponse": {
             "type": "object",
             "properties": {
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"kube-jit/internal/models"
+	"kube-jit/internal/tracing"
 	"kube-jit/pkg/utils"
 
 	"go.uber.org/zap"
@@ -46,6 +47,11 @@ func InitDB() {
 		logger.Fatal("Failed to open database connection", zap.Error(err))
 	}
 
+	// Trace queries as children of the request context passed with DB.WithContext
+	if err := DB.Use(tracing.GormPlugin{}); err != nil {
+		logger.Fatal("Failed to register GORM tracing plugin", zap.Error(err))
+	}
+
 	// Enable GORM debug mode if DB_DEBUG=true
 	if os.Getenv("DB_DEBUG") == "true" {
 		DB = DB.Debug()
@@ -70,10 +76,12 @@ func InitDB() {
 	sqlDB.SetConnMaxIdleTime(connMaxIdleTime)
 
 	logger.Info("Migrating database schema...")
-	err = DB.AutoMigrate(&models.RequestData{}, &models.RequestNamespace{})
+	err = DB.AutoMigrate(&models.RequestData{}, &models.RequestNamespace{}, &models.APIToken{})
 	if err != nil {
 		logger.Fatal("Error migrating database", zap.Error(err))
 	}
 
 	logger.Info("Database schema migrated successfully")
+
+	registerMetrics(DB, sqlDB, dbname)
 }
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"database/sql"
+	"kube-jit/internal/metrics"
+	"kube-jit/internal/models"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	pendingRequestsDesc = prometheus.NewDesc(
+		"kube_jit_api_pending_requests",
+		"Number of JIT requests waiting for approval.",
+		nil, nil,
+	)
+	pendingRequestOldestAgeDesc = prometheus.NewDesc(
+		"kube_jit_api_pending_request_oldest_age_seconds",
+		"Age of the oldest JIT request waiting for approval.",
+		nil, nil,
+	)
+)
+
+// pendingQueueCollector reports the approval queue depth and age, queried on every scrape
+type pendingQueueCollector struct {
+	db *gorm.DB
+}
+
+// Describe implements prometheus.Collector
+func (c *pendingQueueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- pendingRequestsDesc
+	ch <- pendingRequestOldestAgeDesc
+}
+
+// Collect implements prometheus.Collector, the metrics are reported invalid if the query fails so the scrape shows the error
+func (c *pendingQueueCollector) Collect(ch chan<- prometheus.Metric) {
+	var result struct {
+		Count  int64
+		Oldest *time.Time
+	}
+	err := c.db.Model(&models.RequestData{}).
+		Select("COUNT(*) AS count, MIN(created_at) AS oldest").
+		Where("status = ?", "Requested").
+		Scan(&result).Error
+	if err != nil {
+		logger.Warn("Failed to query pending requests for metrics", zap.Error(err))
+		ch <- prometheus.NewInvalidMetric(pendingRequestsDesc, err)
+		ch <- prometheus.NewInvalidMetric(pendingRequestOldestAgeDesc, err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(pendingRequestsDesc, prometheus.GaugeValue, float64(result.Count))
+	oldestAge := 0.0
+	if result.Oldest != nil {
+		oldestAge = time.Since(*result.Oldest).Seconds()
+	}
+	ch <- prometheus.MustNewConstMetric(pendingRequestOldestAgeDesc, prometheus.GaugeValue, oldestAge)
+}
+
+// registerMetrics registers the connection pool stats and pending approval queue collectors
+func registerMetrics(gormDB *gorm.DB, sqlDB *sql.DB, dbName string) {
+	if err := metrics.Registry.Register(collectors.NewDBStatsCollector(sqlDB, dbName)); err != nil {
+		logger.Warn("Failed to register database pool metrics", zap.Error(err))
+	}
+	if err := metrics.Registry.Register(&pendingQueueCollector{db: gormDB}); err != nil {
+		logger.Warn("Failed to register pending queue metrics", zap.Error(err))
+	}
+}
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestPendingQueueCollector(t *testing.T) {
+	l, _ := zap.NewDevelopment()
+	InitLogger(l)
+
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	require.NoError(t, err)
+
+	oldest := time.Now().Add(-2 * time.Hour)
+	mock.ExpectQuery(`SELECT COUNT\(\*\) AS count, MIN\(created_at\) AS oldest FROM "request_data"`).
+		WithArgs("Requested").
+		WillReturnRows(sqlmock.NewRows([]string{"count", "oldest"}).AddRow(3, oldest))
+
+	collector := &pendingQueueCollector{db: gormDB}
+	expected := `
+# HELP kube_jit_api_pending_requests Number of JIT requests waiting for approval.
+# TYPE kube_jit_api_pending_requests gauge
+kube_jit_api_pending_requests 3
+`
+	err = testutil.CollectAndCompare(collector, strings.NewReader(expected), "kube_jit_api_pending_requests")
+	assert.NoError(t, err)
+}
+
+func TestPendingQueueCollector_QueryError(t *testing.T) {
+	l, _ := zap.NewDevelopment()
+	InitLogger(l)
+
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	require.NoError(t, err)
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) AS count, MIN\(created_at\) AS oldest FROM "request_data"`).
+		WillReturnError(errors.New("connection reset"))
+
+	// The failure is reported to the scrape rather than the gauges being dropped
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(&pendingQueueCollector{db: gormDB})
+	_, err = registry.Gather()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connection reset")
+}
@@ -0,0 +1,133 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+
+	"kube-jit/internal/models"
+	"kube-jit/pkg/sessioncookie"
+	"kube-jit/pkg/utils"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SessionStore keeps sessions in the sessions table, it implements sessioncookie.Store
+// The OAuth access token of the session data is stored encrypted, see utils.TokenCipher
+type SessionStore struct{}
+
+// encryptedTokenKey is the key of the encrypted access token in the stored session data
+const encryptedTokenKey = "encryptedToken"
+
+// EnableSessionStore migrates the sessions table and keeps sessions in it instead of in the cookies
+func EnableSessionStore() {
+	if err := DB.AutoMigrate(&models.Session{}); err != nil {
+		logger.Fatal("Error migrating sessions table", zap.Error(err))
+	}
+	sessioncookie.UseStore(SessionStore{})
+	logger.Info("Server-side sessions enabled")
+}
+
+// HashSessionID returns the sessions table ID of a session ID
+func HashSessionID(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
+
+// Load returns the data of an active session, sessioncookie.ErrNoSession if it is unknown, expired or revoked
+func (SessionStore) Load(ctx context.Context, id string) (map[string]interface{}, time.Time, error) {
+	var session models.Session
+	err := DB.WithContext(ctx).
+		Where("id = ? AND revoked_at IS NULL AND expires_at > ?", HashSessionID(id), time.Now()).
+		First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, time.Time{}, sessioncookie.ErrNoSession
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if err := decryptToken(session.Data); err != nil {
+		return nil, time.Time{}, err
+	}
+	return session.Data, session.ExpiresAt, nil
+}
+
+// Save creates or updates a session, a revoked session stays revoked
+// The user's sessions which have expired are deleted at the same time
+func (SessionStore) Save(ctx context.Context, id string, data map[string]interface{}, expiresAt time.Time, meta sessioncookie.Metadata) error {
+	stored, err := encryptToken(data)
+	if err != nil {
+		return err
+	}
+	session := models.Session{
+		ID:        HashSessionID(id),
+		UserID:    stringValue(data, "id"),
+		Username:  stringValue(data, "name"),
+		Email:     stringValue(data, "email"),
+		Provider:  stringValue(data, "provider"),
+		UserAgent: meta.UserAgent,
+		ClientIP:  meta.ClientIP,
+		Data:      stored,
+		ExpiresAt: expiresAt,
+	}
+	return DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"data", "expires_at", "updated_at", "user_agent", "client_ip"}),
+		}).Create(&session).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ? AND expires_at < ?", session.UserID, time.Now()).Delete(&models.Session{}).Error
+	})
+}
+
+// Revoke ends a session
+func (SessionStore) Revoke(ctx context.Context, id string) error {
+	return DB.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", HashSessionID(id)).
+		Update("revoked_at", time.Now()).Error
+}
+
+// encryptToken returns a copy of the session data with the access token encrypted
+func encryptToken(data map[string]interface{}) (map[string]interface{}, error) {
+	token, ok := data["token"].(string)
+	if !ok {
+		return data, nil
+	}
+	encrypted, err := utils.TokenCipher().Encode(encryptedTokenKey, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt session token: %w", err)
+	}
+	stored := maps.Clone(data)
+	delete(stored, "token")
+	stored[encryptedTokenKey] = encrypted
+	return stored, nil
+}
+
+// decryptToken restores the access token of session data stored by encryptToken
+func decryptToken(data map[string]interface{}) error {
+	encrypted, ok := data[encryptedTokenKey].(string)
+	if !ok {
+		return nil
+	}
+	var token string
+	if err := utils.TokenCipher().Decode(encryptedTokenKey, encrypted, &token); err != nil {
+		return fmt.Errorf("failed to decrypt session token: %w", err)
+	}
+	delete(data, encryptedTokenKey)
+	data["token"] = token
+	return nil
+}
+
+// stringValue returns the string value of key in data, empty if it is not a string
+func stringValue(data map[string]interface{}, key string) string {
+	value, _ := data[key].(string)
+	return value
+}
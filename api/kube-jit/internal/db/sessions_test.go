@@ -0,0 +1,153 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"kube-jit/pkg/sessioncookie"
+	"kube-jit/pkg/utils"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// useMockDB points DB at a sqlmock database for the duration of the test
+func useMockDB(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	InitLogger(zap.NewNop())
+
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	require.NoError(t, err)
+
+	original := DB
+	DB = gormDB
+	t.Cleanup(func() {
+		DB = original
+		assert.NoError(t, mock.ExpectationsWereMet())
+		sqlDB.Close()
+	})
+	return mock
+}
+
+func TestHashSessionID(t *testing.T) {
+	assert.Len(t, HashSessionID("session"), 64)
+	assert.Equal(t, HashSessionID("session"), HashSessionID("session"))
+	assert.NotEqual(t, HashSessionID("session"), HashSessionID("other"))
+	assert.NotContains(t, HashSessionID("session"), "session")
+}
+
+func TestSessionStore_Load(t *testing.T) {
+	query := regexp.QuoteMeta(`SELECT * FROM "sessions" WHERE id = $1 AND revoked_at IS NULL AND expires_at > $2 ORDER BY "sessions"."id" LIMIT $3`)
+
+	t.Run("active session", func(t *testing.T) {
+		mock := useMockDB(t)
+		expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+		mock.ExpectQuery(query).
+			WithArgs(HashSessionID("sid"), sqlmock.AnyArg(), 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "data", "expires_at"}).
+				AddRow(HashSessionID("sid"), `{"id":"42","isAdmin":true}`, expiresAt))
+
+		data, exp, err := SessionStore{}.Load(context.Background(), "sid")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"id": "42", "isAdmin": true}, data)
+		assert.True(t, expiresAt.Equal(exp))
+	})
+
+	t.Run("encrypted access token", func(t *testing.T) {
+		mock := useMockDB(t)
+		encrypted, err := utils.TokenCipher().Encode(encryptedTokenKey, "access-token")
+		require.NoError(t, err)
+		mock.ExpectQuery(query).
+			WithArgs(HashSessionID("sid"), sqlmock.AnyArg(), 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "data", "expires_at"}).
+				AddRow(HashSessionID("sid"), `{"id":"42","encryptedToken":"`+encrypted+`"}`, time.Now().Add(time.Hour)))
+
+		data, _, err := SessionStore{}.Load(context.Background(), "sid")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"id": "42", "token": "access-token"}, data)
+	})
+
+	t.Run("access token encrypted with another key", func(t *testing.T) {
+		mock := useMockDB(t)
+		mock.ExpectQuery(query).
+			WithArgs(HashSessionID("sid"), sqlmock.AnyArg(), 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "data", "expires_at"}).
+				AddRow(HashSessionID("sid"), `{"id":"42","encryptedToken":"tampered"}`, time.Now().Add(time.Hour)))
+
+		_, _, err := SessionStore{}.Load(context.Background(), "sid")
+		assert.ErrorContains(t, err, "failed to decrypt session token")
+	})
+
+	t.Run("unknown, expired or revoked session", func(t *testing.T) {
+		mock := useMockDB(t)
+		mock.ExpectQuery(query).
+			WithArgs(HashSessionID("sid"), sqlmock.AnyArg(), 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		_, _, err := SessionStore{}.Load(context.Background(), "sid")
+		assert.ErrorIs(t, err, sessioncookie.ErrNoSession)
+	})
+}
+
+func TestSessionStore_Save(t *testing.T) {
+	mock := useMockDB(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "sessions"`)+`.*`+regexp.QuoteMeta(`ON CONFLICT ("id") DO UPDATE SET "data"="excluded"."data","expires_at"="excluded"."expires_at","updated_at"="excluded"."updated_at","user_agent"="excluded"."user_agent","client_ip"="excluded"."client_ip"`)).
+		WithArgs(HashSessionID("sid"), "42", "Jane", "jane@example.com", "github", "curl/8.0", "10.0.0.1",
+			storedData{}, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "sessions" WHERE user_id = $1 AND expires_at < $2`)).
+		WithArgs("42", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	data := map[string]interface{}{"id": "42", "name": "Jane", "email": "jane@example.com", "provider": "github", "token": "access-token"}
+	meta := sessioncookie.Metadata{UserAgent: "curl/8.0", ClientIP: "10.0.0.1"}
+	err := SessionStore{}.Save(context.Background(), "sid", data, time.Now().Add(time.Hour), meta)
+	assert.NoError(t, err)
+	assert.Equal(t, "access-token", data["token"], "the session data of the request is left as is")
+}
+
+// storedData matches the session data column, checking the access token is not stored in clear text
+type storedData struct{}
+
+func (storedData) Match(value driver.Value) bool {
+	column, ok := value.(string)
+	if !ok {
+		return false
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(column), &data); err != nil {
+		return false
+	}
+	_, hasToken := data["token"]
+	encrypted, _ := data[encryptedTokenKey].(string)
+	return !hasToken && encrypted != "" && !strings.Contains(column, "access-token")
+}
+
+func TestSessionStore_Revoke(t *testing.T) {
+	mock := useMockDB(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sessions" SET "revoked_at"=$1,"updated_at"=$2 WHERE id = $3 AND revoked_at IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), HashSessionID("sid")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, SessionStore{}.Revoke(context.Background(), "sid"))
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"kube-jit/internal/db"
 	"kube-jit/internal/models"
+	"kube-jit/pkg/k8s"
 	"net/http"
 	"time"
 
@@ -18,7 +19,7 @@ type CleanExpiredResponse struct {
 
 // CleanExpiredRequests godoc
 // @Summary Clean up expired non-approved JIT requests
-// @Description Deletes JIT requests where endDate is in the past and status is "Requested" (not Approved or Rejected). Admin only.
+// @Description Deletes JIT requests where endDate is in the past and status is "Requested" (not Approved or Rejected). Requires the admin permission.
 // @Description Requires one or more cookies named kube_jit_session_<number> (e.g., kube_jit_session_0, kube_jit_session_1).
 // @Description Pass split cookies in the Cookie header, for example:
 // @Description     -H "Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}"
@@ -28,23 +29,15 @@ type CleanExpiredResponse struct {
 // @Produce  json
 // @Param   Cookie header string true "Session cookies (multiple allowed, names: kube_jit_session_0, kube_jit_session_1, etc.)"
 // @Success 200 {object} handlers.CleanExpiredResponse "Expired non-approved requests cleaned"
-// @Failure 401 {object} models.SimpleMessageResponse "Unauthorized: admin only"
+// @Failure 403 {object} models.SimpleMessageResponse "Forbidden: missing permission admin"
 // @Failure 500 {object} models.SimpleMessageResponse "Failed to clean expired requests"
 // @Router /admin/clean-expired [post]
 func CleanExpiredRequests(c *gin.Context) {
-	// Check if the user is logged in and get logger
-	sessionData := GetSessionData(c)
+	// The admin permission is checked by RequirePermission on the admin routes
 	reqLogger := RequestLogger(c)
 
-	isAdmin, _ := sessionData["isAdmin"].(bool)
-	if !isAdmin {
-		reqLogger.Warn("Unauthorized access attempt to CleanExpiredRequests")
-		c.JSON(http.StatusUnauthorized, models.SimpleMessageResponse{Error: "Unauthorized: admin only"})
-		return
-	}
-
 	now := time.Now()
-	result := db.DB.
+	result := db.DB.WithContext(c.Request.Context()).
 		Where("end_date < ? AND status = ?", now, "Requested").
 		Delete(&models.RequestData{})
 
@@ -62,3 +55,25 @@ func CleanExpiredRequests(c *gin.Context) {
 		Deleted: result.RowsAffected,
 	})
 }
+
+// GetClustersStatus godoc
+// @Summary Status of the clusters
+// @Description Returns, for every cluster, the outcome of the last background probe: whether its API server is reachable,
+// @Description when the token of the API's client expires, whether the JitRequest and JitGroupCache CRDs are installed,
+// @Description the operator version and how fresh the JitGroupCache is. Requires the admin permission.
+// @Description Clusters are probed every CLUSTER_PROBE_INTERVAL, checkedAt is empty until a cluster is first probed.
+// @Description Requires one or more cookies named kube_jit_session_<number> (e.g., kube_jit_session_0, kube_jit_session_1).
+// @Description Pass split cookies in the Cookie header, for example:
+// @Description     -H "Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}"
+// @Description Note: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.
+// @Tags admin
+// @Accept  json
+// @Produce  json
+// @Param   Cookie header string true "Session cookies (multiple allowed, names: kube_jit_session_0, kube_jit_session_1, etc.)"
+// @Success 200 {array} k8s.ClusterStatus "Status of every cluster, in the order of the configuration"
+// @Failure 403 {object} models.SimpleMessageResponse "Forbidden: missing permission admin"
+// @Router /clusters/status [get]
+func GetClustersStatus(c *gin.Context) {
+	// The admin permission is checked by RequirePermission on the route
+	c.JSON(http.StatusOK, k8s.ClusterStatuses())
+}
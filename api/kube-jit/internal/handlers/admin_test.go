@@ -11,6 +11,7 @@ import (
 
 	"kube-jit/internal/db"
 	"kube-jit/internal/models"
+	"kube-jit/pkg/k8s"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gin-contrib/sessions"
@@ -86,7 +87,7 @@ func teardownDBMock(t *testing.T, mock sqlmock.Sqlmock) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestCleanExpiredRequests_Unauthorized_NotAdmin(t *testing.T) {
+func TestCleanExpiredRequests_Forbidden_NotAdmin(t *testing.T) {
 	router, mock := setupRouterAndDBMock(t)
 	defer teardownDBMock(t, mock)
 
@@ -106,22 +107,21 @@ func TestCleanExpiredRequests_Unauthorized_NotAdmin(t *testing.T) {
 		}
 		c.Set("sessionData", sessionContextData)
 
-		CleanExpiredRequests(c)
-	})
+	}, RequirePermission(models.PermissionAdmin), CleanExpiredRequests)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("POST", "/admin/clean-expired", nil)
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, http.StatusForbidden, w.Code)
 	var resp models.SimpleMessageResponse
 	err := json.Unmarshal(w.Body.Bytes(), &resp)
 	assert.NoError(t, err)
-	assert.Equal(t, "Unauthorized: admin only", resp.Error)
+	assert.Equal(t, "Forbidden: missing permission admin", resp.Error)
 	assert.NoError(t, mock.ExpectationsWereMet()) // Verify all sqlmock expectations
 }
 
-func TestCleanExpiredRequests_Unauthorized_IsAdminMissing(t *testing.T) {
+func TestCleanExpiredRequests_Forbidden_IsAdminMissing(t *testing.T) {
 	router, mock := setupRouterAndDBMock(t)
 	defer teardownDBMock(t, mock)
 
@@ -140,22 +140,21 @@ func TestCleanExpiredRequests_Unauthorized_IsAdminMissing(t *testing.T) {
 		}
 		c.Set("sessionData", sessionContextData)
 
-		CleanExpiredRequests(c)
-	})
+	}, RequirePermission(models.PermissionAdmin), CleanExpiredRequests)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("POST", "/admin/clean-expired", nil)
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, http.StatusForbidden, w.Code)
 	var resp models.SimpleMessageResponse
 	err := json.Unmarshal(w.Body.Bytes(), &resp)
 	assert.NoError(t, err)
-	assert.Equal(t, "Unauthorized: admin only", resp.Error)
+	assert.Equal(t, "Forbidden: missing permission admin", resp.Error)
 	assert.NoError(t, mock.ExpectationsWereMet()) // Verify all sqlmock expectations
 }
 
-func TestCleanExpiredRequests_Unauthorized_IsAdminNotBool(t *testing.T) {
+func TestCleanExpiredRequests_Forbidden_IsAdminNotBool(t *testing.T) {
 	router, mock := setupRouterAndDBMock(t)
 	defer teardownDBMock(t, mock)
 
@@ -174,18 +173,17 @@ func TestCleanExpiredRequests_Unauthorized_IsAdminNotBool(t *testing.T) {
 		}
 		c.Set("sessionData", sessionContextData)
 
-		CleanExpiredRequests(c)
-	})
+	}, RequirePermission(models.PermissionAdmin), CleanExpiredRequests)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("POST", "/admin/clean-expired", nil)
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, http.StatusForbidden, w.Code)
 	var resp models.SimpleMessageResponse
 	err := json.Unmarshal(w.Body.Bytes(), &resp)
 	assert.NoError(t, err)
-	assert.Equal(t, "Unauthorized: admin only", resp.Error)
+	assert.Equal(t, "Forbidden: missing permission admin", resp.Error)
 	assert.NoError(t, mock.ExpectationsWereMet()) // Verify all sqlmock expectations
 }
 
@@ -215,8 +213,7 @@ func TestCleanExpiredRequests_Success_DeletesRecords(t *testing.T) {
 		}
 		c.Set("sessionData", sessionContextData)
 
-		CleanExpiredRequests(c)
-	})
+	}, RequirePermission(models.PermissionAdmin), CleanExpiredRequests)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("POST", "/admin/clean-expired", nil)
@@ -256,8 +253,7 @@ func TestCleanExpiredRequests_Success_NoRecordsToDelete(t *testing.T) {
 		}
 		c.Set("sessionData", sessionContextData)
 
-		CleanExpiredRequests(c)
-	})
+	}, RequirePermission(models.PermissionAdmin), CleanExpiredRequests)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("POST", "/admin/clean-expired", nil)
@@ -297,8 +293,7 @@ func TestCleanExpiredRequests_DBError(t *testing.T) {
 		}
 		c.Set("sessionData", sessionContextData)
 
-		CleanExpiredRequests(c)
-	})
+	}, RequirePermission(models.PermissionAdmin), CleanExpiredRequests)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("POST", "/admin/clean-expired", nil)
@@ -311,3 +306,28 @@ func TestCleanExpiredRequests_DBError(t *testing.T) {
 	assert.Equal(t, "Failed to clean expired requests", resp.Error)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestGetClustersStatus(t *testing.T) {
+	useSettings(t, func(s *k8s.Settings) {
+		s.ClusterNames = []string{"cluster-alpha", "cluster-beta"}
+	})
+	r := setupTestRouter()
+	r.GET("/clusters/status", func(c *gin.Context) {
+		c.Set("sessionData", map[string]interface{}{"userID": "admin-user", "isAdmin": true})
+	}, RequirePermission(models.PermissionAdmin), GetClustersStatus)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/clusters/status", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp []k8s.ClusterStatus
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.Len(t, resp, 2) {
+		// Not probed yet
+		assert.Equal(t, "cluster-alpha", resp[0].Name)
+		assert.False(t, resp[0].Reachable)
+		assert.Nil(t, resp[0].CheckedAt)
+		assert.Equal(t, "cluster-beta", resp[1].Name)
+	}
+}
@@ -1,9 +1,10 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"kube-jit/internal/models"
-	"kube-jit/pkg/k8s"
 	"kube-jit/pkg/sessioncookie"
 	"net/http"
 	"strings"
@@ -11,7 +12,6 @@ import (
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 // GetSessionData retrieves session data from the context or panics
@@ -23,13 +23,18 @@ func GetSessionData(c *gin.Context) map[string]interface{} {
 
 // Logout godoc
 // @Summary Log out and clear all session cookies
-// @Description Clears all session cookies with the session prefix and logs the user out.
+// @Description Clears all session cookies with the session prefix and logs the user out, revoking the server-side session if enabled.
 // @Tags auth
 // @Accept  json
 // @Produce  json
 // @Success 200 {object} models.SimpleMessageResponse "Logged out successfully"
 // @Router /logout [post]
 func Logout(c *gin.Context) {
+	// End the server-side session, so the cookies cannot be replayed
+	if err := sessioncookie.RevokeSession(c); err != nil {
+		logger.Error("Failed to revoke session on logout", zap.Error(err))
+	}
+
 	// Iterate through cookies with the session prefix
 	for i := 0; ; i++ {
 		cookieName := fmt.Sprintf("%s%d", sessioncookie.SessionPrefix, i)
@@ -48,26 +53,33 @@ func Logout(c *gin.Context) {
 
 // CommonPermissionsResponse represents the response for CommonPermissions
 type CommonPermissionsResponse struct {
-	IsApprover             bool          `json:"isApprover"`
-	ApproverGroups         []models.Team `json:"approverGroups"`
-	IsAdmin                bool          `json:"isAdmin"`
-	IsPlatformApprover     bool          `json:"isPlatformApprover"`
-	AdminGroups            []models.Team `json:"adminGroups"`
-	PlatformApproverGroups []models.Team `json:"platformApproverGroups"`
+	IsApprover               bool          `json:"isApprover"`
+	ApproverGroups           []models.Team `json:"approverGroups"`
+	IsAdmin                  bool          `json:"isAdmin"`
+	IsPlatformApprover       bool          `json:"isPlatformApprover"`
+	AdminGroups              []models.Team `json:"adminGroups"`
+	PlatformApproverGroups   []models.Team `json:"platformApproverGroups"`
+	AdminClusters            []string      `json:"adminClusters"`            // clusters whose admin teams the user is in
+	PlatformApproverClusters []string      `json:"platformApproverClusters"` // clusters whose platform approver teams the user is in
+	ApiRoles                 []string      `json:"apiRoles"`
 }
 
 // CommonPermissionsRequest represents the request payload for CommonPermissions
+// Provider is only required for sessions that predate the provider being stored in the session,
+// otherwise it must match the provider the user logged in with
 type CommonPermissionsRequest struct {
 	Provider string `json:"provider" example:"github"`
 }
 
 // CommonPermissions godoc
 // @Summary Get common permissions for the logged in user
-// @Description Returns the user's permissions and group memberships for the specified provider (GitHub, Google, Azure).
+// @Description Returns the user's permissions and group memberships for the provider the user logged in with (GitHub, Google, Azure, OIDC).
+// @Description The permissions are cached in the session and evaluated again once older than PERMISSIONS_TTL or when the configuration changes.
 // @Description Requires one or more cookies named kube_jit_session_<number> (e.g., kube_jit_session_0, kube_jit_session_1).
 // @Description Pass split cookies in the Cookie header, for example:
 // @Description     -H "Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}"
 // @Description Note: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.
+// @Description Scripts can use an API token with any scope instead: -H "Authorization: Bearer ${token}"
 // @Tags auth
 // @Accept  json
 // @Produce  json
@@ -84,144 +96,69 @@ func CommonPermissions(c *gin.Context) {
 	sessionData := GetSessionData(c)
 	reqLogger := RequestLogger(c)
 
-	// Parse provider from payload
-	var payload struct {
-		Provider string `json:"provider"`
+	// Parse provider from payload, an empty body is allowed
+	var payload CommonPermissionsRequest
+	if err := c.ShouldBindJSON(&payload); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: "Missing or invalid provider"})
+		return
+	}
+
+	// The provider the user logged in with routes the group lookup
+	provider, _ := sessionData["provider"].(string)
+	if provider == "" {
+		// Sessions created before the provider was stored in the session
+		provider = payload.Provider
+	} else if payload.Provider != "" && payload.Provider != provider {
+		reqLogger.Warn("Provider does not match the logged in session",
+			zap.String("sessionProvider", provider),
+			zap.String("requestedProvider", payload.Provider),
+		)
+		c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: "Provider does not match the logged in session"})
+		return
 	}
-	if err := c.ShouldBindJSON(&payload); err != nil || payload.Provider == "" {
+	if provider == "" {
 		c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: "Missing or invalid provider"})
 		return
 	}
 
-	// Check if cached in session
-	isApprover, isApproverOk := sessionData["isApprover"].(bool)
-	isAdmin, isAdminOk := sessionData["isAdmin"].(bool)
-	isPlatformApprover, isPlatformApproverOk := sessionData["isPlatformApprover"].(bool)
-	approverGroups, approverGroupsOk := sessionData["approverGroups"]
-	adminGroups, adminGroupsOk := sessionData["adminGroups"]
-	platformApproverGroups, platformApproverGroupsOk := sessionData["platformApproverGroups"]
-	if isApproverOk && isAdminOk && isPlatformApproverOk && approverGroupsOk && adminGroupsOk && platformApproverGroupsOk {
+	// Check if cached in session and still fresh
+	if permissionsCached(sessionData) && permissionsFresh(sessionData) {
 		c.JSON(http.StatusOK, gin.H{
-			"isApprover":             isApprover,
-			"approverGroups":         approverGroups,
-			"isAdmin":                isAdmin,
-			"isPlatformApprover":     isPlatformApprover,
-			"adminGroups":            adminGroups,
-			"platformApproverGroups": platformApproverGroups,
+			"isApprover":               sessionData["isApprover"],
+			"approverGroups":           sessionData["approverGroups"],
+			"isAdmin":                  sessionData["isAdmin"],
+			"isPlatformApprover":       sessionData["isPlatformApprover"],
+			"adminGroups":              sessionData["adminGroups"],
+			"platformApproverGroups":   sessionData["platformApproverGroups"],
+			"adminClusters":            sessionData["adminClusters"],
+			"platformApproverClusters": sessionData["platformApproverClusters"],
+			"apiRoles":                 sessionData["apiRoles"],
 		})
 		return
 	}
 
-	// Get token from session
-	token, _ := sessionData["token"].(string)
-
-	var userGroups []models.Team
-	var err error
-
-	// Fetch user groups based on the provider
-	switch payload.Provider {
-	case "github": // GitHub provider
-		userGroups, err = GetGithubTeams(token, reqLogger)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to fetch GitHub teams"})
-			return
-		}
-	case "google": // Google provider
-		userEmail, _ := sessionData["email"].(string)
-		userGroups, err = GetGoogleGroupsWithWorkloadIdentity(userEmail, reqLogger)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to fetch Google groups"})
-			return
-		}
-	case "azure": // Azure provider
-		userGroups, err = GetAzureGroups(token, reqLogger)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to fetch Azure groups"})
-			return
-		}
-	default:
+	// Fetch user groups from the provider the user logged in with and match them
+	identityProvider, ok := lookupProvider(provider)
+	if !ok {
 		c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: "Unknown provider"})
 		return
 	}
-
-	// Match user groups to approver/admin teams
-	isAdmin, isPlatformApprover, matchedPlatformGroups, matchedAdminGroups := MatchUserGroups(
-		userGroups,
-		k8s.PlatformApproverTeams,
-		k8s.AdminTeams,
-	)
-
-	// Check and append if user is in any JitGroup for any cluster
-	var matchedApproverGroups []models.Team
-	for _, clusterName := range k8s.ClusterNames {
-		jitGroups, err := k8s.GetJitGroups(clusterName)
-		if err != nil {
-			reqLogger.Error("Error fetching JitGroups for cluster", zap.String("clusterName", clusterName), zap.Error(err))
-			continue
-		}
-		groups, _, _ := unstructured.NestedSlice(jitGroups.Object, "spec", "groups")
-		for _, group := range groups {
-			groupMap, ok := group.(map[string]any)
-			if !ok {
-				continue
-			}
-			groupID, ok := groupMap["groupID"].(string)
-			groupName, _ := groupMap["groupName"].(string)
-			if ok {
-				for _, userGroup := range userGroups {
-					if userGroup.ID == groupID {
-						matchedApproverGroups = append(matchedApproverGroups, models.Team{ID: groupID, Name: groupName})
-					}
-				}
-			}
-		}
+	permissions, err := evaluatePermissions(c.Request.Context(), identityProvider, sessionData, reqLogger)
+	if err != nil {
+		reqLogger.Error("Failed to fetch user groups", zap.String("provider", provider), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to fetch user groups"})
+		return
 	}
 
-	// Check if the user is an approver
-	isApprover = len(matchedApproverGroups) > 0
-
 	// Update session
-	sessionData["isApprover"] = isApprover
-	sessionData["approverGroups"] = matchedApproverGroups
-	sessionData["isAdmin"] = isAdmin
-	sessionData["isPlatformApprover"] = isPlatformApprover
-	sessionData["adminGroups"] = matchedAdminGroups
-	sessionData["platformApproverGroups"] = matchedPlatformGroups
-
 	session := sessions.Default(c)
 	session.Set("data", sessionData)
 	sessioncookie.SplitSessionData(c)
 
-	c.JSON(http.StatusOK, CommonPermissionsResponse{
-		IsApprover:             isApprover,
-		ApproverGroups:         matchedApproverGroups,
-		IsAdmin:                isAdmin,
-		IsPlatformApprover:     isPlatformApprover,
-		AdminGroups:            matchedAdminGroups,
-		PlatformApproverGroups: matchedPlatformGroups,
-	})
+	c.JSON(http.StatusOK, permissions)
 }
 
-// isAllowedUser checks if the user is allowed to access the api
-// It checks the provider and email domain for Google and Azure
-// and checks the organization membership for GitHub
-// It returns true if the user is allowed, false otherwise
-var isAllowedUser = func(provider, email string, extraInfo map[string]any) bool {
-	switch provider {
-	case "google", "azure":
-		return strings.HasSuffix(email, "@"+allowedDomain)
-	case "github":
-		orgs, ok := extraInfo["orgs"].([]string)
-		if !ok {
-			return false
-		}
-		for _, org := range orgs {
-			if org == allowedOrg {
-				return true
-			}
-		}
-		return false
-	default:
-		return false
-	}
+// emailInAllowedDomain checks the email belongs to ALLOWED_DOMAIN
+func emailInAllowedDomain(email string) bool {
+	return strings.HasSuffix(email, "@"+allowedDomain)
 }
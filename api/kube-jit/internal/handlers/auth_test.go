@@ -12,6 +12,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/cookie"
@@ -115,41 +116,16 @@ func TestLogout(t *testing.T) {
 	})
 }
 
-func TestIsAllowedUser(t *testing.T) {
+func TestEmailInAllowedDomain(t *testing.T) {
 	originalAllowedDomain := allowedDomain
-	originalAllowedOrg := allowedOrg
-	defer func() {
-		allowedDomain = originalAllowedDomain
-		allowedOrg = originalAllowedOrg
-	}()
+	defer func() { allowedDomain = originalAllowedDomain }()
 
 	allowedDomain = "testdomain.com"
-	allowedOrg = "testorg"
-
-	tests := []struct {
-		name      string
-		provider  string
-		email     string
-		extraInfo map[string]any
-		want      bool
-	}{
-		{"Google_Allowed", "google", "user@testdomain.com", nil, true},
-		{"Google_Disallowed", "google", "user@other.com", nil, false},
-		{"Azure_Allowed", "azure", "user@testdomain.com", nil, true},
-		{"Azure_Disallowed", "azure", "user@other.com", nil, false},
-		{"GitHub_Allowed", "github", "ghuser", map[string]any{"orgs": []string{"testorg", "another"}}, true},
-		{"GitHub_Disallowed_WrongOrg", "github", "ghuser", map[string]any{"orgs": []string{"another"}}, false},
-		{"GitHub_Disallowed_NoExtraInfoOrgs", "github", "ghuser", nil, false},
-		{"GitHub_Disallowed_MalformedOrgs", "github", "ghuser", map[string]any{"orgs": "not-a-slice"}, false},
-		{"UnknownProvider", "unknownprovider", "user@testdomain.com", nil, false},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := isAllowedUser(tt.provider, tt.email, tt.extraInfo)
-			assert.Equal(t, tt.want, got)
-		})
-	}
+
+	assert.True(t, emailInAllowedDomain("user@testdomain.com"))
+	assert.False(t, emailInAllowedDomain("user@other.com"))
+	assert.False(t, emailInAllowedDomain("user@sub.testdomain.com"))
+	assert.False(t, emailInAllowedDomain("testdomain.com"))
 }
 
 func TestCommonPermissions(t *testing.T) {
@@ -191,6 +167,8 @@ func TestCommonPermissions(t *testing.T) {
 			"approverGroups":         cachedApproverGroups,
 			"adminGroups":            cachedAdminGroups,
 			"platformApproverGroups": cachedPlatformApproverGroups,
+			"permissionsCheckedAt":   time.Now().Unix(),
+			"permissionsVersion":     k8s.PermissionsVersion(),
 		}
 
 		r := setupTestRouter()
@@ -253,17 +231,13 @@ func TestCommonPermissions(t *testing.T) {
 
 	t.Run("GitHub provider - success - fetch and match permissions", func(t *testing.T) {
 		// Mock external dependencies
-		originalGetGithubTeams := GetGithubTeams
-		GetGithubTeams = func(token string, reqLogger *zap.Logger) ([]models.Team, error) {
-			assert.Equal(t, "fake-github-token", token)
-			return []models.Team{
-				{ID: "gh-approver-team-id", Name: "GitHub Approver Team"},
-				{ID: "gh-admin-team-id", Name: "Configured Admin Team"},
-				{ID: "gh-platform-team-id", Name: "Configured Platform Team"},
-				{ID: "gh-other-team-id", Name: "GitHub Other Team"},
-			}, nil
-		}
-		defer func() { GetGithubTeams = originalGetGithubTeams }()
+		github := &fakeProvider{name: "github", groups: []models.Team{
+			{ID: "gh-approver-team-id", Name: "GitHub Approver Team"},
+			{ID: "gh-admin-team-id", Name: "Configured Admin Team"},
+			{ID: "gh-platform-team-id", Name: "Configured Platform Team"},
+			{ID: "gh-other-team-id", Name: "GitHub Other Team"},
+		}}
+		useProvider(t, github)
 
 		originalGetJitGroups := k8s.GetJitGroups
 		k8s.GetJitGroups = func(clusterName string) (*unstructured.Unstructured, error) {
@@ -280,17 +254,11 @@ func TestCommonPermissions(t *testing.T) {
 		}
 		defer func() { k8s.GetJitGroups = originalGetJitGroups }()
 
-		originalPlatformApproverTeams := k8s.PlatformApproverTeams
-		k8s.PlatformApproverTeams = []models.Team{{ID: "gh-platform-team-id", Name: "Configured Platform Team"}}
-		defer func() { k8s.PlatformApproverTeams = originalPlatformApproverTeams }()
-
-		originalAdminTeams := k8s.AdminTeams
-		k8s.AdminTeams = []models.Team{{ID: "gh-admin-team-id", Name: "Configured Admin Team"}}
-		defer func() { k8s.AdminTeams = originalAdminTeams }()
-
-		originalClusterNames := k8s.ClusterNames
-		k8s.ClusterNames = []string{"test-cluster"}
-		defer func() { k8s.ClusterNames = originalClusterNames }()
+		useSettings(t, func(s *k8s.Settings) {
+			s.PlatformApproverTeams = []models.Team{{ID: "gh-platform-team-id", Name: "Configured Platform Team"}}
+			s.AdminTeams = []models.Team{{ID: "gh-admin-team-id", Name: "Configured Admin Team"}}
+			s.ClusterNames = []string{"test-cluster"}
+		})
 
 		// Setup router and session
 		r := setupTestRouter()
@@ -312,6 +280,7 @@ func TestCommonPermissions(t *testing.T) {
 
 		// Assertions
 		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "fake-github-token", github.groupsSession["token"])
 		var resp CommonPermissionsResponse
 		err := json.Unmarshal(w.Body.Bytes(), &resp)
 		assert.NoError(t, err)
@@ -334,11 +303,7 @@ func TestCommonPermissions(t *testing.T) {
 	})
 
 	t.Run("GitHub provider - error fetching teams", func(t *testing.T) {
-		originalGetGithubTeams := GetGithubTeams
-		GetGithubTeams = func(token string, reqLogger *zap.Logger) ([]models.Team, error) {
-			return nil, errors.New("github API error")
-		}
-		defer func() { GetGithubTeams = originalGetGithubTeams }()
+		useProvider(t, &fakeProvider{name: "github", groupsErr: errors.New("github API error")})
 
 		r := setupTestRouter()
 		sessionData := map[string]interface{}{"user": "testuser_github_error", "token": "fake-token"}
@@ -360,19 +325,14 @@ func TestCommonPermissions(t *testing.T) {
 		var respMsg models.SimpleMessageResponse
 		err := json.Unmarshal(w.Body.Bytes(), &respMsg)
 		assert.NoError(t, err)
-		assert.Equal(t, "Failed to fetch GitHub teams", respMsg.Error)
+		assert.Equal(t, "Failed to fetch user groups", respMsg.Error)
 	})
 
 	t.Run("Google provider - success - fetch and match permissions", func(t *testing.T) {
-		originalGetGoogleGroups := GetGoogleGroupsWithWorkloadIdentity
-		GetGoogleGroupsWithWorkloadIdentity = func(userEmail string, reqLogger *zap.Logger) ([]models.Team, error) {
-			assert.Equal(t, "user@example.com", userEmail)
-			return []models.Team{
-				{ID: "google-approver-group-id", Name: "Configured Approver Group"},
-				{ID: "google-admin-group-id", Name: "Configured Google Admin Group"},
-			}, nil
-		}
-		defer func() { GetGoogleGroupsWithWorkloadIdentity = originalGetGoogleGroups }()
+		useProvider(t, &fakeProvider{name: "google", groups: []models.Team{
+			{ID: "google-approver-group-id", Name: "Configured Approver Group"},
+			{ID: "google-admin-group-id", Name: "Configured Google Admin Group"},
+		}})
 
 		originalGetJitGroups := k8s.GetJitGroups
 		k8s.GetJitGroups = func(clusterName string) (*unstructured.Unstructured, error) {
@@ -388,15 +348,11 @@ func TestCommonPermissions(t *testing.T) {
 		}
 		defer func() { k8s.GetJitGroups = originalGetJitGroups }()
 
-		originalAdminTeams := k8s.AdminTeams
-		k8s.AdminTeams = []models.Team{{ID: "google-admin-group-id", Name: "Configured Google Admin Group"}}
-		defer func() { k8s.AdminTeams = originalAdminTeams }()
-		originalPlatformApproverTeams := k8s.PlatformApproverTeams
-		k8s.PlatformApproverTeams = []models.Team{} // No platform approver for this test
-		defer func() { k8s.PlatformApproverTeams = originalPlatformApproverTeams }()
-		originalClusterNames := k8s.ClusterNames
-		k8s.ClusterNames = []string{"test-cluster-google"}
-		defer func() { k8s.ClusterNames = originalClusterNames }()
+		useSettings(t, func(s *k8s.Settings) {
+			s.AdminTeams = []models.Team{{ID: "google-admin-group-id", Name: "Configured Google Admin Group"}}
+			s.PlatformApproverTeams = []models.Team{} // No platform approver for this test
+			s.ClusterNames = []string{"test-cluster-google"}
+		})
 
 		r := setupTestRouter()
 		sessionData := map[string]interface{}{"user": "g_user", "token": "fake-g-token", "email": "user@example.com"}
@@ -428,15 +384,10 @@ func TestCommonPermissions(t *testing.T) {
 
 	t.Run("Azure provider - success - fetch and match permissions", func(t *testing.T) {
 		// Mock external dependencies
-		originalGetAzureGroups := GetAzureGroups
-		GetAzureGroups = func(token string, reqLogger *zap.Logger) ([]models.Team, error) {
-			assert.Equal(t, "fake-azure-token", token)
-			return []models.Team{
-				{ID: "azure-approver-group-id", Name: "Azure Approver Group (from Azure)"},
-				{ID: "azure-admin-group-id", Name: "Azure Admin Group (from Azure)"},
-			}, nil
-		}
-		defer func() { GetAzureGroups = originalGetAzureGroups }()
+		useProvider(t, &fakeProvider{name: "azure", groups: []models.Team{
+			{ID: "azure-approver-group-id", Name: "Azure Approver Group (from Azure)"},
+			{ID: "azure-admin-group-id", Name: "Azure Admin Group (from Azure)"},
+		}})
 
 		originalGetJitGroups := k8s.GetJitGroups
 		k8s.GetJitGroups = func(clusterName string) (*unstructured.Unstructured, error) {
@@ -453,17 +404,11 @@ func TestCommonPermissions(t *testing.T) {
 		}
 		defer func() { k8s.GetJitGroups = originalGetJitGroups }()
 
-		originalAdminTeams := k8s.AdminTeams
-		k8s.AdminTeams = []models.Team{{ID: "azure-admin-group-id", Name: "Azure Admin Group (from Azure)"}}
-		defer func() { k8s.AdminTeams = originalAdminTeams }()
-
-		originalPlatformApproverTeams := k8s.PlatformApproverTeams
-		k8s.PlatformApproverTeams = []models.Team{} // No platform approver role for Azure in this test
-		defer func() { k8s.PlatformApproverTeams = originalPlatformApproverTeams }()
-
-		originalClusterNames := k8s.ClusterNames
-		k8s.ClusterNames = []string{"test-cluster-azure"}
-		defer func() { k8s.ClusterNames = originalClusterNames }()
+		useSettings(t, func(s *k8s.Settings) {
+			s.AdminTeams = []models.Team{{ID: "azure-admin-group-id", Name: "Azure Admin Group (from Azure)"}}
+			s.PlatformApproverTeams = []models.Team{} // No platform approver role for Azure in this test
+			s.ClusterNames = []string{"test-cluster-azure"}
+		})
 
 		// Setup router and session
 		r := setupTestRouter()
@@ -507,11 +452,7 @@ func TestCommonPermissions(t *testing.T) {
 	})
 
 	t.Run("Azure provider - error fetching groups", func(t *testing.T) {
-		originalGetAzureGroups := GetAzureGroups
-		GetAzureGroups = func(token string, reqLogger *zap.Logger) ([]models.Team, error) {
-			return nil, errors.New("azure API error")
-		}
-		defer func() { GetAzureGroups = originalGetAzureGroups }()
+		useProvider(t, &fakeProvider{name: "azure", groupsErr: errors.New("azure API error")})
 
 		r := setupTestRouter()
 		sessionData := map[string]interface{}{"user": "testuser_azure_error", "token": "fake-azure-token"}
@@ -533,15 +474,66 @@ func TestCommonPermissions(t *testing.T) {
 		var respMsg models.SimpleMessageResponse
 		err := json.Unmarshal(w.Body.Bytes(), &respMsg)
 		assert.NoError(t, err)
-		assert.Equal(t, "Failed to fetch Azure groups", respMsg.Error)
+		assert.Equal(t, "Failed to fetch user groups", respMsg.Error)
+	})
+
+	t.Run("Session provider routes group lookup without payload provider", func(t *testing.T) {
+		github := &fakeProvider{name: "github"}
+		useProvider(t, github)
+		useProvider(t, &fakeProvider{name: "azure", groups: []models.Team{{ID: "azure-admin-group-id", Name: "Azure Admin Group"}}})
+
+		useSettings(t, func(s *k8s.Settings) {
+			s.AdminTeams = []models.Team{{ID: "azure-admin-group-id", Name: "Azure Admin Group"}}
+			s.ClusterNames = []string{}
+		})
+
+		r := setupTestRouter()
+		sessionData := map[string]interface{}{"user": "azure_user", "token": "fake-azure-token", "provider": "azure"}
+		r.Use(func(c *gin.Context) {
+			c.Set("logger", defaultLogger)
+			c.Set("sessionData", sessionData)
+			c.Next()
+		})
+		r.POST("/permissions", CommonPermissions)
+
+		req, _ := http.NewRequest(http.MethodPost, "/permissions", bytes.NewBufferString(""))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp CommonPermissionsResponse
+		err := json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.NoError(t, err)
+		assert.True(t, resp.IsAdmin)
+		assert.Nil(t, github.groupsSession, "GitHub teams should not be fetched for an Azure session")
+	})
+
+	t.Run("Payload provider does not match session provider", func(t *testing.T) {
+		r := setupTestRouter()
+		r.Use(func(c *gin.Context) {
+			c.Set("logger", defaultLogger)
+			c.Set("sessionData", map[string]interface{}{"user": "github_user", "token": "fake-github-token", "provider": "github"})
+			c.Next()
+		})
+		r.POST("/permissions", CommonPermissions)
+
+		payload := CommonPermissionsRequest{Provider: "azure"}
+		jsonBody, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(http.MethodPost, "/permissions", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		var respMsg models.SimpleMessageResponse
+		err := json.Unmarshal(w.Body.Bytes(), &respMsg)
+		assert.NoError(t, err)
+		assert.Equal(t, "Provider does not match the logged in session", respMsg.Error)
 	})
 
 	t.Run("Error fetching JitGroups", func(t *testing.T) {
-		originalGetGithubTeams := GetGithubTeams // Use GitHub as an example provider
-		GetGithubTeams = func(token string, reqLogger *zap.Logger) ([]models.Team, error) {
-			return []models.Team{{ID: "some-team", Name: "Some Team"}}, nil
-		}
-		defer func() { GetGithubTeams = originalGetGithubTeams }()
+		// Use GitHub as an example provider
+		useProvider(t, &fakeProvider{name: "github", groups: []models.Team{{ID: "some-team", Name: "Some Team"}}})
 
 		originalGetJitGroups := k8s.GetJitGroups
 		k8s.GetJitGroups = func(clusterName string) (*unstructured.Unstructured, error) {
@@ -549,16 +541,12 @@ func TestCommonPermissions(t *testing.T) {
 		}
 		defer func() { k8s.GetJitGroups = originalGetJitGroups }()
 
-		originalClusterNames := k8s.ClusterNames
-		k8s.ClusterNames = []string{"error-cluster"}
-		defer func() { k8s.ClusterNames = originalClusterNames }()
+		useSettings(t, func(s *k8s.Settings) { s.ClusterNames = []string{"error-cluster"} })
 		// Ensure other k8s teams are empty or don't match to isolate JitGroup impact
-		originalPlatformApproverTeams := k8s.PlatformApproverTeams
-		k8s.PlatformApproverTeams = []models.Team{}
-		defer func() { k8s.PlatformApproverTeams = originalPlatformApproverTeams }()
-		originalAdminTeams := k8s.AdminTeams
-		k8s.AdminTeams = []models.Team{}
-		defer func() { k8s.AdminTeams = originalAdminTeams }()
+		useSettings(t, func(s *k8s.Settings) {
+			s.PlatformApproverTeams = []models.Team{}
+			s.AdminTeams = []models.Team{}
+		})
 
 		r := setupTestRouter()
 		sessionData := map[string]interface{}{"user": "testuser_jit_error", "token": "fake-token"}
@@ -584,7 +572,7 @@ func TestCommonPermissions(t *testing.T) {
 		assert.NoError(t, err)
 		assert.False(t, resp.IsApprover, "IsApprover should be false as JitGroups fetch failed")
 		assert.Empty(t, resp.ApproverGroups, "ApproverGroups should be empty")
-		// Admin and PlatformApprover status depends on k8s.AdminTeams/PlatformApproverTeams and userGroups
+		// Admin and PlatformApprover status depends on the configured AdminTeams/PlatformApproverTeams and userGroups
 		assert.False(t, resp.IsAdmin)
 		assert.Empty(t, resp.AdminGroups)
 		assert.False(t, resp.IsPlatformApprover)
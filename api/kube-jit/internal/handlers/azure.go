@@ -6,24 +6,21 @@ import (
 	"fmt"
 	"io"
 	"kube-jit/internal/models"
-	"kube-jit/pkg/sessioncookie"
 	"kube-jit/pkg/utils"
 	"net/http"
-	"time"
 
-	"github.com/gin-contrib/sessions"
-	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 	"golang.org/x/oauth2"
 )
 
 // getAzureOAuthConfig constructs and returns the Azure OAuth2 config.
-// This ensures it uses the current values of clientID, clientSecret, etc.
+// This ensures it uses the current Azure client settings from oauthClients
 var getAzureOAuthConfig = func() *oauth2.Config {
+	client := oauthClient("azure")
 	return &oauth2.Config{
-		ClientID:     clientID,     // Reads current package-level clientID from common.go
-		ClientSecret: clientSecret, // Reads current package-level clientSecret from common.go
-		RedirectURL:  redirectUri,  // Reads current package-level redirectUri from common.go
+		ClientID:     client.ClientID,
+		ClientSecret: client.ClientSecret,
+		RedirectURL:  client.RedirectURI,
 		Scopes:       []string{"openid", "email", "profile", "User.Read", "Directory.Read.All"},
 		Endpoint: oauth2.Endpoint{
 			AuthURL:  utils.MustGetEnv("AZURE_AUTH_URL"),
@@ -32,201 +29,138 @@ var getAzureOAuthConfig = func() *oauth2.Config {
 	}
 }
 
-// Helper to fetch and decode Azure user profile
-var fetchAzureUserProfile = func(token string) (*models.AzureUser, error) {
-	client := oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}))
-	resp, err := client.Get("https://graph.microsoft.com/v1.0/me")
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch Azure user info: %w", err)
-	}
-	defer resp.Body.Close()
+func init() {
+	RegisterProvider(newAzureProvider())
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("error fetching user profile from Azure AD: %s", string(body))
-	}
+// azureProvider implements IdentityProvider for Azure AD (Entra ID)
+// Users must have an email in ALLOWED_DOMAIN, directory groups are used as groups
+type azureProvider struct {
+	graphURL string
+}
 
-	var azureUser models.AzureUser
-	if err := json.NewDecoder(resp.Body).Decode(&azureUser); err != nil {
-		return nil, fmt.Errorf("failed to decode Azure user info: %w", err)
-	}
-	return &azureUser, nil
+func newAzureProvider() *azureProvider {
+	return &azureProvider{graphURL: "https://graph.microsoft.com"}
 }
 
-// HandleAzureLogin godoc
-// @Summary Azure OAuth callback
-// @Description Handles the Azure OAuth callback, exchanges the code for an access token, fetches user info, sets session data, and returns normalized user data and expiration time.
-// @Tags azure
-// @Accept  json
-// @Produce  json
-// @Param   code query string true "Azure OAuth authorization code"
-// @Success 200 {object} models.LoginResponse "Normalized user data and expiration time"
-// @Failure 400 {object} models.SimpleMessageResponse "Missing or invalid code"
-// @Failure 403 {object} models.SimpleMessageResponse "Unauthorized domain"
-// @Failure 500 {object} models.SimpleMessageResponse "Internal server error"
-// @Router /oauth/azure/callback [get]
-func HandleAzureLogin(c *gin.Context) {
-	code := c.Query("code")
-	if code == "" {
-		logger.Warn("Missing 'code' query parameter in Azure login")
-		c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: "Code query parameter is required"})
-		return
-	}
+func (p *azureProvider) Name() string {
+	return "azure"
+}
 
-	currentAzureOAuthConfig := getAzureOAuthConfig() // Use the function here
+func (p *azureProvider) DeniedMessage() string {
+	return "Unauthorized domain"
+}
 
-	// Exchange the authorization code for a token
-	token, err := currentAzureOAuthConfig.Exchange(context.Background(), code)
-	if err != nil {
-		// Log the detailed error from the Exchange call
-		logger.Error("Failed to exchange Azure token",
-			zap.Error(err), // This will print the underlying error from the oauth2 library
-			zap.String("codeUsed", code),
-			zap.String("clientIDUsed", currentAzureOAuthConfig.ClientID),
-			zap.String("clientSecretUsed", currentAzureOAuthConfig.ClientSecret), // Be careful logging secrets, even in tests
-			zap.String("redirectURIUsed", currentAzureOAuthConfig.RedirectURL),
-			zap.String("tokenURLUsed", currentAzureOAuthConfig.Endpoint.TokenURL),
-		)
-		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to exchange token"})
-		return
-	}
+// ExchangeCode exchanges the authorization code for a token
+func (p *azureProvider) ExchangeCode(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	return getAzureOAuthConfig().Exchange(oauthContext(ctx, "azure"), code, opts...)
+}
 
-	// Fetch user info using the helper
-	azureUser, err := fetchAzureUserProfile(token.AccessToken)
-	if err != nil {
-		logger.Error("Failed to fetch Azure user info", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: err.Error()})
-		return
+// FetchProfile fetches the user from Microsoft Graph
+// Mail is used as email if present, otherwise the UserPrincipalName
+func (p *azureProvider) FetchProfile(ctx context.Context, token *oauth2.Token) (*Identity, error) {
+	var azureUser models.AzureUser
+	if err := p.get(ctx, "/v1.0/me", token.AccessToken, &azureUser); err != nil {
+		return nil, fmt.Errorf("error fetching user profile from Azure AD: %w", err)
 	}
 
-	// Use Mail if present, otherwise fallback to UserPrincipalName
 	email := azureUser.Mail
 	if email == "" {
 		email = azureUser.UserPrincipalName
 	}
 
-	// Check if the user is allowed to log in
-	if !isAllowedUser("azure", email, nil) {
-		logger.Warn("Login attempt from unauthorized Azure domain", zap.String("email", email))
-		c.JSON(http.StatusForbidden, models.SimpleMessageResponse{Error: "Unauthorized domain"})
-		return
-	}
-
-	// Normalize the user data
-	normalizedUserData := models.NormalizedUserData{
+	return &Identity{User: models.NormalizedUserData{
 		ID:        azureUser.ID,
 		Name:      azureUser.DisplayName,
 		Email:     email,
 		AvatarURL: "", // Azure AD doesn't provide an avatar URL by default
 		Provider:  "azure",
-	}
-
-	// Prepare session data
-	sessionData := map[string]interface{}{
-		"email": email,
-		"token": token.AccessToken,
-		"id":    azureUser.ID,
-		"name":  azureUser.DisplayName,
-	}
+	}}, nil
+}
 
-	// Save the session data in the session
-	session := sessions.Default(c)
-	session.Set("data", sessionData)
+// FetchGroups returns the Azure AD groups of the logged in user
+// transitiveMemberOf includes the groups the user is a member of through nested groups,
+// the microsoft.graph.group cast leaves out directory roles and administrative units
+func (p *azureProvider) FetchGroups(ctx context.Context, sessionData map[string]any, reqLogger *zap.Logger) ([]models.Team, error) {
+	token, _ := sessionData["token"].(string)
 
-	// Split the session data into cookies
-	sessioncookie.SplitSessionData(c)
+	groups, err := fetchAllPages[azureGroup](ctx, p.pages(token), p.graphURL+"/v1.0/me/transitiveMemberOf/microsoft.graph.group?$select=id,displayName&$top=999")
+	if err != nil {
+		reqLogger.Warn("Error fetching Azure groups", zap.Error(err))
+		return nil, fmt.Errorf("error fetching groups from Azure AD: %w", err)
+	}
 
-	logger.Debug("Session cookies set successfully for Azure login", zap.String("name", azureUser.DisplayName))
+	var teams []models.Team
+	for _, g := range groups {
+		teams = append(teams, models.Team{
+			ID:   g.ID,
+			Name: g.DisplayName,
+		})
+	}
+	return teams, nil
+}
 
-	// Respond with the normalized user data
-	c.JSON(http.StatusOK, models.LoginResponse{
-		UserData:  normalizedUserData,
-		ExpiresIn: int(time.Until(token.Expiry).Seconds()),
-	})
+// IsAllowed checks the user's email is in the allowed domain
+func (p *azureProvider) IsAllowed(_ context.Context, _ *oauth2.Token, identity *Identity) (bool, error) {
+	return emailInAllowedDomain(identity.User.Email), nil
 }
 
-// GetAzureProfile godoc
-// @Summary Get the logged in user's Azure profile
-// @Description Returns the normalized Azure user profile for the authenticated user.
-// @Description Requires one or more cookies named kube_jit_session_<number> (e.g., kube_jit_session_0, kube_jit_session_1).
-// @Description Pass split cookies in the Cookie header, for example:
-// @Description     -H "Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}"
-// @Description Note: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.
-// @Tags azure
-// @Accept  json
-// @Produce  json
-// @Param   Cookie header string true "Session cookies (multiple allowed, names: kube_jit_session_0, kube_jit_session_1, etc.)"
-// @Success 200 {object} models.NormalizedUserData
-// @Failure 401 {object} models.SimpleMessageResponse "Unauthorized: no token in session data"
-// @Failure 500 {object} models.SimpleMessageResponse "Internal server error"
-// @Router /azure/profile [get]
-func GetAzureProfile(c *gin.Context) {
-	// Check if the user is logged in
-	sessionData := GetSessionData(c)
-	reqLogger := RequestLogger(c)
-
-	reqLogger.Debug("User authenticated", zap.String("userID", sessionData["id"].(string)))
-
-	token, ok := sessionData["token"].(string)
-	if !ok || token == "" {
-		reqLogger.Warn("No token in session data for Azure profile")
-		c.JSON(http.StatusUnauthorized, models.SimpleMessageResponse{Error: "Unauthorized: no token in session data"})
-		return
-	}
+// azureGroup is a group in Microsoft Graph
+type azureGroup struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+}
 
-	// Fetch user info using the helper
-	azureUser, err := fetchAzureUserProfile(token)
+// get sends an authenticated GET request to Microsoft Graph and decodes the response into out
+func (p *azureProvider) get(ctx context.Context, path, token string, out any) error {
+	body, err := p.fetch(ctx, p.graphURL+path, token)
 	if err != nil {
-		reqLogger.Error("Failed to fetch Azure user profile", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: err.Error()})
-		return
+		return err
 	}
+	defer body.Close()
 
-	// Normalize the response to match the structure of other providers
-	normalizedUserData := map[string]any{
-		"id":         azureUser.ID,
-		"name":       azureUser.DisplayName,
-		"email":      azureUser.Mail,
-		"avatar_url": "",
-		"provider":   "azure",
+	if err := json.NewDecoder(body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", path, err)
 	}
+	return nil
+}
 
-	c.JSON(http.StatusOK, normalizedUserData)
+// pages returns a pageFetcher reading Microsoft Graph collections with token
+// Each page holds its items in value and the url of the next page in @odata.nextLink
+func (p *azureProvider) pages(token string) pageFetcher {
+	return func(ctx context.Context, url string, out any) (string, error) {
+		body, err := p.fetch(ctx, url, token)
+		if err != nil {
+			return "", err
+		}
+		defer body.Close()
+
+		var page struct {
+			Value    json.RawMessage `json:"value"`
+			NextLink string          `json:"@odata.nextLink"`
+		}
+		if err := json.NewDecoder(body).Decode(&page); err != nil {
+			return "", fmt.Errorf("failed to decode %s: %w", url, err)
+		}
+		if err := json.Unmarshal(page.Value, out); err != nil {
+			return "", fmt.Errorf("failed to decode %s: %w", url, err)
+		}
+		return page.NextLink, nil
+	}
 }
 
-// Fetch Azure AD groups for a user using their OAuth token
-var GetAzureGroups = func(token string, reqLogger *zap.Logger) ([]models.Team, error) {
-	client := oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}))
-	resp, err := client.Get("https://graph.microsoft.com/v1.0/me/memberOf")
+// fetch sends an authenticated GET request to url and returns the body of a successful response
+func (p *azureProvider) fetch(ctx context.Context, url, token string) (io.ReadCloser, error) {
+	client := oauth2.NewClient(oauthContext(ctx, "azure"), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}))
+	resp, err := client.Get(url)
 	if err != nil {
-		reqLogger.Error("Failed to fetch Azure groups", zap.Error(err))
-		return nil, fmt.Errorf("failed to fetch groups from Azure AD")
+		return nil, fmt.Errorf("failed to fetch %s: %w", url, err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
 		body, _ := io.ReadAll(resp.Body)
-		reqLogger.Warn("Error fetching Azure groups", zap.String("response", string(body)))
-		return nil, fmt.Errorf("error fetching groups from Azure AD")
-	}
-
-	var groupsResponse struct {
-		Value []struct {
-			ID          string `json:"id"`
-			DisplayName string `json:"displayName"`
-		} `json:"value"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&groupsResponse); err != nil {
-		reqLogger.Error("Failed to decode Azure groups response", zap.Error(err))
-		return nil, fmt.Errorf("failed to decode groups response")
-	}
-
-	var teams []models.Team
-	for _, g := range groupsResponse.Value {
-		teams = append(teams, models.Team{
-			ID:   g.ID,
-			Name: g.DisplayName,
-		})
+		return nil, fmt.Errorf("%s returned %d: %s", url, resp.StatusCode, string(body))
 	}
-	return teams, nil
+	return resp.Body, nil
 }
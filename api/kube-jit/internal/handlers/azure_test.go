@@ -14,17 +14,13 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
-	"go.uber.org/zap"
-	"golang.org/x/oauth2"
 )
 
 // --- Test Setup/Teardown for Azure specific tests ---
 func setupAzureTestEnv() (restoreFunc func()) {
 	// Store original package variables
-	originalOauthProvider := oauthProvider
-	originalClientID := clientID
-	originalClientSecret := clientSecret
-	originalRedirectURI := redirectUri
+	originalOauthProviders := oauthProviders
+	originalOauthClients := oauthClients
 	originalAllowedDomain := allowedDomain
 	originalAllowedOrg := allowedOrg // In case other tests (like GitHub) set it
 
@@ -39,10 +35,10 @@ func setupAzureTestEnv() (restoreFunc func()) {
 	originalAzureTokenURLEnv := os.Getenv("AZURE_TOKEN_URL")
 
 	// --- Setup for Azure tests ---
-	oauthProvider = "azure"
-	clientID = "test-azure-client-id"
-	clientSecret = "test-azure-client-secret"
-	redirectUri = "http://localhost/test/azure/callback"
+	oauthProviders = []string{"azure"}
+	oauthClients = map[string]oauthClientConfig{
+		"azure": {ClientID: "test-azure-client-id", ClientSecret: "test-azure-client-secret", RedirectURI: "http://localhost/test/azure/callback"},
+	}
 	allowedDomain = "example.com"
 	allowedOrg = "" // Clear GitHub's setting if any
 
@@ -57,10 +53,8 @@ func setupAzureTestEnv() (restoreFunc func()) {
 
 	return func() {
 		// Restore package variables
-		oauthProvider = originalOauthProvider
-		clientID = originalClientID
-		clientSecret = originalClientSecret
-		redirectUri = originalRedirectURI
+		oauthProviders = originalOauthProviders
+		oauthClients = originalOauthClients
 		allowedDomain = originalAllowedDomain
 		allowedOrg = originalAllowedOrg
 
@@ -76,80 +70,84 @@ func setupAzureTestEnv() (restoreFunc func()) {
 	}
 }
 
-// --- Mock for isAllowedUser ---
-var originalIsAllowedUser func(provider, email string, extraInfo map[string]any) bool
-
-func mockIsAllowedUser(allowed bool) {
-	originalIsAllowedUser = isAllowedUser
-	isAllowedUser = func(provider, email string, extraInfo map[string]any) bool {
-		return allowed
-	}
-}
-
-func restoreIsAllowedUser() {
-	if originalIsAllowedUser != nil {
-		isAllowedUser = originalIsAllowedUser
-	}
+// newAzureGraphFake serves /v1.0/me and the user's transitive groups, over two pages, for the given user
+// and returns an azureProvider pointed at it
+func newAzureGraphFake(t *testing.T, user *models.AzureUser) *azureProvider {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1.0/me", func(w http.ResponseWriter, r *http.Request) {
+		if user == nil {
+			http.Error(w, "graph API error", http.StatusInternalServerError)
+			return
+		}
+		assert.Equal(t, "Bearer mocked_access_token", r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(user)
+	})
+	var server *httptest.Server
+	mux.HandleFunc("/v1.0/me/transitiveMemberOf/microsoft.graph.group", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer mocked_access_token", r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("$skiptoken") == "page2" {
+			fmt.Fprint(w, `{"value":[{"id":"group2","displayName":"Azure Group Two"}]}`)
+			return
+		}
+		assert.Equal(t, "id,displayName", r.URL.Query().Get("$select"))
+		fmt.Fprintf(w, `{"value":[{"id":"group1","displayName":"Azure Group One"}],"@odata.nextLink":"%s%s?$skiptoken=page2"}`, server.URL, r.URL.Path)
+	})
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return &azureProvider{graphURL: server.URL}
 }
 
-// --- Mock for fetchAzureUserProfile ---
-var originalFetchAzureUserProfile func(token string) (*models.AzureUser, error)
-
-func mockFetchAzureUserProfile(user *models.AzureUser, err error) {
-	originalFetchAzureUserProfile = fetchAzureUserProfile
-	fetchAzureUserProfile = func(token string) (*models.AzureUser, error) {
-		return user, err
-	}
+// newAzureTokenServer serves the token endpoint and points AZURE_TOKEN_URL at it
+func newAzureTokenServer(t *testing.T, status int) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		err := r.ParseForm()
+		assert.NoError(t, err)
+		assert.Equal(t, "auth_code", r.Form.Get("code"))
+		assert.Equal(t, "authorization_code", r.Form.Get("grant_type"))
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "mocked_access_token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+		assert.NoError(t, err)
+	}))
+	t.Cleanup(server.Close)
+	os.Setenv("AZURE_TOKEN_URL", server.URL)
 }
 
-func restoreFetchAzureUserProfile() {
-	if originalFetchAzureUserProfile != nil {
-		fetchAzureUserProfile = originalFetchAzureUserProfile
-	}
+func azureCallback(p *azureProvider) *httptest.ResponseRecorder {
+	r := setupTestRouter()
+	r.GET("/oauth/azure/callback", OAuthCallback(p))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/oauth/azure/callback?code=auth_code", nil)
+	r.ServeHTTP(w, req)
+	return w
 }
 
-func TestHandleAzureLogin(t *testing.T) {
+func TestAzureLogin(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	restoreEnv := setupAzureTestEnv()
 	defer restoreEnv()
 
 	t.Run("success", func(t *testing.T) {
-		mockTokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			err := r.ParseForm()
-			assert.NoError(t, err)
-			assert.Equal(t, "auth_code", r.Form.Get("code"))
-			assert.Equal(t, "authorization_code", r.Form.Get("grant_type"))
-			w.Header().Set("Content-Type", "application/json")
-			err = json.NewEncoder(w).Encode(map[string]interface{}{
-				"access_token": "mocked_access_token",
-				"token_type":   "Bearer",
-				"expires_in":   3600,
-			})
-			assert.NoError(t, err)
-		}))
-		defer mockTokenServer.Close()
-
-		os.Setenv("AZURE_TOKEN_URL", mockTokenServer.URL)
-
-		mockIsAllowedUser(true)
-		defer restoreIsAllowedUser()
-
-		mockedAzureUser := &models.AzureUser{
+		newAzureTokenServer(t, http.StatusOK)
+		p := newAzureGraphFake(t, &models.AzureUser{
 			ID:                "azure123",
 			DisplayName:       "Azure Test User",
 			Mail:              "azure.user@example.com",
 			UserPrincipalName: "azure.user@example.com",
-		}
-		mockFetchAzureUserProfile(mockedAzureUser, nil)
-		defer restoreFetchAzureUserProfile()
-
-		r := setupTestRouter()
-		r.GET("/oauth/azure/callback", HandleAzureLogin)
-
-		w := httptest.NewRecorder()
-		req, _ := http.NewRequest(http.MethodGet, "/oauth/azure/callback?code=auth_code", nil)
-		r.ServeHTTP(w, req)
+		})
 
+		w := azureCallback(p)
 		assert.Equal(t, http.StatusOK, w.Code)
 
 		var resp models.LoginResponse
@@ -172,33 +170,22 @@ func TestHandleAzureLogin(t *testing.T) {
 		assert.True(t, sessionCookieFound, "Session cookie should be set")
 	})
 
-	t.Run("missing code", func(t *testing.T) {
-		r := setupTestRouter()
-		r.GET("/oauth/azure/callback", HandleAzureLogin)
-		w := httptest.NewRecorder()
-		req, _ := http.NewRequest(http.MethodGet, "/oauth/azure/callback", nil)
-		r.ServeHTTP(w, req)
+	t.Run("user principal name used without mail", func(t *testing.T) {
+		newAzureTokenServer(t, http.StatusOK)
+		p := newAzureGraphFake(t, &models.AzureUser{ID: "azure123", UserPrincipalName: "upn.user@example.com"})
 
-		assert.Equal(t, http.StatusBadRequest, w.Code)
-		var resp models.SimpleMessageResponse
+		w := azureCallback(p)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp models.LoginResponse
 		err := json.Unmarshal(w.Body.Bytes(), &resp)
 		assert.NoError(t, err)
-		assert.Equal(t, "Code query parameter is required", resp.Error)
+		assert.Equal(t, "upn.user@example.com", resp.UserData.Email)
 	})
 
 	t.Run("token exchange fails", func(t *testing.T) {
-		mockTokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusInternalServerError)
-		}))
-		defer mockTokenServer.Close()
-		os.Setenv("AZURE_TOKEN_URL", mockTokenServer.URL)
-
-		r := setupTestRouter()
-		r.GET("/oauth/azure/callback", HandleAzureLogin)
-		w := httptest.NewRecorder()
-		req, _ := http.NewRequest(http.MethodGet, "/oauth/azure/callback?code=auth_code", nil)
-		r.ServeHTTP(w, req)
+		newAzureTokenServer(t, http.StatusInternalServerError)
 
+		w := azureCallback(newAzureProvider())
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
 		var resp models.SimpleMessageResponse
 		err := json.Unmarshal(w.Body.Bytes(), &resp)
@@ -207,60 +194,21 @@ func TestHandleAzureLogin(t *testing.T) {
 	})
 
 	t.Run("fetch user profile fails", func(t *testing.T) {
-		mockTokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Content-Type", "application/json")
-			err := json.NewEncoder(w).Encode(map[string]interface{}{
-				"access_token": "valid_token_for_profile_fail",
-				"token_type":   "Bearer",
-				"expires_in":   3600,
-			})
-			assert.NoError(t, err)
-		}))
-		defer mockTokenServer.Close()
-		os.Setenv("AZURE_TOKEN_URL", mockTokenServer.URL)
-
-		mockFetchAzureUserProfile(nil, fmt.Errorf("graph API error"))
-		defer restoreFetchAzureUserProfile()
-
-		r := setupTestRouter()
-		r.GET("/oauth/azure/callback", HandleAzureLogin)
-		w := httptest.NewRecorder()
-		req, _ := http.NewRequest(http.MethodGet, "/oauth/azure/callback?code=auth_code", nil)
-		r.ServeHTTP(w, req)
+		newAzureTokenServer(t, http.StatusOK)
 
+		w := azureCallback(newAzureGraphFake(t, nil))
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
 		var resp models.SimpleMessageResponse
 		err := json.Unmarshal(w.Body.Bytes(), &resp)
 		assert.NoError(t, err)
-		assert.Equal(t, "graph API error", resp.Error)
+		assert.Contains(t, resp.Error, "graph API error")
 	})
 
 	t.Run("unauthorized domain", func(t *testing.T) {
-		mockTokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Content-Type", "application/json")
-			err := json.NewEncoder(w).Encode(map[string]interface{}{
-				"access_token": "valid_token_for_unauth_domain",
-				"token_type":   "Bearer",
-				"expires_in":   3600,
-			})
-			assert.NoError(t, err)
-		}))
-		defer mockTokenServer.Close()
-		os.Setenv("AZURE_TOKEN_URL", mockTokenServer.URL)
-
-		mockedAzureUser := &models.AzureUser{Mail: "user@otherdomain.com", UserPrincipalName: "user@otherdomain.com"}
-		mockFetchAzureUserProfile(mockedAzureUser, nil)
-		defer restoreFetchAzureUserProfile()
-
-		mockIsAllowedUser(false)
-		defer restoreIsAllowedUser()
-
-		r := setupTestRouter()
-		r.GET("/oauth/azure/callback", HandleAzureLogin)
-		w := httptest.NewRecorder()
-		req, _ := http.NewRequest(http.MethodGet, "/oauth/azure/callback?code=auth_code", nil)
-		r.ServeHTTP(w, req)
+		newAzureTokenServer(t, http.StatusOK)
+		p := newAzureGraphFake(t, &models.AzureUser{Mail: "user@otherdomain.com", UserPrincipalName: "user@otherdomain.com"})
 
+		w := azureCallback(p)
 		assert.Equal(t, http.StatusForbidden, w.Code)
 		var resp models.SimpleMessageResponse
 		err := json.Unmarshal(w.Body.Bytes(), &resp)
@@ -269,36 +217,32 @@ func TestHandleAzureLogin(t *testing.T) {
 	})
 }
 
-func TestGetAzureProfile(t *testing.T) {
+func TestAzureProfile(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	restoreEnv := setupAzureTestEnv()
 	defer restoreEnv()
 
-	t.Run("success", func(t *testing.T) {
-		mockedAzureUser := &models.AzureUser{
-			ID:          "azure123",
-			DisplayName: "Azure Test User",
-			Mail:        "azure.user@example.com",
-		}
-		mockFetchAzureUserProfile(mockedAzureUser, nil)
-		defer restoreFetchAzureUserProfile()
-
+	profile := func(p *azureProvider, sessionData map[string]interface{}) *httptest.ResponseRecorder {
 		r := setupTestRouter()
 		r.Use(func(c *gin.Context) {
-			sessionData := map[string]interface{}{
-				"token": "fake-azure-profile-token",
-				"id":    "azure123",
-				"name":  "Azure Test User",
-			}
 			c.Set("sessionData", sessionData)
 			c.Next()
 		})
-		r.GET("/azure/profile", GetAzureProfile)
-
+		r.GET("/azure/profile", GetProfile(p))
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest(http.MethodGet, "/azure/profile", nil)
 		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("success", func(t *testing.T) {
+		p := newAzureGraphFake(t, &models.AzureUser{
+			ID:          "azure123",
+			DisplayName: "Azure Test User",
+			Mail:        "azure.user@example.com",
+		})
 
+		w := profile(p, map[string]interface{}{"token": "mocked_access_token", "id": "azure123", "name": "Azure Test User"})
 		assert.Equal(t, http.StatusOK, w.Code)
 		var resp map[string]any
 		err := json.Unmarshal(w.Body.Bytes(), &resp)
@@ -310,17 +254,7 @@ func TestGetAzureProfile(t *testing.T) {
 	})
 
 	t.Run("no token in session", func(t *testing.T) {
-		r := setupTestRouter()
-		r.Use(func(c *gin.Context) {
-			c.Set("sessionData", map[string]interface{}{"id": "azure123", "name": "Test"})
-			c.Next()
-		})
-		r.GET("/azure/profile", GetAzureProfile)
-
-		w := httptest.NewRecorder()
-		req, _ := http.NewRequest(http.MethodGet, "/azure/profile", nil)
-		r.ServeHTTP(w, req)
-
+		w := profile(newAzureProvider(), map[string]interface{}{"id": "azure123", "name": "Test"})
 		assert.Equal(t, http.StatusUnauthorized, w.Code)
 		var respMsg models.SimpleMessageResponse
 		err := json.Unmarshal(w.Body.Bytes(), &respMsg)
@@ -328,143 +262,27 @@ func TestGetAzureProfile(t *testing.T) {
 		assert.Equal(t, "Unauthorized: no token in session data", respMsg.Error)
 	})
 
-	t.Run("fetchAzureUserProfile fails", func(t *testing.T) {
-		mockFetchAzureUserProfile(nil, fmt.Errorf("graph API error"))
-		defer restoreFetchAzureUserProfile()
-
-		r := setupTestRouter()
-		r.Use(func(c *gin.Context) {
-			c.Set("sessionData", map[string]interface{}{"token": "valid-token", "id": "azure123", "name": "Test"})
-			c.Next()
-		})
-		r.GET("/azure/profile", GetAzureProfile)
-
-		w := httptest.NewRecorder()
-		req, _ := http.NewRequest(http.MethodGet, "/azure/profile", nil)
-		r.ServeHTTP(w, req)
-
+	t.Run("graph API fails", func(t *testing.T) {
+		w := profile(newAzureGraphFake(t, nil), map[string]interface{}{"token": "valid-token", "id": "azure123", "name": "Test"})
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
 		var respMsg models.SimpleMessageResponse
 		err := json.Unmarshal(w.Body.Bytes(), &respMsg)
 		assert.NoError(t, err)
-		assert.Equal(t, "graph API error", respMsg.Error)
+		assert.Contains(t, respMsg.Error, "graph API error")
 	})
 }
 
-func TestFetchAzureUserProfile(t *testing.T) {
-	t.Run("success", func(t *testing.T) {
-		expectedUser := models.AzureUser{
-			ID:                "azure-user-id",
-			DisplayName:       "Azure Test User",
-			Mail:              "azure.test@example.com",
-			UserPrincipalName: "azure.test@example.com",
-		}
-		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			assert.Equal(t, "/v1.0/me", r.URL.Path)
-			assert.Equal(t, "Bearer fake-access-token", r.Header.Get("Authorization"))
-			w.WriteHeader(http.StatusOK)
-			err := json.NewEncoder(w).Encode(expectedUser)
-			assert.NoError(t, err)
-		}))
-		defer server.Close()
-
-		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, server.Client())
-		client := oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "fake-access-token"}))
-
-		resp, err := client.Get(server.URL + "/v1.0/me")
-		assert.NoError(t, err)
-		defer resp.Body.Close()
-
-		var user models.AzureUser
-		err = json.NewDecoder(resp.Body).Decode(&user)
-		assert.NoError(t, err)
-		assert.Equal(t, expectedUser, user)
-	})
-
-	t.Run("http error", func(t *testing.T) {
-		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusInternalServerError)
-		}))
-		defer server.Close()
-	})
-}
-
-func TestGetAzureGroups(t *testing.T) {
+func TestAzureProvider_FetchGroups(t *testing.T) {
 	logger := getTestLogger()
 
-	t.Run("success", func(t *testing.T) {
-		expectedGroups := []models.Team{
+	t.Run("follows every page", func(t *testing.T) {
+		p := newAzureGraphFake(t, nil)
+		groups, err := p.FetchGroups(context.Background(), map[string]any{"token": "mocked_access_token"}, logger)
+		assert.NoError(t, err)
+		assert.Equal(t, []models.Team{
 			{ID: "group1", Name: "Azure Group One"},
 			{ID: "group2", Name: "Azure Group Two"},
-		}
-		mockResponse := struct {
-			Value []struct {
-				ID          string `json:"id"`
-				DisplayName string `json:"displayName"`
-			} `json:"value"`
-		}{
-			Value: []struct {
-				ID          string `json:"id"`
-				DisplayName string `json:"displayName"`
-			}{
-				{ID: "group1", DisplayName: "Azure Group One"},
-				{ID: "group2", DisplayName: "Azure Group Two"},
-			},
-		}
-
-		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			assert.Equal(t, "/v1.0/me/memberOf", r.URL.Path)
-			assert.Equal(t, "Bearer fake-access-token", r.Header.Get("Authorization"))
-			w.WriteHeader(http.StatusOK)
-			err := json.NewEncoder(w).Encode(mockResponse)
-			assert.NoError(t, err)
-		}))
-		defer server.Close()
-
-		originalGetAzureGroups := GetAzureGroups
-		defer func() { GetAzureGroups = originalGetAzureGroups }()
-
-		GetAzureGroups = func(token string, reqLogger *zap.Logger) ([]models.Team, error) {
-			ctx := context.WithValue(context.Background(), oauth2.HTTPClient, server.Client())
-			client := oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}))
-			resp, err := client.Get(server.URL + "/v1.0/me/memberOf")
-			if err != nil {
-				reqLogger.Error("Failed to fetch Azure groups", zap.Error(err))
-				return nil, fmt.Errorf("failed to fetch groups from Azure AD")
-			}
-			defer resp.Body.Close()
-
-			if resp.StatusCode != http.StatusOK {
-				bodyBytes := make([]byte, 1024)
-				n, _ := resp.Body.Read(bodyBytes)
-				reqLogger.Warn("Error fetching Azure groups", zap.String("response", string(bodyBytes[:n])))
-				return nil, fmt.Errorf("error fetching groups from Azure AD: status %d", resp.StatusCode)
-			}
-
-			var groupsResponse struct {
-				Value []struct {
-					ID          string `json:"id"`
-					DisplayName string `json:"displayName"`
-				} `json:"value"`
-			}
-			if err := json.NewDecoder(resp.Body).Decode(&groupsResponse); err != nil {
-				reqLogger.Error("Failed to decode Azure groups response", zap.Error(err))
-				return nil, fmt.Errorf("failed to decode groups response")
-			}
-
-			var teams []models.Team
-			for _, g := range groupsResponse.Value {
-				teams = append(teams, models.Team{
-					ID:   g.ID,
-					Name: g.DisplayName,
-				})
-			}
-			return teams, nil
-		}
-
-		groups, err := GetAzureGroups("fake-access-token", logger)
-		assert.NoError(t, err)
-		assert.Equal(t, expectedGroups, groups)
+		}, groups)
 	})
 
 	t.Run("http error", func(t *testing.T) {
@@ -473,24 +291,8 @@ func TestGetAzureGroups(t *testing.T) {
 		}))
 		defer server.Close()
 
-		originalGetAzureGroups := GetAzureGroups
-		defer func() { GetAzureGroups = originalGetAzureGroups }()
-		GetAzureGroups = func(token string, reqLogger *zap.Logger) ([]models.Team, error) {
-			ctx := context.WithValue(context.Background(), oauth2.HTTPClient, server.Client())
-			client := oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}))
-			resp, err := client.Get(server.URL + "/v1.0/me/memberOf")
-			if err != nil {
-				return nil, fmt.Errorf("failed to fetch groups from Azure AD")
-			}
-			defer resp.Body.Close()
-
-			if resp.StatusCode != http.StatusOK {
-				return nil, fmt.Errorf("error fetching groups from Azure AD")
-			}
-			return nil, nil
-		}
-
-		_, err := GetAzureGroups("fake-access-token", logger)
+		p := &azureProvider{graphURL: server.URL}
+		_, err := p.FetchGroups(context.Background(), map[string]any{"token": "fake-access-token"}, logger)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "error fetching groups from Azure AD")
 	})
@@ -502,27 +304,23 @@ func TestGetAzureGroups(t *testing.T) {
 		}))
 		defer server.Close()
 
-		originalGetAzureGroups := GetAzureGroups
-		defer func() { GetAzureGroups = originalGetAzureGroups }()
-		GetAzureGroups = func(token string, reqLogger *zap.Logger) ([]models.Team, error) {
-			ctx := context.WithValue(context.Background(), oauth2.HTTPClient, server.Client())
-			client := oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}))
-			resp, err := client.Get(server.URL + "/v1.0/me/memberOf")
-			if err != nil {
-				return nil, fmt.Errorf("failed to fetch groups from Azure AD")
-			}
-			defer resp.Body.Close()
-
-			if resp.StatusCode != http.StatusOK {
-				return nil, fmt.Errorf("error fetching groups from Azure AD")
-			}
-			if err := json.NewDecoder(resp.Body).Decode(&struct{}{}); err != nil {
-				return nil, fmt.Errorf("failed to decode groups response")
-			}
-			return nil, nil
-		}
-		_, err := GetAzureGroups("fake-access-token", logger)
+		p := &azureProvider{graphURL: server.URL}
+		_, err := p.FetchGroups(context.Background(), map[string]any{"token": "fake-access-token"}, logger)
 		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "failed to decode groups response")
+		assert.Contains(t, err.Error(), "failed to decode")
 	})
 }
+
+func TestAzureProvider_IsAllowed(t *testing.T) {
+	restoreEnv := setupAzureTestEnv()
+	defer restoreEnv()
+
+	p := newAzureProvider()
+	allowed, err := p.IsAllowed(context.Background(), nil, &Identity{User: models.NormalizedUserData{Email: "user@example.com"}})
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+
+	allowed, err = p.IsAllowed(context.Background(), nil, &Identity{User: models.NormalizedUserData{Email: "user@other.com"}})
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+}
@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 	"kube-jit/internal/db"
+	"kube-jit/internal/metrics"
 	"kube-jit/internal/models"
 	"kube-jit/pkg/email"
 	"kube-jit/pkg/k8s"
@@ -14,49 +16,75 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+	"golang.org/x/oauth2"
 )
 
 var (
 	// Get OAuth values environment variables
-	oauthProvider = utils.MustGetEnv("OAUTH_PROVIDER")
-	clientID      = utils.MustGetEnv("OAUTH_CLIENT_ID")
-	clientSecret  = utils.MustGetEnv("OAUTH_CLIENT_SECRET")
-	redirectUri   = utils.MustGetEnv("OAUTH_REDIRECT_URI")
-	allowedDomain string
-	allowedOrg    string
-	httpClient    = &http.Client{
-		Timeout: 60 * time.Second,
+	// OAUTH_PROVIDER accepts a comma separated list to enable several providers at once (e.g. "azure,github")
+	oauthProviders     = parseProviders(utils.MustGetEnv("OAUTH_PROVIDER"))
+	oauthClients       = loadOAuthClients(oauthProviders)
+	allowedDomain      string
+	allowedOrg         string
+	allowedGitlabGroup string
+	httpClient         = &http.Client{
+		Timeout:   60 * time.Second,
+		Transport: metrics.InstrumentOAuthTransport("unknown", nil),
 	}
 )
 
-// OauthClientIdResponse represents the response for GetOauthClientId
-type OauthClientIdResponse struct {
+// oauthContext returns ctx with oauth2 using the instrumented httpClient
+// and the outgoing calls labelled with the provider
+func oauthContext(ctx context.Context, provider string) context.Context {
+	return context.WithValue(metrics.WithProvider(ctx, provider), oauth2.HTTPClient, httpClient)
+}
+
+// OauthProviderConfig represents the login configuration of one identity provider
+type OauthProviderConfig struct {
 	ClientID    string `json:"client_id"`
 	Provider    string `json:"provider"`
 	RedirectURI string `json:"redirect_uri"`
 	AuthURL     string `json:"auth_url"`
 }
 
+// OauthClientIdResponse represents the response for GetOauthClientId
+// The top level fields describe the first configured provider for single provider frontends
+type OauthClientIdResponse struct {
+	ClientID    string                `json:"client_id"`
+	Provider    string                `json:"provider"`
+	RedirectURI string                `json:"redirect_uri"`
+	AuthURL     string                `json:"auth_url"`
+	Providers   []OauthProviderConfig `json:"providers"`
+}
+
 // BuildShaResponse represents the response for GetBuildSha
 type BuildShaResponse struct {
 	Sha string `json:"sha"`
 }
 
 func init() {
-	// Set the admin email for Google OAuth provider
-	if oauthProvider == "google" {
+	loadAllowedLogins()
+}
+
+// loadAllowedLogins sets the allowed domain, org or group required by each enabled provider
+func loadAllowedLogins() {
+	if isProviderEnabled("google") || isProviderEnabled("azure") {
 		allowedDomain = utils.MustGetEnv("ALLOWED_DOMAIN")
-	} else if oauthProvider == "github" {
+	}
+	if isProviderEnabled("github") {
 		allowedOrg = utils.MustGetEnv("ALLOWED_GITHUB_ORG")
-	} else if oauthProvider == "azure" {
-		allowedDomain = utils.MustGetEnv("ALLOWED_DOMAIN")
+	}
+	if isProviderEnabled("gitlab") {
+		allowedGitlabGroup = utils.MustGetEnv("ALLOWED_GITLAB_GROUP")
 	}
 }
 
 // ClustersAndRolesResponse represents the response for clusters and roles
 type ClustersAndRolesResponse struct {
-	Clusters []string       `json:"clusters"`
-	Roles    []models.Roles `json:"roles"`
+	Clusters      []string                     `json:"clusters"`
+	ClusterHealth []k8s.ClusterHealth          `json:"clusterHealth"` // in the order of Clusters
+	ClusterLabels map[string]map[string]string `json:"clusterLabels"` // labels of the clusters having some, matched by clusterSelector
+	Roles         []models.Roles               `json:"roles"`
 }
 
 // K8sCallback godoc
@@ -105,7 +133,7 @@ func K8sCallback(c *gin.Context) {
 		return
 	}
 
-	if err := db.DB.Model(&models.RequestData{}).Where("id = ?", callbackData.TicketID).Updates(map[string]interface{}{
+	if err := db.DB.WithContext(c.Request.Context()).Model(&models.RequestData{}).Where("id = ?", callbackData.TicketID).Updates(map[string]interface{}{
 		"status": callbackData.Status,
 		"notes":  callbackData.Message,
 	}).Error; err != nil {
@@ -124,7 +152,7 @@ func K8sCallback(c *gin.Context) {
 
 	// Send status change email to user
 	var req models.RequestData
-	if err := db.DB.Where("id = ?", callbackData.TicketID).First(&req).Error; err == nil && req.Email != "" {
+	if err := db.DB.WithContext(c.Request.Context()).Where("id = ?", callbackData.TicketID).First(&req).Error; err == nil && req.Email != "" {
 		body := email.BuildRequestEmail(email.EmailRequestDetails{
 			Username:      req.Username,
 			ClusterName:   req.ClusterName,
@@ -149,35 +177,64 @@ func K8sCallback(c *gin.Context) {
 // GetOauthClientId godoc
 // @Summary Get OAuth client configuration
 // @Description Returns the OAuth client_id, provider, redirect URI, and auth URL for the frontend to initiate login.
+// @Description When several providers are enabled, each one is listed in providers; the top level fields describe the first.
 // @Tags auth
 // @Accept  json
 // @Produce  json
 // @Success 200 {object} handlers.OauthClientIdResponse "OAuth client configuration"
 // @Router /client_id [get]
 func GetOauthClientId(c *gin.Context) {
-	authURL := ""
-	// For Azure, specifically:
-	if oauthProvider == "azure" {
-		authURL = getAzureOAuthConfig().Endpoint.AuthURL
+	providers := []OauthProviderConfig{}
+	for _, provider := range oauthProviders {
+		client := oauthClient(provider)
+		providers = append(providers, OauthProviderConfig{
+			ClientID:    client.ClientID,
+			Provider:    provider,
+			RedirectURI: client.RedirectURI,
+			AuthURL:     providerAuthURL(c.Request.Context(), provider),
+		})
 	}
 
-	response := OauthClientIdResponse{
-		ClientID:    clientID,
-		Provider:    oauthProvider,
-		RedirectURI: redirectUri,
-		AuthURL:     authURL,
+	response := OauthClientIdResponse{Providers: providers}
+	if len(providers) > 0 {
+		response.ClientID = providers[0].ClientID
+		response.Provider = providers[0].Provider
+		response.RedirectURI = providers[0].RedirectURI
+		response.AuthURL = providers[0].AuthURL
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
+// providerAuthURL returns the authorization endpoint the frontend redirects to, if the provider needs one
+func providerAuthURL(ctx context.Context, provider string) string {
+	switch provider {
+	case "azure":
+		return getAzureOAuthConfig().Endpoint.AuthURL
+	case "gitlab":
+		return getGitlabOAuthConfig().Endpoint.AuthURL
+	case "oidc":
+		// The frontend should use /oauth/oidc/login which adds state, nonce and PKCE
+		oidcProvider, err := getOIDCProvider(ctx)
+		if err != nil {
+			logger.Warn("Failed to get OIDC provider for client_id", zap.Error(err))
+			return ""
+		}
+		return oidcProvider.Endpoint().AuthURL
+	default:
+		return ""
+	}
+}
+
 // GetClustersAndRoles godoc
 // @Summary Get available clusters and roles
 // @Description Returns the list of clusters and roles available to the user.
+// @Description clusterHealth reports the clusters whose client could not be created, requests for them fail until their retry succeeds.
 // @Description Requires one or more cookies named kube_jit_session_<number> (e.g., kube_jit_session_0, kube_jit_session_1).
 // @Description Pass split cookies in the Cookie header, for example:
 // @Description     -H "Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}"
 // @Description Note: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.
+// @Description Scripts can use an API token with the submit scope instead: -H "Authorization: Bearer ${token}"
 // @Tags records
 // @Accept  json
 // @Produce  json
@@ -186,9 +243,17 @@ func GetOauthClientId(c *gin.Context) {
 // @Failure 401 {object} models.SimpleMessageResponse "Unauthorized: no token in session data"
 // @Router /roles-and-clusters [get]
 func GetClustersAndRoles(c *gin.Context) {
+	settings := k8s.CurrentSettings()
 	response := ClustersAndRolesResponse{
-		Clusters: k8s.ClusterNames,
-		Roles:    k8s.AllowedRoles,
+		Clusters:      settings.ClusterNames,
+		ClusterHealth: k8s.ClustersHealth(settings.ClusterNames),
+		ClusterLabels: map[string]map[string]string{},
+		Roles:         settings.AllowedRoles,
+	}
+	for _, name := range settings.ClusterNames {
+		if labels := settings.ClusterConfigs[name].Labels; len(labels) > 0 {
+			response.ClusterLabels[name] = labels
+		}
 	}
 	c.JSON(http.StatusOK, response)
 }
@@ -231,7 +296,7 @@ func GetApprovingGroups(c *gin.Context) {
 	}
 
 	// Return the platform approving groups
-	c.JSON(http.StatusOK, k8s.PlatformApproverTeams)
+	c.JSON(http.StatusOK, k8s.CurrentSettings().PlatformApproverTeams)
 }
 
 // HealthCheck godoc
@@ -246,6 +311,19 @@ func HealthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, models.SimpleMessageResponse{Status: "healthy"})
 }
 
+// ConfigStatus godoc
+// @Summary Configuration status
+// @Description Returns the version and hash of the apiConfig.yaml in use, and the error of the last reload if it failed.
+// @Description A failed reload keeps the previous configuration, the error is cleared by the next successful reload.
+// @Tags health
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} k8s.ConfigStatus "Configuration in use"
+// @Router /healthz/config [get]
+func ConfigStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, k8s.CurrentConfigStatus())
+}
+
 // contains checks if a string is present in a slice of strings
 func contains(slice []string, item string) bool {
 	for _, a := range slice {
@@ -15,240 +15,229 @@ import (
 	"golang.org/x/oauth2" // For getAzureOAuthConfig, getGoogleOAuthConfig if their mocks are needed
 )
 
-// TestCommonInitLogic tests the logic within the init() function of common.go
+// TestCommonInitLogic tests loadAllowedLogins, the logic run by the init() function of common.go,
 // by checking the values of allowedDomain and allowedOrg after setting
-// OAUTH_PROVIDER and the corresponding domain/org env vars.
+// the enabled providers and the corresponding domain/org env vars.
 func TestCommonInitLogic(t *testing.T) {
-	originalOauthProvider := oauthProvider
+	originalOauthProviders := oauthProviders
 	originalAllowedDomain := allowedDomain
 	originalAllowedOrg := allowedOrg
+	originalAllowedGitlabGroup := allowedGitlabGroup
 
 	// Store original ENV VARS
-	originalOauthProviderEnv := os.Getenv("OAUTH_PROVIDER")
 	originalAllowedDomainEnv := os.Getenv("ALLOWED_DOMAIN")
 	originalAllowedGithubOrgEnv := os.Getenv("ALLOWED_GITHUB_ORG")
+	originalAllowedGitlabGroupEnv := os.Getenv("ALLOWED_GITLAB_GROUP")
 
 	defer func() {
-		oauthProvider = originalOauthProvider
+		oauthProviders = originalOauthProviders
 		allowedDomain = originalAllowedDomain
 		allowedOrg = originalAllowedOrg
-		os.Setenv("OAUTH_PROVIDER", originalOauthProviderEnv)
+		allowedGitlabGroup = originalAllowedGitlabGroup
 		os.Setenv("ALLOWED_DOMAIN", originalAllowedDomainEnv)
 		os.Setenv("ALLOWED_GITHUB_ORG", originalAllowedGithubOrgEnv)
+		os.Setenv("ALLOWED_GITLAB_GROUP", originalAllowedGitlabGroupEnv)
 	}()
 
 	tests := []struct {
-		name                 string
-		providerEnv          string
-		domainEnv            string
-		orgEnv               string
-		expectedDomain       string
-		expectedOrg          string
-		reassignOauthVar     bool   // Flag to indicate if we should reassign oauthProvider package var
-		oauthVarValue        string // Value to assign to oauthProvider package var
-		runInitLogicManually func() // Simulates re-running init logic for package vars
+		name           string
+		providerEnv    string
+		domainEnv      string
+		orgEnv         string
+		groupEnv       string
+		expectedDomain string
+		expectedOrg    string
+		expectedGroup  string
 	}{
 		{
-			name:             "Google Provider",
-			providerEnv:      "google",
-			domainEnv:        "google-domain.com",
-			orgEnv:           "",
-			expectedDomain:   "google-domain.com",
-			expectedOrg:      "",
-			reassignOauthVar: true,
-			oauthVarValue:    "google",
-			runInitLogicManually: func() { // Manually simulate init logic for package vars
-				if oauthProvider == "google" {
-					allowedDomain = os.Getenv("ALLOWED_DOMAIN")
-					allowedOrg = "" // Explicitly clear for test isolation
-				}
-			},
+			name:           "Google Provider",
+			providerEnv:    "google",
+			domainEnv:      "google-domain.com",
+			expectedDomain: "google-domain.com",
 		},
 		{
-			name:             "GitHub Provider",
-			providerEnv:      "github",
-			domainEnv:        "",
-			orgEnv:           "github-org",
-			expectedDomain:   "",
-			expectedOrg:      "github-org",
-			reassignOauthVar: true,
-			oauthVarValue:    "github",
-			runInitLogicManually: func() {
-				if oauthProvider == "github" {
-					allowedOrg = os.Getenv("ALLOWED_GITHUB_ORG")
-					allowedDomain = "" // Explicitly clear
-				}
-			},
+			name:        "GitHub Provider",
+			providerEnv: "github",
+			orgEnv:      "github-org",
+			expectedOrg: "github-org",
 		},
 		{
-			name:             "Azure Provider",
-			providerEnv:      "azure",
-			domainEnv:        "azure-domain.com",
-			orgEnv:           "",
-			expectedDomain:   "azure-domain.com",
-			expectedOrg:      "",
-			reassignOauthVar: true,
-			oauthVarValue:    "azure",
-			runInitLogicManually: func() {
-				if oauthProvider == "azure" {
-					allowedDomain = os.Getenv("ALLOWED_DOMAIN")
-					allowedOrg = "" // Explicitly clear
-				}
-			},
+			name:           "Azure Provider",
+			providerEnv:    "azure",
+			domainEnv:      "azure-domain.com",
+			expectedDomain: "azure-domain.com",
 		},
 		{
-			name:             "Unknown Provider",
-			providerEnv:      "unknown",
-			domainEnv:        "any-domain.com",
-			orgEnv:           "any-org",
-			expectedDomain:   "", // Expecting them to be reset/cleared
-			expectedOrg:      "",
-			reassignOauthVar: true,
-			oauthVarValue:    "unknown",
-			runInitLogicManually: func() {
-				// For unknown, init doesn't set them, so they should remain as they were or be cleared
-				allowedDomain = ""
-				allowedOrg = ""
-			},
+			name:           "Azure and GitHub Providers",
+			providerEnv:    "azure, GitHub",
+			domainEnv:      "azure-domain.com",
+			orgEnv:         "github-org",
+			expectedDomain: "azure-domain.com",
+			expectedOrg:    "github-org",
+		},
+		{
+			name:          "GitLab Provider",
+			providerEnv:   "gitlab",
+			groupEnv:      "gitlab-group",
+			expectedGroup: "gitlab-group",
+		},
+		{
+			name:        "Unknown Provider",
+			providerEnv: "unknown",
+			domainEnv:   "any-domain.com",
+			orgEnv:      "any-org",
+			// Expecting them to stay cleared
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Set environment variables for the test
-			os.Setenv("OAUTH_PROVIDER", tt.providerEnv)
 			os.Setenv("ALLOWED_DOMAIN", tt.domainEnv)
 			os.Setenv("ALLOWED_GITHUB_ORG", tt.orgEnv)
+			os.Setenv("ALLOWED_GITLAB_GROUP", tt.groupEnv)
+			oauthProviders = parseProviders(tt.providerEnv)
+			allowedDomain = ""
+			allowedOrg = ""
+			allowedGitlabGroup = ""
 
-			// Re-assign package variable `oauthProvider` if needed for the test scenario
-			// This simulates the state *after* utils.MustGetEnv has run for oauthProvider
-			if tt.reassignOauthVar {
-				oauthProvider = tt.oauthVarValue
-			}
-
-			// Manually run the logic that init() would perform on package variables
-			// This is because init() itself only runs once per package load.
-			tt.runInitLogicManually()
+			loadAllowedLogins()
 
 			assert.Equal(t, tt.expectedDomain, allowedDomain, "allowedDomain mismatch")
 			assert.Equal(t, tt.expectedOrg, allowedOrg, "allowedOrg mismatch")
-
-			// Clean up env vars for next test iteration
-			os.Unsetenv("OAUTH_PROVIDER")
-			os.Unsetenv("ALLOWED_DOMAIN")
-			os.Unsetenv("ALLOWED_GITHUB_ORG")
-			// Reset package vars to a known state before the next sub-test's re-assignment
-			allowedDomain = ""
-			allowedOrg = ""
+			assert.Equal(t, tt.expectedGroup, allowedGitlabGroup, "allowedGitlabGroup mismatch")
 		})
 	}
 }
 
+func TestParseProviders(t *testing.T) {
+	assert.Equal(t, []string{"azure"}, parseProviders("azure"))
+	assert.Equal(t, []string{"azure", "github"}, parseProviders(" Azure , github,azure,"))
+	assert.Empty(t, parseProviders(""))
+}
+
+func TestLoadOAuthClients(t *testing.T) {
+	t.Setenv("OAUTH_CLIENT_ID", "shared-id")
+	t.Setenv("OAUTH_CLIENT_SECRET", "shared-secret")
+	t.Setenv("OAUTH_REDIRECT_URI", "http://localhost/callback")
+	t.Setenv("OAUTH_GITHUB_CLIENT_ID", "github-id")
+	t.Setenv("OAUTH_GITHUB_CLIENT_SECRET", "github-secret")
+
+	clients := loadOAuthClients([]string{"azure", "github"})
+
+	assert.Equal(t, oauthClientConfig{ClientID: "shared-id", ClientSecret: "shared-secret", RedirectURI: "http://localhost/callback"}, clients["azure"])
+	assert.Equal(t, oauthClientConfig{ClientID: "github-id", ClientSecret: "github-secret", RedirectURI: "http://localhost/callback"}, clients["github"])
+}
+
+func TestEnabledProviders(t *testing.T) {
+	originalOauthProviders := oauthProviders
+	defer func() { oauthProviders = originalOauthProviders }()
+
+	oauthProviders = []string{"github", "unknown", "oidc"}
+	enabled := EnabledProviders()
+
+	if assert.Len(t, enabled, 2) {
+		assert.Equal(t, "github", enabled[0].Name)
+		assert.NotNil(t, enabled[0].Callback)
+		assert.Nil(t, enabled[0].Login)
+		assert.Equal(t, "oidc", enabled[1].Name)
+		assert.NotNil(t, enabled[1].Login)
+	}
+}
+
 func TestGetOauthClientId(t *testing.T) {
 	// Backup original values
-	originalOauthProvider := oauthProvider
-	originalClientID := clientID
-	originalRedirectURI := redirectUri
-	originalAzureAuthURL := os.Getenv("AZURE_AUTH_URL")
-	originalAzureTokenURL := os.Getenv("AZURE_TOKEN_URL")
+	originalOauthProviders := oauthProviders
+	originalOauthClients := oauthClients
 
 	// Mock getAzureOAuthConfig
 	originalGetAzureCfg := getAzureOAuthConfig
 	defer func() {
 		getAzureOAuthConfig = originalGetAzureCfg
-		oauthProvider = originalOauthProvider
-		clientID = originalClientID
-		redirectUri = originalRedirectURI
-		os.Setenv("AZURE_AUTH_URL", originalAzureAuthURL)
-		os.Setenv("AZURE_TOKEN_URL", originalAzureTokenURL)
+		oauthProviders = originalOauthProviders
+		oauthClients = originalOauthClients
 	}()
+	getAzureOAuthConfig = func() *oauth2.Config {
+		// Only AuthURL is used by GetOauthClientId
+		return &oauth2.Config{Endpoint: oauth2.Endpoint{AuthURL: "https://azure.auth.url/authorize"}}
+	}
+
+	oauthClients = map[string]oauthClientConfig{
+		"azure":  {ClientID: "azure-id", RedirectURI: "http://localhost/azure"},
+		"github": {ClientID: "github-id", RedirectURI: "http://localhost/github"},
+		"google": {ClientID: "google-id", RedirectURI: "http://localhost/google"},
+	}
+	azure := OauthProviderConfig{ClientID: "azure-id", Provider: "azure", RedirectURI: "http://localhost/azure", AuthURL: "https://azure.auth.url/authorize"}
+	github := OauthProviderConfig{ClientID: "github-id", Provider: "github", RedirectURI: "http://localhost/github"}
+	google := OauthProviderConfig{ClientID: "google-id", Provider: "google", RedirectURI: "http://localhost/google"}
 
 	r := setupTestRouter()
 	r.GET("/client_id", GetOauthClientId)
 
 	testCases := []struct {
-		name              string
-		setupProvider     string
-		setupClientID     string
-		setupRedirectURI  string
-		setupAzureAuthURL string // For getAzureOAuthConfig().Endpoint.AuthURL
-		expectedStatus    int
-		expectedResponse  OauthClientIdResponse
+		name             string
+		setupProviders   []string
+		expectedResponse OauthClientIdResponse
 	}{
 		{
-			name:              "Azure provider",
-			setupProvider:     "azure",
-			setupClientID:     "azure-id",
-			setupRedirectURI:  "http://localhost/azure",
-			setupAzureAuthURL: "https://azure.auth.url/authorize",
-			expectedStatus:    http.StatusOK,
+			name:           "Azure provider",
+			setupProviders: []string{"azure"},
 			expectedResponse: OauthClientIdResponse{
 				ClientID:    "azure-id",
 				Provider:    "azure",
 				RedirectURI: "http://localhost/azure",
 				AuthURL:     "https://azure.auth.url/authorize",
+				Providers:   []OauthProviderConfig{azure},
 			},
 		},
 		{
-			name:             "GitHub provider",
-			setupProvider:    "github",
-			setupClientID:    "github-id",
-			setupRedirectURI: "http://localhost/github",
-			expectedStatus:   http.StatusOK,
+			name:           "GitHub provider",
+			setupProviders: []string{"github"},
 			expectedResponse: OauthClientIdResponse{
 				ClientID:    "github-id",
 				Provider:    "github",
 				RedirectURI: "http://localhost/github",
-				AuthURL:     "", // AuthURL is empty for non-Azure providers in current logic
+				AuthURL:     "", // AuthURL is empty for GitHub
+				Providers:   []OauthProviderConfig{github},
 			},
 		},
 		{
-			name:             "Google provider",
-			setupProvider:    "google",
-			setupClientID:    "google-id",
-			setupRedirectURI: "http://localhost/google",
-			expectedStatus:   http.StatusOK,
+			name:           "Google provider",
+			setupProviders: []string{"google"},
 			expectedResponse: OauthClientIdResponse{
 				ClientID:    "google-id",
 				Provider:    "google",
 				RedirectURI: "http://localhost/google",
-				AuthURL:     "", // AuthURL is empty for non-Azure providers
+				AuthURL:     "", // AuthURL is empty for Google
+				Providers:   []OauthProviderConfig{google},
+			},
+		},
+		{
+			name:           "GitHub and Azure providers",
+			setupProviders: []string{"github", "azure"},
+			expectedResponse: OauthClientIdResponse{
+				ClientID:    "github-id",
+				Provider:    "github",
+				RedirectURI: "http://localhost/github",
+				Providers:   []OauthProviderConfig{github, azure},
 			},
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			// Set up package variables for this test case
-			oauthProvider = tc.setupProvider
-			clientID = tc.setupClientID
-			redirectUri = tc.setupRedirectURI
-			os.Setenv("AZURE_AUTH_URL", tc.setupAzureAuthURL)
-			// AZURE_TOKEN_URL is also needed by getAzureOAuthConfig
-			os.Setenv("AZURE_TOKEN_URL", "https://dummy.token.url")
-
-			// If provider is Azure, ensure getAzureOAuthConfig returns a config with the expected AuthURL
-			if tc.setupProvider == "azure" {
-				getAzureOAuthConfig = func() *oauth2.Config {
-					return &oauth2.Config{
-						Endpoint: oauth2.Endpoint{AuthURL: tc.setupAzureAuthURL},
-						// Other fields can be minimal as only AuthURL is used by GetOauthClientId
-					}
-				}
-			}
+			oauthProviders = tc.setupProviders
 
 			w := httptest.NewRecorder()
 			req, _ := http.NewRequest(http.MethodGet, "/client_id", nil)
 			r.ServeHTTP(w, req)
 
-			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.Equal(t, http.StatusOK, w.Code)
 
-			if tc.expectedStatus == http.StatusOK {
-				var resp OauthClientIdResponse
-				err := json.Unmarshal(w.Body.Bytes(), &resp)
-				assert.NoError(t, err)
-				assert.Equal(t, tc.expectedResponse, resp)
-			}
+			var resp OauthClientIdResponse
+			err := json.Unmarshal(w.Body.Bytes(), &resp)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedResponse, resp)
 		})
 	}
 }
@@ -405,20 +394,14 @@ func TestGetClustersAndRoles(t *testing.T) {
 	r := setupTestRouter()
 	r.GET("/roles-and-clusters", GetClustersAndRoles)
 
-	// Backup and defer restoration of k8s package variables
-	originalClusterNames := k8s.ClusterNames
-	originalAllowedRoles := k8s.AllowedRoles
-	defer func() {
-		k8s.ClusterNames = originalClusterNames
-		k8s.AllowedRoles = originalAllowedRoles
-	}()
-
-	// Set mock data for k8s package variables
-	k8s.ClusterNames = []string{"cluster-alpha", "cluster-beta"}
-	k8s.AllowedRoles = []models.Roles{
-		{Name: "role-viewer"},
-		{Name: "role-editor"},
-	}
+	// Set mock data for the k8s configuration, restored after the test
+	useSettings(t, func(s *k8s.Settings) {
+		s.ClusterNames = []string{"cluster-alpha", "cluster-beta"}
+		s.AllowedRoles = []models.Roles{
+			{Name: "role-viewer"},
+			{Name: "role-editor"},
+		}
+	})
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodGet, "/roles-and-clusters", nil)
@@ -430,8 +413,12 @@ func TestGetClustersAndRoles(t *testing.T) {
 	err := json.Unmarshal(w.Body.Bytes(), &resp)
 	assert.NoError(t, err)
 
-	assert.Equal(t, k8s.ClusterNames, resp.Clusters)
-	assert.Equal(t, k8s.AllowedRoles, resp.Roles)
+	assert.Equal(t, k8s.CurrentSettings().ClusterNames, resp.Clusters)
+	assert.Equal(t, k8s.CurrentSettings().AllowedRoles, resp.Roles)
+	assert.Equal(t, []k8s.ClusterHealth{
+		{Name: "cluster-alpha", Healthy: true},
+		{Name: "cluster-beta", Healthy: true},
+	}, resp.ClusterHealth)
 }
 
 func TestGetBuildSha(t *testing.T) {
@@ -475,13 +462,12 @@ func TestGetApprovingGroups(t *testing.T) {
 	r := setupTestRouter()
 	r.GET("/approving-groups", GetApprovingGroups)
 
-	originalPlatformApproverTeams := k8s.PlatformApproverTeams
-	defer func() { k8s.PlatformApproverTeams = originalPlatformApproverTeams }()
-
-	k8s.PlatformApproverTeams = []models.Team{
-		{ID: "team-plat-1", Name: "Platform Approvers Alpha"},
-		{ID: "team-plat-2", Name: "Platform Approvers Beta"},
-	}
+	useSettings(t, func(s *k8s.Settings) {
+		s.PlatformApproverTeams = []models.Team{
+			{ID: "team-plat-1", Name: "Platform Approvers Alpha"},
+			{ID: "team-plat-2", Name: "Platform Approvers Beta"},
+		}
+	})
 
 	t.Run("User logged in with token", func(t *testing.T) {
 		w := httptest.NewRecorder()
@@ -511,7 +497,7 @@ func TestGetApprovingGroups(t *testing.T) {
 		var resp []models.Team
 		err := json.Unmarshal(recorder.Body.Bytes(), &resp)
 		assert.NoError(t, err)
-		assert.Equal(t, k8s.PlatformApproverTeams, resp)
+		assert.Equal(t, k8s.CurrentSettings().PlatformApproverTeams, resp)
 	})
 
 	t.Run("User not logged in - no token", func(t *testing.T) {
@@ -549,3 +535,25 @@ func TestHealthCheck(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "healthy", resp.Status)
 }
+
+func TestConfigStatus(t *testing.T) {
+	useSettings(t, func(s *k8s.Settings) {
+		s.Version = 4
+		s.Hash = "abc123"
+		s.ClusterNames = []string{"cluster-alpha", "cluster-beta"}
+	})
+	r := setupTestRouter()
+	r.GET("/healthz/config", ConfigStatus)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/healthz/config", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp k8s.ConfigStatus
+	err := json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), resp.Version)
+	assert.Equal(t, "abc123", resp.Hash)
+	assert.Equal(t, 2, resp.Clusters)
+}
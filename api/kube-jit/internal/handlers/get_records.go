@@ -14,11 +14,14 @@ import (
 // GetRecords godoc
 // @Summary Get JIT requests for a user
 // @Description Returns the latest JIT requests for a user with optional limit and date range.
+// @Description Users with the history:read permission see the requests of all users, limited to the clusters and roles of their API roles.
 // @Description Requires one or more cookies named kube_jit_session_<number> (e.g., kube_jit_session_0, kube_jit_session_1).
 // @Description Pass split cookies in the Cookie header, for example:
 // @Description     -H "Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}"
 // @Description Note: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies:
 // @Description Login required to test via browser, else test via curl
+// @Description Scripts can use an API token with the history scope instead: -H "Authorization: Bearer ${token}"
+// @Description Service principals with the history scope only see the requests they submitted.
 // @Tags records
 // @Accept  json
 // @Produce  json
@@ -34,8 +37,7 @@ func GetRecords(c *gin.Context) {
 	sessionData := GetSessionData(c)
 	reqLogger := RequestLogger(c)
 
-	isAdmin, _ := sessionData["isAdmin"].(bool)
-	isPlatformApprover, _ := sessionData["isPlatformApprover"].(bool)
+	access := AccessOf(c)
 	userID := c.Query("userID")
 	username := c.Query("username")
 	limit := c.Query("limit")
@@ -48,14 +50,31 @@ func GetRecords(c *gin.Context) {
 	}
 
 	var requests []models.RequestData
-	query := db.DB.Order("created_at desc").Limit(limitInt)
-	if isAdmin || isPlatformApprover {
+	query := db.DB.WithContext(c.Request.Context()).Order("created_at desc").Limit(limitInt)
+	if access.CanAll(models.PermissionReadHistory) {
 		if userID != "" {
 			query = query.Where("user_id = ?", userID)
 		}
 		if username != "" {
 			query = query.Where("username = ?", username)
 		}
+	} else if access.Can(models.PermissionReadHistory) {
+		// Auditors of some clusters or roles see every request for them, and their own requests
+		condition, args := access.scopeCondition(models.PermissionReadHistory)
+		ownID, _ := sessionData["id"].(string)
+		query = query.Where("("+condition+") OR user_id = ?", append(args, ownID)...)
+		if userID != "" {
+			query = query.Where("user_id = ?", userID)
+		}
+		if username != "" {
+			query = query.Where("username = ?", username)
+		}
+	} else if servicePrincipal, _ := sessionData["servicePrincipal"].(string); servicePrincipal != "" {
+		// Service principals only see the requests they submitted
+		query = query.Where("submitted_by = ?", servicePrincipal)
+		if userID != "" {
+			query = query.Where("user_id = ?", userID)
+		}
 	} else {
 		if userID != "" {
 			query = query.Where("user_id = ? OR approver_ids @> ?", userID, fmt.Sprintf(`["%s"]`, userID))
@@ -76,7 +95,7 @@ func GetRecords(c *gin.Context) {
 
 	for _, req := range requests {
 		var nsApprovals []models.NamespaceApprovalInfo
-		if err := db.DB.Table("request_namespaces").
+		if err := db.DB.WithContext(c.Request.Context()).Table("request_namespaces").
 			Select("namespace, group_name, group_id, approved, approver_id, approver_name").
 			Where("request_id = ?", req.ID).
 			Find(&nsApprovals).Error; err != nil {
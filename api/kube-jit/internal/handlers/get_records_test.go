@@ -161,6 +161,12 @@ func TestGetRecords(t *testing.T) {
 	requestDataCols := []string{"id", "created_at", "updated_at", "deleted_at", "approver_ids", "approver_names", "cluster_name", "role_name", "status", "notes", "user_id", "username", "users", "namespaces", "justification", "start_date", "end_date", "fully_approved", "email"}
 	nsApprovalCols := []string{"namespace", "group_name", "group_id", "approved", "approver_id", "approver_name"}
 
+	useApiRoles(t, models.ApiRole{
+		Name:        "cluster-b-auditor",
+		Permissions: []string{models.PermissionReadHistory},
+		Clusters:    []string{"cluster-b"},
+	})
+
 	testCases := []struct {
 		name                string
 		setupSession        func(t *testing.T, c *gin.Context)
@@ -232,6 +238,39 @@ func TestGetRecords(t *testing.T) {
 			},
 			expectDBInteraction: true,
 		},
+		{
+			name: "Auditor of a cluster, userID filter",
+			setupSession: func(t *testing.T, c *gin.Context) {
+				session := sessions.Default(c)
+				sessionData := map[string]any{"id": "auditor1", "apiRoles": []string{"cluster-b-auditor"}}
+				session.Set("sessionData", sessionData)
+				assert.NoError(t, session.Save())
+			},
+			queryParams: "?userID=user2&limit=1",
+			mockDB: func(t *testing.T, mock sqlmock.Sqlmock) {
+				usersJSON2, _ := json.Marshal(sampleRecord2.Users)
+				approverIDsJSON2, _ := json.Marshal(sampleRecord2.ApproverIDs)
+				approverNamesJSON2, _ := json.Marshal(sampleRecord2.ApproverNames)
+				namespacesJSON2, _ := json.Marshal(sampleRecord2.Namespaces)
+
+				rowsRd := sqlmock.NewRows(requestDataCols).
+					AddRow(sampleRecord2.ID, sampleRecord2.CreatedAt, sampleRecord2.UpdatedAt, sampleRecord2.DeletedAt, approverIDsJSON2, approverNamesJSON2, sampleRecord2.ClusterName, sampleRecord2.RoleName, sampleRecord2.Status, sampleRecord2.Notes, sampleRecord2.UserID, sampleRecord2.Username, usersJSON2, namespacesJSON2, sampleRecord2.Justification, sampleRecord2.StartDate, sampleRecord2.EndDate, sampleRecord2.FullyApproved, sampleRecord2.Email)
+				// The requests for the clusters of the auditor, or their own
+				mock.ExpectQuery(`SELECT \* FROM "request_data" WHERE \(\(\(cluster_name IN \(\$1\)\)\) OR user_id = \$2\) AND user_id = \$3 ORDER BY created_at desc LIMIT \$4`).
+					WithArgs("cluster-b", "auditor1", "user2", 1).WillReturnRows(rowsRd)
+
+				rowsNs := sqlmock.NewRows(nsApprovalCols).
+					AddRow(sampleNsApproval1ForRecord2.Namespace, sampleNsApproval1ForRecord2.GroupName, sampleNsApproval1ForRecord2.GroupID, sampleNsApproval1ForRecord2.Approved, sampleNsApproval1ForRecord2.ApproverID, sampleNsApproval1ForRecord2.ApproverName).
+					AddRow(sampleNsApproval2ForRecord2.Namespace, sampleNsApproval2ForRecord2.GroupName, sampleNsApproval2ForRecord2.GroupID, sampleNsApproval2ForRecord2.Approved, sampleNsApproval2ForRecord2.ApproverID, sampleNsApproval2ForRecord2.ApproverName)
+				mock.ExpectQuery(`SELECT namespace, group_name, group_id, approved, approver_id, approver_name FROM "request_namespaces" WHERE request_id = \$1`).
+					WithArgs(sampleRecord2.ID).WillReturnRows(rowsNs)
+			},
+			expectedStatus: http.StatusOK,
+			expectedJSONBody: []models.RequestWithNamespaceApprovers{
+				{RequestData: sampleRecord2, NamespaceApprovals: []models.NamespaceApprovalInfo{sampleNsApproval1ForRecord2, sampleNsApproval2ForRecord2}},
+			},
+			expectDBInteraction: true,
+		},
 		{
 			name: "Non-admin user, userID filter (own record)",
 			setupSession: func(t *testing.T, c *gin.Context) {
@@ -341,6 +380,23 @@ func TestGetRecords(t *testing.T) {
 			expectedJSONBody:    models.SimpleMessageResponse{Error: "Failed to fetch namespace approvals"},
 			expectDBInteraction: true,
 		},
+		{
+			name: "Service principal only sees the requests it submitted",
+			setupSession: func(t *testing.T, c *gin.Context) {
+				session := sessions.Default(c)
+				sessionData := map[string]any{"isAdmin": false, "isPlatformApprover": false, "servicePrincipal": "incident-bot"}
+				session.Set("sessionData", sessionData)
+				assert.NoError(t, session.Save())
+			},
+			queryParams: "?userID=user1",
+			mockDB: func(t *testing.T, mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM "request_data" WHERE submitted_by = \$1 AND user_id = \$2 ORDER BY created_at desc LIMIT \$3`).
+					WithArgs("incident-bot", "user1", 1).WillReturnRows(sqlmock.NewRows(requestDataCols))
+			},
+			expectedStatus:      http.StatusOK,
+			expectedJSONBody:    []models.RequestWithNamespaceApprovers{},
+			expectDBInteraction: true,
+		},
 		{
 			name: "No records found",
 			setupSession: func(t *testing.T, c *gin.Context) {
@@ -6,314 +6,243 @@ import (
 	"fmt"
 	"io"
 	"kube-jit/internal/models"
-	"kube-jit/pkg/sessioncookie"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/gin-contrib/sessions"
-	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+	"golang.org/x/oauth2"
 )
 
-// Helper to fetch and decode GitHub user profile
-func fetchGitHubUserProfile(token string) (*models.GitHubUser, error) {
-	req, err := http.NewRequest("GET", "https://api.github.com/user", nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request for GitHub user: %w", err)
-	}
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch GitHub user: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("error fetching user data from GitHub: %s", string(body))
-	}
-
-	var githubUser models.GitHubUser
-	if err := json.NewDecoder(resp.Body).Decode(&githubUser); err != nil {
-		return nil, fmt.Errorf("failed to decode GitHub user: %w", err)
-	}
-	return &githubUser, nil
+func init() {
+	RegisterProvider(newGithubProvider())
 }
 
-// Helper to fetch primary/verified email if not present in user profile
-func fetchGitHubPrimaryEmail(token string) (string, error) {
-	req, err := http.NewRequest("GET", "https://api.github.com/user/emails", nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to create request for GitHub emails: %w", err)
-	}
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to fetch GitHub emails: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("error fetching emails from GitHub: %s", string(body))
-	}
-
-	var emails []struct {
-		Email    string `json:"email"`
-		Primary  bool   `json:"primary"`
-		Verified bool   `json:"verified"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&emails); err != nil {
-		return "", fmt.Errorf("failed to decode GitHub emails: %w", err)
-	}
-	for _, e := range emails {
-		if e.Primary && e.Verified {
-			return e.Email, nil
-		}
-	}
-	for _, e := range emails {
-		if e.Verified {
-			return e.Email, nil
-		}
-	}
-	return "", fmt.Errorf("no verified email found")
+// githubProvider implements IdentityProvider for GitHub OAuth apps
+// Users must be a member of the ALLOWED_GITHUB_ORG organization, teams are used as groups
+type githubProvider struct {
+	oauthURL string
+	apiURL   string
 }
 
-// Fetch GitHub teams for a user using their OAuth token
-// This function is used to get the teams associated with the authenticated user
-// It sends a GET request to the GitHub API endpoint for user teams
-// and returns a slice of models.Team
-// Each team is represented by its ID and name
-// It returns an error if the request fails or if the response is not as expected
-var GetGithubTeams = func(token string, reqLogger *zap.Logger) ([]models.Team, error) {
-	req, err := http.NewRequest("GET", "https://api.github.com/user/teams", nil)
-	if err != nil {
-		reqLogger.Error("Failed to create request for GitHub teams", zap.Error(err))
-		return nil, err
-	}
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		reqLogger.Error("Failed to fetch GitHub teams", zap.Error(err))
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		reqLogger.Warn("Error fetching teams from GitHub", zap.String("response", string(body)))
-		return nil, fmt.Errorf("error fetching teams from GitHub: %s", string(body))
-	}
-
-	var githubTeams []struct {
-		ID   int    `json:"id"`
-		Name string `json:"name"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&githubTeams); err != nil {
-		reqLogger.Error("Failed to decode GitHub teams", zap.Error(err))
-		return nil, err
+func newGithubProvider() *githubProvider {
+	return &githubProvider{
+		oauthURL: "https://github.com",
+		apiURL:   "https://api.github.com",
 	}
+}
 
-	var teams []models.Team
-	for _, t := range githubTeams {
-		teams = append(teams, models.Team{
-			ID:   strconv.Itoa(t.ID),
-			Name: t.Name,
-		})
-	}
-	return teams, nil
+func (p *githubProvider) Name() string {
+	return "github"
 }
 
-// HandleGitHubLogin godoc
-// @Summary GitHub OAuth callback
-// @Description Handles the GitHub OAuth callback, exchanges the code for an access token, fetches user info, sets session data, and returns normalized user data and expiration time.
-// @Tags github
-// @Accept  json
-// @Produce  json
-// @Param   code query string true "GitHub OAuth authorization code"
-// @Success 200 {object} models.LoginResponse "Normalized user data and expiration time"
-// @Failure 400 {object} models.SimpleMessageResponse "Missing or invalid code"
-// @Failure 403 {object} models.SimpleMessageResponse "Unauthorized org"
-// @Failure 500 {object} models.SimpleMessageResponse "Internal server error"
-// @Router /oauth/github/callback [get]
-func HandleGitHubLogin(c *gin.Context) {
-	// Check for the presence of the 'code' query parameter
-	code := c.Query("code")
-	if code == "" {
-		logger.Warn("Missing 'code' query parameter in GitHub login")
-		c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: "Code query parameter is required"})
-		return
-	}
+func (p *githubProvider) DeniedMessage() string {
+	return "Unauthorized org"
+}
 
-	// Get the client ID and secret from global variables
-	ctx := context.Background()
+// ExchangeCode exchanges the code for an access token
+// GitHub only returns an expiry for apps with expiring user tokens enabled
+func (p *githubProvider) ExchangeCode(ctx context.Context, code string, _ ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	client := oauthClient("github")
 	data := url.Values{
-		"client_id":     {clientID},
-		"client_secret": {clientSecret},
+		"client_id":     {client.ClientID},
+		"client_secret": {client.ClientSecret},
 		"code":          {code},
 	}
 	// Send a POST request to GitHub to exchange the code for an access token
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://github.com/login/oauth/access_token", strings.NewReader(data.Encode()))
+	req, err := http.NewRequestWithContext(oauthContext(ctx, "github"), "POST", p.oauthURL+"/login/oauth/access_token", strings.NewReader(data.Encode()))
 	if err != nil {
-		logger.Error("Failed to create request for GitHub access token", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to create request for access token"})
-		return
+		return nil, fmt.Errorf("failed to create request for GitHub access token: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Accept", "application/json")
 
 	resp, err := httpClient.Do(req)
 	if err != nil {
-		logger.Error("Failed to fetch GitHub access token", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to fetch access token"})
-		return
+		return nil, fmt.Errorf("failed to fetch GitHub access token: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		logger.Warn("Error fetching access token from GitHub", zap.Int("status", resp.StatusCode))
-		c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: "Error fetching access token from GitHub"})
-		return
+		return nil, &LoginError{
+			Status:  http.StatusBadRequest,
+			Message: "Error fetching access token from GitHub",
+			Err:     fmt.Errorf("unexpected status %d", resp.StatusCode),
+		}
 	}
 
 	// Decode the response body to get the access token
 	var tokenData models.GitHubTokenResponse
 	if err := json.NewDecoder(resp.Body).Decode(&tokenData); err != nil {
-		logger.Error("Failed to decode GitHub token response", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to decode access token"})
-		return
+		return nil, fmt.Errorf("failed to decode GitHub token response: %w", err)
 	}
 
-	// Fetch user profile
-	githubUser, err := fetchGitHubUserProfile(tokenData.AccessToken)
+	token := &oauth2.Token{AccessToken: tokenData.AccessToken, TokenType: tokenData.TokenType}
+	if tokenData.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(tokenData.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// FetchProfile fetches the GitHub user, falling back to the primary verified email
+// if the email is not public
+func (p *githubProvider) FetchProfile(ctx context.Context, token *oauth2.Token) (*Identity, error) {
+	githubUser, err := p.fetchUser(ctx, token.AccessToken)
 	if err != nil {
-		logger.Error("Failed to get GitHub user info", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: err.Error()})
-		return
+		return nil, err
 	}
 
-	// Fetch email if not present in profile
 	email := githubUser.Email
 	if email == "" {
-		email, _ = fetchGitHubPrimaryEmail(tokenData.AccessToken)
+		email, _ = p.fetchPrimaryEmail(ctx, token.AccessToken)
 	}
 
-	normalizedUserData := models.NormalizedUserData{
+	return &Identity{User: models.NormalizedUserData{
 		ID:        strconv.Itoa(githubUser.ID),
 		Name:      githubUser.Login,
 		Email:     email,
 		AvatarURL: githubUser.AvatarURL,
 		Provider:  "github",
-	}
+	}}, nil
+}
 
-	sessionData := map[string]interface{}{
-		"token": tokenData.AccessToken,
-		"email": email,
-		"id":    normalizedUserData.ID,
-		"name":  normalizedUserData.Name,
-	}
+// FetchGroups returns the GitHub teams of the logged in user, including the parents of nested teams
+// as members of a child team are also members of its parent team
+func (p *githubProvider) FetchGroups(ctx context.Context, sessionData map[string]any, reqLogger *zap.Logger) ([]models.Team, error) {
+	token, _ := sessionData["token"].(string)
 
-	// Save the session data in the session
-	session := sessions.Default(c)
-	session.Set("data", sessionData)
-	sessioncookie.SplitSessionData(c)
+	githubTeams, err := fetchAllPages[githubTeam](ctx, p.pages(token), p.apiURL+"/user/teams?per_page=100")
+	if err != nil {
+		reqLogger.Warn("Error fetching teams from GitHub", zap.Error(err))
+		return nil, err
+	}
 
-	logger.Debug("Session cookies set successfully for GitHub login", zap.String("user", githubUser.Login))
+	var teams []models.Team
+	seen := map[int]bool{}
+	add := func(t *githubTeam) {
+		seen[t.ID] = true
+		teams = append(teams, models.Team{
+			ID:   strconv.Itoa(t.ID),
+			Name: t.Name,
+		})
+	}
+	for i := range githubTeams {
+		add(&githubTeams[i])
+	}
+	for _, t := range githubTeams {
+		// The parent in a team listing has no parent of its own, fetch each ancestor for the next one
+		for parent := t.Parent; parent != nil && !seen[parent.ID]; {
+			add(parent)
+			var ancestor githubTeam
+			if err := p.get(ctx, "/orgs/"+t.Organization.Login+"/teams/"+parent.Slug, token, &ancestor); err != nil {
+				reqLogger.Warn("Error fetching parent team from GitHub", zap.String("team", parent.Slug), zap.Error(err))
+				return nil, err
+			}
+			parent = ancestor.Parent
+		}
+	}
+	return teams, nil
+}
 
-	// Fetch orgs for the user
-	orgReq, err := http.NewRequest("GET", "https://api.github.com/user/orgs", nil)
+// IsAllowed checks the user is a member of the allowed GitHub org
+func (p *githubProvider) IsAllowed(ctx context.Context, token *oauth2.Token, _ *Identity) (bool, error) {
+	orgs, err := fetchAllPages[githubOrg](ctx, p.pages(token.AccessToken), p.apiURL+"/user/orgs?per_page=100")
 	if err != nil {
-		logger.Error("Failed to create request for GitHub orgs", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to create request for orgs"})
-		return
+		return false, &LoginError{Status: http.StatusInternalServerError, Message: "Failed to fetch orgs", Err: err}
 	}
-	orgReq.Header.Set("Authorization", tokenData.TokenType+" "+tokenData.AccessToken)
-	orgResp, err := httpClient.Do(orgReq)
-	if err != nil {
-		logger.Error("Failed to fetch GitHub orgs", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to fetch orgs"})
-		return
+	for _, org := range orgs {
+		if org.Login == allowedOrg {
+			return true, nil
+		}
 	}
-	defer orgResp.Body.Close()
+	return false, nil
+}
 
-	var orgs []struct {
-		Login string `json:"login"`
+// fetchUser fetches and decodes the GitHub user profile
+func (p *githubProvider) fetchUser(ctx context.Context, token string) (*models.GitHubUser, error) {
+	var githubUser models.GitHubUser
+	if err := p.get(ctx, "/user", token, &githubUser); err != nil {
+		return nil, fmt.Errorf("error fetching user data from GitHub: %w", err)
 	}
-	if err := json.NewDecoder(orgResp.Body).Decode(&orgs); err != nil {
-		logger.Error("Failed to decode GitHub orgs", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to decode orgs"})
-		return
+	return &githubUser, nil
+}
+
+// fetchPrimaryEmail fetches the primary/verified email if not present in user profile
+func (p *githubProvider) fetchPrimaryEmail(ctx context.Context, token string) (string, error) {
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
 	}
-	orgNames := []string{}
-	for _, org := range orgs {
-		orgNames = append(orgNames, org.Login)
+	if err := p.get(ctx, "/user/emails", token, &emails); err != nil {
+		return "", fmt.Errorf("error fetching emails from GitHub: %w", err)
 	}
-	extraInfo := map[string]any{"orgs": orgNames}
-
-	if !isAllowedUser("github", email, extraInfo) {
-		logger.Warn("Login attempt from unauthorized GitHub org", zap.String("email", email))
-		c.JSON(http.StatusForbidden, models.SimpleMessageResponse{Error: "Unauthorized org"})
-		return
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			return e.Email, nil
+		}
 	}
+	for _, e := range emails {
+		if e.Verified {
+			return e.Email, nil
+		}
+	}
+	return "", fmt.Errorf("no verified email found")
+}
 
-	// return the normalized user data and expiration time
-	c.JSON(http.StatusOK, models.LoginResponse{
-		UserData:  normalizedUserData,
-		ExpiresIn: tokenData.ExpiresIn,
-	})
+// githubOrg is an organization in the GitHub API
+type githubOrg struct {
+	Login string `json:"login"`
 }
 
-// GetGithubProfile godoc
-// @Summary Get the logged in user's GitHub profile
-// @Description Returns the normalized GitHub user profile for the authenticated user.
-// @Description Requires one or more cookies named kube_jit_session_<number> (e.g., kube_jit_session_0, kube_jit_session_1).
-// @Description Pass split cookies in the Cookie header, for example:
-// @Description     -H "Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}"
-// @Description Note: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.
-// @Tags github
-// @Accept  json
-// @Produce  json
-// @Param   Cookie header string true "Session cookies (multiple allowed, names: kube_jit_session_0, kube_jit_session_1, etc.)"
-// @Success 200 {object} models.NormalizedUserData
-// @Failure 401 {object} models.SimpleMessageResponse "Unauthorized: no token in session data"
-// @Failure 500 {object} models.SimpleMessageResponse "Internal server error"
-// @Router /github/profile [get]
-func GetGithubProfile(c *gin.Context) {
-	// Check if the user is logged in
-	sessionData := GetSessionData(c)
-	reqLogger := RequestLogger(c)
+// githubTeam is a team in the GitHub API, the parent is set for nested teams
+type githubTeam struct {
+	ID           int         `json:"id"`
+	Name         string      `json:"name"`
+	Slug         string      `json:"slug"`
+	Parent       *githubTeam `json:"parent"`
+	Organization struct {
+		Login string `json:"login"`
+	} `json:"organization"`
+}
 
-	token, ok := sessionData["token"].(string)
-	if !ok || token == "" {
-		reqLogger.Warn("No token in session data for GitHub profile")
-		c.JSON(http.StatusUnauthorized, models.SimpleMessageResponse{Error: "Unauthorized: no token in session data"})
-		return
+// get sends an authenticated GET request to the GitHub API and decodes the response into out
+func (p *githubProvider) get(ctx context.Context, path, token string, out any) error {
+	_, err := p.fetch(ctx, p.apiURL+path, token, out)
+	return err
+}
+
+// pages returns a pageFetcher following the GitHub Link header with token
+func (p *githubProvider) pages(token string) pageFetcher {
+	return func(ctx context.Context, url string, out any) (string, error) {
+		return p.fetch(ctx, url, token, out)
 	}
+}
 
-	githubUser, err := fetchGitHubUserProfile(token)
+// fetch sends an authenticated GET request to url, decodes the response into out
+// and returns the url of the next page if any
+func (p *githubProvider) fetch(ctx context.Context, url, token string, out any) (string, error) {
+	req, err := http.NewRequestWithContext(oauthContext(ctx, "github"), "GET", url, nil)
 	if err != nil {
-		reqLogger.Error("Failed to fetch GitHub user profile", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: err.Error()})
-		return
+		return "", fmt.Errorf("failed to create request for %s: %w", url, err)
 	}
+	req.Header.Set("Authorization", "Bearer "+token)
 
-	email := githubUser.Email
-	if email == "" {
-		email, _ = fetchGitHubPrimaryEmail(token)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch %s: %w", url, err)
 	}
+	defer resp.Body.Close()
 
-	normalizedUserData := models.NormalizedUserData{
-		ID:        strconv.Itoa(githubUser.ID),
-		Name:      githubUser.Login,
-		Email:     email,
-		AvatarURL: githubUser.AvatarURL,
-		Provider:  "github",
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("%s returned %d: %s", url, resp.StatusCode, string(body))
 	}
 
-	c.JSON(http.StatusOK, normalizedUserData)
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return "", fmt.Errorf("failed to decode %s: %w", url, err)
+	}
+	return nextLink(resp.Header), nil
 }
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"kube-jit/internal/models"
@@ -15,18 +16,17 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/jarcoal/httpmock"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
 )
 
-// TestHandleGitHubLogin tests the GitHub login handler
-func TestHandleGitHubLogin(t *testing.T) {
+// TestGitHubLogin tests the login flow of the GitHub provider
+func TestGitHubLogin(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	// --- Original values storage and deferred restoration ---
 	// Package variables
-	originalOauthProvider := oauthProvider
-	originalClientID := clientID
-	originalClientSecret := clientSecret
-	originalRedirectURI := redirectUri // Though GitHub handler doesn't use this one directly from var
+	originalOauthProviders := oauthProviders
+	originalOauthClients := oauthClients
 	originalAllowedOrg := allowedOrg
 	originalAllowedDomain := allowedDomain // To restore Azure's setting
 
@@ -41,10 +41,8 @@ func TestHandleGitHubLogin(t *testing.T) {
 	// Mocked functions' original states
 	defer func() {
 		// Restore package variables
-		oauthProvider = originalOauthProvider
-		clientID = originalClientID
-		clientSecret = originalClientSecret
-		redirectUri = originalRedirectURI
+		oauthProviders = originalOauthProviders
+		oauthClients = originalOauthClients
 		allowedOrg = originalAllowedOrg
 		allowedDomain = originalAllowedDomain
 
@@ -60,17 +58,18 @@ func TestHandleGitHubLogin(t *testing.T) {
 	}()
 
 	// --- Setup for GitHub tests ---
-	oauthProvider = "github"
-	clientID = "test-github-client-id"
-	clientSecret = "test-github-client-secret"
-	// redirectUri is not directly used by HandleGitHubLogin's oauth flow in the same way as Azure's oauth2.Config
+	oauthProviders = []string{"github"}
+	// RedirectURI is not used by the GitHub token exchange in the same way as Azure's oauth2.Config
+	oauthClients = map[string]oauthClientConfig{
+		"github": {ClientID: "test-github-client-id", ClientSecret: "test-github-client-secret"},
+	}
 	allowedOrg = "test-valid-org"
 	allowedDomain = "" // Clear Azure's setting for this test scope
 
 	os.Setenv("OAUTH_PROVIDER", "github")
 	os.Setenv("OAUTH_CLIENT_ID", "test-github-client-id")
 	os.Setenv("OAUTH_CLIENT_SECRET", "test-github-client-secret")
-	os.Setenv("ALLOWED_GITHUB_ORG", "test-valid-org") // For common.go init() and isAllowedUser
+	os.Setenv("ALLOWED_GITHUB_ORG", "test-valid-org") // For common.go init() and githubProvider.IsAllowed
 	os.Setenv("ALLOWED_DOMAIN", "")                   // Ensure Azure's domain doesn't interfere
 
 	// Activate httpmock
@@ -85,8 +84,8 @@ func TestHandleGitHubLogin(t *testing.T) {
 			func(req *http.Request) (*http.Response, error) {
 				bodyBytes, _ := io.ReadAll(req.Body)
 				params, _ := url.ParseQuery(string(bodyBytes))
-				assert.Equal(t, clientID, params.Get("client_id"))
-				assert.Equal(t, clientSecret, params.Get("client_secret"))
+				assert.Equal(t, oauthClient("github").ClientID, params.Get("client_id"))
+				assert.Equal(t, oauthClient("github").ClientSecret, params.Get("client_secret"))
 				assert.Equal(t, "test_code", params.Get("code"))
 
 				respBody := models.GitHubTokenResponse{
@@ -113,7 +112,7 @@ func TestHandleGitHubLogin(t *testing.T) {
 			},
 		)
 
-		// 3. Mock GitHub user orgs fetch (for isAllowedUser)
+		// 3. Mock GitHub user orgs fetch (for githubProvider.IsAllowed)
 		httpmock.RegisterResponder("GET", "https://api.github.com/user/orgs",
 			func(req *http.Request) (*http.Response, error) {
 				assert.Equal(t, "Bearer gh_mock_access_token", req.Header.Get("Authorization"))
@@ -129,7 +128,7 @@ func TestHandleGitHubLogin(t *testing.T) {
 
 		// Setup router and make request
 		router := setupTestRouter() // Assumes setupTestRouter is available and includes session middleware
-		router.GET("/oauth/github/callback", HandleGitHubLogin)
+		router.GET("/oauth/github/callback", OAuthCallback(newGithubProvider()))
 
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("GET", "/oauth/github/callback?code=test_code", nil)
@@ -160,7 +159,7 @@ func TestHandleGitHubLogin(t *testing.T) {
 
 	t.Run("missing code query parameter", func(t *testing.T) {
 		router := setupTestRouter()
-		router.GET("/oauth/github/callback", HandleGitHubLogin)
+		router.GET("/oauth/github/callback", OAuthCallback(newGithubProvider()))
 
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("GET", "/oauth/github/callback", nil)
@@ -180,13 +179,13 @@ func TestHandleGitHubLogin(t *testing.T) {
 		)
 
 		router := setupTestRouter()
-		router.GET("/oauth/github/callback", HandleGitHubLogin)
+		router.GET("/oauth/github/callback", OAuthCallback(newGithubProvider()))
 
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("GET", "/oauth/github/callback?code=bad_code", nil)
 		router.ServeHTTP(w, req)
 
-		assert.Equal(t, http.StatusBadRequest, w.Code) // HandleGitHubLogin returns 400 for this
+		assert.Equal(t, http.StatusBadRequest, w.Code) // the GitHub provider returns 400 for this
 		var respData models.SimpleMessageResponse
 		err := json.Unmarshal(w.Body.Bytes(), &respData)
 		assert.NoError(t, err)
@@ -208,7 +207,7 @@ func TestHandleGitHubLogin(t *testing.T) {
 		)
 
 		router := setupTestRouter()
-		router.GET("/oauth/github/callback", HandleGitHubLogin)
+		router.GET("/oauth/github/callback", OAuthCallback(newGithubProvider()))
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("GET", "/oauth/github/callback?code=test_code", nil)
 		router.ServeHTTP(w, req)
@@ -256,7 +255,7 @@ func TestHandleGitHubLogin(t *testing.T) {
 		)
 
 		router := setupTestRouter()
-		router.GET("/oauth/github/callback", HandleGitHubLogin)
+		router.GET("/oauth/github/callback", OAuthCallback(newGithubProvider()))
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("GET", "/oauth/github/callback?code=test_code", nil)
 		router.ServeHTTP(w, req)
@@ -270,8 +269,114 @@ func TestHandleGitHubLogin(t *testing.T) {
 	})
 
 	// Add more test cases:
-	// - Email not in primary profile, successfully fetched from /user/emails
 	// - Email fetch from /user/emails fails
 	// - No verified email found
-	// - Fetch orgs fails
+}
+
+// newGithubAPIFake serves the GitHub API endpoints used by githubProvider
+// and returns a provider pointed at it
+func newGithubAPIFake(t *testing.T, routes map[string]string) *githubProvider {
+	t.Helper()
+	mux := http.NewServeMux()
+	for path, body := range routes {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "Bearer gh_token", r.Header.Get("Authorization"))
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, body)
+		})
+	}
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return &githubProvider{oauthURL: server.URL, apiURL: server.URL}
+}
+
+func TestGithubProvider_FetchProfile(t *testing.T) {
+	t.Run("email falls back to primary verified email", func(t *testing.T) {
+		p := newGithubAPIFake(t, map[string]string{
+			"/user":        `{"id":7,"login":"octocat","avatar_url":"https://avatar.example.com/octocat"}`,
+			"/user/emails": `[{"email":"old@example.com","verified":true},{"email":"octo@example.com","primary":true,"verified":true}]`,
+		})
+
+		identity, err := p.FetchProfile(context.Background(), &oauth2.Token{AccessToken: "gh_token"})
+		assert.NoError(t, err)
+		assert.Equal(t, models.NormalizedUserData{
+			ID:        "7",
+			Name:      "octocat",
+			Email:     "octo@example.com",
+			AvatarURL: "https://avatar.example.com/octocat",
+			Provider:  "github",
+		}, identity.User)
+	})
+
+	t.Run("user endpoint fails", func(t *testing.T) {
+		p := newGithubAPIFake(t, map[string]string{})
+
+		_, err := p.FetchProfile(context.Background(), &oauth2.Token{AccessToken: "gh_token"})
+		assert.ErrorContains(t, err, "error fetching user data from GitHub")
+	})
+}
+
+func TestGithubProvider_FetchGroups(t *testing.T) {
+	t.Run("follows every page and nested team parents", func(t *testing.T) {
+		mux := http.NewServeMux()
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		mux.HandleFunc("/user/teams", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "Bearer gh_token", r.Header.Get("Authorization"))
+			if r.URL.Query().Get("page") == "2" {
+				_, _ = io.WriteString(w, `[{"id":3,"name":"sre","slug":"sre","organization":{"login":"acme"},"parent":{"id":2,"name":"platform","slug":"platform"}}]`)
+				return
+			}
+			w.Header().Set("Link", `<`+server.URL+`/user/teams?per_page=100&page=2>; rel="next", <`+server.URL+`/user/teams?per_page=100&page=2>; rel="last"`)
+			_, _ = io.WriteString(w, `[{"id":1,"name":"dev","slug":"dev","organization":{"login":"acme"}}]`)
+		})
+		// platform is itself nested in engineering
+		mux.HandleFunc("/orgs/acme/teams/platform", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, `{"id":2,"name":"platform","slug":"platform","parent":{"id":4,"name":"engineering","slug":"engineering"}}`)
+		})
+		mux.HandleFunc("/orgs/acme/teams/engineering", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, `{"id":4,"name":"engineering","slug":"engineering"}`)
+		})
+
+		p := &githubProvider{oauthURL: server.URL, apiURL: server.URL}
+		teams, err := p.FetchGroups(context.Background(), map[string]any{"token": "gh_token"}, getTestLogger())
+		assert.NoError(t, err)
+		assert.Equal(t, []models.Team{
+			{ID: "1", Name: "dev"},
+			{ID: "3", Name: "sre"},
+			{ID: "2", Name: "platform"},
+			{ID: "4", Name: "engineering"},
+		}, teams)
+	})
+
+	t.Run("teams endpoint fails", func(t *testing.T) {
+		p := newGithubAPIFake(t, map[string]string{})
+		_, err := p.FetchGroups(context.Background(), map[string]any{"token": "gh_token"}, getTestLogger())
+		assert.Error(t, err)
+	})
+}
+
+func TestGithubProvider_IsAllowed(t *testing.T) {
+	originalAllowedOrg := allowedOrg
+	defer func() { allowedOrg = originalAllowedOrg }()
+	allowedOrg = "testorg"
+
+	p := newGithubAPIFake(t, map[string]string{
+		"/user/orgs": `[{"login":"another"},{"login":"testorg"}]`,
+	})
+	allowed, err := p.IsAllowed(context.Background(), &oauth2.Token{AccessToken: "gh_token"}, nil)
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+
+	allowedOrg = "strictly-this-org-only"
+	allowed, err = p.IsAllowed(context.Background(), &oauth2.Token{AccessToken: "gh_token"}, nil)
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+
+	// Failing to read the orgs is an error, not a denial
+	p = newGithubAPIFake(t, map[string]string{})
+	_, err = p.IsAllowed(context.Background(), &oauth2.Token{AccessToken: "gh_token"}, nil)
+	var loginErr *LoginError
+	assert.ErrorAs(t, err, &loginErr)
+	assert.Equal(t, "Failed to fetch orgs", loginErr.Message)
 }
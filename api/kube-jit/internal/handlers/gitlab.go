@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"kube-jit/internal/models"
+	"kube-jit/pkg/utils"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+)
+
+// gitlabURL points at a self-hosted GitLab with GITLAB_URL, defaults to gitlab.com
+var gitlabURL = strings.TrimSuffix(utils.GetEnv("GITLAB_URL", "https://gitlab.com"), "/")
+
+func init() {
+	RegisterProvider(newGitlabProvider(gitlabURL))
+}
+
+// getGitlabOAuthConfig constructs and returns the GitLab OAuth2 config.
+// This ensures it uses the current GitLab client settings from oauthClients
+var getGitlabOAuthConfig = func() *oauth2.Config {
+	client := oauthClient("gitlab")
+	return &oauth2.Config{
+		ClientID:     client.ClientID,
+		ClientSecret: client.ClientSecret,
+		RedirectURL:  client.RedirectURI,
+		// read_api is required to list the user's groups
+		Scopes: []string{"read_user", "read_api"},
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  gitlabURL + "/oauth/authorize",
+			TokenURL: gitlabURL + "/oauth/token",
+		},
+	}
+}
+
+// gitlabProvider implements IdentityProvider for GitLab.com and self-hosted GitLab
+// Users must be a member of the ALLOWED_GITLAB_GROUP top-level group (or one of its subgroups),
+// group IDs are used as teams
+type gitlabProvider struct {
+	apiURL string
+}
+
+func newGitlabProvider(baseURL string) *gitlabProvider {
+	return &gitlabProvider{apiURL: baseURL + "/api/v4"}
+}
+
+func (p *gitlabProvider) Name() string {
+	return "gitlab"
+}
+
+func (p *gitlabProvider) DeniedMessage() string {
+	return "Unauthorized group"
+}
+
+// ExchangeCode exchanges the authorization code for a token
+func (p *gitlabProvider) ExchangeCode(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	return getGitlabOAuthConfig().Exchange(oauthContext(ctx, "gitlab"), code, opts...)
+}
+
+// FetchProfile fetches the GitLab user, the display name falls back to the username
+func (p *gitlabProvider) FetchProfile(ctx context.Context, token *oauth2.Token) (*Identity, error) {
+	var gitlabUser models.GitLabUser
+	if err := p.get(ctx, "/user", token.AccessToken, &gitlabUser); err != nil {
+		return nil, fmt.Errorf("error fetching user data from GitLab: %w", err)
+	}
+
+	name := gitlabUser.Name
+	if name == "" {
+		name = gitlabUser.Username
+	}
+
+	return &Identity{User: models.NormalizedUserData{
+		ID:        strconv.Itoa(gitlabUser.ID),
+		Name:      name,
+		Email:     gitlabUser.Email,
+		AvatarURL: gitlabUser.AvatarURL,
+		Provider:  "gitlab",
+	}}, nil
+}
+
+// FetchGroups returns the GitLab groups of the logged in user
+// The team ID is the numeric group ID, the name is the group's full path
+func (p *gitlabProvider) FetchGroups(ctx context.Context, sessionData map[string]any, reqLogger *zap.Logger) ([]models.Team, error) {
+	token, _ := sessionData["token"].(string)
+
+	groups, err := p.fetchGroups(ctx, token)
+	if err != nil {
+		reqLogger.Warn("Error fetching groups from GitLab", zap.Error(err))
+		return nil, err
+	}
+
+	var teams []models.Team
+	for _, g := range groups {
+		teams = append(teams, models.Team{
+			ID:   strconv.Itoa(g.ID),
+			Name: g.FullPath,
+		})
+	}
+	return teams, nil
+}
+
+// IsAllowed checks the user is a member of the allowed top-level group or one of its subgroups
+func (p *gitlabProvider) IsAllowed(ctx context.Context, token *oauth2.Token, _ *Identity) (bool, error) {
+	groups, err := p.fetchGroups(ctx, token.AccessToken)
+	if err != nil {
+		return false, &LoginError{Status: http.StatusInternalServerError, Message: "Failed to fetch groups", Err: err}
+	}
+	for _, g := range groups {
+		topLevel, _, _ := strings.Cut(g.FullPath, "/")
+		// GitLab group paths are case insensitive
+		if strings.EqualFold(topLevel, allowedGitlabGroup) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// fetchGroups lists the groups the user is a direct or inherited member of, following every page
+func (p *gitlabProvider) fetchGroups(ctx context.Context, token string) ([]models.GitLabGroup, error) {
+	// min_access_level=10 (guest) limits the list to groups the user is a member of,
+	// including the subgroups inheriting a membership
+	groups, err := fetchAllPages[models.GitLabGroup](ctx, p.pages(token), p.apiURL+"/groups?min_access_level=10&per_page=100")
+	if err != nil {
+		return nil, fmt.Errorf("error fetching groups from GitLab: %w", err)
+	}
+	return groups, nil
+}
+
+// get sends an authenticated GET request to the GitLab API and decodes the response into out
+func (p *gitlabProvider) get(ctx context.Context, path, token string, out any) error {
+	_, err := p.fetch(ctx, p.apiURL+path, token, out)
+	return err
+}
+
+// pages returns a pageFetcher following the GitLab Link header with token
+func (p *gitlabProvider) pages(token string) pageFetcher {
+	return func(ctx context.Context, url string, out any) (string, error) {
+		return p.fetch(ctx, url, token, out)
+	}
+}
+
+// fetch sends an authenticated GET request to url, decodes the response into out
+// and returns the url of the next page if any
+func (p *gitlabProvider) fetch(ctx context.Context, url, token string, out any) (string, error) {
+	req, err := http.NewRequestWithContext(oauthContext(ctx, "gitlab"), "GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request for %s: %w", url, err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("%s returned %d: %s", url, resp.StatusCode, string(body))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return "", fmt.Errorf("failed to decode %s: %w", url, err)
+	}
+	return nextLink(resp.Header), nil
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"kube-jit/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+// newGitlabFake serves the GitLab token endpoint and the API endpoints used by gitlabProvider
+// API routes are relative to /api/v4
+func newGitlabFake(t *testing.T, routes map[string]string) *gitlabProvider {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "auth_code", r.Form.Get("code"))
+		w.Header().Set("Content-Type", "application/json")
+		assert.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "gl_token",
+			"token_type":   "Bearer",
+			"expires_in":   7200,
+		}))
+	})
+	for path, body := range routes {
+		mux.HandleFunc("/api/v4"+path, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "Bearer gl_token", r.Header.Get("Authorization"))
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, body)
+		})
+	}
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	originalGetConfigFunc := getGitlabOAuthConfig
+	getGitlabOAuthConfig = func() *oauth2.Config {
+		return &oauth2.Config{
+			ClientID:     "gitlab_client",
+			ClientSecret: "gitlab_secret",
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  server.URL + "/oauth/authorize",
+				TokenURL: server.URL + "/oauth/token",
+			},
+		}
+	}
+	t.Cleanup(func() { getGitlabOAuthConfig = originalGetConfigFunc })
+
+	return newGitlabProvider(server.URL)
+}
+
+func useAllowedGitlabGroup(t *testing.T, group string) {
+	t.Helper()
+	original := allowedGitlabGroup
+	allowedGitlabGroup = group
+	t.Cleanup(func() { allowedGitlabGroup = original })
+}
+
+func TestGitlabLogin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useAllowedGitlabGroup(t, "acme")
+
+	callback := func(p *gitlabProvider) *httptest.ResponseRecorder {
+		r := setupTestRouter()
+		r.GET("/oauth/gitlab/callback", OAuthCallback(p))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/oauth/gitlab/callback?code=auth_code", nil))
+		return w
+	}
+	user := `{"id":42,"username":"jdoe","name":"Jane Doe","email":"jane@acme.com","avatar_url":"https://gitlab.example.com/jdoe.png"}`
+
+	t.Run("success", func(t *testing.T) {
+		p := newGitlabFake(t, map[string]string{
+			"/user":   user,
+			"/groups": `[{"id":10,"name":"platform","full_path":"acme/platform"}]`,
+		})
+
+		w := callback(p)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp models.LoginResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, models.NormalizedUserData{
+			ID:        "42",
+			Name:      "Jane Doe",
+			Email:     "jane@acme.com",
+			AvatarURL: "https://gitlab.example.com/jdoe.png",
+			Provider:  "gitlab",
+		}, resp.UserData)
+		assert.Equal(t, 7200, resp.ExpiresIn)
+		assert.NotEmpty(t, w.Result().Cookies())
+	})
+
+	t.Run("not a member of the allowed group", func(t *testing.T) {
+		p := newGitlabFake(t, map[string]string{
+			"/user":   user,
+			"/groups": `[{"id":11,"name":"other","full_path":"other"}]`,
+		})
+
+		w := callback(p)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "Unauthorized group")
+	})
+}
+
+func TestGitlabProvider_FetchProfile(t *testing.T) {
+	t.Run("name falls back to username", func(t *testing.T) {
+		p := newGitlabFake(t, map[string]string{
+			"/user": `{"id":7,"username":"jdoe","email":"jane@acme.com"}`,
+		})
+
+		identity, err := p.FetchProfile(context.Background(), &oauth2.Token{AccessToken: "gl_token"})
+		assert.NoError(t, err)
+		assert.Equal(t, "7", identity.User.ID)
+		assert.Equal(t, "jdoe", identity.User.Name)
+		assert.Equal(t, "gitlab", identity.User.Provider)
+	})
+
+	t.Run("user endpoint fails", func(t *testing.T) {
+		p := newGitlabFake(t, map[string]string{})
+
+		_, err := p.FetchProfile(context.Background(), &oauth2.Token{AccessToken: "gl_token"})
+		assert.ErrorContains(t, err, "error fetching user data from GitLab")
+	})
+}
+
+func TestGitlabProvider_FetchGroups(t *testing.T) {
+	p := newGitlabFake(t, map[string]string{
+		"/groups": `[{"id":10,"name":"acme","full_path":"acme"},{"id":12,"name":"platform","full_path":"acme/platform"}]`,
+	})
+
+	teams, err := p.FetchGroups(context.Background(), map[string]any{"token": "gl_token"}, getTestLogger())
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Team{{ID: "10", Name: "acme"}, {ID: "12", Name: "acme/platform"}}, teams)
+
+	p = newGitlabFake(t, map[string]string{})
+	_, err = p.FetchGroups(context.Background(), map[string]any{"token": "gl_token"}, getTestLogger())
+	assert.ErrorContains(t, err, "error fetching groups from GitLab")
+}
+
+func TestGitlabProvider_FetchGroupsPages(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v4/groups", r.URL.Path)
+		assert.Equal(t, "10", r.URL.Query().Get("min_access_level"))
+		if r.URL.Query().Get("page") == "2" {
+			_, _ = io.WriteString(w, `[{"id":12,"full_path":"acme/platform"}]`)
+			return
+		}
+		w.Header().Set("Link", `<`+server.URL+`/api/v4/groups?min_access_level=10&page=2&per_page=100>; rel="next"`)
+		_, _ = io.WriteString(w, `[{"id":10,"full_path":"acme"}]`)
+	}))
+	defer server.Close()
+
+	teams, err := newGitlabProvider(server.URL).FetchGroups(context.Background(), map[string]any{"token": "gl_token"}, getTestLogger())
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Team{{ID: "10", Name: "acme"}, {ID: "12", Name: "acme/platform"}}, teams)
+}
+
+func TestGitlabProvider_IsAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		groups  string
+		allowed bool
+	}{
+		{name: "member of the top-level group", groups: `[{"id":10,"full_path":"acme"}]`, allowed: true},
+		{name: "member of a subgroup", groups: `[{"id":12,"full_path":"acme/platform/sre"}]`, allowed: true},
+		{name: "group path is case insensitive", groups: `[{"id":10,"full_path":"ACME"}]`, allowed: true},
+		{name: "prefix of another group", groups: `[{"id":13,"full_path":"acme-labs/platform"}]`, allowed: false},
+		{name: "no groups", groups: `[]`, allowed: false},
+	}
+
+	useAllowedGitlabGroup(t, "acme")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newGitlabFake(t, map[string]string{"/groups": tt.groups})
+			allowed, err := p.IsAllowed(context.Background(), &oauth2.Token{AccessToken: "gl_token"}, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.allowed, allowed)
+		})
+	}
+
+	// Failing to read the groups is an error, not a denial
+	p := newGitlabFake(t, map[string]string{})
+	_, err := p.IsAllowed(context.Background(), &oauth2.Token{AccessToken: "gl_token"}, nil)
+	var loginErr *LoginError
+	assert.ErrorAs(t, err, &loginErr)
+	assert.Equal(t, "Failed to fetch groups", loginErr.Message)
+}
@@ -6,15 +6,12 @@ import (
 	"fmt"
 	"io"
 	"kube-jit/internal/models"
-	"kube-jit/pkg/sessioncookie"
 	"kube-jit/pkg/utils"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/gin-contrib/sessions"
-	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 	"golang.org/x/oauth2"
 	admin "google.golang.org/api/admin/directory/v1"
@@ -30,20 +27,23 @@ var (
 )
 
 func init() {
+	RegisterProvider(newGoogleProvider())
+
 	// Set the admin email for Google Workspace
 	// This email is used to impersonate the user to read their groups
-	if oauthProvider == "google" {
+	if isProviderEnabled("google") {
 		adminEmail = utils.MustGetEnv("GOOGLE_ADMIN_EMAIL")
 	}
 }
 
 // getGoogleOAuthConfig constructs and returns the Google OAuth2 config.
-// This ensures it uses the current values of clientID, clientSecret, etc.
+// This ensures it uses the current Google client settings from oauthClients
 var getGoogleOAuthConfig = func() *oauth2.Config {
+	client := oauthClient("google")
 	return &oauth2.Config{
-		ClientID:     clientID,     // Reads current package-level clientID from common.go
-		ClientSecret: clientSecret, // Reads current package-level clientSecret from common.go
-		RedirectURL:  redirectUri,  // Reads current package-level redirectUri from common.go
+		ClientID:     client.ClientID,
+		ClientSecret: client.ClientSecret,
+		RedirectURL:  client.RedirectURI,
 		Scopes: []string{
 			"https://www.googleapis.com/auth/userinfo.profile",
 			"https://www.googleapis.com/auth/userinfo.email",
@@ -55,27 +55,6 @@ var getGoogleOAuthConfig = func() *oauth2.Config {
 	}
 }
 
-// Helper to fetch and decode Google user profile
-var fetchGoogleUserProfile = func(token string) (*models.GoogleUser, error) {
-	client := oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}))
-	resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch Google user info: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("error fetching user profile from Google: %s", string(body))
-	}
-
-	var googleUser models.GoogleUser
-	if err := json.NewDecoder(resp.Body).Decode(&googleUser); err != nil {
-		return nil, fmt.Errorf("failed to decode Google user info: %w", err)
-	}
-	return &googleUser, nil
-}
-
 // getGSAEmail retrieves the Google Service Account (GSA) email from the metadata server
 func getGSAEmail(reqLogger *zap.Logger) (string, error) {
 
@@ -191,138 +170,103 @@ var GetGoogleGroupsWithWorkloadIdentity = func(userEmail string, reqLogger *zap.
 		return nil, fmt.Errorf("failed to create Admin SDK service")
 	}
 
-	groupsCall := service.Groups.List().UserKey(userEmail)
-	groupsResponse, err := groupsCall.Do()
+	teams, err := listGoogleGroups(ctx, service, userEmail)
 	if err != nil {
 		reqLogger.Error("Failed to list Google groups", zap.Error(err))
 		return nil, fmt.Errorf("failed to list groups")
 	}
 
+	return teams, nil
+}
+
+// listGoogleGroups returns the groups of userEmail, including the groups inherited through nested groups
+// The Directory API only lists direct memberships, so each group found is listed in turn for its own groups
+func listGoogleGroups(ctx context.Context, service *admin.Service, userEmail string) ([]models.Team, error) {
 	var teams []models.Team
-	for _, group := range groupsResponse.Groups {
-		teams = append(teams, models.Team{
-			Name: group.Name,
-			ID:   group.Email,
+	seen := map[string]bool{}
+	keys := []string{userEmail}
+	for len(keys) > 0 {
+		key := keys[0]
+		keys = keys[1:]
+		err := service.Groups.List().UserKey(key).MaxResults(200).Pages(ctx, func(groups *admin.Groups) error {
+			for _, group := range groups.Groups {
+				if seen[group.Email] {
+					continue
+				}
+				seen[group.Email] = true
+				teams = append(teams, models.Team{
+					Name: group.Name,
+					ID:   group.Email,
+				})
+				keys = append(keys, group.Email)
+			}
+			return nil
 		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list groups of %s: %w", key, err)
+		}
 	}
-
 	return teams, nil
 }
 
-// HandleGoogleLogin godoc
-// @Summary Google OAuth callback
-// @Description Handles the Google OAuth callback, exchanges the code for an access token, fetches user info, sets session data, and returns normalized user data and expiration time.
-// @Tags google
-// @Accept  json
-// @Produce  json
-// @Param   code query string true "Google OAuth authorization code"
-// @Success 200 {object} models.LoginResponse "Normalized user data and expiration time"
-// @Failure 400 {object} models.SimpleMessageResponse "Missing or invalid code"
-// @Failure 403 {object} models.SimpleMessageResponse "Unauthorized domain"
-// @Failure 500 {object} models.SimpleMessageResponse "Internal server error"
-// @Router /oauth/google/callback [get]
-func HandleGoogleLogin(c *gin.Context) {
-	code := c.Query("code")
-
-	if code == "" {
-		logger.Warn("Missing 'code' query parameter in Google login")
-		c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: "Code query parameter is required"})
-		return
-	}
+// googleProvider implements IdentityProvider for Google Workspace
+// Users must have an email in ALLOWED_DOMAIN, groups are read with workload identity
+type googleProvider struct {
+	userinfoURL string
+}
+
+func newGoogleProvider() *googleProvider {
+	return &googleProvider{userinfoURL: "https://www.googleapis.com/oauth2/v2/userinfo"}
+}
+
+func (p *googleProvider) Name() string {
+	return "google"
+}
+
+func (p *googleProvider) DeniedMessage() string {
+	return "Unauthorized domain"
+}
 
-	currentGoogleOAuthConfig := getGoogleOAuthConfig() // Use the function
+// ExchangeCode exchanges the authorization code for a token
+func (p *googleProvider) ExchangeCode(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	return getGoogleOAuthConfig().Exchange(oauthContext(ctx, "google"), code, opts...)
+}
 
-	token, err := currentGoogleOAuthConfig.Exchange(context.Background(), code)
+// FetchProfile fetches the user from the Google userinfo endpoint
+func (p *googleProvider) FetchProfile(ctx context.Context, token *oauth2.Token) (*Identity, error) {
+	client := oauth2.NewClient(oauthContext(ctx, "google"), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token.AccessToken}))
+	resp, err := client.Get(p.userinfoURL)
 	if err != nil {
-		logger.Error("Failed to exchange Google token", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to exchange token"})
-		return
+		return nil, fmt.Errorf("failed to fetch Google user info: %w", err)
 	}
+	defer resp.Body.Close()
 
-	googleUser, err := fetchGoogleUserProfile(token.AccessToken)
-	if err != nil {
-		logger.Error("Failed to get Google user info", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: err.Error()})
-		return
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("error fetching user profile from Google: %s", string(body))
 	}
 
-	// Check if the user is allowed to log in
-	if !isAllowedUser("google", googleUser.Email, nil) {
-		logger.Warn("Login attempt from unauthorized Google domain", zap.String("email", googleUser.Email))
-		c.JSON(http.StatusForbidden, models.SimpleMessageResponse{Error: "Unauthorized domain"})
-		return
+	var googleUser models.GoogleUser
+	if err := json.NewDecoder(resp.Body).Decode(&googleUser); err != nil {
+		return nil, fmt.Errorf("failed to decode Google user info: %w", err)
 	}
 
-	normalizedUserData := models.NormalizedUserData{
+	return &Identity{User: models.NormalizedUserData{
 		ID:        googleUser.ID,
 		Name:      googleUser.Name,
 		Email:     googleUser.Email,
 		AvatarURL: googleUser.Picture,
 		Provider:  "google",
-	}
-
-	sessionData := map[string]any{
-		"id":    googleUser.ID,
-		"name":  googleUser.Name,
-		"email": googleUser.Email,
-		"token": token.AccessToken,
-	}
-
-	session := sessions.Default(c)
-	session.Set("data", sessionData)
-
-	sessioncookie.SplitSessionData(c)
-
-	logger.Debug("Session cookies set successfully for Google login", zap.String("email", googleUser.Email))
-
-	c.JSON(http.StatusOK, models.LoginResponse{
-		UserData:  normalizedUserData,
-		ExpiresIn: int(time.Until(token.Expiry).Seconds()),
-	})
+	}}, nil
 }
 
-// GetGoogleProfile godoc
-// @Summary Get the logged in user's Google profile
-// @Description Returns the normalized Google user profile for the authenticated user.
-// @Description Requires one or more cookies named kube_jit_session_<number> (e.g., kube_jit_session_0, kube_jit_session_1).
-// @Description Pass split cookies in the Cookie header, for example:
-// @Description     -H "Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}"
-// @Description Note: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.
-// @Tags google
-// @Accept  json
-// @Produce  json
-// @Param   Cookie header string true "Session cookies (multiple allowed, names: kube_jit_session_0, kube_jit_session_1, etc.)"
-// @Success 200 {object} models.NormalizedUserData
-// @Failure 401 {object} models.SimpleMessageResponse "Unauthorized: no token in session data"
-// @Failure 500 {object} models.SimpleMessageResponse "Internal server error"
-// @Router /google/profile [get]
-func GetGoogleProfile(c *gin.Context) {
-	// Check if the user is logged
-	sessionData := GetSessionData(c)
-	reqLogger := RequestLogger(c)
-
-	// Retrieve the token from the session data
-	token, ok := sessionData["token"].(string)
-	if !ok || token == "" {
-		reqLogger.Warn("No token in session data for Google profile")
-		c.JSON(http.StatusUnauthorized, models.SimpleMessageResponse{Error: "Unauthorized: no token in session data"})
-		return
-	}
-
-	googleUser, err := fetchGoogleUserProfile(token)
-	if err != nil {
-		reqLogger.Error("Failed to fetch Google user profile", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: err.Error()})
-		return
-	}
-
-	normalizedUserData := map[string]interface{}{
-		"id":         googleUser.ID,
-		"name":       googleUser.Name,
-		"email":      googleUser.Email,
-		"avatar_url": googleUser.Picture,
-		"provider":   "google",
-	}
+// FetchGroups returns the Google groups of the logged in user
+func (p *googleProvider) FetchGroups(_ context.Context, sessionData map[string]any, reqLogger *zap.Logger) ([]models.Team, error) {
+	userEmail, _ := sessionData["email"].(string)
+	return GetGoogleGroupsWithWorkloadIdentity(userEmail, reqLogger)
+}
 
-	c.JSON(http.StatusOK, normalizedUserData)
+// IsAllowed checks the user's email is in the allowed domain
+func (p *googleProvider) IsAllowed(_ context.Context, _ *oauth2.Token, identity *Identity) (bool, error) {
+	return emailInAllowedDomain(identity.User.Email), nil
 }
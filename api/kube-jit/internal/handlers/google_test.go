@@ -1,8 +1,8 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"io"
 	"kube-jit/internal/models"
 	"kube-jit/pkg/sessioncookie"
@@ -14,14 +14,15 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
 	"golang.org/x/oauth2"
+	admin "google.golang.org/api/admin/directory/v1"
+	"google.golang.org/api/option"
 )
 
 func setupGoogleTestEnv() (restoreFunc func()) {
-	originalOauthProvider := oauthProvider
-	originalClientID := clientID
-	originalClientSecret := clientSecret
-	originalRedirectURI := redirectUri
+	originalOauthProviders := oauthProviders
+	originalOauthClients := oauthClients
 	originalAllowedDomain := allowedDomain
 	originalAllowedOrg := allowedOrg
 	originalAdminEmail := adminEmail
@@ -34,10 +35,10 @@ func setupGoogleTestEnv() (restoreFunc func()) {
 	originalAllowedGithubOrgEnv := os.Getenv("ALLOWED_GITHUB_ORG")
 	originalGoogleAdminEmailEnv := os.Getenv("GOOGLE_ADMIN_EMAIL")
 
-	oauthProvider = "google"
-	clientID = "test-google-client-id"
-	clientSecret = "test-google-client-secret"
-	redirectUri = "http://localhost/test/google/callback"
+	oauthProviders = []string{"google"}
+	oauthClients = map[string]oauthClientConfig{
+		"google": {ClientID: "test-google-client-id", ClientSecret: "test-google-client-secret", RedirectURI: "http://localhost/test/google/callback"},
+	}
 	allowedDomain = "example.com"
 	allowedOrg = ""
 	adminEmail = "test-admin@example.com"
@@ -51,10 +52,8 @@ func setupGoogleTestEnv() (restoreFunc func()) {
 	os.Setenv("GOOGLE_ADMIN_EMAIL", "test-admin@example.com")
 
 	return func() {
-		oauthProvider = originalOauthProvider
-		clientID = originalClientID
-		clientSecret = originalClientSecret
-		redirectUri = originalRedirectURI
+		oauthProviders = originalOauthProviders
+		oauthClients = originalOauthClients
 		allowedDomain = originalAllowedDomain
 		allowedOrg = originalAllowedOrg
 		adminEmail = originalAdminEmail
@@ -69,22 +68,24 @@ func setupGoogleTestEnv() (restoreFunc func()) {
 	}
 }
 
-var originalFetchGoogleUserProfile func(token string) (*models.GoogleUser, error)
-
-func mockFetchGoogleUserProfile(user *models.GoogleUser, err error) {
-	originalFetchGoogleUserProfile = fetchGoogleUserProfile
-	fetchGoogleUserProfile = func(token string) (*models.GoogleUser, error) {
-		return user, err
-	}
-}
-
-func restoreFetchGoogleUserProfile() {
-	if originalFetchGoogleUserProfile != nil {
-		fetchGoogleUserProfile = originalFetchGoogleUserProfile
-	}
+// newGoogleUserinfoFake serves the userinfo endpoint for the given user
+// and returns a googleProvider pointed at it
+func newGoogleUserinfoFake(t *testing.T, user *models.GoogleUser) *googleProvider {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/oauth2/v2/userinfo", r.URL.Path)
+		if user == nil {
+			http.Error(w, "userinfo error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(user)
+	}))
+	t.Cleanup(server.Close)
+	return &googleProvider{userinfoURL: server.URL + "/oauth2/v2/userinfo"}
 }
 
-func TestHandleGoogleLogin(t *testing.T) {
+func TestGoogleLogin(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	restoreEnv := setupGoogleTestEnv()
 	defer restoreEnv()
@@ -171,20 +172,15 @@ func TestHandleGoogleLogin(t *testing.T) {
 		}
 		defer func() { getGoogleOAuthConfig = originalGetConfigFunc }()
 
-		mockIsAllowedUser(true)
-		defer restoreIsAllowedUser()
-
-		mockedGoogleUser := &models.GoogleUser{
+		p := newGoogleUserinfoFake(t, &models.GoogleUser{
 			ID:      "google123",
 			Name:    "Google Test User",
 			Email:   "google.user@example.com",
 			Picture: "http://example.com/avatar.jpg",
-		}
-		mockFetchGoogleUserProfile(mockedGoogleUser, nil)
-		defer restoreFetchGoogleUserProfile()
+		})
 
 		r := setupTestRouter()
-		r.GET("/oauth/google/callback", HandleGoogleLogin)
+		r.GET("/oauth/google/callback", OAuthCallback(p))
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest(http.MethodGet, "/oauth/google/callback?code=auth_code", nil)
 		r.ServeHTTP(w, req)
@@ -237,7 +233,7 @@ func TestHandleGoogleLogin(t *testing.T) {
 		defer func() { getGoogleOAuthConfig = originalGetConfigFunc }()
 
 		r := setupTestRouter()
-		r.GET("/oauth/google/callback", HandleGoogleLogin)
+		r.GET("/oauth/google/callback", OAuthCallback(newGoogleProvider()))
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest(http.MethodGet, "/oauth/google/callback?code=auth_code", nil)
 		r.ServeHTTP(w, req)
@@ -249,20 +245,18 @@ func TestHandleGoogleLogin(t *testing.T) {
 	})
 }
 
-func TestGetGoogleProfile(t *testing.T) {
+func TestGoogleProfile(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	restoreEnv := setupGoogleTestEnv()
 	defer restoreEnv()
 
 	t.Run("success", func(t *testing.T) {
-		mockedGoogleUser := &models.GoogleUser{
+		p := newGoogleUserinfoFake(t, &models.GoogleUser{
 			ID:      "google123",
 			Name:    "Google Test User",
 			Email:   "google.user@example.com",
 			Picture: "http://example.com/avatar.jpg",
-		}
-		mockFetchGoogleUserProfile(mockedGoogleUser, nil)
-		defer restoreFetchGoogleUserProfile()
+		})
 
 		r := setupTestRouter()
 		r.Use(func(c *gin.Context) {
@@ -274,7 +268,7 @@ func TestGetGoogleProfile(t *testing.T) {
 			c.Set("sessionData", sessionData)
 			c.Next()
 		})
-		r.GET("/google/profile", GetGoogleProfile)
+		r.GET("/google/profile", GetProfile(p))
 
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest(http.MethodGet, "/google/profile", nil)
@@ -292,46 +286,61 @@ func TestGetGoogleProfile(t *testing.T) {
 	})
 }
 
-func TestFetchGoogleUserProfileHelper(t *testing.T) {
-	t.Run("success", func(t *testing.T) {
-		expectedUser := models.GoogleUser{
-			ID:      "google-user-id",
-			Name:    "Google Test User",
-			Email:   "google.test@example.com",
-			Picture: "http://example.com/pic.jpg",
+func TestGoogleProvider_FetchProfile(t *testing.T) {
+	t.Run("userinfo fails", func(t *testing.T) {
+		p := newGoogleUserinfoFake(t, nil)
+		_, err := p.FetchProfile(context.Background(), &oauth2.Token{AccessToken: "fake-access-token"})
+		assert.ErrorContains(t, err, "error fetching user profile from Google")
+	})
+}
+
+func TestGoogleProvider_FetchGroups(t *testing.T) {
+	originalGetGoogleGroups := GetGoogleGroupsWithWorkloadIdentity
+	defer func() { GetGoogleGroupsWithWorkloadIdentity = originalGetGoogleGroups }()
+	GetGoogleGroupsWithWorkloadIdentity = func(userEmail string, reqLogger *zap.Logger) ([]models.Team, error) {
+		assert.Equal(t, "user@example.com", userEmail)
+		return []models.Team{{ID: "group@example.com", Name: "Group"}}, nil
+	}
+
+	groups, err := newGoogleProvider().FetchGroups(context.Background(), map[string]any{"email": "user@example.com"}, getTestLogger())
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Team{{ID: "group@example.com", Name: "Group"}}, groups)
+}
+
+func TestListGoogleGroups(t *testing.T) {
+	// user@example.com is in eng and all over two pages, eng is in platform and all is in eng again
+	groupsByKey := map[string][]string{
+		"user@example.com": {
+			`{"groups":[{"email":"eng@example.com","name":"Engineering"}],"nextPageToken":"page2"}`,
+			`{"groups":[{"email":"all@example.com","name":"Everyone"}]}`,
+		},
+		"eng@example.com": {`{"groups":[{"email":"platform@example.com","name":"Platform"}]}`},
+		"all@example.com": {`{"groups":[{"email":"eng@example.com","name":"Engineering"}]}`},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasSuffix(r.URL.Path, "/groups"), r.URL.Path)
+		pages := groupsByKey[r.URL.Query().Get("userKey")]
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("pageToken") == "page2" {
+			_, _ = io.WriteString(w, pages[1])
+			return
 		}
-		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			assert.Equal(t, "/oauth2/v2/userinfo", r.URL.Path)
-			assert.Equal(t, "Bearer fake-access-token", r.Header.Get("Authorization"))
-			w.WriteHeader(http.StatusOK)
-			err := json.NewEncoder(w).Encode(expectedUser)
-			assert.NoError(t, err)
-		}))
-		defer server.Close()
-
-		originalFunc := fetchGoogleUserProfile
-		fetchGoogleUserProfile = func(token string) (*models.GoogleUser, error) {
-			client := server.Client()
-			req, _ := http.NewRequest("GET", server.URL+"/oauth2/v2/userinfo", nil)
-			req.Header.Set("Authorization", "Bearer "+token)
-			resp, err := client.Do(req)
-			if err != nil {
-				return nil, err
-			}
-			defer resp.Body.Close()
-			if resp.StatusCode != http.StatusOK {
-				return nil, fmt.Errorf("server returned status %d", resp.StatusCode)
-			}
-			var user models.GoogleUser
-			if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
-				return nil, err
-			}
-			return &user, nil
+		if len(pages) == 0 {
+			_, _ = io.WriteString(w, `{}`)
+			return
 		}
-		defer func() { fetchGoogleUserProfile = originalFunc }()
-
-		user, err := fetchGoogleUserProfile("fake-access-token")
-		assert.NoError(t, err)
-		assert.Equal(t, &expectedUser, user)
-	})
+		_, _ = io.WriteString(w, pages[0])
+	}))
+	defer server.Close()
+
+	service, err := admin.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	assert.NoError(t, err)
+
+	teams, err := listGoogleGroups(context.Background(), service, "user@example.com")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []models.Team{
+		{ID: "eng@example.com", Name: "Engineering"},
+		{ID: "all@example.com", Name: "Everyone"},
+		{ID: "platform@example.com", Name: "Platform"},
+	}, teams)
 }
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"kube-jit/internal/metrics"
+	"kube-jit/internal/models"
+	"kube-jit/pkg/utils"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// groupCacheTTL is how long the groups of a user are cached, set with GROUP_CACHE_TTL
+// Group changes at the provider can take this long to apply, 0 disables the cache
+var groupCacheTTL = utils.GetEnvDuration("GROUP_CACHE_TTL", 5*time.Minute)
+
+// userGroupCache holds the groups of each user by provider and user ID
+var userGroupCache sync.Map
+
+type cachedGroups struct {
+	Teams     []models.Team
+	ExpiresAt time.Time
+}
+
+// fetchUserGroups returns the groups of the logged in user, from the cache if they were
+// fetched less than groupCacheTTL ago, otherwise from the provider with their IDs qualified by qualifyID
+func fetchUserGroups(ctx context.Context, p IdentityProvider, sessionData map[string]any, reqLogger *zap.Logger) ([]models.Team, error) {
+	userID, _ := sessionData["id"].(string)
+	key, cacheable := groupCacheKey(p.Name(), userID)
+	if cacheable {
+		if teams, ok := cachedUserGroups(p.Name(), userID); ok {
+			reqLogger.Debug("Using cached user groups", zap.String("provider", p.Name()))
+			return teams, nil
+		}
+	}
+
+	teams, err := p.FetchGroups(ctx, sessionData, reqLogger)
+	if err != nil {
+		return nil, err
+	}
+	teams = qualifyTeams(p.Name(), teams)
+
+	if cacheable {
+		userGroupCache.Store(key, &cachedGroups{
+			Teams:     teams,
+			ExpiresAt: time.Now().Add(groupCacheTTL),
+		})
+	}
+	return teams, nil
+}
+
+// cachedUserGroups returns the groups of a user fetched by fetchUserGroups less than groupCacheTTL ago
+func cachedUserGroups(provider, userID string) ([]models.Team, bool) {
+	key, cacheable := groupCacheKey(provider, userID)
+	if !cacheable {
+		return nil, false
+	}
+	if cached, exists := userGroupCache.Load(key); exists {
+		entry := cached.(*cachedGroups)
+		if time.Now().Before(entry.ExpiresAt) {
+			metrics.GroupCacheTotal.WithLabelValues(provider, metrics.CacheHit).Inc()
+			return entry.Teams, true
+		}
+	}
+	metrics.GroupCacheTotal.WithLabelValues(provider, metrics.CacheMiss).Inc()
+	return nil, false
+}
+
+// SweepUserGroupCache drops the expired groups from the cache every GROUP_CACHE_TTL until ctx is done,
+// so the groups of users who stopped using the API are not kept forever
+func SweepUserGroupCache(ctx context.Context) {
+	if groupCacheTTL <= 0 {
+		return
+	}
+	ticker := time.NewTicker(groupCacheTTL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sweepUserGroups(time.Now())
+		}
+	}
+}
+
+// sweepUserGroups drops the cache entries expired at now, entries replaced meanwhile are kept
+func sweepUserGroups(now time.Time) {
+	userGroupCache.Range(func(key, value any) bool {
+		if !now.Before(value.(*cachedGroups).ExpiresAt) {
+			userGroupCache.CompareAndDelete(key, value)
+		}
+		return true
+	})
+}
+
+// invalidateUserGroups drops the cached groups of a user, so logging in again picks up group changes
+func invalidateUserGroups(provider, userID string) {
+	key, cacheable := groupCacheKey(provider, userID)
+	if cacheable {
+		userGroupCache.Delete(key)
+	}
+}
+
+// groupCacheKey returns the cache key of a user, false if the cache is disabled or the user ID is unknown
+func groupCacheKey(provider, userID string) (string, bool) {
+	if groupCacheTTL <= 0 || userID == "" {
+		return "", false
+	}
+	return provider + "/" + userID, true
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"kube-jit/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// countingProvider counts the calls to FetchGroups
+type countingProvider struct {
+	fakeProvider
+	calls int
+}
+
+func (p *countingProvider) FetchGroups(ctx context.Context, sessionData map[string]any, reqLogger *zap.Logger) ([]models.Team, error) {
+	p.calls++
+	return p.fakeProvider.FetchGroups(ctx, sessionData, reqLogger)
+}
+
+func useGroupCacheTTL(t *testing.T, ttl time.Duration) {
+	t.Helper()
+	original := groupCacheTTL
+	groupCacheTTL = ttl
+	t.Cleanup(func() {
+		groupCacheTTL = original
+		userGroupCache.Clear()
+	})
+}
+
+func TestFetchUserGroups(t *testing.T) {
+	ctx := context.Background()
+	logger := getTestLogger()
+	session := map[string]any{"id": "42", "token": "token"}
+	groups := []models.Team{{ID: "1", Name: "platform"}}
+
+	t.Run("cached until the ttl expires", func(t *testing.T) {
+		useGroupCacheTTL(t, time.Minute)
+		p := &countingProvider{fakeProvider: fakeProvider{name: "fake", groups: groups}}
+
+		for i := 0; i < 2; i++ {
+			teams, err := fetchUserGroups(ctx, p, session, logger)
+			assert.NoError(t, err)
+			assert.Equal(t, groups, teams)
+		}
+		assert.Equal(t, 1, p.calls)
+
+		// Expire the entry
+		cached, _ := userGroupCache.Load("fake/42")
+		cached.(*cachedGroups).ExpiresAt = time.Now().Add(-time.Second)
+		_, err := fetchUserGroups(ctx, p, session, logger)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, p.calls)
+	})
+
+	t.Run("users and providers are cached separately", func(t *testing.T) {
+		useGroupCacheTTL(t, time.Minute)
+		p := &countingProvider{fakeProvider: fakeProvider{name: "fake", groups: groups}}
+		other := &countingProvider{fakeProvider: fakeProvider{name: "other", groups: groups}}
+
+		_, _ = fetchUserGroups(ctx, p, session, logger)
+		_, _ = fetchUserGroups(ctx, p, map[string]any{"id": "43"}, logger)
+		_, _ = fetchUserGroups(ctx, other, session, logger)
+		assert.Equal(t, 2, p.calls)
+		assert.Equal(t, 1, other.calls)
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		useGroupCacheTTL(t, time.Minute)
+		p := &countingProvider{fakeProvider: fakeProvider{name: "fake", groupsErr: errors.New("boom")}}
+
+		for i := 0; i < 2; i++ {
+			_, err := fetchUserGroups(ctx, p, session, logger)
+			assert.EqualError(t, err, "boom")
+		}
+		assert.Equal(t, 2, p.calls)
+	})
+
+	t.Run("disabled with a zero ttl", func(t *testing.T) {
+		useGroupCacheTTL(t, 0)
+		p := &countingProvider{fakeProvider: fakeProvider{name: "fake", groups: groups}}
+
+		_, _ = fetchUserGroups(ctx, p, session, logger)
+		_, _ = fetchUserGroups(ctx, p, session, logger)
+		assert.Equal(t, 2, p.calls)
+	})
+
+	t.Run("invalidated on login", func(t *testing.T) {
+		useGroupCacheTTL(t, time.Minute)
+		p := &countingProvider{fakeProvider: fakeProvider{name: "fake", groups: groups}}
+
+		_, _ = fetchUserGroups(ctx, p, session, logger)
+		invalidateUserGroups("fake", "42")
+		_, _ = fetchUserGroups(ctx, p, session, logger)
+		assert.Equal(t, 2, p.calls)
+	})
+}
+
+func TestSweepUserGroups(t *testing.T) {
+	useGroupCacheTTL(t, time.Minute)
+	now := time.Now()
+	userGroupCache.Store("fake/expired", &cachedGroups{ExpiresAt: now.Add(-time.Second)})
+	userGroupCache.Store("fake/fresh", &cachedGroups{ExpiresAt: now.Add(time.Minute)})
+
+	sweepUserGroups(now)
+
+	_, expired := userGroupCache.Load("fake/expired")
+	_, fresh := userGroupCache.Load("fake/fresh")
+	assert.False(t, expired)
+	assert.True(t, fresh)
+}
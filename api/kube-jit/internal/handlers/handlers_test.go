@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/gob"
 	"kube-jit/internal/models"
+	"kube-jit/pkg/k8s"
 	"os"
 	"testing"
 )
@@ -22,3 +23,12 @@ func TestMain(m *testing.M) {
 
 	os.Exit(exitCode)
 }
+
+// useSettings applies change to a copy of the configuration in use, for the duration of the test
+func useSettings(t *testing.T, change func(s *k8s.Settings)) {
+	t.Helper()
+	next := *k8s.CurrentSettings()
+	change(&next)
+	original := k8s.SetSettings(&next)
+	t.Cleanup(func() { k8s.SetSettings(original) })
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kube-jit/internal/models"
+	"kube-jit/pkg/sessioncookie"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+)
+
+// IdentityProvider is implemented by each supported identity provider
+// Providers register themselves with RegisterProvider from an init function,
+// the login, profile and permission handlers only go through this interface
+type IdentityProvider interface {
+	// Name is the provider name used in OAUTH_PROVIDER, routes and the session
+	Name() string
+	// ExchangeCode exchanges an authorization code for a token
+	ExchangeCode(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error)
+	// FetchProfile returns the identity of the user owning the token
+	FetchProfile(ctx context.Context, token *oauth2.Token) (*Identity, error)
+	// FetchGroups returns the groups of the logged in user as teams
+	FetchGroups(ctx context.Context, sessionData map[string]any, reqLogger *zap.Logger) ([]models.Team, error)
+	// IsAllowed reports whether the user may log in to the api
+	IsAllowed(ctx context.Context, token *oauth2.Token, identity *Identity) (bool, error)
+}
+
+// Identity is the user returned by IdentityProvider.FetchProfile
+type Identity struct {
+	User models.NormalizedUserData
+	// SessionData holds provider specific values to keep in the session, e.g. the OIDC groups
+	SessionData map[string]any
+}
+
+// LoginError lets a provider choose the status and message returned for a failed login step
+type LoginError struct {
+	Status  int
+	Message string
+	Err     error
+}
+
+func (e *LoginError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *LoginError) Unwrap() error {
+	return e.Err
+}
+
+// deniedMessager is implemented by providers returning a specific message when IsAllowed fails
+type deniedMessager interface {
+	DeniedMessage() string
+}
+
+// loginStarter is implemented by providers with a server side login entrypoint (e.g. OIDC with PKCE)
+type loginStarter interface {
+	StartLogin(c *gin.Context)
+}
+
+// callbackHandler is implemented by providers whose callback does more than exchange the code
+type callbackHandler interface {
+	HandleCallback(c *gin.Context)
+}
+
+// providerRegistry holds the registered identity providers by name
+var providerRegistry = map[string]IdentityProvider{}
+
+// RegisterProvider adds an identity provider to the registry
+// It panics if a provider with the same name is already registered
+func RegisterProvider(p IdentityProvider) {
+	if _, exists := providerRegistry[p.Name()]; exists {
+		panic("identity provider already registered: " + p.Name())
+	}
+	providerRegistry[p.Name()] = p
+}
+
+// lookupProvider returns the registered identity provider with the given name
+func lookupProvider(name string) (IdentityProvider, bool) {
+	p, ok := providerRegistry[name]
+	return p, ok
+}
+
+// RegisteredProviders returns the names of the registered identity providers, sorted
+func RegisteredProviders() []string {
+	names := make([]string, 0, len(providerRegistry))
+	for name := range providerRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OAuthCallback godoc
+// @Summary OAuth callback
+// @Description Handles the OAuth callback of an enabled provider (github, gitlab, google, azure), exchanges the code for an access token, fetches user info, checks the user is allowed, sets session data, and returns normalized user data and expiration time.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param   provider path string true "Identity provider" Enums(github, gitlab, google, azure)
+// @Param   code query string true "OAuth authorization code"
+// @Success 200 {object} models.LoginResponse "Normalized user data and expiration time"
+// @Failure 400 {object} models.SimpleMessageResponse "Missing or invalid code"
+// @Failure 403 {object} models.SimpleMessageResponse "Unauthorized domain, org or group"
+// @Failure 500 {object} models.SimpleMessageResponse "Internal server error"
+// @Router /oauth/{provider}/callback [get]
+func OAuthCallback(p IdentityProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		code := c.Query("code")
+		if code == "" {
+			logger.Warn("Missing 'code' query parameter in login", zap.String("provider", p.Name()))
+			c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: "Code query parameter is required"})
+			return
+		}
+		completeLogin(c, c.Request.Context(), p, code)
+	}
+}
+
+// completeLogin exchanges the code, fetches the profile, checks the user is allowed
+// and stores the session, writing the login response or error to c
+func completeLogin(c *gin.Context, ctx context.Context, p IdentityProvider, code string, opts ...oauth2.AuthCodeOption) {
+	providerLogger := logger.With(zap.String("provider", p.Name()))
+
+	token, err := p.ExchangeCode(ctx, code, opts...)
+	if err != nil {
+		providerLogger.Error("Failed to exchange token", zap.Error(err))
+		writeLoginError(c, err, http.StatusInternalServerError, "Failed to exchange token")
+		return
+	}
+
+	identity, err := p.FetchProfile(ctx, token)
+	if err != nil {
+		providerLogger.Error("Failed to fetch user info", zap.Error(err))
+		writeLoginError(c, err, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Check if the user is allowed to log in
+	allowed, err := p.IsAllowed(ctx, token, identity)
+	if err != nil {
+		providerLogger.Error("Failed to check if user is allowed", zap.Error(err))
+		writeLoginError(c, err, http.StatusInternalServerError, "Failed to check user access")
+		return
+	}
+	if !allowed {
+		message := "Unauthorized user"
+		if d, ok := p.(deniedMessager); ok {
+			message = d.DeniedMessage()
+		}
+		providerLogger.Warn("Login attempt from unauthorized user", zap.String("email", identity.User.Email))
+		c.JSON(http.StatusForbidden, models.SimpleMessageResponse{Error: message})
+		return
+	}
+
+	identity.User.Provider = p.Name()
+	identity.User.ID = qualifyID(p.Name(), identity.User.ID)
+	invalidateUserGroups(p.Name(), identity.User.ID)
+	sessionData := map[string]interface{}{
+		"email": identity.User.Email,
+		"token": token.AccessToken,
+		"id":    identity.User.ID,
+		"name":  identity.User.Name,
+		// provider routes group lookups in CommonPermissions
+		"provider": p.Name(),
+	}
+	for key, value := range identity.SessionData {
+		sessionData[key] = value
+	}
+
+	// Save the session data in the session
+	session := sessions.Default(c)
+	session.Set("data", sessionData)
+	sessioncookie.SplitSessionData(c)
+
+	providerLogger.Debug("Session cookies set successfully", zap.String("name", identity.User.Name))
+
+	c.JSON(http.StatusOK, models.LoginResponse{
+		UserData:  identity.User,
+		ExpiresIn: expiresIn(token),
+	})
+}
+
+// writeLoginError writes err as a LoginError if it is one, otherwise the given status and message
+func writeLoginError(c *gin.Context, err error, status int, message string) {
+	var loginErr *LoginError
+	if errors.As(err, &loginErr) {
+		status, message = loginErr.Status, loginErr.Message
+	}
+	c.JSON(status, models.SimpleMessageResponse{Error: message})
+}
+
+// expiresIn returns the seconds until the token expires, 0 if it does not expire
+func expiresIn(token *oauth2.Token) int {
+	if token.Expiry.IsZero() {
+		return 0
+	}
+	return int(time.Until(token.Expiry).Round(time.Second).Seconds())
+}
+
+// GetProfile godoc
+// @Summary Get the logged in user's profile
+// @Description Returns the normalized user profile from an enabled provider (github, gitlab, google, azure, oidc) for the authenticated user.
+// @Description Requires one or more cookies named kube_jit_session_<number> (e.g., kube_jit_session_0, kube_jit_session_1).
+// @Description Pass split cookies in the Cookie header, for example:
+// @Description     -H "Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}"
+// @Description Note: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param   provider path string true "Identity provider" Enums(github, gitlab, google, azure, oidc)
+// @Param   Cookie header string true "Session cookies (multiple allowed, names: kube_jit_session_0, kube_jit_session_1, etc.)"
+// @Success 200 {object} models.NormalizedUserData
+// @Failure 401 {object} models.SimpleMessageResponse "Unauthorized: no token in session data"
+// @Failure 500 {object} models.SimpleMessageResponse "Internal server error"
+// @Router /{provider}/profile [get]
+func GetProfile(p IdentityProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Check if the user is logged in
+		sessionData := GetSessionData(c)
+		reqLogger := RequestLogger(c).With(zap.String("provider", p.Name()))
+
+		token, ok := sessionData["token"].(string)
+		if !ok || token == "" {
+			reqLogger.Warn("No token in session data for profile")
+			c.JSON(http.StatusUnauthorized, models.SimpleMessageResponse{Error: "Unauthorized: no token in session data"})
+			return
+		}
+
+		identity, err := p.FetchProfile(c.Request.Context(), &oauth2.Token{AccessToken: token})
+		if err != nil {
+			reqLogger.Error("Failed to fetch user profile", zap.Error(err))
+			writeLoginError(c, err, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		identity.User.Provider = p.Name()
+		identity.User.ID = qualifyID(p.Name(), identity.User.ID)
+		c.JSON(http.StatusOK, identity.User)
+	}
+}
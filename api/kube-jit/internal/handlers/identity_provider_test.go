@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"kube-jit/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+)
+
+// fakeProvider is an IdentityProvider returning canned results
+type fakeProvider struct {
+	name        string
+	token       *oauth2.Token
+	exchangeErr error
+	identity    *Identity
+	profileErr  error
+	allowed     bool
+	allowErr    error
+	groups      []models.Team
+	groupsErr   error
+
+	// groupsSession records the session data passed to FetchGroups
+	groupsSession map[string]any
+}
+
+func (p *fakeProvider) Name() string {
+	return p.name
+}
+
+func (p *fakeProvider) ExchangeCode(_ context.Context, _ string, _ ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	return p.token, p.exchangeErr
+}
+
+func (p *fakeProvider) FetchProfile(_ context.Context, _ *oauth2.Token) (*Identity, error) {
+	return p.identity, p.profileErr
+}
+
+func (p *fakeProvider) FetchGroups(_ context.Context, sessionData map[string]any, _ *zap.Logger) ([]models.Team, error) {
+	p.groupsSession = sessionData
+	return p.groups, p.groupsErr
+}
+
+func (p *fakeProvider) IsAllowed(_ context.Context, _ *oauth2.Token, _ *Identity) (bool, error) {
+	return p.allowed, p.allowErr
+}
+
+// useProvider registers p in place of the provider with the same name for the duration of the test
+func useProvider(t *testing.T, p IdentityProvider) {
+	t.Helper()
+	original, existed := providerRegistry[p.Name()]
+	providerRegistry[p.Name()] = p
+	t.Cleanup(func() {
+		if existed {
+			providerRegistry[p.Name()] = original
+		} else {
+			delete(providerRegistry, p.Name())
+		}
+	})
+}
+
+func TestRegisterProvider(t *testing.T) {
+	assert.ElementsMatch(t, []string{"azure", "github", "gitlab", "google", "oidc"}, RegisteredProviders())
+
+	p := &fakeProvider{name: "fake"}
+	RegisterProvider(p)
+	defer delete(providerRegistry, "fake")
+
+	got, ok := lookupProvider("fake")
+	assert.True(t, ok)
+	assert.Same(t, p, got)
+	assert.Panics(t, func() { RegisterProvider(&fakeProvider{name: "fake"}) })
+
+	_, ok = lookupProvider("unknown")
+	assert.False(t, ok)
+}
+
+func TestOAuthCallback(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	identity := &Identity{
+		User:        models.NormalizedUserData{ID: "42", Name: "Jane", Email: "jane@example.com"},
+		SessionData: map[string]any{"extra": "value"},
+	}
+
+	callback := func(p IdentityProvider, query string) *httptest.ResponseRecorder {
+		r := setupTestRouter()
+		r.GET("/oauth/fake/callback", OAuthCallback(p))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/oauth/fake/callback"+query, nil))
+		return w
+	}
+
+	t.Run("success", func(t *testing.T) {
+		p := &fakeProvider{
+			name:     "fake",
+			token:    &oauth2.Token{AccessToken: "token", Expiry: time.Now().Add(time.Hour)},
+			identity: identity,
+			allowed:  true,
+		}
+		w := callback(p, "?code=code")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp models.LoginResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "42", resp.UserData.ID)
+		assert.Equal(t, "fake", resp.UserData.Provider)
+		assert.Equal(t, 3600, resp.ExpiresIn)
+		assert.NotEmpty(t, w.Result().Cookies())
+	})
+
+	t.Run("token without expiry", func(t *testing.T) {
+		p := &fakeProvider{name: "fake", token: &oauth2.Token{AccessToken: "token"}, identity: identity, allowed: true}
+		w := callback(p, "?code=code")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp models.LoginResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, 0, resp.ExpiresIn)
+	})
+
+	t.Run("user ID qualified with several providers", func(t *testing.T) {
+		originalProviders := oauthProviders
+		oauthProviders = []string{"fake", "other"}
+		t.Cleanup(func() { oauthProviders = originalProviders })
+
+		p := &fakeProvider{
+			name:     "fake",
+			token:    &oauth2.Token{AccessToken: "token"},
+			identity: &Identity{User: models.NormalizedUserData{ID: "42", Name: "Jane"}},
+			allowed:  true,
+		}
+		w := callback(p, "?code=code")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp models.LoginResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "fake:42", resp.UserData.ID)
+	})
+
+	t.Run("missing code", func(t *testing.T) {
+		w := callback(&fakeProvider{name: "fake"}, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Code query parameter is required")
+	})
+
+	t.Run("exchange fails", func(t *testing.T) {
+		w := callback(&fakeProvider{name: "fake", exchangeErr: errors.New("boom")}, "?code=code")
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "Failed to exchange token")
+	})
+
+	t.Run("provider chooses the error response", func(t *testing.T) {
+		p := &fakeProvider{
+			name:       "fake",
+			token:      &oauth2.Token{AccessToken: "token"},
+			profileErr: &LoginError{Status: http.StatusUnauthorized, Message: "Invalid ID token", Err: errors.New("bad signature")},
+		}
+		w := callback(p, "?code=code")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "Invalid ID token")
+	})
+
+	t.Run("user not allowed", func(t *testing.T) {
+		p := &fakeProvider{name: "fake", token: &oauth2.Token{AccessToken: "token"}, identity: identity}
+		w := callback(p, "?code=code")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "Unauthorized user")
+	})
+}
+
+func TestGetProfile(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	p := &fakeProvider{name: "fake", identity: &Identity{User: models.NormalizedUserData{ID: "42", Name: "Jane"}}}
+
+	profile := func(sessionData map[string]any) *httptest.ResponseRecorder {
+		r := setupTestRouter()
+		r.Use(func(c *gin.Context) {
+			c.Set("sessionData", sessionData)
+			c.Next()
+		})
+		r.GET("/fake/profile", GetProfile(p))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fake/profile", nil))
+		return w
+	}
+
+	w := profile(map[string]any{"token": "token"})
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp models.NormalizedUserData
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "42", resp.ID)
+	assert.Equal(t, "fake", resp.Provider)
+
+	w = profile(map[string]any{})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Unauthorized: no token in session data")
+}
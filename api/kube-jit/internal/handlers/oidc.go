@@ -0,0 +1,395 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"kube-jit/internal/models"
+	"kube-jit/pkg/utils"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+)
+
+// oidcLoginCookie holds the state, nonce and PKCE verifier between login and callback
+const (
+	oidcLoginCookie    = "kube_jit_oidc_login"
+	oidcLoginCookieTTL = 10 * time.Minute
+)
+
+var (
+	oidcIssuerURL     string
+	oidcGroupsClaim   string
+	oidcScopes        []string
+	oidcAllowedGroups []string
+
+	oidcProviderMu     sync.Mutex
+	oidcProviderCached *oidc.Provider
+)
+
+// OidcLoginResponse represents the response for StartOidcLogin
+type OidcLoginResponse struct {
+	AuthURL string `json:"auth_url"`
+}
+
+// oidcLoginState is stored in a signed cookie between StartOidcLogin and HandleOidcLogin
+type oidcLoginState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// oidcClaims are the ID token claims used to build the user profile
+type oidcClaims struct {
+	Subject           string `json:"sub"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	Picture           string `json:"picture"`
+	Nonce             string `json:"nonce"`
+}
+
+func init() {
+	RegisterProvider(&oidcProvider{})
+
+	if isProviderEnabled("oidc") {
+		oidcIssuerURL = utils.MustGetEnv("OIDC_ISSUER_URL")
+		allowedDomain = utils.GetEnv("ALLOWED_DOMAIN", "")
+	}
+	oidcGroupsClaim = utils.GetEnv("OIDC_GROUPS_CLAIM", "groups")
+	oidcScopes = strings.Fields(utils.GetEnv("OIDC_SCOPES", "openid profile email groups"))
+	oidcAllowedGroups = splitAndTrim(utils.GetEnv("OIDC_ALLOWED_GROUPS", ""))
+}
+
+// getOIDCProvider returns the issuer's provider from OIDC discovery
+// The discovery document and JWKS endpoint are fetched once and cached,
+// a failed discovery is retried on the next call
+var getOIDCProvider = func(ctx context.Context) (*oidc.Provider, error) {
+	oidcProviderMu.Lock()
+	defer oidcProviderMu.Unlock()
+
+	if oidcProviderCached != nil {
+		return oidcProviderCached, nil
+	}
+	provider, err := oidc.NewProvider(oauthContext(ctx, "oidc"), oidcIssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed OIDC discovery for issuer %s: %w", oidcIssuerURL, err)
+	}
+	oidcProviderCached = provider
+	return provider, nil
+}
+
+// getOIDCOAuthConfig constructs the OAuth2 config from the discovered provider endpoints
+func getOIDCOAuthConfig(provider *oidc.Provider) *oauth2.Config {
+	client := oauthClient("oidc")
+	return &oauth2.Config{
+		ClientID:     client.ClientID,
+		ClientSecret: client.ClientSecret,
+		RedirectURL:  client.RedirectURI,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       oidcScopes,
+	}
+}
+
+// StartOidcLogin godoc
+// @Summary Start OIDC login
+// @Description Generates state, nonce and a PKCE verifier, stores them in a short-lived signed cookie and returns the authorization URL to redirect the user to.
+// @Tags oidc
+// @Produce  json
+// @Success 200 {object} handlers.OidcLoginResponse "Authorization URL"
+// @Failure 500 {object} models.SimpleMessageResponse "Internal server error"
+// @Router /oauth/oidc/login [get]
+func StartOidcLogin(c *gin.Context) {
+	provider, err := getOIDCProvider(c.Request.Context())
+	if err != nil {
+		logger.Error("Failed to get OIDC provider", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to reach identity provider"})
+		return
+	}
+
+	state, err := randomString()
+	if err != nil {
+		logger.Error("Failed to generate OIDC state", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to start login"})
+		return
+	}
+	nonce, err := randomString()
+	if err != nil {
+		logger.Error("Failed to generate OIDC nonce", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to start login"})
+		return
+	}
+	loginState := oidcLoginState{
+		State:    state,
+		Nonce:    nonce,
+		Verifier: oauth2.GenerateVerifier(),
+	}
+
+	encoded, err := utils.SecureCookie().Encode(oidcLoginCookie, loginState)
+	if err != nil {
+		logger.Error("Failed to encode OIDC login state", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to start login"})
+		return
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oidcLoginCookie,
+		Value:    encoded,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   true,
+		MaxAge:   int(oidcLoginCookieTTL.Seconds()),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	authURL := getOIDCOAuthConfig(provider).AuthCodeURL(
+		loginState.State,
+		oidc.Nonce(loginState.Nonce),
+		oauth2.S256ChallengeOption(loginState.Verifier),
+	)
+	c.JSON(http.StatusOK, OidcLoginResponse{AuthURL: authURL})
+}
+
+// HandleOidcLogin godoc
+// @Summary OIDC OAuth callback
+// @Description Handles the OIDC callback, validates state, exchanges the code with the PKCE verifier, verifies the ID token signature against the issuer JWKS, maps the groups claim to teams, sets session data, and returns normalized user data and expiration time.
+// @Tags oidc
+// @Accept  json
+// @Produce  json
+// @Param   code query string true "OIDC authorization code"
+// @Param   state query string true "OIDC state returned by the identity provider"
+// @Success 200 {object} models.LoginResponse "Normalized user data and expiration time"
+// @Failure 400 {object} models.SimpleMessageResponse "Missing or invalid code or state"
+// @Failure 401 {object} models.SimpleMessageResponse "Invalid ID token"
+// @Failure 403 {object} models.SimpleMessageResponse "Unauthorized user"
+// @Failure 500 {object} models.SimpleMessageResponse "Internal server error"
+// @Router /oauth/oidc/callback [get]
+func HandleOidcLogin(c *gin.Context) {
+	code := c.Query("code")
+	if code == "" {
+		logger.Warn("Missing 'code' query parameter in OIDC login")
+		c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: "Code query parameter is required"})
+		return
+	}
+
+	// Validate state against the login cookie
+	var loginState oidcLoginState
+	cookie, err := c.Cookie(oidcLoginCookie)
+	if err == nil {
+		err = utils.SecureCookie().Decode(oidcLoginCookie, cookie, &loginState)
+	}
+	if err != nil || loginState.State == "" || loginState.State != c.Query("state") {
+		logger.Warn("Invalid or missing OIDC login state", zap.Error(err))
+		c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: "Invalid login state"})
+		return
+	}
+	// The login state is single use
+	c.SetCookie(oidcLoginCookie, "", -1, "/", "", true, true)
+
+	// Exchange the authorization code with the PKCE verifier, the ID token must carry the login nonce
+	ctx := context.WithValue(c.Request.Context(), oidcNonceKey{}, loginState.Nonce)
+	completeLogin(c, ctx, providerRegistry["oidc"], code, oauth2.VerifierOption(loginState.Verifier))
+}
+
+// oidcProvider implements IdentityProvider for any OpenID Connect issuer
+// Users are allowed by email domain and/or groups claim, groups come from the verified ID token
+type oidcProvider struct{}
+
+// oidcNonceKey carries the expected ID token nonce from the callback to FetchProfile
+type oidcNonceKey struct{}
+
+func (p *oidcProvider) Name() string {
+	return "oidc"
+}
+
+func (p *oidcProvider) StartLogin(c *gin.Context) {
+	StartOidcLogin(c)
+}
+
+func (p *oidcProvider) HandleCallback(c *gin.Context) {
+	HandleOidcLogin(c)
+}
+
+// ExchangeCode exchanges the code at the discovered token endpoint
+func (p *oidcProvider) ExchangeCode(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	provider, err := getOIDCProvider(ctx)
+	if err != nil {
+		return nil, &LoginError{Status: http.StatusInternalServerError, Message: "Failed to reach identity provider", Err: err}
+	}
+	return getOIDCOAuthConfig(provider).Exchange(oauthContext(ctx, "oidc"), code, opts...)
+}
+
+// FetchProfile verifies the ID token during login, and reads the userinfo endpoint otherwise
+func (p *oidcProvider) FetchProfile(ctx context.Context, token *oauth2.Token) (*Identity, error) {
+	provider, err := getOIDCProvider(ctx)
+	if err != nil {
+		return nil, &LoginError{Status: http.StatusInternalServerError, Message: "Failed to reach identity provider", Err: err}
+	}
+	ctx = oauthContext(ctx, "oidc")
+
+	nonce, login := ctx.Value(oidcNonceKey{}).(string)
+	if !login {
+		userInfo, err := provider.UserInfo(ctx, oauth2.StaticTokenSource(token))
+		if err != nil {
+			return nil, &LoginError{Status: http.StatusInternalServerError, Message: "Failed to fetch user info", Err: err}
+		}
+		var claims oidcClaims
+		if err := userInfo.Claims(&claims); err != nil {
+			return nil, &LoginError{Status: http.StatusInternalServerError, Message: "Failed to decode user info", Err: err}
+		}
+		return &Identity{User: normalizeOidcUser(claims)}, nil
+	}
+
+	// Verify the ID token signature, issuer, audience and expiry
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, &LoginError{Status: http.StatusUnauthorized, Message: "Invalid ID token", Err: fmt.Errorf("no id_token in token response")}
+	}
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: oauthClient("oidc").ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, &LoginError{Status: http.StatusUnauthorized, Message: "Invalid ID token", Err: err}
+	}
+
+	var claims oidcClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, &LoginError{Status: http.StatusInternalServerError, Message: "Failed to decode ID token", Err: err}
+	}
+	if claims.Nonce != nonce {
+		return nil, &LoginError{Status: http.StatusUnauthorized, Message: "Invalid ID token", Err: fmt.Errorf("nonce mismatch")}
+	}
+
+	groups, err := oidcGroupsFromToken(idToken)
+	if err != nil {
+		return nil, &LoginError{Status: http.StatusInternalServerError, Message: "Failed to decode groups claim", Err: err}
+	}
+
+	return &Identity{
+		User:        normalizeOidcUser(claims),
+		SessionData: map[string]any{"oidcGroups": groups, "oidcEmailVerified": claims.EmailVerified},
+	}, nil
+}
+
+// FetchGroups returns the groups kept in the session at login
+func (p *oidcProvider) FetchGroups(_ context.Context, sessionData map[string]any, _ *zap.Logger) ([]models.Team, error) {
+	return GetOidcGroups(sessionData), nil
+}
+
+// IsAllowed checks the email domain and/or group membership, whichever is configured
+func (p *oidcProvider) IsAllowed(_ context.Context, _ *oauth2.Token, identity *Identity) (bool, error) {
+	groups, _ := identity.SessionData["oidcGroups"].([]string)
+	emailVerified, _ := identity.SessionData["oidcEmailVerified"].(bool)
+	return oidcUserAllowed(identity.User.Email, emailVerified, groups), nil
+}
+
+// oidcUserAllowed checks the email domain and/or group membership, whichever is configured
+// It fails closed if no restriction is configured, and the domain is only trusted for verified emails
+func oidcUserAllowed(email string, emailVerified bool, groups []string) bool {
+	if allowedDomain == "" && len(oidcAllowedGroups) == 0 {
+		return false
+	}
+	if allowedDomain != "" && (!emailVerified || !emailInAllowedDomain(email)) {
+		return false
+	}
+	if len(oidcAllowedGroups) > 0 {
+		for _, group := range groups {
+			if contains(oidcAllowedGroups, group) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// GetOidcGroups returns the groups of the logged in user as teams
+// Groups are taken from the verified ID token at login and kept in the session
+func GetOidcGroups(sessionData map[string]interface{}) []models.Team {
+	var groups []string
+	switch raw := sessionData["oidcGroups"].(type) {
+	case []string:
+		groups = raw
+	case []any:
+		for _, g := range raw {
+			if s, ok := g.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+	}
+
+	teams := make([]models.Team, 0, len(groups))
+	for _, g := range groups {
+		teams = append(teams, models.Team{ID: g, Name: g})
+	}
+	return teams
+}
+
+// oidcGroupsFromToken reads the configured groups claim, a missing claim means no groups
+func oidcGroupsFromToken(idToken *oidc.IDToken) ([]string, error) {
+	var raw map[string]any
+	if err := idToken.Claims(&raw); err != nil {
+		return nil, err
+	}
+
+	var groups []string
+	switch value := raw[oidcGroupsClaim].(type) {
+	case nil:
+	case string:
+		groups = append(groups, value)
+	case []any:
+		for _, g := range value {
+			s, ok := g.(string)
+			if !ok {
+				return nil, fmt.Errorf("claim %q contains a non-string value", oidcGroupsClaim)
+			}
+			groups = append(groups, s)
+		}
+	default:
+		return nil, fmt.Errorf("claim %q is not a string or list of strings", oidcGroupsClaim)
+	}
+	return groups, nil
+}
+
+// normalizeOidcUser builds the normalized profile from standard claims
+func normalizeOidcUser(claims oidcClaims) models.NormalizedUserData {
+	name := claims.Name
+	if name == "" {
+		name = claims.PreferredUsername
+	}
+	if name == "" {
+		name = claims.Email
+	}
+	return models.NormalizedUserData{
+		ID:        claims.Subject,
+		Name:      name,
+		Email:     claims.Email,
+		AvatarURL: claims.Picture,
+		Provider:  "oidc",
+	}
+}
+
+// randomString returns a URL safe random string for state and nonce values
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// splitAndTrim splits a comma separated list and drops empty entries
+func splitAndTrim(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,342 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"kube-jit/internal/models"
+	"kube-jit/pkg/sessioncookie"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubIssuer is a minimal OIDC issuer serving discovery, JWKS, token and userinfo endpoints
+type stubIssuer struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	signKey   *rsa.PrivateKey // key used to sign ID tokens, differs from key to simulate a bad signature
+	challenge string
+	nonce     string
+	email     string
+	verified  bool
+	groups    []string
+}
+
+func newStubIssuer(t *testing.T) *stubIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	s := &stubIssuer{key: key, signKey: key, email: "jane@example.com", verified: true, groups: []string{"platform", "dev"}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                s.server.URL,
+			"authorization_endpoint":                s.server.URL + "/authorize",
+			"token_endpoint":                        s.server.URL + "/token",
+			"jwks_uri":                              s.server.URL + "/keys",
+			"userinfo_endpoint":                     s.server.URL + "/userinfo",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{
+			Key:       &s.key.PublicKey,
+			KeyID:     "test",
+			Algorithm: "RS256",
+			Use:       "sig",
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(sum[:]) != s.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "stub-access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     s.idToken(t),
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer stub-access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"sub":   "user-123",
+			"name":  "Jane Doe",
+			"email": s.email,
+		})
+	})
+	s.server = httptest.NewServer(mux)
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+// idToken signs an ID token for the current nonce, email and groups
+func (s *stubIssuer) idToken(t *testing.T) string {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: s.signKey},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "test"),
+	)
+	require.NoError(t, err)
+
+	payload, err := json.Marshal(map[string]any{
+		"iss":            s.server.URL,
+		"sub":            "user-123",
+		"aud":            oauthClient("oidc").ClientID,
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          s.nonce,
+		"name":           "Jane Doe",
+		"email":          s.email,
+		"groups":         s.groups,
+		"email_verified": s.verified,
+	})
+	require.NoError(t, err)
+
+	signed, err := signer.Sign(payload)
+	require.NoError(t, err)
+	token, err := signed.CompactSerialize()
+	require.NoError(t, err)
+	return token
+}
+
+// setupOidcTest points the OIDC handlers at the stub issuer and restores package state after the test
+func setupOidcTest(t *testing.T, issuer *stubIssuer) {
+	t.Helper()
+	origIssuer, origDomain, origAllowedGroups, origProvider := oidcIssuerURL, allowedDomain, oidcAllowedGroups, oidcProviderCached
+	origClients := oauthClients
+	t.Cleanup(func() {
+		oidcIssuerURL, allowedDomain, oidcAllowedGroups, oidcProviderCached = origIssuer, origDomain, origAllowedGroups, origProvider
+		oauthClients = origClients
+	})
+	oauthClients = map[string]oauthClientConfig{
+		"oidc": {ClientID: "test-oidc-client-id", ClientSecret: "test-oidc-client-secret", RedirectURI: "http://localhost/test/oidc/callback"},
+	}
+	oidcIssuerURL = issuer.server.URL
+	allowedDomain = ""
+	oidcAllowedGroups = []string{"platform"}
+	oidcProviderCached = nil
+}
+
+// startOidcLogin calls the login endpoint, records the PKCE challenge and nonce on the issuer
+// and returns the login cookie and state
+func startOidcLogin(t *testing.T, r *gin.Engine, issuer *stubIssuer) (*http.Cookie, string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/oauth/oidc/login", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp OidcLoginResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	authURL, err := url.Parse(resp.AuthURL)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(resp.AuthURL, issuer.server.URL+"/authorize"))
+	assert.Equal(t, "S256", authURL.Query().Get("code_challenge_method"))
+
+	issuer.challenge = authURL.Query().Get("code_challenge")
+	issuer.nonce = authURL.Query().Get("nonce")
+
+	var loginCookie *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == oidcLoginCookie {
+			loginCookie = cookie
+		}
+	}
+	require.NotNil(t, loginCookie, "login cookie should be set")
+	return loginCookie, authURL.Query().Get("state")
+}
+
+func oidcCallback(r *gin.Engine, cookie *http.Cookie, state string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/oauth/oidc/callback?code=auth_code&state="+url.QueryEscape(state), nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func setupOidcRouter() *gin.Engine {
+	r := setupTestRouter()
+	r.GET("/oauth/oidc/login", StartOidcLogin)
+	r.GET("/oauth/oidc/callback", HandleOidcLogin)
+	return r
+}
+
+func TestHandleOidcLogin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("success", func(t *testing.T) {
+		issuer := newStubIssuer(t)
+		setupOidcTest(t, issuer)
+		r := setupOidcRouter()
+
+		cookie, state := startOidcLogin(t, r, issuer)
+		w := oidcCallback(r, cookie, state)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp models.LoginResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "user-123", resp.UserData.ID)
+		assert.Equal(t, "Jane Doe", resp.UserData.Name)
+		assert.Equal(t, "jane@example.com", resp.UserData.Email)
+		assert.Equal(t, "oidc", resp.UserData.Provider)
+
+		sessionCookieFound := false
+		for _, c := range w.Result().Cookies() {
+			if strings.HasPrefix(c.Name, sessioncookie.SessionPrefix) {
+				sessionCookieFound = true
+			}
+		}
+		assert.True(t, sessionCookieFound, "Session cookie should be set")
+	})
+
+	t.Run("state mismatch", func(t *testing.T) {
+		issuer := newStubIssuer(t)
+		setupOidcTest(t, issuer)
+		r := setupOidcRouter()
+
+		cookie, _ := startOidcLogin(t, r, issuer)
+		w := oidcCallback(r, cookie, "forged-state")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("missing login cookie", func(t *testing.T) {
+		issuer := newStubIssuer(t)
+		setupOidcTest(t, issuer)
+		r := setupOidcRouter()
+
+		_, state := startOidcLogin(t, r, issuer)
+		w := oidcCallback(r, nil, state)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("wrong PKCE verifier", func(t *testing.T) {
+		issuer := newStubIssuer(t)
+		setupOidcTest(t, issuer)
+		r := setupOidcRouter()
+
+		cookie, state := startOidcLogin(t, r, issuer)
+		issuer.challenge = "not-the-challenge"
+		w := oidcCallback(r, cookie, state)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("ID token signed with unknown key", func(t *testing.T) {
+		issuer := newStubIssuer(t)
+		setupOidcTest(t, issuer)
+		otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		issuer.signKey = otherKey
+		r := setupOidcRouter()
+
+		cookie, state := startOidcLogin(t, r, issuer)
+		w := oidcCallback(r, cookie, state)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("nonce mismatch", func(t *testing.T) {
+		issuer := newStubIssuer(t)
+		setupOidcTest(t, issuer)
+		r := setupOidcRouter()
+
+		cookie, state := startOidcLogin(t, r, issuer)
+		issuer.nonce = "replayed-nonce"
+		w := oidcCallback(r, cookie, state)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("unverified email in allowed domain", func(t *testing.T) {
+		issuer := newStubIssuer(t)
+		setupOidcTest(t, issuer)
+		allowedDomain, oidcAllowedGroups = "example.com", nil
+		issuer.verified = false
+		r := setupOidcRouter()
+
+		cookie, state := startOidcLogin(t, r, issuer)
+		w := oidcCallback(r, cookie, state)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("user not in allowed group", func(t *testing.T) {
+		issuer := newStubIssuer(t)
+		setupOidcTest(t, issuer)
+		issuer.groups = []string{"sales"}
+		r := setupOidcRouter()
+
+		cookie, state := startOidcLogin(t, r, issuer)
+		w := oidcCallback(r, cookie, state)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+func TestGetOidcProfile(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	issuer := newStubIssuer(t)
+	setupOidcTest(t, issuer)
+
+	r := gin.New()
+	r.GET("/oidc/profile", func(c *gin.Context) {
+		c.Set("sessionData", map[string]interface{}{"token": "stub-access-token"})
+		GetProfile(&oidcProvider{})(c)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/oidc/profile", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp models.NormalizedUserData
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "user-123", resp.ID)
+	assert.Equal(t, "oidc", resp.Provider)
+}
+
+func TestGetOidcGroups(t *testing.T) {
+	// Groups round-trip through the JSON session cookie as []any
+	teams := GetOidcGroups(map[string]interface{}{"oidcGroups": []any{"platform", "dev"}})
+	assert.Equal(t, []models.Team{{ID: "platform", Name: "platform"}, {ID: "dev", Name: "dev"}}, teams)
+
+	assert.Empty(t, GetOidcGroups(map[string]interface{}{}))
+}
+
+func TestOidcUserAllowed(t *testing.T) {
+	origDomain, origGroups := allowedDomain, oidcAllowedGroups
+	defer func() { allowedDomain, oidcAllowedGroups = origDomain, origGroups }()
+
+	allowedDomain, oidcAllowedGroups = "", nil
+	assert.False(t, oidcUserAllowed("jane@example.com", true, nil), "no restriction configured must deny")
+
+	allowedDomain, oidcAllowedGroups = "example.com", nil
+	assert.True(t, oidcUserAllowed("jane@example.com", true, nil))
+	assert.False(t, oidcUserAllowed("jane@other.com", true, nil))
+	assert.False(t, oidcUserAllowed("jane@example.com", false, nil), "unverified email must not match the domain")
+
+	allowedDomain, oidcAllowedGroups = "example.com", []string{"platform"}
+	assert.True(t, oidcUserAllowed("jane@example.com", true, []string{"platform"}))
+	assert.False(t, oidcUserAllowed("jane@example.com", true, []string{"dev"}))
+
+	allowedDomain, oidcAllowedGroups = "", []string{"platform"}
+	assert.True(t, oidcUserAllowed("jane@example.com", false, []string{"platform"}), "group only restriction ignores email_verified")
+}
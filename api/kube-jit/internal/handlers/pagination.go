@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// maxPages bounds the pages followed for one listing, guarding against a provider looping on next links
+const maxPages = 100
+
+// pageFetcher fetches the page at url into out and returns the url of the next page, empty on the last page
+type pageFetcher func(ctx context.Context, url string, out any) (string, error)
+
+// fetchAllPages follows the next page urls returned by fetch, starting at url,
+// and returns the items of every page
+func fetchAllPages[T any](ctx context.Context, fetch pageFetcher, url string) ([]T, error) {
+	var all []T
+	for page := 0; url != ""; page++ {
+		if page == maxPages {
+			return nil, fmt.Errorf("stopped after %d pages, next page is %s", maxPages, url)
+		}
+		var items []T
+		next, err := fetch(ctx, url, &items)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+		url = next
+	}
+	return all, nil
+}
+
+// nextLink returns the rel="next" url of a Link header, as sent by the GitHub and GitLab APIs
+// e.g. Link: <https://api.github.com/user/teams?page=2>; rel="next", <...>; rel="last"
+func nextLink(header http.Header) string {
+	for _, link := range strings.Split(header.Get("Link"), ",") {
+		target, params, ok := strings.Cut(link, ";")
+		if !ok {
+			continue
+		}
+		for _, param := range strings.Split(params, ";") {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(target), "<>")
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextLink(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{
+			name:   "next and last",
+			header: `<https://api.github.com/user/teams?page=2>; rel="next", <https://api.github.com/user/teams?page=5>; rel="last"`,
+			want:   "https://api.github.com/user/teams?page=2",
+		},
+		{
+			name:   "next after first",
+			header: `<https://gitlab.com/api/v4/groups?page=1>; rel="first", <https://gitlab.com/api/v4/groups?page=3>; rel="next"`,
+			want:   "https://gitlab.com/api/v4/groups?page=3",
+		},
+		{name: "last page", header: `<https://api.github.com/user/teams?page=1>; rel="prev"`, want: ""},
+		{name: "no header", header: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.header != "" {
+				header.Set("Link", tt.header)
+			}
+			assert.Equal(t, tt.want, nextLink(header))
+		})
+	}
+}
+
+func TestFetchAllPages(t *testing.T) {
+	// Each page holds its number, the next url is the next page number until page 3
+	fetch := func(_ context.Context, url string, out any) (string, error) {
+		page, _ := strconv.Atoi(url)
+		*out.(*[]int) = []int{page}
+		if page == 3 {
+			return "", nil
+		}
+		return strconv.Itoa(page + 1), nil
+	}
+	items, err := fetchAllPages[int](context.Background(), fetch, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, items)
+
+	t.Run("error on a page", func(t *testing.T) {
+		failing := func(_ context.Context, url string, out any) (string, error) {
+			if url == "2" {
+				return "", errors.New("boom")
+			}
+			return fetch(context.Background(), url, out)
+		}
+		_, err := fetchAllPages[int](context.Background(), failing, "1")
+		assert.EqualError(t, err, "boom")
+	})
+
+	t.Run("endless next links", func(t *testing.T) {
+		loop := func(_ context.Context, url string, _ any) (string, error) {
+			return url, nil
+		}
+		_, err := fetchAllPages[int](context.Background(), loop, "1")
+		assert.ErrorContains(t, err, "stopped after 100 pages")
+	})
+}
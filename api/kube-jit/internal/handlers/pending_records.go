@@ -5,6 +5,7 @@ import (
 	"kube-jit/internal/models"
 	"net/http"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -28,6 +29,7 @@ type PendingRequestRow struct {
 	GroupName     string    `json:"groupName"`
 	Approved      bool      `json:"approved"`
 	CreatedAt     time.Time `json:"CreatedAt"`
+	BundleID      string    `json:"bundleID,omitempty"`
 }
 
 type PendingRequest struct {
@@ -46,6 +48,7 @@ type PendingRequest struct {
 	GroupNames    []string  `json:"groupNames"`
 	ApprovedList  []bool    `json:"approvedList"`
 	CreatedAt     time.Time `json:"CreatedAt"`
+	BundleID      string    `json:"bundleID,omitempty"` // shared by the requests submitted with a cluster selector
 }
 
 // PendingApprovalsResponse is used for Swagger docs
@@ -56,16 +59,18 @@ type PendingApprovalsResponse struct {
 // GetPendingApprovals godoc
 // @Summary Get pending JIT requests for approver groups
 // @Description Returns the pending JIT requests for the authenticated user's approver groups.
+// @Description Users with the requests:approve permission for some clusters or roles also get every pending request for them.
 // @Description Requires one or more cookies named kube_jit_session_<number> (e.g., kube_jit_session_0, kube_jit_session_1).
 // @Description Pass split cookies in the Cookie header, for example:
 // @Description     -H "Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}"
 // @Description Note: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.
+// @Description Scripts can use an API token with the approve scope instead: -H "Authorization: Bearer ${token}"
 // @Tags records
 // @Accept  json
 // @Produce  json
 // @Param   Cookie header string true "Session cookies (multiple allowed, names: kube_jit_session_0, kube_jit_session_1, etc.)"
 // @Success 200 {object} handlers.PendingApprovalsResponse "List of pending requests"
-// @Failure 401 {object} models.SimpleMessageResponse "Unauthorized: no approver groups in session"
+// @Failure 403 {object} models.SimpleMessageResponse "Forbidden: missing permission requests:approve"
 // @Failure 500 {object} models.SimpleMessageResponse "Failed to fetch pending requests"
 // @Router /approvals [get]
 func GetPendingApprovals(c *gin.Context) {
@@ -74,15 +79,14 @@ func GetPendingApprovals(c *gin.Context) {
 
 	reqLogger.Debug("GetPendingApprovals: Got sessionData", zap.Any("sessionData", sessionData))
 
-	isAdmin, isAdminOk := sessionData["isAdmin"].(bool)
-	isPlatformApprover, isPlatformApproverOk := sessionData["isPlatformApprover"].(bool)
-	reqLogger.Debug("GetPendingApprovals: Admin/PlatformApprover check", zap.Bool("isAdmin", isAdmin), zap.Bool("isAdminOk", isAdminOk), zap.Bool("isPlatformApprover", isPlatformApprover), zap.Bool("isPlatformApproverOk", isPlatformApproverOk))
+	access := AccessOf(c)
+	reqLogger.Debug("GetPendingApprovals: Access check", zap.Bool("approvesAll", access.CanAll(models.PermissionApprove)), zap.Strings("approverGroups", access.ApproverGroups))
 
-	if (isAdminOk && isAdmin) || (isPlatformApproverOk && isPlatformApprover) {
+	if access.CanAll(models.PermissionApprove) {
 		reqLogger.Debug("GetPendingApprovals: Admin or Platform Approver path")
 		var pendingRequests []models.RequestData // This is a slice of models.RequestData
 
-		if err := db.DB.
+		if err := db.DB.WithContext(c.Request.Context()).
 			Where("status = ?", "Requested").
 			Find(&pendingRequests).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: err.Error()})
@@ -98,42 +102,32 @@ func GetPendingApprovals(c *gin.Context) {
 		return
 	}
 
+	// Namespaces of the user's JitGroups, and every namespace of the requests for the clusters and roles of the user's API roles
 	reqLogger.Debug("GetPendingApprovals: Non-admin path")
-	rawApproverGroups, ok := sessionData["approverGroups"]
-	reqLogger.Debug("GetPendingApprovals: ApproverGroups check", zap.Any("rawApproverGroups", rawApproverGroups), zap.Bool("ok", ok))
-
-	if !ok {
-		reqLogger.Info("GetPendingApprovals: No approver groups in session, returning 401")
-		c.JSON(http.StatusUnauthorized, models.SimpleMessageResponse{Error: "Unauthorized: no approver groups in session"})
-		return
+	var approverConditions []string
+	var approverArgs []any
+	if len(access.ApproverGroups) > 0 {
+		approverConditions = append(approverConditions, "request_namespaces.group_id IN (?)")
+		approverArgs = append(approverArgs, access.ApproverGroups)
 	}
-
-	// Convert approverGroups to a slice of group IDs
-	reqLogger.Debug("GetPendingApprovals: Processing approver groups")
-	approverGroupIDs := []string{}
-	if rawGroups, ok := rawApproverGroups.([]models.Team); ok {
-		for _, group := range rawGroups {
-			approverGroupIDs = append(approverGroupIDs, group.ID)
-		}
-	} else if rawGroups, ok := rawApproverGroups.([]any); ok {
-		for _, group := range rawGroups {
-			if groupMap, ok := group.(map[string]any); ok {
-				if id, ok := groupMap["id"].(string); ok {
-					approverGroupIDs = append(approverGroupIDs, id)
-				}
-			}
-		}
+	if condition, args := access.scopeCondition(models.PermissionApprove); condition != "" {
+		approverConditions = append(approverConditions, condition)
+		approverArgs = append(approverArgs, args...)
 	}
-
-	if len(approverGroupIDs) == 0 {
-		reqLogger.Debug("GetPendingApprovals: No approver groups in session, returning 401")
-		c.JSON(http.StatusUnauthorized, models.SimpleMessageResponse{Error: "Unauthorized: no approver groups in session"})
+	if len(approverConditions) == 0 {
+		reqLogger.Debug("GetPendingApprovals: No approver groups or API roles, returning 403")
+		c.JSON(http.StatusForbidden, models.SimpleMessageResponse{Error: "Forbidden: missing permission " + models.PermissionApprove})
 		return
 	}
 
+	approverCondition := strings.Join(approverConditions, " OR ")
+	if len(approverConditions) > 1 {
+		approverCondition = "(" + approverCondition + ")"
+	}
+
 	var rows []PendingRequestRow
 
-	if err := db.DB.
+	if err := db.DB.WithContext(c.Request.Context()).
 		Table("request_data").
 		Select(
 			"request_data.id, "+
@@ -146,13 +140,14 @@ func GetPendingApprovals(c *gin.Context) {
 				"request_data.end_date, "+
 				"request_data.created_at, "+
 				"request_data.users, "+
+				"request_data.bundle_id, "+
 				"request_namespaces.namespace, "+
 				"request_namespaces.group_id, "+
 				"request_namespaces.group_name, "+
 				"request_namespaces.approved",
 		).
 		Joins("JOIN request_namespaces ON request_namespaces.request_id = request_data.id").
-		Where("request_namespaces.group_id IN (?) AND request_data.status = ? AND request_namespaces.approved = false", approverGroupIDs, "Requested").
+		Where(approverCondition+" AND request_data.status = ? AND request_namespaces.approved = false", append(approverArgs, "Requested")...).
 		Scan(&rows).Error; err != nil {
 		reqLogger.Error("GetPendingApprovals: Error fetching pending requests", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: err.Error()})
@@ -175,6 +170,7 @@ func GetPendingApprovals(c *gin.Context) {
 				StartDate:     row.StartDate,
 				EndDate:       row.EndDate,
 				CreatedAt:     row.CreatedAt,
+				BundleID:      row.BundleID,
 				Namespaces:    []string{},
 				GroupIDs:      []string{},
 				GroupNames:    []string{},
@@ -130,6 +130,12 @@ func TestGetPendingApprovals(t *testing.T) {
 		Namespace: "ns3", GroupID: "groupX", GroupName: "Group X", Approved: false,
 	}
 
+	useApiRoles(t, models.ApiRole{
+		Name:        "cluster-c-approver",
+		Permissions: []string{models.PermissionApprove},
+		Clusters:    []string{"cluster-c"},
+	})
+
 	testCases := []struct {
 		name                string
 		setupSession        func(t *testing.T, c *gin.Context) // setupSession still takes a *gin.Context
@@ -209,7 +215,7 @@ func TestGetPendingApprovals(t *testing.T) {
 					AddRow(nonAdminRow1Ns2.ID, nonAdminRow1Ns2.ClusterName, nonAdminRow1Ns2.RoleName, nonAdminRow1Ns2.UserID, nonAdminRow1Ns2.Username, nonAdminRow1Ns2.Justification, nonAdminRow1Ns2.StartDate, nonAdminRow1Ns2.EndDate, nonAdminRow1Ns2.CreatedAt, usersReq1JSON, nonAdminRow1Ns2.Namespace, nonAdminRow1Ns2.GroupID, nonAdminRow1Ns2.GroupName, nonAdminRow1Ns2.Approved).
 					AddRow(nonAdminRow2.ID, nonAdminRow2.ClusterName, nonAdminRow2.RoleName, nonAdminRow2.UserID, nonAdminRow2.Username, nonAdminRow2.Justification, nonAdminRow2.StartDate, nonAdminRow2.EndDate, nonAdminRow2.CreatedAt, usersReq2JSON, nonAdminRow2.Namespace, nonAdminRow2.GroupID, nonAdminRow2.GroupName, nonAdminRow2.Approved)
 
-				expectedQuery := regexp.QuoteMeta(`SELECT request_data.id, request_data.cluster_name, request_data.role_name, request_data.user_id, request_data.username, request_data.justification, request_data.start_date, request_data.end_date, request_data.created_at, request_data.users, request_namespaces.namespace, request_namespaces.group_id, request_namespaces.group_name, request_namespaces.approved FROM "request_data" JOIN request_namespaces ON request_namespaces.request_id = request_data.id WHERE request_namespaces.group_id IN ($1,$2) AND request_data.status = $3 AND request_namespaces.approved = false`)
+				expectedQuery := regexp.QuoteMeta(`SELECT request_data.id, request_data.cluster_name, request_data.role_name, request_data.user_id, request_data.username, request_data.justification, request_data.start_date, request_data.end_date, request_data.created_at, request_data.users, request_data.bundle_id, request_namespaces.namespace, request_namespaces.group_id, request_namespaces.group_name, request_namespaces.approved FROM "request_data" JOIN request_namespaces ON request_namespaces.request_id = request_data.id WHERE request_namespaces.group_id IN ($1,$2) AND request_data.status = $3 AND request_namespaces.approved = false`)
 				mock.ExpectQuery(expectedQuery).
 					WithArgs("group1", "group2", "Requested").
 					WillReturnRows(rows)
@@ -237,7 +243,7 @@ func TestGetPendingApprovals(t *testing.T) {
 				rows := sqlmock.NewRows(cols).
 					AddRow(nonAdminRow3AnyGroup.ID, nonAdminRow3AnyGroup.ClusterName, nonAdminRow3AnyGroup.RoleName, nonAdminRow3AnyGroup.UserID, nonAdminRow3AnyGroup.Username, nonAdminRow3AnyGroup.Justification, nonAdminRow3AnyGroup.StartDate, nonAdminRow3AnyGroup.EndDate, nonAdminRow3AnyGroup.CreatedAt, usersReq3JSON, nonAdminRow3AnyGroup.Namespace, nonAdminRow3AnyGroup.GroupID, nonAdminRow3AnyGroup.GroupName, nonAdminRow3AnyGroup.Approved)
 
-				expectedQuery := regexp.QuoteMeta(`SELECT request_data.id, request_data.cluster_name, request_data.role_name, request_data.user_id, request_data.username, request_data.justification, request_data.start_date, request_data.end_date, request_data.created_at, request_data.users, request_namespaces.namespace, request_namespaces.group_id, request_namespaces.group_name, request_namespaces.approved FROM "request_data" JOIN request_namespaces ON request_namespaces.request_id = request_data.id WHERE request_namespaces.group_id IN ($1) AND request_data.status = $2 AND request_namespaces.approved = false`)
+				expectedQuery := regexp.QuoteMeta(`SELECT request_data.id, request_data.cluster_name, request_data.role_name, request_data.user_id, request_data.username, request_data.justification, request_data.start_date, request_data.end_date, request_data.created_at, request_data.users, request_data.bundle_id, request_namespaces.namespace, request_namespaces.group_id, request_namespaces.group_name, request_namespaces.approved FROM "request_data" JOIN request_namespaces ON request_namespaces.request_id = request_data.id WHERE request_namespaces.group_id IN ($1) AND request_data.status = $2 AND request_namespaces.approved = false`)
 				mock.ExpectQuery(expectedQuery).
 					WithArgs("groupX", "Requested").
 					WillReturnRows(rows)
@@ -261,7 +267,7 @@ func TestGetPendingApprovals(t *testing.T) {
 				cols := []string{"id", "cluster_name", "role_name", "user_id", "username", "justification", "start_date", "end_date", "created_at", "users", "namespace", "group_id", "group_name", "approved"}
 				rows := sqlmock.NewRows(cols) // No rows added
 
-				expectedQuery := regexp.QuoteMeta(`SELECT request_data.id, request_data.cluster_name, request_data.role_name, request_data.user_id, request_data.username, request_data.justification, request_data.start_date, request_data.end_date, request_data.created_at, request_data.users, request_namespaces.namespace, request_namespaces.group_id, request_namespaces.group_name, request_namespaces.approved FROM "request_data" JOIN request_namespaces ON request_namespaces.request_id = request_data.id WHERE request_namespaces.group_id IN ($1) AND request_data.status = $2 AND request_namespaces.approved = false`)
+				expectedQuery := regexp.QuoteMeta(`SELECT request_data.id, request_data.cluster_name, request_data.role_name, request_data.user_id, request_data.username, request_data.justification, request_data.start_date, request_data.end_date, request_data.created_at, request_data.users, request_data.bundle_id, request_namespaces.namespace, request_namespaces.group_id, request_namespaces.group_name, request_namespaces.approved FROM "request_data" JOIN request_namespaces ON request_namespaces.request_id = request_data.id WHERE request_namespaces.group_id IN ($1) AND request_data.status = $2 AND request_namespaces.approved = false`)
 				mock.ExpectQuery(expectedQuery).
 					WithArgs("groupNoMatch", "Requested").
 					WillReturnRows(rows)
@@ -271,7 +277,53 @@ func TestGetPendingApprovals(t *testing.T) {
 			expectDBInteraction: true,
 		},
 		{
-			name: "Unauthorized if non-admin and no approver groups in session",
+			name: "API role approver for a cluster fetches its pending requests and those of their groups",
+			setupSession: func(t *testing.T, c *gin.Context) {
+				session := sessions.Default(c)
+				approverGroups := []models.Team{{ID: "group2", Name: "Group Two"}}
+				sessionData := map[string]any{"apiRoles": []string{"cluster-c-approver"}, "approverGroups": approverGroups, "userID": "roleUser", "username": "role@example.com"}
+				session.Set("sessionData", sessionData)
+				assert.NoError(t, session.Save())
+			},
+			mockDB: func(t *testing.T, mock sqlmock.Sqlmock) {
+				cols := []string{"id", "cluster_name", "role_name", "user_id", "username", "justification", "start_date", "end_date", "created_at", "users", "namespace", "group_id", "group_name", "approved"}
+				rows := sqlmock.NewRows(cols).
+					AddRow(nonAdminRow1.ID, nonAdminRow1.ClusterName, nonAdminRow1.RoleName, nonAdminRow1.UserID, nonAdminRow1.Username, nonAdminRow1.Justification, nonAdminRow1.StartDate, nonAdminRow1.EndDate, nonAdminRow1.CreatedAt, usersReq1JSON, nonAdminRow1.Namespace, nonAdminRow1.GroupID, nonAdminRow1.GroupName, nonAdminRow1.Approved).
+					AddRow(nonAdminRow2.ID, nonAdminRow2.ClusterName, nonAdminRow2.RoleName, nonAdminRow2.UserID, nonAdminRow2.Username, nonAdminRow2.Justification, nonAdminRow2.StartDate, nonAdminRow2.EndDate, nonAdminRow2.CreatedAt, usersReq2JSON, nonAdminRow2.Namespace, nonAdminRow2.GroupID, nonAdminRow2.GroupName, nonAdminRow2.Approved)
+
+				expectedQuery := regexp.QuoteMeta(`SELECT request_data.id, request_data.cluster_name, request_data.role_name, request_data.user_id, request_data.username, request_data.justification, request_data.start_date, request_data.end_date, request_data.created_at, request_data.users, request_data.bundle_id, request_namespaces.namespace, request_namespaces.group_id, request_namespaces.group_name, request_namespaces.approved FROM "request_data" JOIN request_namespaces ON request_namespaces.request_id = request_data.id WHERE (request_namespaces.group_id IN ($1) OR (cluster_name IN ($2))) AND request_data.status = $3 AND request_namespaces.approved = false`)
+				mock.ExpectQuery(expectedQuery).
+					WithArgs("group2", "cluster-c", "Requested").
+					WillReturnRows(rows)
+			},
+			expectedStatus: http.StatusOK,
+			expectedJSONBody: gin.H{"pendingRequests": []PendingRequest{
+				{ID: nonAdminRow1.ID, ClusterName: nonAdminRow1.ClusterName, RoleName: nonAdminRow1.RoleName, Status: "", UserID: nonAdminRow1.UserID, Users: nonAdminRow1.Users, Username: nonAdminRow1.Username, Justification: nonAdminRow1.Justification, StartDate: nonAdminRow1.StartDate, EndDate: nonAdminRow1.EndDate, CreatedAt: nonAdminRow1.CreatedAt, Namespaces: []string{nonAdminRow1.Namespace}, GroupIDs: []string{nonAdminRow1.GroupID}, GroupNames: []string{nonAdminRow1.GroupName}, ApprovedList: []bool{nonAdminRow1.Approved}},
+				{ID: nonAdminRow2.ID, ClusterName: nonAdminRow2.ClusterName, RoleName: nonAdminRow2.RoleName, Status: "", UserID: nonAdminRow2.UserID, Users: nonAdminRow2.Users, Username: nonAdminRow2.Username, Justification: nonAdminRow2.Justification, StartDate: nonAdminRow2.StartDate, EndDate: nonAdminRow2.EndDate, CreatedAt: nonAdminRow2.CreatedAt, Namespaces: []string{nonAdminRow2.Namespace}, GroupIDs: []string{nonAdminRow2.GroupID}, GroupNames: []string{nonAdminRow2.GroupName}, ApprovedList: []bool{nonAdminRow2.Approved}},
+			}},
+			expectDBInteraction: true,
+		},
+		{
+			name: "API role approver without approver groups fetches the pending requests of its clusters",
+			setupSession: func(t *testing.T, c *gin.Context) {
+				session := sessions.Default(c)
+				sessionData := map[string]any{"apiRoles": []string{"cluster-c-approver"}, "userID": "roleUser", "username": "role@example.com"}
+				session.Set("sessionData", sessionData)
+				assert.NoError(t, session.Save())
+			},
+			mockDB: func(t *testing.T, mock sqlmock.Sqlmock) {
+				cols := []string{"id", "cluster_name", "role_name", "user_id", "username", "justification", "start_date", "end_date", "created_at", "users", "namespace", "group_id", "group_name", "approved"}
+				expectedQuery := regexp.QuoteMeta(`FROM "request_data" JOIN request_namespaces ON request_namespaces.request_id = request_data.id WHERE (cluster_name IN ($1)) AND request_data.status = $2 AND request_namespaces.approved = false`)
+				mock.ExpectQuery(expectedQuery).
+					WithArgs("cluster-c", "Requested").
+					WillReturnRows(sqlmock.NewRows(cols))
+			},
+			expectedStatus:      http.StatusOK,
+			expectedJSONBody:    gin.H{"pendingRequests": []PendingRequest{}},
+			expectDBInteraction: true,
+		},
+		{
+			name: "Forbidden if non-admin and no approver groups in session",
 			setupSession: func(t *testing.T, c *gin.Context) {
 				session := sessions.Default(c)
 				sessionData := map[string]any{"isAdmin": false, "isPlatformApprover": false, "userID": "noGroupUser", "username": "nogroup@example.com"}
@@ -281,12 +333,12 @@ func TestGetPendingApprovals(t *testing.T) {
 			mockDB: func(t *testing.T, mock sqlmock.Sqlmock) {
 				// No DB interaction expected
 			},
-			expectedStatus:      http.StatusUnauthorized,
-			expectedJSONBody:    models.SimpleMessageResponse{Error: "Unauthorized: no approver groups in session"},
+			expectedStatus:      http.StatusForbidden,
+			expectedJSONBody:    models.SimpleMessageResponse{Error: "Forbidden: missing permission requests:approve"},
 			expectDBInteraction: false,
 		},
 		{
-			name: "Unauthorized if non-admin and empty approver groups in session",
+			name: "Forbidden if non-admin and empty approver groups in session",
 			setupSession: func(t *testing.T, c *gin.Context) {
 				session := sessions.Default(c)
 				sessionData := map[string]any{"isAdmin": false, "isPlatformApprover": false, "approverGroups": []models.Team{}, "userID": "emptyGroupUser", "username": "emptygroup@example.com"}
@@ -296,8 +348,8 @@ func TestGetPendingApprovals(t *testing.T) {
 			mockDB: func(t *testing.T, mock sqlmock.Sqlmock) {
 				// No DB interaction expected
 			},
-			expectedStatus:      http.StatusUnauthorized,
-			expectedJSONBody:    models.SimpleMessageResponse{Error: "Unauthorized: no approver groups in session"},
+			expectedStatus:      http.StatusForbidden,
+			expectedJSONBody:    models.SimpleMessageResponse{Error: "Forbidden: missing permission requests:approve"},
 			expectDBInteraction: false,
 		},
 		{
@@ -327,7 +379,7 @@ func TestGetPendingApprovals(t *testing.T) {
 				assert.NoError(t, session.Save())
 			},
 			mockDB: func(t *testing.T, mock sqlmock.Sqlmock) {
-				expectedQuery := regexp.QuoteMeta(`SELECT request_data.id, request_data.cluster_name, request_data.role_name, request_data.user_id, request_data.username, request_data.justification, request_data.start_date, request_data.end_date, request_data.created_at, request_data.users, request_namespaces.namespace, request_namespaces.group_id, request_namespaces.group_name, request_namespaces.approved FROM "request_data" JOIN request_namespaces ON request_namespaces.request_id = request_data.id WHERE request_namespaces.group_id IN ($1) AND request_data.status = $2 AND request_namespaces.approved = false`)
+				expectedQuery := regexp.QuoteMeta(`SELECT request_data.id, request_data.cluster_name, request_data.role_name, request_data.user_id, request_data.username, request_data.justification, request_data.start_date, request_data.end_date, request_data.created_at, request_data.users, request_data.bundle_id, request_namespaces.namespace, request_namespaces.group_id, request_namespaces.group_name, request_namespaces.approved FROM "request_data" JOIN request_namespaces ON request_namespaces.request_id = request_data.id WHERE request_namespaces.group_id IN ($1) AND request_data.status = $2 AND request_namespaces.approved = false`)
 				mock.ExpectQuery(expectedQuery).
 					WithArgs("group1", "Requested").
 					WillReturnError(errors.New("db query failed for non-admin"))
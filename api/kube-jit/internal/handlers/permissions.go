@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"context"
+	"kube-jit/internal/models"
+	"kube-jit/pkg/k8s"
+	"kube-jit/pkg/sessioncookie"
+	"kube-jit/pkg/utils"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// permissionsTTL is how long the permissions cached in the session are used before being
+// evaluated again, set with PERMISSIONS_TTL. Group changes at the provider apply after at most
+// PERMISSIONS_TTL plus GROUP_CACHE_TTL, configuration changes apply on the next request
+var permissionsTTL = utils.GetEnvDuration("PERMISSIONS_TTL", 5*time.Minute)
+
+// permissionKeys are the session keys holding the permissions of the user
+var permissionKeys = []string{
+	"isApprover",
+	"approverGroups",
+	"isAdmin",
+	"isPlatformApprover",
+	"adminGroups",
+	"platformApproverGroups",
+	"adminClusters",
+	"platformApproverClusters",
+	"apiRoles",
+	"permissionsCheckedAt",
+	"permissionsVersion",
+}
+
+// evaluatePermissions fetches the groups of the user, matches them with matchPermissions
+// and stores the permissions in the session data
+func evaluatePermissions(ctx context.Context, p IdentityProvider, sessionData map[string]any, reqLogger *zap.Logger) (CommonPermissionsResponse, error) {
+	// Read the version first, so a change while evaluating is picked up by the next request
+	version := k8s.PermissionsVersion()
+
+	userGroups, err := fetchUserGroups(ctx, p, sessionData, reqLogger)
+	if err != nil {
+		return CommonPermissionsResponse{}, err
+	}
+
+	permissions := matchPermissions(userGroups, reqLogger)
+	storePermissions(sessionData, permissions, version)
+	return permissions, nil
+}
+
+// matchPermissions matches the groups of a user against the admin and platform approver teams,
+// those of every cluster, the API roles and the JitGroups of every cluster
+func matchPermissions(userGroups []models.Team, reqLogger *zap.Logger) CommonPermissionsResponse {
+	settings := k8s.CurrentSettings()
+
+	// Match user groups to approver/admin teams
+	isAdmin, isPlatformApprover, matchedPlatformGroups, matchedAdminGroups := MatchUserGroups(
+		userGroups,
+		settings.PlatformApproverTeams,
+		settings.AdminTeams,
+	)
+
+	// Check and append if user is in any JitGroup for any cluster
+	var matchedApproverGroups []models.Team
+	for _, clusterName := range settings.ClusterNames {
+		jitGroups, err := k8s.GetJitGroups(clusterName)
+		if err != nil {
+			reqLogger.Error("Error fetching JitGroups for cluster", zap.String("clusterName", clusterName), zap.Error(err))
+			continue
+		}
+		groups, _, _ := unstructured.NestedSlice(jitGroups.Object, "spec", "groups")
+		for _, group := range groups {
+			groupMap, ok := group.(map[string]any)
+			if !ok {
+				continue
+			}
+			groupID, ok := groupMap["groupID"].(string)
+			groupName, _ := groupMap["groupName"].(string)
+			if ok {
+				for _, userGroup := range userGroups {
+					if userGroup.ID == groupID {
+						matchedApproverGroups = append(matchedApproverGroups, models.Team{ID: groupID, Name: groupName})
+					}
+				}
+			}
+		}
+	}
+
+	// Users in the teams of a cluster, or with an API role granting the approve permission, are approvers too
+	adminClusters, platformApproverClusters := matchClusterTeams(settings, userGroups)
+	apiRoles := matchApiRoles(settings, userGroups)
+	approvesForSome := accessFromSession(map[string]any{
+		"adminClusters":            adminClusters,
+		"platformApproverClusters": platformApproverClusters,
+		"apiRoles":                 apiRoles,
+	}).Can(models.PermissionApprove)
+
+	return CommonPermissionsResponse{
+		IsApprover:               len(matchedApproverGroups) > 0 || approvesForSome,
+		ApproverGroups:           matchedApproverGroups,
+		IsAdmin:                  isAdmin,
+		IsPlatformApprover:       isPlatformApprover,
+		AdminGroups:              matchedAdminGroups,
+		PlatformApproverGroups:   matchedPlatformGroups,
+		AdminClusters:            adminClusters,
+		PlatformApproverClusters: platformApproverClusters,
+		ApiRoles:                 apiRoles,
+	}
+}
+
+// storePermissions stores the permissions in the session data, evaluated against the given configuration version
+func storePermissions(sessionData map[string]any, permissions CommonPermissionsResponse, version int64) {
+	sessionData["isApprover"] = permissions.IsApprover
+	sessionData["approverGroups"] = permissions.ApproverGroups
+	sessionData["isAdmin"] = permissions.IsAdmin
+	sessionData["isPlatformApprover"] = permissions.IsPlatformApprover
+	sessionData["adminGroups"] = permissions.AdminGroups
+	sessionData["platformApproverGroups"] = permissions.PlatformApproverGroups
+	sessionData["adminClusters"] = permissions.AdminClusters
+	sessionData["platformApproverClusters"] = permissions.PlatformApproverClusters
+	sessionData["apiRoles"] = permissions.ApiRoles
+	sessionData["permissionsCheckedAt"] = time.Now().Unix()
+	sessionData["permissionsVersion"] = version
+}
+
+// permissionsCached reports whether the session holds permissions, fresh or not
+func permissionsCached(sessionData map[string]any) bool {
+	_, ok := sessionData["isAdmin"]
+	return ok
+}
+
+// permissionsFresh reports whether the permissions in the session were evaluated less than
+// permissionsTTL ago, against the current configuration
+func permissionsFresh(sessionData map[string]any) bool {
+	checkedAt, ok := sessionInt(sessionData["permissionsCheckedAt"])
+	if !ok || time.Since(time.Unix(checkedAt, 0)) >= permissionsTTL {
+		return false
+	}
+	version, ok := sessionInt(sessionData["permissionsVersion"])
+	return ok && version == k8s.PermissionsVersion()
+}
+
+// clearPermissions removes the permissions from the session, the user then has none until they are evaluated again
+func clearPermissions(sessionData map[string]any) {
+	for _, key := range permissionKeys {
+		delete(sessionData, key)
+	}
+}
+
+// sessionInt reads a number from the session data, numbers are float64 once the session is decoded
+func sessionInt(value any) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case float64:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+// RefreshPermissions is a middleware evaluating the permissions cached in the session again
+// once they are stale, so removed group memberships and configuration changes apply mid-session.
+// If they cannot be evaluated they are removed from the session rather than trusted.
+// It must run after RequireAuth and is a no-op for sessions without cached permissions.
+func RefreshPermissions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionData := GetSessionData(c)
+		if !permissionsCached(sessionData) || permissionsFresh(sessionData) {
+			c.Next()
+			return
+		}
+
+		reqLogger := RequestLogger(c)
+		provider, _ := sessionData["provider"].(string)
+		identityProvider, ok := lookupProvider(provider)
+		if !ok {
+			reqLogger.Warn("Cannot refresh permissions, unknown provider in session", zap.String("provider", provider))
+			clearPermissions(sessionData)
+		} else if _, err := evaluatePermissions(c.Request.Context(), identityProvider, sessionData, reqLogger); err != nil {
+			reqLogger.Error("Failed to refresh permissions", zap.String("provider", provider), zap.Error(err))
+			clearPermissions(sessionData)
+		} else {
+			reqLogger.Debug("Refreshed permissions", zap.String("provider", provider))
+		}
+
+		sessions.Default(c).Set("data", sessionData)
+		sessioncookie.SplitSessionData(c)
+		c.Next()
+	}
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"kube-jit/internal/models"
+	"kube-jit/pkg/k8s"
+	"kube-jit/pkg/sessioncookie"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// useAdminTeams configures the admin teams and no clusters for the duration of the test
+func useAdminTeams(t *testing.T, teams []models.Team) {
+	t.Helper()
+	useSettings(t, func(s *k8s.Settings) {
+		s.AdminTeams, s.PlatformApproverTeams, s.ClusterNames = teams, nil, nil
+	})
+}
+
+// adminSession returns session data of an admin whose permissions were evaluated at checkedAt
+func adminSession(provider string, checkedAt time.Time) map[string]any {
+	return map[string]any{
+		"id":                     "42",
+		"provider":               provider,
+		"isApprover":             false,
+		"approverGroups":         []models.Team{},
+		"isAdmin":                true,
+		"isPlatformApprover":     false,
+		"adminGroups":            []models.Team{{ID: "admins", Name: "Admins"}},
+		"platformApproverGroups": []models.Team{},
+		"permissionsCheckedAt":   checkedAt.Unix(),
+		"permissionsVersion":     k8s.PermissionsVersion(),
+	}
+}
+
+// serveRefresh runs RefreshPermissions for sessionData and returns the isAdmin seen by the handler
+func serveRefresh(t *testing.T, sessionData map[string]any) any {
+	t.Helper()
+	var isAdmin any
+	r := setupTestRouter()
+	r.Use(func(c *gin.Context) {
+		c.Set("logger", getTestLogger())
+		c.Set("sessionData", sessionData)
+		c.Next()
+	})
+	r.GET("/", RefreshPermissions(), func(c *gin.Context) {
+		isAdmin = GetSessionData(c)["isAdmin"]
+	})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	return isAdmin
+}
+
+func TestPermissionsFresh(t *testing.T) {
+	assert.True(t, permissionsFresh(adminSession("fake", time.Now())))
+	assert.False(t, permissionsFresh(adminSession("fake", time.Now().Add(-permissionsTTL))))
+
+	// Decoded sessions hold float64 numbers
+	decoded := adminSession("fake", time.Now())
+	decoded["permissionsCheckedAt"] = float64(time.Now().Unix())
+	decoded["permissionsVersion"] = float64(k8s.PermissionsVersion())
+	assert.True(t, permissionsFresh(decoded))
+
+	// Sessions from before permissions expired
+	legacy := adminSession("fake", time.Now())
+	delete(legacy, "permissionsCheckedAt")
+	assert.False(t, permissionsFresh(legacy))
+
+	// Configuration changes invalidate every session
+	session := adminSession("fake", time.Now())
+	k8s.InvalidatePermissions()
+	assert.False(t, permissionsFresh(session))
+}
+
+func TestRefreshPermissions(t *testing.T) {
+	admins := []models.Team{{ID: "admins", Name: "Admins"}}
+
+	t.Run("fresh permissions are kept", func(t *testing.T) {
+		useGroupCacheTTL(t, 0)
+		p := &countingProvider{fakeProvider: fakeProvider{name: "fake"}}
+		useProvider(t, p)
+
+		assert.Equal(t, true, serveRefresh(t, adminSession("fake", time.Now())))
+		assert.Equal(t, 0, p.calls)
+	})
+
+	t.Run("sessions without permissions are left alone", func(t *testing.T) {
+		p := &countingProvider{fakeProvider: fakeProvider{name: "fake"}}
+		useProvider(t, p)
+
+		assert.Nil(t, serveRefresh(t, map[string]any{"id": "42", "provider": "fake"}))
+		assert.Equal(t, 0, p.calls)
+	})
+
+	t.Run("stale permissions are evaluated again", func(t *testing.T) {
+		useGroupCacheTTL(t, 0)
+		useAdminTeams(t, admins)
+		// The user was removed from the admin team
+		p := &countingProvider{fakeProvider: fakeProvider{name: "fake", groups: []models.Team{{ID: "devs"}}}}
+		useProvider(t, p)
+
+		session := adminSession("fake", time.Now().Add(-2*permissionsTTL))
+		assert.Equal(t, false, serveRefresh(t, session))
+		assert.Equal(t, 1, p.calls)
+		assert.True(t, permissionsFresh(session))
+	})
+
+	t.Run("configuration changes apply immediately", func(t *testing.T) {
+		useGroupCacheTTL(t, 0)
+		useAdminTeams(t, admins)
+		p := &countingProvider{fakeProvider: fakeProvider{name: "fake", groups: admins}}
+		useProvider(t, p)
+
+		session := adminSession("fake", time.Now())
+		// Replacing the configuration invalidates the permissions
+		useSettings(t, func(s *k8s.Settings) { s.AdminTeams = []models.Team{{ID: "new-admins"}} })
+		assert.Equal(t, false, serveRefresh(t, session))
+		assert.Equal(t, 1, p.calls)
+	})
+
+	t.Run("approver groups follow JitGroups", func(t *testing.T) {
+		useGroupCacheTTL(t, 0)
+		useAdminTeams(t, nil)
+		useSettings(t, func(s *k8s.Settings) { s.ClusterNames = []string{"cluster"} })
+		originalGetJitGroups := k8s.GetJitGroups
+		k8s.GetJitGroups = func(string) (*unstructured.Unstructured, error) {
+			return &unstructured.Unstructured{Object: map[string]any{
+				"spec": map[string]any{"groups": []any{
+					map[string]any{"groupID": "devs", "groupName": "Developers"},
+				}},
+			}}, nil
+		}
+		t.Cleanup(func() { k8s.GetJitGroups = originalGetJitGroups })
+		useProvider(t, &fakeProvider{name: "fake", groups: []models.Team{{ID: "devs"}}})
+
+		session := adminSession("fake", time.Now().Add(-2*permissionsTTL))
+		serveRefresh(t, session)
+		assert.Equal(t, true, session["isApprover"])
+		assert.Equal(t, []models.Team{{ID: "devs", Name: "Developers"}}, session["approverGroups"])
+	})
+
+	t.Run("refreshed permissions are written to the session cookies", func(t *testing.T) {
+		useGroupCacheTTL(t, 0)
+		useAdminTeams(t, admins)
+		useProvider(t, &fakeProvider{name: "fake", groups: []models.Team{{ID: "devs"}}})
+
+		r := setupTestRouter()
+		r.Use(func(c *gin.Context) {
+			c.Set("logger", getTestLogger())
+			c.Set("sessionData", adminSession("fake", time.Now().Add(-2*permissionsTTL)))
+			c.Next()
+		})
+		r.GET("/", RefreshPermissions(), func(c *gin.Context) {})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		r.ServeHTTP(w, req)
+
+		var names []string
+		for _, cookie := range w.Result().Cookies() {
+			names = append(names, cookie.Name)
+		}
+		assert.Contains(t, names, sessioncookie.SessionPrefix+"0")
+	})
+
+	t.Run("permissions are dropped when they cannot be evaluated", func(t *testing.T) {
+		useGroupCacheTTL(t, 0)
+		useProvider(t, &fakeProvider{name: "fake", groupsErr: errors.New("provider down")})
+
+		session := adminSession("fake", time.Now().Add(-2*permissionsTTL))
+		assert.Nil(t, serveRefresh(t, session))
+		assert.False(t, permissionsCached(session))
+	})
+
+	t.Run("permissions are dropped for sessions without a known provider", func(t *testing.T) {
+		session := adminSession("", time.Now().Add(-2*permissionsTTL))
+		assert.Nil(t, serveRefresh(t, session))
+		assert.False(t, permissionsCached(session))
+	})
+}
+
+func TestMatchPermissionsWithSeveralProviders(t *testing.T) {
+	originalProviders := oauthProviders
+	oauthProviders = []string{"github", "gitlab"}
+	t.Cleanup(func() { oauthProviders = originalProviders })
+	useGroupCacheTTL(t, 0)
+
+	// GitHub team 123 and GitLab group 123 are different groups with the same ID
+	sharedID := []models.Team{{ID: "123", Name: "platform"}}
+	github := &fakeProvider{name: "github", groups: sharedID}
+	gitlab := &fakeProvider{name: "gitlab", groups: sharedID}
+
+	useAdminTeams(t, []models.Team{{ID: "gitlab:123", Name: "platform"}})
+	useSettings(t, func(s *k8s.Settings) { s.ClusterNames = []string{"cluster"} })
+	originalGetJitGroups := k8s.GetJitGroups
+	k8s.GetJitGroups = func(string) (*unstructured.Unstructured, error) {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"spec": map[string]any{"groups": []any{
+				map[string]any{"groupID": "github:123", "groupName": "Developers"},
+			}},
+		}}, nil
+	}
+	t.Cleanup(func() { k8s.GetJitGroups = originalGetJitGroups })
+
+	githubPermissions, err := evaluatePermissions(context.Background(), github, map[string]any{"id": "github:7"}, getTestLogger())
+	assert.NoError(t, err)
+	assert.False(t, githubPermissions.IsAdmin, "a GitHub team must not match the GitLab group with the same ID")
+	assert.Equal(t, []models.Team{{ID: "github:123", Name: "Developers"}}, githubPermissions.ApproverGroups)
+
+	gitlabPermissions, err := evaluatePermissions(context.Background(), gitlab, map[string]any{"id": "gitlab:7"}, getTestLogger())
+	assert.NoError(t, err)
+	assert.True(t, gitlabPermissions.IsAdmin)
+	assert.Equal(t, []models.Team{{ID: "gitlab:123", Name: "platform"}}, gitlabPermissions.AdminGroups)
+	assert.Empty(t, gitlabPermissions.ApproverGroups, "a GitLab group must not match the JitGroup of the GitHub team with the same ID")
+}
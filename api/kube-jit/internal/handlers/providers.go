@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"kube-jit/internal/models"
+	"kube-jit/pkg/utils"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oauthClientConfig holds the OAuth client settings of one identity provider
+type oauthClientConfig struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURI  string
+}
+
+// ProviderRoutes holds the handlers a provider exposes, used by routes.SetupRoutes
+// to register the callback and profile endpoints of the enabled providers only
+type ProviderRoutes struct {
+	Name     string
+	Callback gin.HandlerFunc
+	Profile  gin.HandlerFunc
+	// Login is an optional server side login entrypoint (e.g. OIDC with PKCE)
+	Login gin.HandlerFunc
+}
+
+// parseProviders splits a comma separated OAUTH_PROVIDER value into provider names,
+// lower cased and de-duplicated, keeping the configured order
+func parseProviders(raw string) []string {
+	var providers []string
+	for _, p := range splitAndTrim(raw) {
+		p = strings.ToLower(p)
+		if !contains(providers, p) {
+			providers = append(providers, p)
+		}
+	}
+	return providers
+}
+
+// loadOAuthClients reads the OAuth client settings of each provider
+// OAUTH_<PROVIDER>_CLIENT_ID, OAUTH_<PROVIDER>_CLIENT_SECRET and OAUTH_<PROVIDER>_REDIRECT_URI
+// take precedence, falling back to the shared OAUTH_CLIENT_ID, OAUTH_CLIENT_SECRET and OAUTH_REDIRECT_URI
+func loadOAuthClients(providers []string) map[string]oauthClientConfig {
+	clients := make(map[string]oauthClientConfig, len(providers))
+	for _, provider := range providers {
+		clients[provider] = oauthClientConfig{
+			ClientID:     providerEnv(provider, "CLIENT_ID"),
+			ClientSecret: providerEnv(provider, "CLIENT_SECRET"),
+			RedirectURI:  providerEnv(provider, "REDIRECT_URI"),
+		}
+	}
+	return clients
+}
+
+// providerEnv returns OAUTH_<PROVIDER>_<KEY> if set, otherwise the required OAUTH_<KEY>
+func providerEnv(provider, key string) string {
+	if val := os.Getenv("OAUTH_" + strings.ToUpper(provider) + "_" + key); val != "" {
+		return val
+	}
+	return utils.MustGetEnv("OAUTH_" + key)
+}
+
+// oauthClient returns the OAuth client settings of the given provider
+func oauthClient(provider string) oauthClientConfig {
+	return oauthClients[provider]
+}
+
+// isProviderEnabled reports whether the provider is configured in OAUTH_PROVIDER
+func isProviderEnabled(provider string) bool {
+	return contains(oauthProviders, provider)
+}
+
+// ProviderNames returns the names of the providers configured in OAUTH_PROVIDER, in the configured order
+func ProviderNames() []string {
+	return slices.Clone(oauthProviders)
+}
+
+// qualifyID prefixes a user or group ID with its provider, e.g. gitlab:123, when several providers
+// are enabled, since the IDs of different providers can collide. With a single provider IDs are kept as is
+func qualifyID(provider, id string) string {
+	if len(oauthProviders) < 2 || id == "" {
+		return id
+	}
+	return provider + ":" + id
+}
+
+// qualifyTeams returns the groups of a provider with their IDs qualified by qualifyID
+func qualifyTeams(provider string, teams []models.Team) []models.Team {
+	if len(oauthProviders) < 2 {
+		return teams
+	}
+	qualified := make([]models.Team, len(teams))
+	for i, team := range teams {
+		qualified[i] = models.Team{ID: qualifyID(provider, team.ID), Name: team.Name}
+	}
+	return qualified
+}
+
+// EnabledProviders returns the routes of the configured providers, in the configured order
+// Names without a registered IdentityProvider are skipped
+func EnabledProviders() []ProviderRoutes {
+	var enabled []ProviderRoutes
+	for _, name := range oauthProviders {
+		provider, ok := lookupProvider(name)
+		if !ok {
+			continue
+		}
+		routes := ProviderRoutes{
+			Name:     name,
+			Callback: OAuthCallback(provider),
+			Profile:  GetProfile(provider),
+		}
+		if h, ok := provider.(callbackHandler); ok {
+			routes.Callback = h.HandleCallback
+		}
+		if s, ok := provider.(loginStarter); ok {
+			routes.Login = s.StartLogin
+		}
+		enabled = append(enabled, routes)
+	}
+	return enabled
+}
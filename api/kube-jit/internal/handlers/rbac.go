@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"kube-jit/internal/models"
+	"kube-jit/pkg/k8s"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Grant is a permission of the user, limited to requests for the listed clusters and roles when set
+type Grant struct {
+	Permission string
+	Clusters   []string
+	Roles      []string
+}
+
+// allows reports whether the grant covers a request for role on cluster
+func (g Grant) allows(cluster, role string) bool {
+	return (len(g.Clusters) == 0 || slices.Contains(g.Clusters, cluster)) &&
+		(len(g.Roles) == 0 || slices.Contains(g.Roles, role))
+}
+
+// unlimited reports whether the grant covers requests for every cluster and role
+func (g Grant) unlimited() bool {
+	return len(g.Clusters) == 0 && len(g.Roles) == 0
+}
+
+// Access is what the user of a request may do in the API, see AccessOf
+type Access struct {
+	Grants         []Grant
+	ApproverGroups []string // IDs of the JitGroups the user approves namespaces for
+}
+
+// adminGrants and platformApproverGrants are the built-in grants of the admin and platform approver teams
+var (
+	adminGrants = []Grant{
+		{Permission: models.PermissionAdmin},
+		{Permission: models.PermissionReadHistory},
+		{Permission: models.PermissionApprove},
+	}
+	platformApproverGrants = []Grant{
+		{Permission: models.PermissionReadHistory},
+		{Permission: models.PermissionApprove},
+	}
+)
+
+// clusterGrants returns the grants of the admin and platform approver teams of some clusters,
+// limited to the requests for those clusters. The admin permission is not limited to clusters,
+// so cluster admins read and approve the requests for their clusters like cluster platform approvers
+func clusterGrants(clusters []string) []Grant {
+	if len(clusters) == 0 {
+		return nil
+	}
+	return []Grant{
+		{Permission: models.PermissionReadHistory, Clusters: clusters},
+		{Permission: models.PermissionApprove, Clusters: clusters},
+	}
+}
+
+// Can reports whether the user has the permission, for at least some clusters and roles
+func (a Access) Can(permission string) bool {
+	return slices.ContainsFunc(a.Grants, func(g Grant) bool { return g.Permission == permission })
+}
+
+// CanAll reports whether the user has the permission for every cluster and role
+func (a Access) CanAll(permission string) bool {
+	return slices.ContainsFunc(a.Grants, func(g Grant) bool { return g.Permission == permission && g.unlimited() })
+}
+
+// CanOn reports whether the user has the permission for a request for role on cluster
+func (a Access) CanOn(permission, cluster, role string) bool {
+	return slices.ContainsFunc(a.Grants, func(g Grant) bool { return g.Permission == permission && g.allows(cluster, role) })
+}
+
+// scopeCondition returns a condition on request_data limiting it to the clusters and roles
+// the user has the permission for, callers check CanAll first as unlimited grants add no condition
+func (a Access) scopeCondition(permission string) (string, []any) {
+	var conditions []string
+	var args []any
+	for _, grant := range a.Grants {
+		if grant.Permission != permission || grant.unlimited() {
+			continue
+		}
+		var parts []string
+		if len(grant.Clusters) > 0 {
+			parts = append(parts, "cluster_name IN ?")
+			args = append(args, grant.Clusters)
+		}
+		if len(grant.Roles) > 0 {
+			parts = append(parts, "role_name IN ?")
+			args = append(args, grant.Roles)
+		}
+		conditions = append(conditions, "("+strings.Join(parts, " AND ")+")")
+	}
+	return strings.Join(conditions, " OR "), args
+}
+
+// AccessOf returns the access of the user of the request, built from the permissions in the session:
+// the admin and platform approver flags, the clusters whose teams the user is in, the API roles
+// and the JitGroups the user approves for.
+// API roles are looked up in the current configuration, so removed roles apply immediately
+func AccessOf(c *gin.Context) Access {
+	if access, ok := c.Get("access"); ok {
+		return access.(Access)
+	}
+	access := accessFromSession(GetSessionData(c))
+	c.Set("access", access)
+	return access
+}
+
+// accessFromSession builds the access of a user from the permissions in the session data
+func accessFromSession(sessionData map[string]any) Access {
+	var access Access
+	if isAdmin, _ := sessionData["isAdmin"].(bool); isAdmin {
+		access.Grants = append(access.Grants, adminGrants...)
+	}
+	if isPlatformApprover, _ := sessionData["isPlatformApprover"].(bool); isPlatformApprover {
+		access.Grants = append(access.Grants, platformApproverGrants...)
+	}
+	access.Grants = append(access.Grants, clusterGrants(sessionStrings(sessionData["adminClusters"]))...)
+	access.Grants = append(access.Grants, clusterGrants(sessionStrings(sessionData["platformApproverClusters"]))...)
+	for _, name := range sessionStrings(sessionData["apiRoles"]) {
+		for _, role := range k8s.CurrentSettings().ApiRoles {
+			if role.Name != name {
+				continue
+			}
+			for _, permission := range role.Permissions {
+				access.Grants = append(access.Grants, Grant{Permission: permission, Clusters: role.Clusters, Roles: role.Roles})
+			}
+		}
+	}
+	access.ApproverGroups = approverGroupIDs(sessionData)
+	return access
+}
+
+// matchApiRoles returns the names of the API roles with a team the user is a member of
+func matchApiRoles(settings *k8s.Settings, userGroups []models.Team) []string {
+	var names []string
+	for _, role := range settings.ApiRoles {
+		if slices.ContainsFunc(userGroups, func(group models.Team) bool { return slices.Contains(role.Teams, group) }) {
+			names = append(names, role.Name)
+		}
+	}
+	return names
+}
+
+// matchClusterTeams returns the names of the clusters with an admin or platform approver team the user is a member of
+func matchClusterTeams(settings *k8s.Settings, userGroups []models.Team) (adminClusters, platformApproverClusters []string) {
+	for _, clusterName := range settings.ClusterNames {
+		cluster := settings.ClusterConfigs[clusterName]
+		isAdmin, isPlatformApprover, _, _ := MatchUserGroups(userGroups, cluster.PlatformApproverTeams, cluster.AdminTeams)
+		if isAdmin {
+			adminClusters = append(adminClusters, clusterName)
+		}
+		if isPlatformApprover {
+			platformApproverClusters = append(platformApproverClusters, clusterName)
+		}
+	}
+	return adminClusters, platformApproverClusters
+}
+
+// approverGroupIDs returns the IDs of the JitGroups in the session data,
+// handling both []models.Team and []any (from session serialization)
+func approverGroupIDs(sessionData map[string]any) []string {
+	var ids []string
+	switch groups := sessionData["approverGroups"].(type) {
+	case []models.Team:
+		for _, group := range groups {
+			ids = append(ids, group.ID)
+		}
+	case []any:
+		for _, group := range groups {
+			if groupMap, ok := group.(map[string]any); ok {
+				if id, ok := groupMap["id"].(string); ok {
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// sessionStrings reads a list of strings from the session data, lists are []any once the session is decoded
+func sessionStrings(value any) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []any:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// RequirePermission is a middleware for route groups, allowing only users with the permission for at least
+// some clusters and roles. Handlers narrow the requests to the user's grants with AccessOf.
+// JitGroup approvers have the approve permission for the namespaces of their groups.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		access := AccessOf(c)
+		if access.Can(permission) || (permission == models.PermissionApprove && len(access.ApproverGroups) > 0) {
+			c.Next()
+			return
+		}
+		RequestLogger(c).Warn("Forbidden, missing permission",
+			zap.String("permission", permission),
+			zap.String("route", c.FullPath()),
+		)
+		c.AbortWithStatusJSON(http.StatusForbidden, models.SimpleMessageResponse{Error: "Forbidden: missing permission " + permission})
+	}
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"kube-jit/internal/models"
+	"kube-jit/pkg/k8s"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// useApiRoles sets the configured API roles for the duration of the test
+func useApiRoles(t *testing.T, roles ...models.ApiRole) {
+	t.Helper()
+	useSettings(t, func(s *k8s.Settings) { s.ApiRoles = roles })
+}
+
+// useClusters configures the clusters for the duration of the test
+func useClusters(t *testing.T, clusters ...k8s.ClusterConfig) {
+	t.Helper()
+	useSettings(t, func(s *k8s.Settings) {
+		s.ClusterNames, s.ClusterConfigs = nil, make(map[string]k8s.ClusterConfig)
+		for _, cluster := range clusters {
+			s.ClusterNames = append(s.ClusterNames, cluster.Name)
+			s.ClusterConfigs[cluster.Name] = cluster
+		}
+	})
+}
+
+var (
+	auditorRole = models.ApiRole{
+		Name:        "auditor",
+		Permissions: []string{models.PermissionReadHistory},
+		Teams:       []models.Team{{ID: "audit", Name: "Audit"}},
+	}
+	prodAdminRole = models.ApiRole{
+		Name:        "prod-admin",
+		Permissions: []string{models.PermissionReadHistory, models.PermissionApprove},
+		Clusters:    []string{"prod"},
+		Teams:       []models.Team{{ID: "sre", Name: "SRE"}},
+	}
+	editApproverRole = models.ApiRole{
+		Name:        "edit-approver",
+		Permissions: []string{models.PermissionApprove},
+		Roles:       []string{"edit"},
+		Teams:       []models.Team{{ID: "leads", Name: "Leads"}},
+	}
+)
+
+func TestAccessFromSession(t *testing.T) {
+	useApiRoles(t, auditorRole, prodAdminRole)
+
+	t.Run("admins can do everything", func(t *testing.T) {
+		access := accessFromSession(map[string]any{"isAdmin": true})
+		assert.True(t, access.CanAll(models.PermissionAdmin))
+		assert.True(t, access.CanAll(models.PermissionReadHistory))
+		assert.True(t, access.CanAll(models.PermissionApprove))
+	})
+
+	t.Run("platform approvers are not admins", func(t *testing.T) {
+		access := accessFromSession(map[string]any{"isPlatformApprover": true})
+		assert.False(t, access.Can(models.PermissionAdmin))
+		assert.True(t, access.CanAll(models.PermissionApprove))
+	})
+
+	t.Run("API roles from a decoded session", func(t *testing.T) {
+		access := accessFromSession(map[string]any{
+			"apiRoles":       []any{"prod-admin", "removed-role"},
+			"approverGroups": []any{map[string]any{"id": "devs", "name": "Developers"}},
+		})
+		assert.Len(t, access.Grants, 2)
+		assert.True(t, access.Can(models.PermissionApprove))
+		assert.False(t, access.CanAll(models.PermissionApprove))
+		assert.True(t, access.CanOn(models.PermissionApprove, "prod", "edit"))
+		assert.False(t, access.CanOn(models.PermissionApprove, "staging", "edit"))
+		assert.Equal(t, []string{"devs"}, access.ApproverGroups)
+	})
+
+	t.Run("cluster teams from a decoded session", func(t *testing.T) {
+		access := accessFromSession(map[string]any{
+			"adminClusters":            []any{"eu-1"},
+			"platformApproverClusters": []any{"eu-2"},
+		})
+		assert.False(t, access.Can(models.PermissionAdmin))
+		assert.False(t, access.CanAll(models.PermissionApprove))
+		assert.True(t, access.CanOn(models.PermissionApprove, "eu-1", "edit"))
+		assert.True(t, access.CanOn(models.PermissionReadHistory, "eu-2", "view"))
+		assert.False(t, access.CanOn(models.PermissionApprove, "us-1", "edit"))
+	})
+
+	t.Run("users without permissions", func(t *testing.T) {
+		access := accessFromSession(map[string]any{"id": "42"})
+		assert.Empty(t, access.Grants)
+		assert.False(t, access.Can(models.PermissionReadHistory))
+	})
+}
+
+func TestAccess_ScopeCondition(t *testing.T) {
+	access := Access{Grants: []Grant{
+		{Permission: models.PermissionApprove, Clusters: []string{"prod"}},
+		{Permission: models.PermissionApprove, Clusters: []string{"staging"}, Roles: []string{"edit"}},
+		{Permission: models.PermissionReadHistory},
+	}}
+
+	condition, args := access.scopeCondition(models.PermissionApprove)
+	assert.Equal(t, "(cluster_name IN ?) OR (cluster_name IN ? AND role_name IN ?)", condition)
+	assert.Equal(t, []any{[]string{"prod"}, []string{"staging"}, []string{"edit"}}, args)
+
+	// Unlimited grants add no condition
+	condition, args = access.scopeCondition(models.PermissionReadHistory)
+	assert.Empty(t, condition)
+	assert.Empty(t, args)
+}
+
+func TestMatchApiRoles(t *testing.T) {
+	useApiRoles(t, auditorRole, prodAdminRole, editApproverRole)
+	useAdminTeams(t, nil)
+
+	settings := k8s.CurrentSettings()
+	assert.Equal(t, []string{"auditor", "edit-approver"}, matchApiRoles(settings, []models.Team{{ID: "audit", Name: "Audit"}, {ID: "leads", Name: "Leads"}}))
+	// Teams match on both ID and name, like the admin and platform approver teams
+	assert.Empty(t, matchApiRoles(settings, []models.Team{{ID: "sre", Name: "Other"}}))
+
+	t.Run("API roles granting approve make the user an approver", func(t *testing.T) {
+		permissions := matchPermissions([]models.Team{{ID: "sre", Name: "SRE"}}, getTestLogger())
+		assert.True(t, permissions.IsApprover)
+		assert.Equal(t, []string{"prod-admin"}, permissions.ApiRoles)
+
+		permissions = matchPermissions([]models.Team{{ID: "audit", Name: "Audit"}}, getTestLogger())
+		assert.False(t, permissions.IsApprover)
+		assert.Equal(t, []string{"auditor"}, permissions.ApiRoles)
+	})
+}
+
+func TestRequirePermission(t *testing.T) {
+	useApiRoles(t, auditorRole, editApproverRole)
+
+	serve := func(sessionData map[string]any, permission string) *httptest.ResponseRecorder {
+		r := setupTestRouter()
+		r.Use(func(c *gin.Context) {
+			c.Set("logger", getTestLogger())
+			c.Set("sessionData", sessionData)
+			c.Next()
+		})
+		r.GET("/", RequirePermission(permission), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	testCases := []struct {
+		name           string
+		sessionData    map[string]any
+		permission     string
+		expectedStatus int
+	}{
+		{"admin", map[string]any{"isAdmin": true}, models.PermissionAdmin, http.StatusOK},
+		{"platform approver is not admin", map[string]any{"isPlatformApprover": true}, models.PermissionAdmin, http.StatusForbidden},
+		{"auditor reads history", map[string]any{"apiRoles": []any{"auditor"}}, models.PermissionReadHistory, http.StatusOK},
+		{"auditor does not approve", map[string]any{"apiRoles": []any{"auditor"}}, models.PermissionApprove, http.StatusForbidden},
+		{"scoped approver", map[string]any{"apiRoles": []any{"edit-approver"}}, models.PermissionApprove, http.StatusOK},
+		{"JitGroup approver", map[string]any{"approverGroups": []models.Team{{ID: "devs"}}}, models.PermissionApprove, http.StatusOK},
+		{"JitGroup approver is not admin", map[string]any{"approverGroups": []models.Team{{ID: "devs"}}}, models.PermissionAdmin, http.StatusForbidden},
+		{"user without permissions", map[string]any{"id": "42"}, models.PermissionApprove, http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := serve(tc.sessionData, tc.permission)
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			if tc.expectedStatus == http.StatusForbidden {
+				assert.Contains(t, w.Body.String(), "Forbidden: missing permission "+tc.permission)
+			}
+		})
+	}
+}
+
+func TestMatchClusterTeams(t *testing.T) {
+	useApiRoles(t)
+	useAdminTeams(t, nil)
+	useClusters(t,
+		k8s.ClusterConfig{Name: "eu-1", AdminTeams: []models.Team{{ID: "eu", Name: "EU Platform"}}},
+		k8s.ClusterConfig{Name: "eu-2", PlatformApproverTeams: []models.Team{{ID: "eu", Name: "EU Platform"}}},
+		k8s.ClusterConfig{Name: "us-1", PlatformApproverTeams: []models.Team{{ID: "us", Name: "US Platform"}}},
+	)
+	originalGetJitGroups := k8s.GetJitGroups
+	k8s.GetJitGroups = func(string) (*unstructured.Unstructured, error) {
+		return &unstructured.Unstructured{Object: map[string]any{}}, nil
+	}
+	t.Cleanup(func() { k8s.GetJitGroups = originalGetJitGroups })
+
+	adminClusters, platformApproverClusters := matchClusterTeams(k8s.CurrentSettings(), []models.Team{{ID: "eu", Name: "EU Platform"}})
+	assert.Equal(t, []string{"eu-1"}, adminClusters)
+	assert.Equal(t, []string{"eu-2"}, platformApproverClusters)
+
+	t.Run("cluster teams make the user an approver for their clusters only", func(t *testing.T) {
+		permissions := matchPermissions([]models.Team{{ID: "us", Name: "US Platform"}}, getTestLogger())
+		assert.True(t, permissions.IsApprover)
+		assert.False(t, permissions.IsPlatformApprover)
+		assert.Equal(t, []string{"us-1"}, permissions.PlatformApproverClusters)
+		assert.Empty(t, permissions.AdminClusters)
+
+		sessionData := map[string]any{}
+		storePermissions(sessionData, permissions, k8s.PermissionsVersion())
+		access := accessFromSession(sessionData)
+		assert.True(t, access.CanOn(models.PermissionApprove, "us-1", "edit"))
+		assert.False(t, access.CanOn(models.PermissionApprove, "eu-1", "edit"))
+	})
+}
@@ -1,17 +1,25 @@
 package handlers
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"kube-jit/internal/db"
+	"kube-jit/internal/metrics"
 	"kube-jit/internal/models"
 	"kube-jit/pkg/email"
 	"kube-jit/pkg/k8s"
+	"maps"
 	"net/http"
+	"slices"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 // AdminApproveRequest represents the request payload for admin approval
@@ -55,6 +63,9 @@ type SubmitRequestPayload struct {
 	Justification string         `json:"justification"`
 	StartDate     time.Time      `json:"startDate"`
 	EndDate       time.Time      `json:"endDate"`
+	OnBehalfOf    *OnBehalfOf    `json:"onBehalfOf,omitempty"` // required for service principals, not allowed for users
+	// ClusterSelector replaces cluster with a label selector, submitting a request for each matching cluster
+	ClusterSelector string `json:"clusterSelector,omitempty" example:"env=prod"`
 }
 
 // SubmitRequest godoc
@@ -64,15 +75,20 @@ type SubmitRequestPayload struct {
 // @Description Pass split cookies in the Cookie header, for example:
 // @Description     -H "Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}"
 // @Description Note: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.
+// @Description Scripts can use an API token with the submit scope instead: -H "Authorization: Bearer ${token}"
+// @Description Service principals submit on behalf of a user with onBehalfOf, the user is recorded as the requestor and the principal as submittedBy.
+// @Description With clusterSelector instead of cluster, a request is submitted for every cluster whose labels match it, linked by their bundleID.
+// @Description The namespaces must exist on every matching cluster, and approvals of the namespaces of a JitGroup are shared by the requests of a bundle.
 // @Tags request
 // @Accept  json
 // @Produce  json
 // @Param   Cookie header string true "Session cookies (multiple allowed, names: kube_jit_session_0, kube_jit_session_1, etc.)"
 // @Param   request body handlers.SubmitRequestPayload true "JIT request payload"
 // @Success 200 {object} models.SimpleMessageResponse "Request submitted successfully"
-// @Failure 400 {object} models.SimpleMessageResponse "Invalid request data"
+// @Failure 400 {object} models.SimpleMessageResponse "Invalid request data, onBehalfOf or clusterSelector"
 // @Failure 401 {object} models.SimpleMessageResponse "Unauthorized: no token in session data"
 // @Failure 500 {object} models.SimpleMessageResponse "Failed to submit request"
+// @Failure 503 {object} models.SimpleMessageResponse "Cluster unavailable"
 // @Router /submit-request [post]
 func SubmitRequest(c *gin.Context) {
 	// Check if the user is logged in
@@ -89,16 +105,153 @@ func SubmitRequest(c *gin.Context) {
 		return
 	}
 
+	// Service principals submit on behalf of a user, who is recorded as the requestor
+	servicePrincipal, _ := sessionData["servicePrincipal"].(string)
+	if servicePrincipal != "" {
+		if requestData.OnBehalfOf == nil || requestData.OnBehalfOf.UserID == "" || requestData.OnBehalfOf.Username == "" {
+			c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: "Service principals must submit on behalf of a user (onBehalfOf userID and username)"})
+			return
+		}
+		requestData.UserID = requestData.OnBehalfOf.UserID
+		requestData.Username = requestData.OnBehalfOf.Username
+		emailAddress = requestData.OnBehalfOf.Email
+		reqLogger.Info("Service principal submitting request on behalf of user",
+			zap.String("servicePrincipal", servicePrincipal),
+			zap.String("onBehalfOfUserID", requestData.UserID),
+			zap.String("onBehalfOfUsername", requestData.Username),
+		)
+	} else if requestData.OnBehalfOf != nil {
+		c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: "Only service principals can submit on behalf of another user"})
+		return
+	}
+
+	// A cluster selector submits a bundle of requests, one for each matching cluster
+	if requestData.ClusterSelector != "" {
+		if requestData.ClusterName.Name != "" {
+			c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: "Set either cluster or clusterSelector, not both"})
+			return
+		}
+		submitRequestBundle(c, requestData, emailAddress, servicePrincipal)
+		return
+	}
+
 	// Validate namespaces and fetch group IDs and names
 	namespaceGroups, err := k8s.ValidateNamespaces(requestData.ClusterName.Name, requestData.Namespaces)
+	if errors.Is(err, k8s.ErrClusterUnavailable) {
+		reqLogger.Warn("Cluster unavailable, cannot validate namespaces", zap.String("cluster", requestData.ClusterName.Name), zap.Error(err))
+		c.JSON(http.StatusServiceUnavailable, models.SimpleMessageResponse{Error: clusterUnavailableMessage(requestData.ClusterName.Name)})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: fmt.Sprintf("Namespace validation failed: %v", err)})
 		return
 	}
 
 	// Create a new RequestData in database
-	dbRequestData := models.RequestData{
-		ClusterName:   requestData.ClusterName.Name,
+	dbRequestData := newRequestData(requestData, requestData.ClusterName.Name, emailAddress, servicePrincipal)
+
+	// Insert the request data into the database
+	if err := db.DB.WithContext(c.Request.Context()).Create(&dbRequestData).Error; err != nil {
+		reqLogger.Error("Error inserting data in SubmitRequest", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to submit request (database error)"})
+		return
+	}
+
+	// Insert namespaces into the request_namespaces table
+	if err := createRequestNamespaces(db.DB.WithContext(c.Request.Context()), dbRequestData.ID, namespaceGroups); err != nil {
+		reqLogger.Error("Error inserting namespace data in SubmitRequest", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to submit request (namespace error)"})
+		return
+	}
+
+	metrics.RequestsSubmittedTotal.WithLabelValues(dbRequestData.ClusterName, dbRequestData.RoleName).Inc()
+
+	// Send submission email
+	if dbRequestData.Email != "" {
+		sendSubmissionEmail(reqLogger, dbRequestData, fmt.Sprintf("Your JIT request #%d has been submitted", dbRequestData.ID))
+	}
+
+	// Respond with success message
+	c.JSON(http.StatusOK, models.SimpleMessageResponse{Message: "Request submitted successfully"})
+}
+
+// submitRequestBundle submits a request for every cluster matching the selector of the payload, linked by a bundle ID.
+// The namespaces are validated on every cluster before the requests are created in one transaction, so a bundle is submitted whole or not at all
+func submitRequestBundle(c *gin.Context, requestData SubmitRequestPayload, emailAddress string, servicePrincipal string) {
+	reqLogger := RequestLogger(c)
+
+	clusterNames, err := k8s.SelectClusters(requestData.ClusterSelector)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: err.Error()})
+		return
+	}
+	if len(clusterNames) == 0 {
+		c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: fmt.Sprintf("No cluster matches the selector %s", requestData.ClusterSelector)})
+		return
+	}
+
+	namespaceGroups := make(map[string]map[string]struct{ GroupID, GroupName string }, len(clusterNames))
+	for _, clusterName := range clusterNames {
+		groups, err := k8s.ValidateNamespaces(clusterName, requestData.Namespaces)
+		if errors.Is(err, k8s.ErrClusterUnavailable) {
+			reqLogger.Warn("Cluster unavailable, cannot validate namespaces", zap.String("cluster", clusterName), zap.Error(err))
+			c.JSON(http.StatusServiceUnavailable, models.SimpleMessageResponse{Error: clusterUnavailableMessage(clusterName)})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: fmt.Sprintf("Namespace validation failed on cluster %s: %v", clusterName, err)})
+			return
+		}
+		namespaceGroups[clusterName] = groups
+	}
+
+	bundleID, err := newBundleID()
+	if err != nil {
+		reqLogger.Error("Error generating bundle ID in SubmitRequest", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to submit request bundle"})
+		return
+	}
+	requests := make([]models.RequestData, len(clusterNames))
+	err = db.DB.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		for i, clusterName := range clusterNames {
+			requests[i] = newRequestData(requestData, clusterName, emailAddress, servicePrincipal)
+			requests[i].BundleID = bundleID
+			if err := tx.Create(&requests[i]).Error; err != nil {
+				return err
+			}
+			if err := createRequestNamespaces(tx, requests[i].ID, namespaceGroups[clusterName]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		reqLogger.Error("Error inserting request bundle in SubmitRequest", zap.String("bundleID", bundleID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to submit request bundle (database error)"})
+		return
+	}
+
+	requestIDs := make([]string, len(requests))
+	for i, request := range requests {
+		requestIDs[i] = fmt.Sprintf("#%d", request.ID)
+		metrics.RequestsSubmittedTotal.WithLabelValues(request.ClusterName, request.RoleName).Inc()
+	}
+	reqLogger.Info("Request bundle submitted", zap.String("bundleID", bundleID), zap.Strings("clusters", clusterNames))
+
+	// One email for the whole bundle
+	if emailAddress != "" {
+		bundle := requests[0]
+		bundle.ClusterName = strings.Join(clusterNames, ", ")
+		sendSubmissionEmail(reqLogger, bundle, fmt.Sprintf("Your JIT requests %s have been submitted", strings.Join(requestIDs, ", ")))
+	}
+
+	c.JSON(http.StatusOK, models.SimpleMessageResponse{Message: fmt.Sprintf("Requests submitted successfully for clusters %s", strings.Join(clusterNames, ", "))})
+}
+
+// newRequestData returns the request of a payload for a cluster, before it is inserted
+func newRequestData(requestData SubmitRequestPayload, clusterName string, emailAddress string, servicePrincipal string) models.RequestData {
+	return models.RequestData{
+		ClusterName:   clusterName,
 		RoleName:      requestData.Role.Name,
 		Status:        "Requested",
 		UserID:        requestData.UserID,
@@ -109,16 +262,12 @@ func SubmitRequest(c *gin.Context) {
 		StartDate:     requestData.StartDate,
 		EndDate:       requestData.EndDate,
 		Email:         emailAddress,
+		SubmittedBy:   servicePrincipal,
 	}
+}
 
-	// Insert the request data into the database
-	if err := db.DB.Create(&dbRequestData).Error; err != nil {
-		reqLogger.Error("Error inserting data in SubmitRequest", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to submit request (database error)"})
-		return
-	}
-
-	// Insert namespaces into the request_namespaces table
+// createRequestNamespaces inserts the namespaces of a request with the JitGroups approving them, in the order of their names
+func createRequestNamespaces(tx *gorm.DB, requestID uint, namespaceGroups map[string]struct{ GroupID, GroupName string }) error {
 	var namespaces []string
 	for ns := range namespaceGroups {
 		namespaces = append(namespaces, ns)
@@ -127,50 +276,62 @@ func SubmitRequest(c *gin.Context) {
 	for _, namespace := range namespaces {
 		groupInfo := namespaceGroups[namespace]
 		namespaceEntry := models.RequestNamespace{
-			RequestID: dbRequestData.ID,
+			RequestID: requestID,
 			Namespace: namespace,
 			GroupID:   groupInfo.GroupID,
 			GroupName: groupInfo.GroupName,
 			Approved:  false,
 		}
-		if err := db.DB.Create(&namespaceEntry).Error; err != nil {
-			reqLogger.Error("Error inserting namespace data in SubmitRequest", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to submit request (namespace error)"})
-			return
+		if err := tx.Create(&namespaceEntry).Error; err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	// Send submission email
-	if dbRequestData.Email != "" {
-		body := email.BuildRequestEmail(email.EmailRequestDetails{
-			Username:      dbRequestData.Username,
-			ClusterName:   dbRequestData.ClusterName,
-			Namespaces:    dbRequestData.Namespaces,
-			RoleName:      dbRequestData.RoleName,
-			Justification: dbRequestData.Justification,
-			StartDate:     dbRequestData.StartDate,
-			EndDate:       dbRequestData.EndDate,
-			Status:        "submitted",
-			Message:       "",
-		})
-		go func() {
-			if err := email.SendMail(dbRequestData.Email, fmt.Sprintf("Your JIT request #%d has been submitted", dbRequestData.ID), body); err != nil {
-				reqLogger.Warn("Failed to send submission email", zap.String("email", dbRequestData.Email), zap.Error(err))
-			}
-		}()
+// sendSubmissionEmail tells the requestor that their request was submitted, in the background
+func sendSubmissionEmail(reqLogger *zap.Logger, request models.RequestData, subject string) {
+	message := ""
+	if request.SubmittedBy != "" {
+		message = fmt.Sprintf("Submitted on your behalf by %s", request.SubmittedBy)
 	}
+	body := email.BuildRequestEmail(email.EmailRequestDetails{
+		Username:      request.Username,
+		ClusterName:   request.ClusterName,
+		Namespaces:    request.Namespaces,
+		RoleName:      request.RoleName,
+		Justification: request.Justification,
+		StartDate:     request.StartDate,
+		EndDate:       request.EndDate,
+		Status:        "submitted",
+		Message:       message,
+	})
+	go func() {
+		if err := email.SendMail(request.Email, subject, body); err != nil {
+			reqLogger.Warn("Failed to send submission email", zap.String("email", request.Email), zap.Error(err))
+		}
+	}()
+}
 
-	// Respond with success message
-	c.JSON(http.StatusOK, models.SimpleMessageResponse{Message: "Request submitted successfully"})
+// newBundleID returns a random ID linking the requests of a bundle
+func newBundleID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
 
 // ApproveOrRejectRequests godoc
 // @Summary Approve or reject JIT access requests
 // @Description Approves or rejects pending JIT access requests. Admins and platform approvers can approve/reject multiple requests at once. Non-admins can approve/reject individual namespaces.
+// @Description Users with the requests:approve permission for the cluster and role of a request approve/reject all of its namespaces.
+// @Description Approving the namespaces of a JitGroup in a request of a bundle approves them in its other pending requests too, rejections are not shared.
 // @Description Requires one or more cookies named kube_jit_session_<number> (e.g., kube_jit_session_0, kube_jit_session_1).
 // @Description Pass split cookies in the Cookie header, for example:
 // @Description     -H "Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}"
 // @Description Note: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.
+// @Description Scripts can use an API token with the approve scope instead: -H "Authorization: Bearer ${token}"
 // @Tags request
 // @Accept  json
 // @Produce  json
@@ -218,57 +379,40 @@ func SubmitRequest(c *gin.Context) {
 // @example }
 // @Success 200 {object} models.SimpleMessageResponse "Requests processed successfully"
 // @Failure 400 {object} models.SimpleMessageResponse "Invalid request format"
-// @Failure 401 {object} models.SimpleMessageResponse "Unauthorized: no approver groups in session"
+// @Failure 403 {object} models.SimpleMessageResponse "Forbidden: missing permission requests:approve"
 // @Failure 500 {object} models.SimpleMessageResponse "Failed to process requests"
+// @Failure 503 {object} models.SimpleMessageResponse "Cluster unavailable"
 // @Router /approve-reject [post]
 func ApproveOrRejectRequests(c *gin.Context) {
-	// Check if the user is logged in
-	sessionData := GetSessionData(c)
 	reqLogger := RequestLogger(c)
 
-	// Check if the user is an admin or platform approver
-	isAdmin, _ := sessionData["isAdmin"].(bool)
-	isPlatformApprover, _ := sessionData["isPlatformApprover"].(bool)
+	// Users approving every request (admins and platform approvers) send the requests, others the namespaces,
+	// the approve permission is checked by RequirePermission on the approver routes
+	access := AccessOf(c)
 
-	var approverGroups []string
-	if !isAdmin && !isPlatformApprover {
-		// Only non-admins need approverGroups
-		rawApproverGroups, ok := sessionData["approverGroups"]
-		if !ok {
-			c.JSON(http.StatusUnauthorized, models.SimpleMessageResponse{Error: "Unauthorized: no approver groups in session"})
-			return
-		}
-		// Handle both []models.Team and []interface{} (from session serialization)
-		if rawGroups, ok := rawApproverGroups.([]models.Team); ok {
-			for _, group := range rawGroups {
-				approverGroups = append(approverGroups, group.ID)
-			}
-		} else if rawGroups, ok := rawApproverGroups.([]any); ok {
-			for _, group := range rawGroups {
-				if groupMap, ok := group.(map[string]any); ok {
-					if id, ok := groupMap["id"].(string); ok {
-						approverGroups = append(approverGroups, id)
-					}
-				}
-			}
-		}
-		if len(approverGroups) == 0 {
-			c.JSON(http.StatusUnauthorized, models.SimpleMessageResponse{Error: "Unauthorized: no approver groups in session"})
-			return
-		}
-	}
-
-	if isAdmin || isPlatformApprover {
+	if access.CanAll(models.PermissionApprove) {
 		// Admin/Platform Approver: expects Namespaces []string
 		var req AdminApproveRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: "Invalid request format"})
 			return
 		}
+		// Every request is processed, the response is the first failure if any
+		bundles := newBundleApprovals()
+		var failed error
 		for _, r := range req.Requests {
-			processApproval(reqLogger, r.ID, r, req.ApproverID, req.ApproverName, req.Status, nil, c)
+			outcome, err := processApproval(reqLogger, r.ID, r, req.ApproverID, req.ApproverName, req.Status, access, c)
+			bundles.add(r.ID, outcome)
+			if failed == nil {
+				failed = err
+			}
 		}
+		bundles.share(reqLogger, req.ApproverID, req.ApproverName, req.Status, c)
 
+		if failed != nil {
+			writeApprovalError(c, failed)
+			return
+		}
 		c.JSON(http.StatusOK, models.SimpleMessageResponse{Message: "Admin/Platform requests processed successfully"})
 		return
 	} else {
@@ -277,6 +421,9 @@ func ApproveOrRejectRequests(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: "Invalid request format"})
 			return
 		}
+		// Every request is processed, the response is the first failure if any
+		bundles := newBundleApprovals()
+		var failed error
 		for _, r := range req.Requests {
 			namespaces := []string{r.Namespace}
 			// Convert to models.RequestData for downstream compatibility
@@ -293,18 +440,30 @@ func ApproveOrRejectRequests(c *gin.Context) {
 				EndDate:       r.EndDate,
 				FullyApproved: r.FullyApproved,
 			}
-			processApproval(reqLogger, r.ID, requestData, req.ApproverID, req.ApproverName, req.Status, approverGroups, c)
+			outcome, err := processApproval(reqLogger, r.ID, requestData, req.ApproverID, req.ApproverName, req.Status, access, c)
+			bundles.add(r.ID, outcome)
+			if failed == nil {
+				failed = err
+			}
 		}
+		bundles.share(reqLogger, req.ApproverID, req.ApproverName, req.Status, c)
 
+		if failed != nil {
+			writeApprovalError(c, failed)
+			return
+		}
 		c.JSON(http.StatusOK, models.SimpleMessageResponse{Message: "User requests processed successfully"})
 		return
 	}
 }
 
 // Helper function to process approval logic for each request
+// Users with the approve permission for the request's cluster and role approve every namespace,
+// JitGroup approvers the namespaces of their groups
 // It updates the request status and approver information in the database
 // It also creates the k8s object if all namespaces are approved
 // It sends an email notification to the user if the request is approved
+// It returns the bundle of the request and the JitGroups approved, or an approvalError for the caller to respond with
 func processApproval(
 	reqLogger *zap.Logger,
 	requestID uint,
@@ -312,21 +471,37 @@ func processApproval(
 	approverID string,
 	approverName string,
 	status string,
-	approverGroups []string,
+	access Access,
 	c *gin.Context,
-) {
+) (*approvalOutcome, error) {
+	// Approvers not allowed on every cluster and role approve the stored request, not the payload
+	approveAll := access.CanAll(models.PermissionApprove)
+	if !approveAll {
+		var stored models.RequestData
+		if err := db.DB.WithContext(c.Request.Context()).First(&stored, requestID).Error; err != nil {
+			reqLogger.Error("Error fetching request for approval", zap.Uint("requestID", requestID), zap.Error(err))
+			return nil, &approvalError{Status: http.StatusInternalServerError, Message: "Failed to fetch request", Err: err}
+		}
+		requestData = stored
+		approveAll = access.CanOn(models.PermissionApprove, stored.ClusterName, stored.RoleName)
+	}
+
 	// Fetch namespaces for the request
 	var dbNamespaces []models.RequestNamespace
-	if err := db.DB.Where("request_id = ?", requestID).Find(&dbNamespaces).Error; err != nil {
+	if err := db.DB.WithContext(c.Request.Context()).Where("request_id = ?", requestID).Find(&dbNamespaces).Error; err != nil {
 		reqLogger.Error("Error fetching namespaces for request", zap.Uint("requestID", requestID), zap.Error(err))
-		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to fetch namespaces"})
-		return
+		return nil, &approvalError{Status: http.StatusInternalServerError, Message: "Failed to fetch namespaces", Err: err}
 	}
 
-	// Approve all if admin (approverGroups == nil), else check group
+	// Approve all if allowed for the cluster and role, else check group.
+	// Only the approvals not limited to the cluster or role of the request are shared with its bundle
+	var sharedGroups []string
 	for i := range dbNamespaces {
 		ns := &dbNamespaces[i]
-		if approverGroups == nil || contains(approverGroups, ns.GroupID) {
+		if approveAll || contains(access.ApproverGroups, ns.GroupID) {
+			if (access.CanAll(models.PermissionApprove) || contains(access.ApproverGroups, ns.GroupID)) && !contains(sharedGroups, ns.GroupID) {
+				sharedGroups = append(sharedGroups, ns.GroupID)
+			}
 			if status == "Approved" {
 				ns.Approved = true
 			} else if status == "Rejected" {
@@ -334,10 +509,9 @@ func processApproval(
 			}
 			ns.ApproverID = approverID
 			ns.ApproverName = approverName
-			if err := db.DB.Save(ns).Error; err != nil {
+			if err := db.DB.WithContext(c.Request.Context()).Save(ns).Error; err != nil {
 				reqLogger.Error("Error updating namespace approval", zap.Uint("requestID", requestID), zap.String("namespace", ns.Namespace), zap.Error(err))
-				c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to update namespace approval"})
-				return
+				return nil, &approvalError{Status: http.StatusInternalServerError, Message: "Failed to update namespace approval", Err: err}
 			}
 		} else {
 			reqLogger.Info("Skipping namespace - approver does not have permissions",
@@ -377,19 +551,20 @@ func processApproval(
 		}
 		requestData.Namespaces = namespacesToSpec
 		requestData.ID = requestID
-		if err := k8s.CreateK8sObject(requestData, approverName); err != nil {
+		if err := k8s.CreateK8sObject(c.Request.Context(), requestData, approverName); err != nil {
 			reqLogger.Error("Error creating k8s object for request", zap.Uint("requestID", requestID), zap.Error(err))
-			c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to create k8s object"})
-			return
+			if errors.Is(err, k8s.ErrClusterUnavailable) {
+				return nil, &approvalError{Status: http.StatusServiceUnavailable, Message: clusterUnavailableMessage(requestData.ClusterName), Err: err}
+			}
+			return nil, &approvalError{Status: http.StatusInternalServerError, Message: "Failed to create k8s object", Err: err}
 		}
 	}
 
 	// Fetch the request record
 	var req models.RequestData
-	if err := db.DB.First(&req, requestID).Error; err != nil {
+	if err := db.DB.WithContext(c.Request.Context()).First(&req, requestID).Error; err != nil {
 		reqLogger.Error("Error fetching request for update", zap.Uint("requestID", requestID), zap.Error(err))
-		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to fetch request"})
-		return
+		return nil, &approvalError{Status: http.StatusInternalServerError, Message: "Failed to fetch request", Err: err}
 	}
 
 	// Append approver if not already present
@@ -404,10 +579,16 @@ func processApproval(
 	req.Status = finalStatus
 	req.FullyApproved = allApproved
 
-	if err := db.DB.Model(&req).Select("Status", "ApproverIDs", "ApproverNames", "FullyApproved").Updates(req).Error; err != nil {
+	if err := db.DB.WithContext(c.Request.Context()).Model(&req).Select("Status", "ApproverIDs", "ApproverNames", "FullyApproved").Updates(req).Error; err != nil {
 		reqLogger.Error("Error updating request after approval", zap.Uint("requestID", requestID), zap.Error(err))
-		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to update request"})
-		return
+		return nil, &approvalError{Status: http.StatusInternalServerError, Message: "Failed to update request", Err: err}
+	}
+
+	switch req.Status {
+	case "Approved":
+		metrics.RequestsApprovedTotal.WithLabelValues(req.ClusterName, req.RoleName).Inc()
+	case "Rejected":
+		metrics.RequestsRejectedTotal.WithLabelValues(req.ClusterName, req.RoleName).Inc()
 	}
 
 	if req.Email != "" {
@@ -428,4 +609,93 @@ func processApproval(
 			}
 		}()
 	}
+	return &approvalOutcome{bundleID: req.BundleID, groups: sharedGroups}, nil
+}
+
+// approvalError is a failure of processApproval, with the status and message the handler responds with
+type approvalError struct {
+	Status  int
+	Message string
+	Err     error
+}
+
+func (e *approvalError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Message, e.Err)
+}
+
+func (e *approvalError) Unwrap() error {
+	return e.Err
+}
+
+// writeApprovalError writes the status and message of an approvalError, or a 500 for other errors
+func writeApprovalError(c *gin.Context, err error) {
+	var approvalErr *approvalError
+	if errors.As(err, &approvalErr) {
+		c.JSON(approvalErr.Status, models.SimpleMessageResponse{Error: approvalErr.Message})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to process requests"})
+}
+
+// approvalOutcome is what processApproval approved in a request, to share it with the other requests of its bundle
+type approvalOutcome struct {
+	bundleID string
+	groups   []string
+}
+
+// bundleApprovals collects the JitGroups approved in bundled requests, to approve the namespaces of the same groups
+// in the other requests of their bundles, so approvers act once for a bundle
+type bundleApprovals struct {
+	requestIDs map[uint]bool       // requests of the payload, processed already
+	groups     map[string][]string // JitGroups approved, by bundle ID
+}
+
+func newBundleApprovals() *bundleApprovals {
+	return &bundleApprovals{requestIDs: make(map[uint]bool), groups: make(map[string][]string)}
+}
+
+// add records the outcome of a request of the payload
+func (b *bundleApprovals) add(requestID uint, outcome *approvalOutcome) {
+	b.requestIDs[requestID] = true
+	if outcome == nil || outcome.bundleID == "" {
+		return
+	}
+	for _, group := range outcome.groups {
+		if !contains(b.groups[outcome.bundleID], group) {
+			b.groups[outcome.bundleID] = append(b.groups[outcome.bundleID], group)
+		}
+	}
+}
+
+// share approves the namespaces of the approved JitGroups in the pending requests of the bundles, rejections are not shared.
+// Failures are only logged, the requests of the payload were processed and the handler responds for them
+func (b *bundleApprovals) share(reqLogger *zap.Logger, approverID string, approverName string, status string, c *gin.Context) {
+	if status != "Approved" {
+		return
+	}
+	for _, bundleID := range slices.Sorted(maps.Keys(b.groups)) {
+		groups := b.groups[bundleID]
+		if len(groups) == 0 {
+			continue
+		}
+		var siblings []models.RequestData
+		if err := db.DB.WithContext(c.Request.Context()).Where("bundle_id = ? AND status = ?", bundleID, "Requested").Order("id").Find(&siblings).Error; err != nil {
+			reqLogger.Error("Error fetching bundled requests", zap.String("bundleID", bundleID), zap.Error(err))
+			continue
+		}
+		for _, sibling := range siblings {
+			if b.requestIDs[sibling.ID] {
+				continue
+			}
+			reqLogger.Info("Sharing approval with bundled request", zap.String("bundleID", bundleID), zap.Uint("requestID", sibling.ID), zap.Strings("groups", groups))
+			if _, err := processApproval(reqLogger, sibling.ID, sibling, approverID, approverName, status, Access{ApproverGroups: groups}, c); err != nil {
+				reqLogger.Warn("Failed to share approval with bundled request", zap.String("bundleID", bundleID), zap.Uint("requestID", sibling.ID), zap.Error(err))
+			}
+		}
+	}
+}
+
+// clusterUnavailableMessage is the error returned when a cluster's client cannot be created, it is retried with a backoff
+func clusterUnavailableMessage(clusterName string) string {
+	return fmt.Sprintf("Cluster %s is unavailable, try again later", clusterName)
 }
@@ -2,10 +2,13 @@ package handlers
 
 import (
 	"bytes"
+	"context"
+	"database/sql/driver"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
-	"sync"
 	"testing"
 	"time"
 
@@ -30,9 +33,8 @@ var originalK8sValidateNamespaces func(clusterName string, namespaces []string)
 	GroupID   string
 	GroupName string
 }, error)
-var originalK8sCreateK8sObject func(request models.RequestData, approverName string) error
+var originalK8sCreateK8sObject func(ctx context.Context, request models.RequestData, approverName string) error
 var originalEmailSendMail func(to, subject, body string) error
-var emailSentOnce sync.Once
 
 // setupRequestTest configures a Gin router with a mocked DB and session management for request handler tests.
 func setupRequestTest(t *testing.T) (*gin.Engine, sqlmock.Sqlmock, func()) {
@@ -132,6 +134,7 @@ func TestSubmitRequest(t *testing.T) {
 	testCases := []struct {
 		name                      string
 		setupSession              func(s sessions.Session)
+		settings                  func(s *k8s.Settings) // labels of the clusters matched by clusterSelector
 		payload                   SubmitRequestPayload
 		mockK8sValidateNamespaces func() // To set up the mock for k8s.ValidateNamespaces
 		mockDB                    func(t *testing.T, mock sqlmock.Sqlmock, payload SubmitRequestPayload, expectedRequestID uint)
@@ -212,6 +215,240 @@ func TestSubmitRequest(t *testing.T) {
 			expectedStatus: http.StatusOK,
 			expectedBody:   models.SimpleMessageResponse{Message: "Request submitted successfully"},
 		},
+		{
+			name: "Service principal submits on behalf of a user",
+			setupSession: func(s sessions.Session) {
+				s.Set("sessionData", map[string]interface{}{
+					"id":               "incident-bot-id",
+					"name":             "incident-bot",
+					"servicePrincipal": "incident-bot",
+				})
+			},
+			payload: SubmitRequestPayload{
+				Role:          models.Roles{Name: "view"},
+				ClusterName:   models.Cluster{Name: "test-cluster"},
+				Users:         []string{"jane@example.com"},
+				Namespaces:    []string{"ns1"},
+				Justification: "Incident 123",
+				StartDate:     sampleTime,
+				EndDate:       sampleTime.Add(1 * time.Hour),
+				OnBehalfOf:    &OnBehalfOf{UserID: "jane", Username: "Jane Doe", Email: "jane@example.com"},
+			},
+			mockK8sValidateNamespaces: func() {
+				k8s.ValidateNamespaces = func(clusterName string, namespaces []string) (map[string]struct {
+					GroupID   string
+					GroupName string
+				}, error) {
+					return map[string]struct {
+						GroupID   string
+						GroupName string
+					}{
+						"ns1": {GroupID: "group1", GroupName: "Group One"},
+					}, nil
+				}
+			},
+			mockDB: func(t *testing.T, mock sqlmock.Sqlmock, payload SubmitRequestPayload, expectedRequestID uint) {
+				// The user is recorded as the requestor and the principal as the submitter
+				mock.ExpectBegin()
+				mock.ExpectQuery(`INSERT INTO "request_data" .*"email","submitted_by"`).
+					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+						"test-cluster", "view", "Requested", "", "jane", "Jane Doe", sqlmock.AnyArg(), sqlmock.AnyArg(),
+						"Incident 123", sqlmock.AnyArg(), sqlmock.AnyArg(), false, "jane@example.com", "incident-bot", "").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(expectedRequestID))
+				mock.ExpectCommit()
+				mock.ExpectBegin()
+				mock.ExpectQuery(`INSERT INTO "request_namespaces"`).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(101))
+				mock.ExpectCommit()
+			},
+			mockEmail: func() {
+				email.SendMail = func(to, subject, body string) error {
+					assert.Equal(t, "jane@example.com", to)
+					assert.Contains(t, body, "Submitted on your behalf by incident-bot")
+					return nil
+				}
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   models.SimpleMessageResponse{Message: "Request submitted successfully"},
+		},
+		{
+			name: "Service principal without onBehalfOf",
+			setupSession: func(s sessions.Session) {
+				s.Set("sessionData", map[string]interface{}{
+					"id":               "incident-bot-id",
+					"name":             "incident-bot",
+					"servicePrincipal": "incident-bot",
+				})
+			},
+			payload: SubmitRequestPayload{
+				Role:        models.Roles{Name: "view"},
+				ClusterName: models.Cluster{Name: "test-cluster"},
+				Namespaces:  []string{"ns1"},
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   models.SimpleMessageResponse{Error: "Service principals must submit on behalf of a user (onBehalfOf userID and username)"},
+		},
+		{
+			name: "User submitting on behalf of another user",
+			setupSession: func(s sessions.Session) {
+				s.Set("sessionData", map[string]interface{}{
+					"email": "testuser@example.com",
+					"id":    "testuser",
+					"name":  "Test User",
+				})
+			},
+			payload: SubmitRequestPayload{
+				Role:        models.Roles{Name: "view"},
+				ClusterName: models.Cluster{Name: "test-cluster"},
+				Namespaces:  []string{"ns1"},
+				OnBehalfOf:  &OnBehalfOf{UserID: "jane", Username: "Jane Doe"},
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   models.SimpleMessageResponse{Error: "Only service principals can submit on behalf of another user"},
+		},
+		{
+			name: "Cluster unavailable",
+			setupSession: func(s sessions.Session) {
+				s.Set("sessionData", map[string]interface{}{
+					"email": "testuser@example.com",
+					"id":    "testuser",
+					"name":  "Test User",
+				})
+			},
+			payload: SubmitRequestPayload{
+				Role:        models.Roles{Name: "view"},
+				ClusterName: models.Cluster{Name: "broken-cluster"},
+				Namespaces:  []string{"ns1"},
+			},
+			mockK8sValidateNamespaces: func() {
+				k8s.ValidateNamespaces = func(clusterName string, namespaces []string) (map[string]struct {
+					GroupID   string
+					GroupName string
+				}, error) {
+					return nil, fmt.Errorf("error fetching JitGroups: %w: broken-cluster: invalid CA", k8s.ErrClusterUnavailable)
+				}
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   models.SimpleMessageResponse{Error: "Cluster broken-cluster is unavailable, try again later"},
+		},
+		{
+			name: "Cluster selector submits a bundle of requests",
+			setupSession: func(s sessions.Session) {
+				s.Set("sessionData", map[string]interface{}{
+					"email": "testuser@example.com",
+					"id":    "testuser",
+					"name":  "Test User",
+				})
+			},
+			settings: bundleClusters,
+			payload: SubmitRequestPayload{
+				Role:            models.Roles{Name: "view"},
+				ClusterSelector: "env=prod",
+				UserID:          "testuser",
+				Username:        "Test User",
+				Namespaces:      []string{"ns1"},
+				Justification:   "Fleet rollout",
+				StartDate:       sampleTime,
+				EndDate:         sampleTime.Add(1 * time.Hour),
+			},
+			mockK8sValidateNamespaces: func() {
+				k8s.ValidateNamespaces = func(clusterName string, namespaces []string) (map[string]struct {
+					GroupID   string
+					GroupName string
+				}, error) {
+					assert.Contains(t, []string{"eu-1", "us-1"}, clusterName)
+					return map[string]struct {
+						GroupID   string
+						GroupName string
+					}{
+						"ns1": {GroupID: "group1", GroupName: "Group One"},
+					}, nil
+				}
+			},
+			mockDB: func(t *testing.T, mock sqlmock.Sqlmock, payload SubmitRequestPayload, expectedRequestID uint) {
+				// Every request of the bundle is created in one transaction, with the same bundle ID
+				bundleID := &bundleIDArg{}
+				mock.ExpectBegin()
+				for i, clusterName := range []string{"eu-1", "us-1"} {
+					requestID := expectedRequestID + uint(i)
+					mock.ExpectQuery(`INSERT INTO "request_data" .*"submitted_by","bundle_id"`).
+						WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+							clusterName, "view", "Requested", "", "testuser", "Test User", sqlmock.AnyArg(), sqlmock.AnyArg(),
+							"Fleet rollout", sqlmock.AnyArg(), sqlmock.AnyArg(), false, "testuser@example.com", "", bundleID).
+						WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(requestID))
+					mock.ExpectQuery(`INSERT INTO "request_namespaces"`).
+						WithArgs(requestID, "ns1", "group1", "Group One", false, "", "").
+						WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(100 + requestID))
+				}
+				mock.ExpectCommit()
+			},
+			mockEmail: func() {
+				email.SendMail = func(to, subject, body string) error {
+					assert.Equal(t, "testuser@example.com", to)
+					assert.Equal(t, "Your JIT requests #1, #2 have been submitted", subject)
+					assert.Contains(t, body, "eu-1, us-1")
+					return nil
+				}
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   models.SimpleMessageResponse{Message: "Requests submitted successfully for clusters eu-1, us-1"},
+		},
+		{
+			name: "Cluster selector matching no cluster",
+			setupSession: func(s sessions.Session) {
+				s.Set("sessionData", map[string]interface{}{"id": "testuser", "name": "Test User"})
+			},
+			settings: bundleClusters,
+			payload: SubmitRequestPayload{
+				Role:            models.Roles{Name: "view"},
+				ClusterSelector: "env=staging",
+				Namespaces:      []string{"ns1"},
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   models.SimpleMessageResponse{Error: "No cluster matches the selector env=staging"},
+		},
+		{
+			name: "Cluster selector with a cluster",
+			setupSession: func(s sessions.Session) {
+				s.Set("sessionData", map[string]interface{}{"id": "testuser", "name": "Test User"})
+			},
+			payload: SubmitRequestPayload{
+				Role:            models.Roles{Name: "view"},
+				ClusterName:     models.Cluster{Name: "test-cluster"},
+				ClusterSelector: "env=prod",
+				Namespaces:      []string{"ns1"},
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   models.SimpleMessageResponse{Error: "Set either cluster or clusterSelector, not both"},
+		},
+		{
+			name: "Namespace missing on a selected cluster",
+			setupSession: func(s sessions.Session) {
+				s.Set("sessionData", map[string]interface{}{"id": "testuser", "name": "Test User"})
+			},
+			settings: bundleClusters,
+			payload: SubmitRequestPayload{
+				Role:            models.Roles{Name: "view"},
+				ClusterSelector: "env=prod",
+				Namespaces:      []string{"ns1"},
+			},
+			mockK8sValidateNamespaces: func() {
+				k8s.ValidateNamespaces = func(clusterName string, namespaces []string) (map[string]struct {
+					GroupID   string
+					GroupName string
+				}, error) {
+					if clusterName == "us-1" {
+						return nil, fmt.Errorf("namespace ns1 not found")
+					}
+					return map[string]struct {
+						GroupID   string
+						GroupName string
+					}{"ns1": {GroupID: "group1", GroupName: "Group One"}}, nil
+				}
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   models.SimpleMessageResponse{Error: "Namespace validation failed on cluster us-1: namespace ns1 not found"},
+		},
 		// Add more test cases:
 		// - Invalid request data (binding error)
 		// - k8s.ValidateNamespaces returns an error
@@ -226,11 +463,21 @@ func TestSubmitRequest(t *testing.T) {
 			defer teardown()
 
 			// Setup mocks
+			if tc.settings != nil {
+				useSettings(t, tc.settings)
+			}
 			if tc.mockK8sValidateNamespaces != nil {
 				tc.mockK8sValidateNamespaces()
 			}
+			// The submission email is sent in a goroutine, wait for it so it does not reach the mock of a later test
+			emailSent := make(chan struct{})
 			if tc.mockEmail != nil {
 				tc.mockEmail()
+				sendMail := email.SendMail
+				email.SendMail = func(to, subject, body string) error {
+					defer close(emailSent)
+					return sendMail(to, subject, body)
+				}
 			}
 			// The expectedRequestID for mockDB might need to be dynamic if not always 1
 			if tc.mockDB != nil {
@@ -278,10 +525,43 @@ func TestSubmitRequest(t *testing.T) {
 				expectedJSON, _ := json.Marshal(tc.expectedBody)
 				assert.JSONEq(t, string(expectedJSON), w.Body.String())
 			}
+			if tc.mockEmail != nil {
+				select {
+				case <-emailSent:
+				case <-time.After(time.Second):
+					t.Error("Expected the submission email to be sent")
+				}
+			}
 		})
 	}
 }
 
+// bundleClusters configures clusters labeled by environment for the cluster selector tests
+func bundleClusters(s *k8s.Settings) {
+	s.ClusterNames = []string{"eu-1", "dev-1", "us-1"}
+	s.ClusterConfigs = map[string]k8s.ClusterConfig{
+		"eu-1":  {Name: "eu-1", Labels: map[string]string{"env": "prod", "region": "eu"}},
+		"dev-1": {Name: "dev-1", Labels: map[string]string{"env": "dev", "region": "eu"}},
+		"us-1":  {Name: "us-1", Labels: map[string]string{"env": "prod", "region": "us"}},
+	}
+}
+
+// bundleIDArg matches a generated bundle ID, and the same one on every later match
+type bundleIDArg struct {
+	value string
+}
+
+func (a *bundleIDArg) Match(v driver.Value) bool {
+	id, ok := v.(string)
+	if !ok || len(id) != 32 {
+		return false
+	}
+	if a.value == "" {
+		a.value = id
+	}
+	return id == a.value
+}
+
 func TestApproveOrRejectRequests(t *testing.T) {
 	// Define column names for sqlmock
 	requestDataCols := []string{"id", "created_at", "updated_at", "deleted_at", "cluster_name", "role_name", "status", "user_id", "username", "users", "namespaces", "justification", "start_date", "end_date", "email", "approver_ids", "approver_names", "fully_approved", "notes"}
@@ -289,7 +569,11 @@ func TestApproveOrRejectRequests(t *testing.T) {
 
 	sampleTime := time.Now().Truncate(time.Second)
 
-	var emailSent chan struct{}
+	useApiRoles(t, models.ApiRole{
+		Name:        "test-cluster-approver",
+		Permissions: []string{models.PermissionApprove},
+		Clusters:    []string{"test-cluster"},
+	})
 
 	testCases := []struct {
 		name                   string
@@ -298,6 +582,7 @@ func TestApproveOrRejectRequests(t *testing.T) {
 		mockDB                 func(t *testing.T, mock sqlmock.Sqlmock)
 		mockK8sCreateK8sObject func() // To set up the mock for k8s.CreateK8sObject
 		mockEmail              func() // To set up the mock for email.SendMail
+		expectedEmails         int    // Status change emails sent in goroutines, waited for before the next case
 		expectedStatus         int
 		expectedBody           interface{}
 	}{
@@ -385,7 +670,7 @@ func TestApproveOrRejectRequests(t *testing.T) {
 				mock.ExpectCommit()
 			},
 			mockK8sCreateK8sObject: func() {
-				k8s.CreateK8sObject = func(request models.RequestData, approverName string) error {
+				k8s.CreateK8sObject = func(ctx context.Context, request models.RequestData, approverName string) error {
 					assert.Equal(t, uint(1), request.ID)
 					assert.Equal(t, "Admin User", approverName)
 					assert.ElementsMatch(t, []string{"ns-a", "ns-b"}, request.Namespaces) // Namespaces should be the ones from dbNamespaces
@@ -393,18 +678,272 @@ func TestApproveOrRejectRequests(t *testing.T) {
 				}
 			},
 			mockEmail: func() {
-				emailSent = make(chan struct{})
-				emailSentOnce = sync.Once{}
 				email.SendMail = func(to, subject, body string) error {
 					assert.Equal(t, "requestor@example.com", to)
 					assert.Contains(t, subject, "Your JIT request #1 is now Approved")
-					emailSentOnce.Do(func() { close(emailSent) })
 					return nil
 				}
 			},
+			expectedEmails: 1,
 			expectedStatus: http.StatusOK,
 			expectedBody:   models.SimpleMessageResponse{Message: "Admin/Platform requests processed successfully"},
 		},
+		{
+			name: "API role approver for the cluster approves every namespace of a request",
+			setupSession: func(s sessions.Session) {
+				s.Set("sessionData", map[string]interface{}{
+					"id":       "lead001",
+					"name":     "Team Lead",
+					"apiRoles": []string{"test-cluster-approver"},
+				})
+				err := s.Save()
+				assert.NoError(t, err)
+			},
+			payload: gin.H{
+				"approverID":   "lead001",
+				"approverName": "Team Lead",
+				"status":       "Approved",
+				// The cluster of the payload is not trusted, the stored request is checked
+				"requests": []gin.H{{"id": 1, "clusterName": "other-cluster", "roleName": "view", "namespace": "ns-a"}},
+			},
+			mockDB: func(t *testing.T, mock sqlmock.Sqlmock) {
+				requestID := uint(1)
+				initialApproverIDsJSON, _ := json.Marshal([]string{})
+				initialApproverNamesJSON, _ := json.Marshal([]string{})
+				storedRow := func() *sqlmock.Rows {
+					return sqlmock.NewRows(requestDataCols).
+						AddRow(requestID, sampleTime, sampleTime, nil, "test-cluster", "view", "Requested", "user123", "User OneTwoThree", `["user123@example.com"]`, `["ns-a","ns-b"]`, "Scoped approval test", sampleTime, sampleTime.Add(2*time.Hour), "requestor@example.com", initialApproverIDsJSON, initialApproverNamesJSON, false, "")
+				}
+
+				// 1. Expect fetch of the stored request to check the cluster and role
+				mock.ExpectQuery(`SELECT \* FROM "request_data" WHERE "request_data"."id" = \$1 ORDER BY "request_data"."id" LIMIT \$2`).
+					WithArgs(requestID, 1).
+					WillReturnRows(storedRow())
+
+				// 2. Expect fetch namespaces, and a save for each, as the role covers the cluster
+				nsRows := sqlmock.NewRows(requestNamespaceCols).
+					AddRow(uint(10), requestID, "ns-a", "groupA", "Group A", false, "", "").
+					AddRow(uint(11), requestID, "ns-b", "groupB", "Group B", false, "", "")
+				mock.ExpectQuery(`SELECT \* FROM "request_namespaces" WHERE request_id = \$1`).
+					WithArgs(requestID).
+					WillReturnRows(nsRows)
+				for _, ns := range []struct {
+					id    uint
+					name  string
+					group string
+				}{{10, "ns-a", "A"}, {11, "ns-b", "B"}} {
+					mock.ExpectBegin()
+					mock.ExpectExec(`UPDATE "request_namespaces" SET "request_id"=\$1,"namespace"=\$2,"group_id"=\$3,"group_name"=\$4,"approved"=\$5,"approver_id"=\$6,"approver_name"=\$7 WHERE "id" = \$8`).
+						WithArgs(requestID, ns.name, "group"+ns.group, "Group "+ns.group, true, "lead001", "Team Lead", ns.id).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectCommit()
+				}
+
+				// 3. Expect fetch and update of the request
+				mock.ExpectQuery(`SELECT \* FROM "request_data" WHERE "request_data"."id" = \$1 ORDER BY "request_data"."id" LIMIT \$2`).
+					WithArgs(requestID, 1).
+					WillReturnRows(storedRow())
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "request_data" SET "updated_at"=\$1,"approver_ids"=\$2,"approver_names"=\$3,"status"=\$4,"fully_approved"=\$5 WHERE "id" = \$6`).
+					WithArgs(sqlmock.AnyArg(), `["lead001"]`, `["Team Lead"]`, "Approved", true, requestID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			mockK8sCreateK8sObject: func() {
+				k8s.CreateK8sObject = func(ctx context.Context, request models.RequestData, approverName string) error {
+					assert.Equal(t, "test-cluster", request.ClusterName)
+					assert.ElementsMatch(t, []string{"ns-a", "ns-b"}, request.Namespaces)
+					return nil
+				}
+			},
+			mockEmail: func() {
+				email.SendMail = func(to, subject, body string) error {
+					return nil
+				}
+			},
+			expectedEmails: 1,
+			expectedStatus: http.StatusOK,
+			expectedBody:   models.SimpleMessageResponse{Message: "User requests processed successfully"},
+		},
+		{
+			name: "JitGroup approval is shared with the other requests of the bundle",
+			setupSession: func(s sessions.Session) {
+				s.Set("sessionData", map[string]interface{}{
+					"id":             "approver001",
+					"name":           "Group Approver",
+					"approverGroups": []models.Team{{ID: "groupA", Name: "Group A"}},
+				})
+				err := s.Save()
+				assert.NoError(t, err)
+			},
+			payload: gin.H{
+				"approverID":   "approver001",
+				"approverName": "Group Approver",
+				"status":       "Approved",
+				// The users and dates of the payload are not trusted, the stored request is approved
+				"requests": []gin.H{{"id": 1, "clusterName": "eu-1", "roleName": "view", "namespace": "ns-a", "users": []string{"intruder@example.com"}, "endDate": sampleTime.Add(48 * time.Hour)}},
+			},
+			mockDB: func(t *testing.T, mock sqlmock.Sqlmock) {
+				bundleCols := append(append([]string{}, requestDataCols...), "bundle_id")
+				initialApproverIDsJSON, _ := json.Marshal([]string{})
+				initialApproverNamesJSON, _ := json.Marshal([]string{})
+				bundledRow := func(rows *sqlmock.Rows, requestID uint, clusterName string) *sqlmock.Rows {
+					return rows.AddRow(requestID, sampleTime, sampleTime, nil, clusterName, "view", "Requested", "user123", "User OneTwoThree", `["user123@example.com"]`, `["ns-a"]`, "Fleet rollout", sampleTime, sampleTime.Add(2*time.Hour), "requestor@example.com", initialApproverIDsJSON, initialApproverNamesJSON, false, "", "bundle-1")
+				}
+
+				// The namespace of the approver's group is approved in the stored request of the payload, then in the other request of its bundle
+				for _, request := range []struct {
+					id      uint
+					cluster string
+				}{{1, "eu-1"}, {2, "us-1"}} {
+					if request.id == 2 {
+						mock.ExpectQuery(`SELECT \* FROM "request_data" WHERE bundle_id = \$1 AND status = \$2 ORDER BY id`).
+							WithArgs("bundle-1", "Requested").
+							WillReturnRows(bundledRow(sqlmock.NewRows(bundleCols), 2, "us-1"))
+					}
+					mock.ExpectQuery(`SELECT \* FROM "request_data" WHERE "request_data"."id" = \$1 ORDER BY "request_data"."id" LIMIT \$2`).
+						WithArgs(request.id, 1).
+						WillReturnRows(bundledRow(sqlmock.NewRows(bundleCols), request.id, request.cluster))
+					mock.ExpectQuery(`SELECT \* FROM "request_namespaces" WHERE request_id = \$1`).
+						WithArgs(request.id).
+						WillReturnRows(sqlmock.NewRows(requestNamespaceCols).AddRow(10*request.id, request.id, "ns-a", "groupA", "Group A", false, "", ""))
+					mock.ExpectBegin()
+					mock.ExpectExec(`UPDATE "request_namespaces"`).
+						WithArgs(request.id, "ns-a", "groupA", "Group A", true, "approver001", "Group Approver", 10*request.id).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectCommit()
+					mock.ExpectQuery(`SELECT \* FROM "request_data" WHERE "request_data"."id" = \$1 ORDER BY "request_data"."id" LIMIT \$2`).
+						WithArgs(request.id, 1).
+						WillReturnRows(bundledRow(sqlmock.NewRows(bundleCols), request.id, request.cluster))
+					mock.ExpectBegin()
+					mock.ExpectExec(`UPDATE "request_data" SET "updated_at"=\$1,"approver_ids"=\$2,"approver_names"=\$3,"status"=\$4,"fully_approved"=\$5 WHERE "id" = \$6`).
+						WithArgs(sqlmock.AnyArg(), `["approver001"]`, `["Group Approver"]`, "Approved", true, request.id).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectCommit()
+				}
+			},
+			mockK8sCreateK8sObject: func() {
+				k8s.CreateK8sObject = func(ctx context.Context, request models.RequestData, approverName string) error {
+					assert.Contains(t, []string{"eu-1", "us-1"}, request.ClusterName)
+					assert.Equal(t, []string{"ns-a"}, request.Namespaces)
+					assert.Equal(t, []string{"user123@example.com"}, request.Users)
+					assert.True(t, request.EndDate.Equal(sampleTime.Add(2*time.Hour)))
+					return nil
+				}
+			},
+			mockEmail: func() {
+				email.SendMail = func(to, subject, body string) error {
+					return nil
+				}
+			},
+			expectedEmails: 2,
+			expectedStatus: http.StatusOK,
+			expectedBody:   models.SimpleMessageResponse{Message: "User requests processed successfully"},
+		},
+		{
+			name: "Failure of a bundled request is logged, not returned",
+			setupSession: func(s sessions.Session) {
+				s.Set("sessionData", map[string]interface{}{
+					"id":             "approver001",
+					"name":           "Group Approver",
+					"approverGroups": []models.Team{{ID: "groupA", Name: "Group A"}},
+				})
+				err := s.Save()
+				assert.NoError(t, err)
+			},
+			payload: gin.H{
+				"approverID":   "approver001",
+				"approverName": "Group Approver",
+				"status":       "Approved",
+				"requests":     []gin.H{{"id": 1, "clusterName": "eu-1", "roleName": "view", "namespace": "ns-a"}},
+			},
+			mockDB: func(t *testing.T, mock sqlmock.Sqlmock) {
+				bundleCols := append(append([]string{}, requestDataCols...), "bundle_id")
+				initialApproverIDsJSON, _ := json.Marshal([]string{})
+				initialApproverNamesJSON, _ := json.Marshal([]string{})
+				bundledRow := func(rows *sqlmock.Rows, requestID uint, clusterName string) *sqlmock.Rows {
+					return rows.AddRow(requestID, sampleTime, sampleTime, nil, clusterName, "view", "Requested", "user123", "User OneTwoThree", `["user123@example.com"]`, `["ns-a"]`, "Fleet rollout", sampleTime, sampleTime.Add(2*time.Hour), "requestor@example.com", initialApproverIDsJSON, initialApproverNamesJSON, false, "", "bundle-1")
+				}
+
+				// The request of the payload is approved, the cluster of the other request of its bundle is unavailable
+				for _, id := range []uint{1, 2} {
+					cluster := "eu-1"
+					if id == 2 {
+						cluster = "us-1"
+						mock.ExpectQuery(`SELECT \* FROM "request_data" WHERE bundle_id = \$1 AND status = \$2 ORDER BY id`).
+							WithArgs("bundle-1", "Requested").
+							WillReturnRows(bundledRow(sqlmock.NewRows(bundleCols), 2, cluster))
+					}
+					mock.ExpectQuery(`SELECT \* FROM "request_data" WHERE "request_data"."id" = \$1 ORDER BY "request_data"."id" LIMIT \$2`).
+						WithArgs(id, 1).
+						WillReturnRows(bundledRow(sqlmock.NewRows(bundleCols), id, cluster))
+					mock.ExpectQuery(`SELECT \* FROM "request_namespaces" WHERE request_id = \$1`).
+						WithArgs(id).
+						WillReturnRows(sqlmock.NewRows(requestNamespaceCols).AddRow(10*id, id, "ns-a", "groupA", "Group A", false, "", ""))
+					mock.ExpectBegin()
+					mock.ExpectExec(`UPDATE "request_namespaces"`).
+						WithArgs(id, "ns-a", "groupA", "Group A", true, "approver001", "Group Approver", 10*id).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectCommit()
+					if id == 2 {
+						break
+					}
+					mock.ExpectQuery(`SELECT \* FROM "request_data" WHERE "request_data"."id" = \$1 ORDER BY "request_data"."id" LIMIT \$2`).
+						WithArgs(id, 1).
+						WillReturnRows(bundledRow(sqlmock.NewRows(bundleCols), id, "eu-1"))
+					mock.ExpectBegin()
+					mock.ExpectExec(`UPDATE "request_data" SET "updated_at"=\$1,"approver_ids"=\$2,"approver_names"=\$3,"status"=\$4,"fully_approved"=\$5 WHERE "id" = \$6`).
+						WithArgs(sqlmock.AnyArg(), `["approver001"]`, `["Group Approver"]`, "Approved", true, id).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectCommit()
+				}
+			},
+			mockK8sCreateK8sObject: func() {
+				k8s.CreateK8sObject = func(ctx context.Context, request models.RequestData, approverName string) error {
+					if request.ClusterName == "us-1" {
+						return k8s.ErrClusterUnavailable
+					}
+					return nil
+				}
+			},
+			mockEmail: func() {
+				email.SendMail = func(to, subject, body string) error {
+					return nil
+				}
+			},
+			expectedEmails: 1,
+			expectedStatus: http.StatusOK,
+			expectedBody:   models.SimpleMessageResponse{Message: "User requests processed successfully"},
+		},
+		{
+			name: "Failure of a request of the payload is the only response",
+			setupSession: func(s sessions.Session) {
+				s.Set("sessionData", map[string]interface{}{
+					"id":             "approver001",
+					"name":           "Group Approver",
+					"approverGroups": []models.Team{{ID: "groupA", Name: "Group A"}},
+				})
+				err := s.Save()
+				assert.NoError(t, err)
+			},
+			payload: gin.H{
+				"approverID":   "approver001",
+				"approverName": "Group Approver",
+				"status":       "Approved",
+				"requests":     []gin.H{{"id": 1, "clusterName": "eu-1", "roleName": "view", "namespace": "ns-a"}},
+			},
+			mockDB: func(t *testing.T, mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM "request_data" WHERE "request_data"."id" = \$1 ORDER BY "request_data"."id" LIMIT \$2`).
+					WithArgs(1, 1).
+					WillReturnRows(sqlmock.NewRows(requestDataCols).
+						AddRow(1, sampleTime, sampleTime, nil, "eu-1", "view", "Requested", "user123", "User OneTwoThree", `["user123@example.com"]`, `["ns-a"]`, "Failure test", sampleTime, sampleTime.Add(2*time.Hour), "requestor@example.com", `[]`, `[]`, false, ""))
+				mock.ExpectQuery(`SELECT \* FROM "request_namespaces" WHERE request_id = \$1`).
+					WithArgs(1).
+					WillReturnError(errors.New("connection reset"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   models.SimpleMessageResponse{Error: "Failed to fetch namespaces"},
+		},
 		// Add more test cases:
 		// - Admin rejects a request
 		// - Platform approver approves/rejects
@@ -429,8 +968,15 @@ func TestApproveOrRejectRequests(t *testing.T) {
 			if tc.mockK8sCreateK8sObject != nil {
 				tc.mockK8sCreateK8sObject()
 			}
+			// The status change emails are sent in goroutines, each is counted on this case's channel
+			emailSent := make(chan struct{}, tc.expectedEmails)
 			if tc.mockEmail != nil {
 				tc.mockEmail()
+				sendMail := email.SendMail
+				email.SendMail = func(to, subject, body string) error {
+					defer func() { emailSent <- struct{}{} }()
+					return sendMail(to, subject, body)
+				}
 			}
 
 			jsonPayload, _ := json.Marshal(tc.payload)
@@ -471,8 +1017,8 @@ func TestApproveOrRejectRequests(t *testing.T) {
 				assert.JSONEq(t, string(expectedJSON), w.Body.String())
 			}
 
-			// Wait for email goroutine (if this test expects an email)
-			if tc.mockEmail != nil {
+			// Wait for every email goroutine so none reaches the mock of a later case
+			for i := 0; i < tc.expectedEmails; i++ {
 				select {
 				case <-emailSent:
 					// Email sent
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"kube-jit/internal/models"
+	"kube-jit/pkg/k8s"
+	"kube-jit/pkg/utils"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// serviceTokenPrefix marks access tokens of service principals, told apart from personal API tokens in TokenAuth
+	serviceTokenPrefix = "kjitsa_"
+	// serviceTokenName is the securecookie name of service principal access tokens,
+	// so session cookies signed with the same key cannot be used as access tokens
+	serviceTokenName = "kube_jit_service_token"
+	// serviceTokenLifetime is how long a service principal access token is valid
+	serviceTokenLifetime = 15 * time.Minute
+)
+
+// servicePrincipalScopes are the scopes service principals can be granted,
+// they have no groups so cannot approve requests
+var servicePrincipalScopes = []string{ScopeSubmit, ScopeHistory}
+
+// OnBehalfOf is the user a service principal submits a request for
+type OnBehalfOf struct {
+	UserID   string `json:"userID" example:"12345"`
+	Username string `json:"username" example:"Jane Doe"`
+	Email    string `json:"email" example:"jane@example.com"`
+}
+
+// ServiceTokenResponse represents the OAuth 2.0 token response for a service principal
+type ServiceTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type" example:"Bearer"`
+	ExpiresIn   int    `json:"expires_in" example:"900"`
+	Scope       string `json:"scope" example:"submit history"`
+}
+
+// servicePrincipalClaims are the signed contents of a service principal access token
+type servicePrincipalClaims struct {
+	ClientID  string
+	Scopes    []string
+	ExpiresAt int64
+}
+
+// ServicePrincipalToken godoc
+// @Summary Get an access token for a service principal
+// @Description OAuth 2.0 client credentials grant for the service principals configured in apiConfig.yaml.
+// @Description Send the client ID and secret with HTTP basic auth, or as the client_id and client_secret form fields.
+// @Description The token is valid for 15 minutes, send it as "Authorization: Bearer <token>" on the routes of its scopes.
+// @Description Scopes: submit (submit requests on behalf of a user, see onBehalfOf), history (read the requests submitted by the principal).
+// @Tags tokens
+// @Accept  x-www-form-urlencoded
+// @Produce  json
+// @Param   grant_type formData string true "Must be client_credentials"
+// @Param   client_id formData string false "Client ID, unless sent with basic auth"
+// @Param   client_secret formData string false "Client secret, unless sent with basic auth"
+// @Param   scope formData string false "Space separated scopes, defaults to all scopes of the service principal"
+// @Success 200 {object} handlers.ServiceTokenResponse "Access token"
+// @Failure 400 {object} models.SimpleMessageResponse "unsupported_grant_type or invalid_scope"
+// @Failure 401 {object} models.SimpleMessageResponse "invalid_client"
+// @Failure 500 {object} models.SimpleMessageResponse "server_error"
+// @Router /oauth/token [post]
+func ServicePrincipalToken(c *gin.Context) {
+	if c.PostForm("grant_type") != "client_credentials" {
+		c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: "unsupported_grant_type"})
+		return
+	}
+
+	clientID, clientSecret, ok := c.Request.BasicAuth()
+	if !ok {
+		clientID, clientSecret = c.PostForm("client_id"), c.PostForm("client_secret")
+	}
+	principal, ok := k8s.CurrentSettings().ServicePrincipals[clientID]
+	if !ok || clientSecret == "" || subtle.ConstantTimeCompare([]byte(clientSecret), []byte(principal.ClientSecret)) != 1 {
+		logger.Warn("Invalid service principal credentials", zap.String("clientID", clientID), zap.String("clientIP", c.ClientIP()))
+		c.Header("WWW-Authenticate", `Basic realm="kube-jit"`)
+		c.JSON(http.StatusUnauthorized, models.SimpleMessageResponse{Error: "invalid_client"})
+		return
+	}
+
+	// Grant the requested scopes, or all scopes of the principal
+	var scopes []string
+	requested := strings.Fields(c.PostForm("scope"))
+	if len(requested) == 0 {
+		for _, scope := range principal.Scopes {
+			if slices.Contains(servicePrincipalScopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	} else {
+		for _, scope := range requested {
+			if !slices.Contains(servicePrincipalScopes, scope) || !slices.Contains(principal.Scopes, scope) {
+				c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: "invalid_scope"})
+				return
+			}
+		}
+		scopes = requested
+	}
+	if len(scopes) == 0 {
+		c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: "invalid_scope"})
+		return
+	}
+
+	encoded, err := utils.SecureCookie().Encode(serviceTokenName, servicePrincipalClaims{
+		ClientID:  principal.ClientID,
+		Scopes:    scopes,
+		ExpiresAt: time.Now().Add(serviceTokenLifetime).Unix(),
+	})
+	if err != nil {
+		logger.Error("Failed to encode service principal token", zap.String("servicePrincipal", principal.Name), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "server_error"})
+		return
+	}
+
+	logger.Info("Service principal token issued", zap.String("servicePrincipal", principal.Name), zap.Strings("scopes", scopes))
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, ServiceTokenResponse{
+		AccessToken: serviceTokenPrefix + encoded,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(serviceTokenLifetime.Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	})
+}
+
+// servicePrincipalAuth authenticates a request with a service principal access token for TokenAuth.
+// The principal must still be configured and allowed the scope of the route, so removing it from
+// apiConfig.yaml revokes its tokens. The session data has no permissions, as principals have no groups
+func servicePrincipalAuth(c *gin.Context, raw, scope string) {
+	var claims servicePrincipalClaims
+	err := utils.SecureCookie().Decode(serviceTokenName, strings.TrimPrefix(raw, serviceTokenPrefix), &claims)
+	if err != nil || time.Now().Unix() >= claims.ExpiresAt {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, models.SimpleMessageResponse{Error: "Unauthorized: invalid or expired access token"})
+		return
+	}
+	principal, ok := k8s.CurrentSettings().ServicePrincipals[claims.ClientID]
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, models.SimpleMessageResponse{Error: "Unauthorized: unknown service principal"})
+		return
+	}
+
+	reqLogger := logger.With(zap.String("servicePrincipal", principal.Name))
+	if scope != "" && (!slices.Contains(claims.Scopes, scope) || !slices.Contains(principal.Scopes, scope)) {
+		reqLogger.Warn("Access token is missing the scope for the route", zap.String("scope", scope), zap.String("route", c.FullPath()))
+		c.AbortWithStatusJSON(http.StatusForbidden, models.SimpleMessageResponse{Error: "Access token is missing the " + scope + " scope"})
+		return
+	}
+
+	sessionData := map[string]any{
+		"id":               principal.ClientID,
+		"name":             principal.Name,
+		"servicePrincipal": principal.Name,
+	}
+	storePermissions(sessionData, CommonPermissionsResponse{}, k8s.PermissionsVersion())
+
+	c.Set("sessionData", sessionData)
+	c.Set("userID", principal.ClientID)
+	c.Set("username", principal.Name)
+	c.Next()
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"kube-jit/pkg/k8s"
+	"kube-jit/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useServicePrincipals sets the configured service principals for the duration of the test
+func useServicePrincipals(t *testing.T, principals ...k8s.ServicePrincipalConfig) {
+	t.Helper()
+	useSettings(t, func(s *k8s.Settings) {
+		s.ServicePrincipals = make(map[string]k8s.ServicePrincipalConfig)
+		for _, principal := range principals {
+			s.ServicePrincipals[principal.ClientID] = principal
+		}
+	})
+}
+
+var incidentBot = k8s.ServicePrincipalConfig{
+	Name:         "incident-bot",
+	ClientID:     "incident-bot-id",
+	ClientSecret: "s3cret",
+	Scopes:       []string{"submit", "history", "approve"},
+}
+
+// requestServiceToken posts form to the token endpoint, with basic auth if clientID is set
+func requestServiceToken(form url.Values, clientID, clientSecret string) *httptest.ResponseRecorder {
+	router := setupTestRouter()
+	router.POST("/kube-jit-api/oauth/token", ServicePrincipalToken)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/kube-jit-api/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if clientID != "" {
+		req.SetBasicAuth(clientID, clientSecret)
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestServicePrincipalToken(t *testing.T) {
+	useServicePrincipals(t, incidentBot)
+
+	t.Run("unsupported grant type", func(t *testing.T) {
+		w := requestServiceToken(url.Values{"grant_type": {"password"}}, "incident-bot-id", "s3cret")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "unsupported_grant_type")
+	})
+
+	t.Run("invalid client", func(t *testing.T) {
+		for _, credentials := range [][2]string{{"incident-bot-id", "wrong"}, {"unknown", "s3cret"}, {"incident-bot-id", ""}} {
+			w := requestServiceToken(url.Values{"grant_type": {"client_credentials"}}, credentials[0], credentials[1])
+			assert.Equal(t, http.StatusUnauthorized, w.Code, credentials)
+			assert.Contains(t, w.Body.String(), "invalid_client")
+		}
+	})
+
+	t.Run("all scopes of the principal by default, without approve", func(t *testing.T) {
+		w := requestServiceToken(url.Values{"grant_type": {"client_credentials"}}, "incident-bot-id", "s3cret")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp ServiceTokenResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.True(t, strings.HasPrefix(resp.AccessToken, serviceTokenPrefix))
+		assert.Equal(t, "Bearer", resp.TokenType)
+		assert.Equal(t, 900, resp.ExpiresIn)
+		assert.Equal(t, "submit history", resp.Scope)
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	})
+
+	t.Run("requested scopes with form credentials", func(t *testing.T) {
+		form := url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {"incident-bot-id"},
+			"client_secret": {"s3cret"},
+			"scope":         {"submit"},
+		}
+		w := requestServiceToken(form, "", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `"scope":"submit"`)
+	})
+
+	t.Run("invalid scope", func(t *testing.T) {
+		for _, scope := range []string{"approve", "submit admin"} {
+			form := url.Values{"grant_type": {"client_credentials"}, "scope": {scope}}
+			w := requestServiceToken(form, "incident-bot-id", "s3cret")
+			assert.Equal(t, http.StatusBadRequest, w.Code, scope)
+			assert.Contains(t, w.Body.String(), "invalid_scope")
+		}
+	})
+}
+
+func TestTokenAuth_ServicePrincipal(t *testing.T) {
+	// issue returns an access token of incident-bot with the given scopes and expiry
+	issue := func(t *testing.T, scopes []string, expiresAt time.Time) string {
+		encoded, err := utils.SecureCookie().Encode(serviceTokenName, servicePrincipalClaims{
+			ClientID:  "incident-bot-id",
+			Scopes:    scopes,
+			ExpiresAt: expiresAt.Unix(),
+		})
+		require.NoError(t, err)
+		return serviceTokenPrefix + encoded
+	}
+
+	serveToken := func(route, authorization string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		router := setupTestRouter()
+		var sessionData map[string]interface{}
+		router.GET(route, TokenAuth(), func(c *gin.Context) {
+			sessionData = GetSessionData(c)
+			c.Status(http.StatusOK)
+		})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", route, nil)
+		req.Header.Set("Authorization", authorization)
+		router.ServeHTTP(w, req)
+		return w, sessionData
+	}
+
+	t.Run("valid token with the route's scope", func(t *testing.T) {
+		useServicePrincipals(t, incidentBot)
+
+		w, sessionData := serveToken("/kube-jit-api/submit-request", "Bearer "+issue(t, []string{"submit"}, time.Now().Add(time.Minute)))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "incident-bot", sessionData["servicePrincipal"])
+		assert.Equal(t, "incident-bot-id", sessionData["id"])
+		// Principals have no groups, so no permissions
+		assert.Equal(t, false, sessionData["isAdmin"])
+		assert.True(t, permissionsFresh(sessionData))
+	})
+
+	t.Run("token without the route's scope", func(t *testing.T) {
+		useServicePrincipals(t, incidentBot)
+
+		w, _ := serveToken("/kube-jit-api/history", "Bearer "+issue(t, []string{"submit"}, time.Now().Add(time.Minute)))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "Access token is missing the history scope")
+	})
+
+	t.Run("scope removed from the principal", func(t *testing.T) {
+		useServicePrincipals(t, k8s.ServicePrincipalConfig{Name: "incident-bot", ClientID: "incident-bot-id", Scopes: []string{"history"}})
+
+		w, _ := serveToken("/kube-jit-api/submit-request", "Bearer "+issue(t, []string{"submit"}, time.Now().Add(time.Minute)))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("principal removed from the configuration", func(t *testing.T) {
+		useServicePrincipals(t)
+
+		w, _ := serveToken("/kube-jit-api/submit-request", "Bearer "+issue(t, []string{"submit"}, time.Now().Add(time.Minute)))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "unknown service principal")
+	})
+
+	t.Run("expired or tampered token", func(t *testing.T) {
+		useServicePrincipals(t, incidentBot)
+
+		for _, token := range []string{
+			issue(t, []string{"submit"}, time.Now().Add(-time.Minute)),
+			issue(t, []string{"submit"}, time.Now().Add(time.Minute)) + "x",
+			serviceTokenPrefix + "garbage",
+		} {
+			w, _ := serveToken("/kube-jit-api/submit-request", "Bearer "+token)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.Contains(t, w.Body.String(), "invalid or expired access token")
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"kube-jit/internal/db"
+	"kube-jit/internal/models"
+	"kube-jit/pkg/sessioncookie"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RevokeSessionsRequest represents the request body for RevokeSessions
+// Either the user ID, to revoke all of the user's sessions, or a session ID is required
+type RevokeSessionsRequest struct {
+	UserID    string `json:"userID"`
+	SessionID string `json:"sessionID"`
+}
+
+// RevokeSessionsResponse represents the response for RevokeSessions
+type RevokeSessionsResponse struct {
+	Message string `json:"message"`
+	Revoked int64  `json:"revoked"`
+}
+
+// ListSessions godoc
+// @Summary List active server-side sessions
+// @Description Returns the active server-side sessions, optionally only those of a user. Requires the admin permission and SESSION_STORE=database.
+// @Description Requires one or more cookies named kube_jit_session_<number> (e.g., kube_jit_session_0, kube_jit_session_1).
+// @Description Pass split cookies in the Cookie header, for example:
+// @Description     -H "Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}"
+// @Description Note: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.
+// @Tags admin
+// @Accept  json
+// @Produce  json
+// @Param   Cookie header string true "Session cookies (multiple allowed, names: kube_jit_session_0, kube_jit_session_1, etc.)"
+// @Param   userID query string false "Only list the sessions of this user"
+// @Success 200 {array} models.Session "Active sessions, most recently used first"
+// @Failure 400 {object} models.SimpleMessageResponse "Server-side sessions are not enabled"
+// @Failure 403 {object} models.SimpleMessageResponse "Forbidden: missing permission admin"
+// @Failure 500 {object} models.SimpleMessageResponse "Failed to list sessions"
+// @Router /admin/sessions [get]
+func ListSessions(c *gin.Context) {
+	reqLogger, ok := requireServerSideSessions(c)
+	if !ok {
+		return
+	}
+
+	query := db.DB.WithContext(c.Request.Context()).
+		Where("revoked_at IS NULL AND expires_at > ?", time.Now())
+	if userID := c.Query("userID"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var sessions []models.Session
+	if err := query.Order("updated_at DESC").Find(&sessions).Error; err != nil {
+		reqLogger.Error("Failed to list sessions", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to list sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSessions godoc
+// @Summary Revoke server-side sessions
+// @Description Revokes one session by ID, or every active session of a user, logging them out on their next request. Requires the admin permission and SESSION_STORE=database.
+// @Description Requires one or more cookies named kube_jit_session_<number> (e.g., kube_jit_session_0, kube_jit_session_1).
+// @Description Pass split cookies in the Cookie header, for example:
+// @Description     -H "Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}"
+// @Description Note: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.
+// @Tags admin
+// @Accept  json
+// @Produce  json
+// @Param   Cookie header string true "Session cookies (multiple allowed, names: kube_jit_session_0, kube_jit_session_1, etc.)"
+// @Param   request body handlers.RevokeSessionsRequest true "User ID or session ID to revoke"
+// @Success 200 {object} handlers.RevokeSessionsResponse "Sessions revoked"
+// @Failure 400 {object} models.SimpleMessageResponse "Invalid request or server-side sessions are not enabled"
+// @Failure 403 {object} models.SimpleMessageResponse "Forbidden: missing permission admin"
+// @Failure 500 {object} models.SimpleMessageResponse "Failed to revoke sessions"
+// @Router /admin/sessions/revoke [post]
+func RevokeSessions(c *gin.Context) {
+	reqLogger, ok := requireServerSideSessions(c)
+	if !ok {
+		return
+	}
+
+	var req RevokeSessionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.UserID == "") == (req.SessionID == "") {
+		c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: "Either userID or sessionID is required"})
+		return
+	}
+
+	query := db.DB.WithContext(c.Request.Context()).Model(&models.Session{}).Where("revoked_at IS NULL")
+	if req.UserID != "" {
+		query = query.Where("user_id = ?", req.UserID)
+	} else {
+		query = query.Where("id = ?", req.SessionID)
+	}
+	result := query.Update("revoked_at", time.Now())
+	if result.Error != nil {
+		reqLogger.Error("Failed to revoke sessions", zap.Error(result.Error))
+		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to revoke sessions"})
+		return
+	}
+
+	reqLogger.Info("Sessions revoked",
+		zap.String("revokedUserID", req.UserID),
+		zap.String("revokedSessionID", req.SessionID),
+		zap.Int64("revoked", result.RowsAffected),
+	)
+	c.JSON(http.StatusOK, RevokeSessionsResponse{
+		Message: "Sessions revoked",
+		Revoked: result.RowsAffected,
+	})
+}
+
+// requireServerSideSessions checks server-side sessions are enabled,
+// writing the error response and returning false otherwise
+// The admin permission is checked by RequirePermission on the admin routes
+func requireServerSideSessions(c *gin.Context) (*zap.Logger, bool) {
+	if !sessioncookie.ServerSideEnabled() {
+		c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: "Server-side sessions are not enabled"})
+		return nil, false
+	}
+	return RequestLogger(c), true
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"kube-jit/internal/db"
+	"kube-jit/internal/models"
+	"kube-jit/pkg/sessioncookie"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useSessionStore enables server-side sessions for the duration of the test
+func useSessionStore(t *testing.T) {
+	t.Helper()
+	sessioncookie.UseStore(db.SessionStore{})
+	t.Cleanup(func() { sessioncookie.UseStore(nil) })
+}
+
+// serveAdmin calls handler on an admin route, requiring the admin permission, as a user with the given admin flag
+func serveAdmin(router *gin.Engine, handler gin.HandlerFunc, isAdmin bool, method, target, body string) *httptest.ResponseRecorder {
+	router.Handle(method, strings.Split(target, "?")[0], func(c *gin.Context) {
+		c.Set("sessionData", map[string]interface{}{"id": "admin-user", "isAdmin": isAdmin})
+	}, RequirePermission(models.PermissionAdmin), handler)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, target, strings.NewReader(body))
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestListSessions(t *testing.T) {
+	t.Run("admin permission required", func(t *testing.T) {
+		router, mock := setupRouterAndDBMock(t)
+		defer teardownDBMock(t, mock)
+		useSessionStore(t)
+
+		w := serveAdmin(router, ListSessions, false, "GET", "/admin/sessions", "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "Forbidden: missing permission admin")
+	})
+
+	t.Run("server-side sessions disabled", func(t *testing.T) {
+		router, mock := setupRouterAndDBMock(t)
+		defer teardownDBMock(t, mock)
+
+		w := serveAdmin(router, ListSessions, true, "GET", "/admin/sessions", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Server-side sessions are not enabled")
+	})
+
+	t.Run("sessions of a user", func(t *testing.T) {
+		router, mock := setupRouterAndDBMock(t)
+		defer teardownDBMock(t, mock)
+		useSessionStore(t)
+
+		expiresAt := time.Now().Add(time.Hour)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sessions" WHERE (revoked_at IS NULL AND expires_at > $1) AND user_id = $2 ORDER BY updated_at DESC`)).
+			WithArgs(sqlmock.AnyArg(), "user-1").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "username", "provider", "user_agent", "data", "expires_at"}).
+				AddRow("hash-1", "user-1", "Jane", "github", "curl/8.0", `{"token":"secret"}`, expiresAt))
+
+		w := serveAdmin(router, ListSessions, true, "GET", "/admin/sessions?userID=user-1", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var sessions []models.Session
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sessions))
+		require.Len(t, sessions, 1)
+		assert.Equal(t, "hash-1", sessions[0].ID)
+		assert.Equal(t, "Jane", sessions[0].Username)
+		// The session data, including the OAuth token, is never returned
+		assert.NotContains(t, w.Body.String(), "secret")
+	})
+}
+
+func TestRevokeSessions(t *testing.T) {
+	t.Run("admin permission required", func(t *testing.T) {
+		router, mock := setupRouterAndDBMock(t)
+		defer teardownDBMock(t, mock)
+		useSessionStore(t)
+
+		w := serveAdmin(router, RevokeSessions, false, "POST", "/admin/sessions/revoke", `{"userID":"user-1"}`)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("user or session ID required", func(t *testing.T) {
+		for _, body := range []string{`{}`, `{"userID":"user-1","sessionID":"hash-1"}`, `not json`} {
+			router, mock := setupRouterAndDBMock(t)
+			useSessionStore(t)
+
+			w := serveAdmin(router, RevokeSessions, true, "POST", "/admin/sessions/revoke", body)
+			assert.Equal(t, http.StatusBadRequest, w.Code, body)
+			assert.Contains(t, w.Body.String(), "Either userID or sessionID is required")
+			teardownDBMock(t, mock)
+		}
+	})
+
+	t.Run("all sessions of a user", func(t *testing.T) {
+		router, mock := setupRouterAndDBMock(t)
+		defer teardownDBMock(t, mock)
+		useSessionStore(t)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sessions" SET "revoked_at"=$1,"updated_at"=$2 WHERE revoked_at IS NULL AND user_id = $3`)).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "user-1").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		w := serveAdmin(router, RevokeSessions, true, "POST", "/admin/sessions/revoke", `{"userID":"user-1"}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp RevokeSessionsResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, int64(2), resp.Revoked)
+	})
+
+	t.Run("one session", func(t *testing.T) {
+		router, mock := setupRouterAndDBMock(t)
+		defer teardownDBMock(t, mock)
+		useSessionStore(t)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sessions" SET "revoked_at"=$1,"updated_at"=$2 WHERE revoked_at IS NULL AND id = $3`)).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "hash-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		w := serveAdmin(router, RevokeSessions, true, "POST", "/admin/sessions/revoke", `{"sessionID":"hash-1"}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+}
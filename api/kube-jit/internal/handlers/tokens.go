@@ -0,0 +1,371 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"kube-jit/internal/db"
+	"kube-jit/internal/models"
+	"kube-jit/pkg/k8s"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Scopes of personal API tokens
+const (
+	ScopeSubmit  = "submit"  // submit requests
+	ScopeApprove = "approve" // list, approve and reject requests
+	ScopeHistory = "history" // read the request history
+)
+
+const (
+	// apiTokenPrefix marks personal API tokens, so leaked tokens are easy to spot
+	apiTokenPrefix = "kjit_"
+	// defaultTokenLifetimeDays and maxTokenLifetimeDays bound the expiry of API tokens
+	defaultTokenLifetimeDays = 30
+	maxTokenLifetimeDays     = 90
+	// tokenTouchInterval is how often the last use of an API token is recorded
+	tokenTouchInterval = 5 * time.Minute
+)
+
+// tokenScopes is the scope API tokens need for each route, routes not listed only accept session cookies
+// An empty scope is allowed for any token
+var tokenScopes = map[string]string{
+	"/kube-jit-api/roles-and-clusters": ScopeSubmit,
+	"/kube-jit-api/submit-request":     ScopeSubmit,
+	"/kube-jit-api/approvals":          ScopeApprove,
+	"/kube-jit-api/approve-reject":     ScopeApprove,
+	"/kube-jit-api/history":            ScopeHistory,
+	"/kube-jit-api/permissions":        "",
+}
+
+// CreateTokenRequest represents the request body for CreateToken
+type CreateTokenRequest struct {
+	Name          string   `json:"name" binding:"required" example:"ci-pipeline"`
+	Scopes        []string `json:"scopes" binding:"required" example:"submit,history"`
+	ExpiresInDays int      `json:"expiresInDays" example:"30"`
+}
+
+// CreateTokenResponse represents the response for CreateToken
+// Token is only returned once, it cannot be retrieved later
+type CreateTokenResponse struct {
+	Token    string          `json:"token"`
+	APIToken models.APIToken `json:"apiToken"`
+}
+
+// RevokeTokenRequest represents the request body for RevokeToken
+type RevokeTokenRequest struct {
+	ID uint `json:"id" binding:"required"`
+}
+
+// CreateToken godoc
+// @Summary Create a personal API token
+// @Description Creates a scoped, expiring API token for the logged in user, to be sent as "Authorization: Bearer <token>" by scripts and CI.
+// @Description Scopes: submit (submit requests), approve (list, approve and reject requests), history (read the request history).
+// @Description The token acts with the user's groups, matched against the current configuration on every request. The groups are checked again every PERMISSIONS_TTL from the groups cached by the user's sessions. While the user does not use the web app, the token keeps the user's own permissions, and the approve routes fail with 403 until the user signs in again to refresh the groups.
+// @Description The token is only returned once. Tokens cannot be used to create, list or revoke tokens.
+// @Description Requires one or more cookies named kube_jit_session_<number> (e.g., kube_jit_session_0, kube_jit_session_1).
+// @Description Pass split cookies in the Cookie header, for example:
+// @Description     -H "Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}"
+// @Description Note: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.
+// @Tags tokens
+// @Accept  json
+// @Produce  json
+// @Param   Cookie header string true "Session cookies (multiple allowed, names: kube_jit_session_0, kube_jit_session_1, etc.)"
+// @Param   request body handlers.CreateTokenRequest true "Token name, scopes and lifetime in days (default 30, max 90)"
+// @Success 200 {object} handlers.CreateTokenResponse "Created token"
+// @Failure 400 {object} models.SimpleMessageResponse "Invalid request"
+// @Failure 500 {object} models.SimpleMessageResponse "Failed to create token"
+// @Router /tokens [post]
+func CreateToken(c *gin.Context) {
+	sessionData := GetSessionData(c)
+	reqLogger := RequestLogger(c)
+
+	var req CreateTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: "Invalid request: name and scopes are required"})
+		return
+	}
+	for _, scope := range req.Scopes {
+		if scope != ScopeSubmit && scope != ScopeApprove && scope != ScopeHistory {
+			c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: "Invalid scope: " + scope})
+			return
+		}
+	}
+	if len(req.Scopes) == 0 {
+		c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: "Invalid request: name and scopes are required"})
+		return
+	}
+	if req.ExpiresInDays == 0 {
+		req.ExpiresInDays = defaultTokenLifetimeDays
+	}
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > maxTokenLifetimeDays {
+		c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: "Invalid request: expiresInDays must be between 1 and 90"})
+		return
+	}
+
+	// The token keeps the user's groups, as the provider cannot be queried without the OAuth token.
+	// TokenAuth checks them again every permissionsTTL against the groups cached by the user's sessions
+	provider, _ := sessionData["provider"].(string)
+	identityProvider, ok := lookupProvider(provider)
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: "Unknown provider"})
+		return
+	}
+	userGroups, err := fetchUserGroups(c.Request.Context(), identityProvider, sessionData, reqLogger)
+	if err != nil {
+		reqLogger.Error("Failed to fetch user groups", zap.String("provider", provider), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to fetch user groups"})
+		return
+	}
+
+	raw, err := newAPIToken()
+	if err != nil {
+		reqLogger.Error("Failed to generate API token", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to create token"})
+		return
+	}
+
+	userID, _ := sessionData["id"].(string)
+	username, _ := sessionData["name"].(string)
+	email, _ := sessionData["email"].(string)
+	token := models.APIToken{
+		Name:            req.Name,
+		Prefix:          raw[:len(apiTokenPrefix)+8],
+		TokenHash:       hashAPIToken(raw),
+		Scopes:          req.Scopes,
+		UserID:          userID,
+		Username:        username,
+		Email:           email,
+		Provider:        provider,
+		Groups:          userGroups,
+		GroupsCheckedAt: time.Now(),
+		ExpiresAt:       time.Now().AddDate(0, 0, req.ExpiresInDays),
+	}
+	if err := db.DB.WithContext(c.Request.Context()).Create(&token).Error; err != nil {
+		reqLogger.Error("Failed to create API token", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to create token"})
+		return
+	}
+
+	reqLogger.Info("API token created",
+		zap.Uint("tokenID", token.ID),
+		zap.Strings("scopes", token.Scopes),
+		zap.Time("expiresAt", token.ExpiresAt),
+	)
+	c.JSON(http.StatusOK, CreateTokenResponse{Token: raw, APIToken: token})
+}
+
+// ListTokens godoc
+// @Summary List personal API tokens
+// @Description Returns the active API tokens of the logged in user. Users with the admin permission can list the tokens of another user with userID.
+// @Description Requires one or more cookies named kube_jit_session_<number> (e.g., kube_jit_session_0, kube_jit_session_1).
+// @Description Pass split cookies in the Cookie header, for example:
+// @Description     -H "Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}"
+// @Description Note: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.
+// @Tags tokens
+// @Accept  json
+// @Produce  json
+// @Param   Cookie header string true "Session cookies (multiple allowed, names: kube_jit_session_0, kube_jit_session_1, etc.)"
+// @Param   userID query string false "List the tokens of this user, admin only"
+// @Success 200 {array} models.APIToken "Active tokens, newest first"
+// @Failure 403 {object} models.SimpleMessageResponse "Forbidden: missing permission admin"
+// @Failure 500 {object} models.SimpleMessageResponse "Failed to list tokens"
+// @Router /tokens [get]
+func ListTokens(c *gin.Context) {
+	sessionData := GetSessionData(c)
+	reqLogger := RequestLogger(c)
+
+	userID, _ := sessionData["id"].(string)
+	if requested := c.Query("userID"); requested != "" && requested != userID {
+		if !AccessOf(c).Can(models.PermissionAdmin) {
+			reqLogger.Warn("Forbidden attempt to list the API tokens of another user")
+			c.JSON(http.StatusForbidden, models.SimpleMessageResponse{Error: "Forbidden: missing permission admin"})
+			return
+		}
+		userID = requested
+	}
+
+	var tokens []models.APIToken
+	if err := db.DB.WithContext(c.Request.Context()).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("created_at DESC").
+		Find(&tokens).Error; err != nil {
+		reqLogger.Error("Failed to list API tokens", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to list tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// RevokeToken godoc
+// @Summary Revoke a personal API token
+// @Description Revokes one of the logged in user's API tokens. Users with the admin permission can revoke any token.
+// @Description Requires one or more cookies named kube_jit_session_<number> (e.g., kube_jit_session_0, kube_jit_session_1).
+// @Description Pass split cookies in the Cookie header, for example:
+// @Description     -H "Cookie: kube_jit_session_0=${cookie_0};kube_jit_session_1=${cookie_1}"
+// @Description Note: Swagger UI cannot send custom Cookie headers due to browser security restrictions. Use curl for testing with split cookies.
+// @Tags tokens
+// @Accept  json
+// @Produce  json
+// @Param   Cookie header string true "Session cookies (multiple allowed, names: kube_jit_session_0, kube_jit_session_1, etc.)"
+// @Param   request body handlers.RevokeTokenRequest true "ID of the token to revoke"
+// @Success 200 {object} models.SimpleMessageResponse "Token revoked"
+// @Failure 400 {object} models.SimpleMessageResponse "Invalid request"
+// @Failure 404 {object} models.SimpleMessageResponse "Token not found"
+// @Failure 500 {object} models.SimpleMessageResponse "Failed to revoke token"
+// @Router /tokens/revoke [post]
+func RevokeToken(c *gin.Context) {
+	sessionData := GetSessionData(c)
+	reqLogger := RequestLogger(c)
+
+	var req RevokeTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.SimpleMessageResponse{Error: "Invalid request: id is required"})
+		return
+	}
+
+	query := db.DB.WithContext(c.Request.Context()).Model(&models.APIToken{}).
+		Where("id = ? AND revoked_at IS NULL", req.ID)
+	if !AccessOf(c).Can(models.PermissionAdmin) {
+		userID, _ := sessionData["id"].(string)
+		query = query.Where("user_id = ?", userID)
+	}
+	result := query.Update("revoked_at", time.Now())
+	if result.Error != nil {
+		reqLogger.Error("Failed to revoke API token", zap.Uint("tokenID", req.ID), zap.Error(result.Error))
+		c.JSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to revoke token"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, models.SimpleMessageResponse{Error: "Token not found"})
+		return
+	}
+
+	reqLogger.Info("API token revoked", zap.Uint("tokenID", req.ID))
+	c.JSON(http.StatusOK, models.SimpleMessageResponse{Message: "Token revoked"})
+}
+
+// TokenAuth is a middleware authenticating requests with an "Authorization: Bearer" API token.
+// It sets the session data of the token's user, with the permissions matched from the token's groups,
+// so RequireAuth and the handlers work unchanged. Requests without the header are left to the cookie session.
+// The groups are checked again every permissionsTTL, see tokenGroups.
+// Access tokens of service principals are handled by servicePrincipalAuth.
+func TokenAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok {
+			c.Next()
+			return
+		}
+
+		scope, allowed := tokenScopes[c.FullPath()]
+		if !allowed {
+			c.AbortWithStatusJSON(http.StatusForbidden, models.SimpleMessageResponse{Error: "API tokens cannot be used for this route"})
+			return
+		}
+		if strings.HasPrefix(raw, serviceTokenPrefix) {
+			servicePrincipalAuth(c, strings.TrimSpace(raw), scope)
+			return
+		}
+
+		var token models.APIToken
+		err := db.DB.WithContext(c.Request.Context()).
+			Where("token_hash = ? AND revoked_at IS NULL AND expires_at > ?", hashAPIToken(strings.TrimSpace(raw)), time.Now()).
+			First(&token).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.SimpleMessageResponse{Error: "Unauthorized: invalid, expired or revoked API token"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to look up API token", zap.Error(err))
+			c.AbortWithStatusJSON(http.StatusInternalServerError, models.SimpleMessageResponse{Error: "Failed to look up API token"})
+			return
+		}
+
+		reqLogger := logger.With(
+			zap.String("userID", token.UserID),
+			zap.String("username", token.Username),
+			zap.Uint("tokenID", token.ID),
+		)
+		if scope != "" && !slices.Contains(token.Scopes, scope) {
+			reqLogger.Warn("API token is missing the scope for the route", zap.String("scope", scope), zap.String("route", c.FullPath()))
+			c.AbortWithStatusJSON(http.StatusForbidden, models.SimpleMessageResponse{Error: "API token is missing the " + scope + " scope"})
+			return
+		}
+
+		sessionData := map[string]any{
+			"id":         token.UserID,
+			"name":       token.Username,
+			"email":      token.Email,
+			"provider":   token.Provider,
+			"apiTokenID": token.ID,
+		}
+		groups, updates, fresh := tokenGroups(&token, reqLogger)
+		if !fresh && scope == ScopeApprove {
+			c.AbortWithStatusJSON(http.StatusForbidden, models.SimpleMessageResponse{Error: "The groups of the API token's user must be refreshed, sign in to the web app to refresh them"})
+			return
+		}
+		version := k8s.PermissionsVersion()
+		storePermissions(sessionData, matchPermissions(groups, reqLogger), version)
+
+		if token.LastUsedAt == nil || time.Since(*token.LastUsedAt) > tokenTouchInterval {
+			now := time.Now()
+			token.LastUsedAt = &now
+			updates = append(updates, "LastUsedAt")
+		}
+		if len(updates) > 0 {
+			if err := db.DB.WithContext(c.Request.Context()).Model(&token).Select(updates).Updates(&token).Error; err != nil {
+				reqLogger.Warn("Failed to record API token use", zap.Error(err))
+			}
+		}
+
+		c.Set("sessionData", sessionData)
+		c.Set("userID", token.UserID)
+		c.Set("username", token.Username)
+		c.Next()
+	}
+}
+
+// tokenGroups returns the groups an API token acts with, the token fields to save when they were checked again,
+// and whether the groups are fresh. The groups kept on the token are used for permissionsTTL, then replaced by the
+// groups cached by fetchUserGroups for the user's sessions, as the provider cannot be queried without the user's OAuth token.
+// While the user has no cached groups, the token gets no group permissions, so removed memberships stop applying,
+// and TokenAuth rejects the routes that need them rather than narrowing them silently.
+func tokenGroups(token *models.APIToken, reqLogger *zap.Logger) ([]models.Team, []string, bool) {
+	if time.Since(token.GroupsCheckedAt) < permissionsTTL {
+		return token.Groups, nil, true
+	}
+
+	groups, ok := cachedUserGroups(token.Provider, token.UserID)
+	if !ok {
+		reqLogger.Info("Groups of the API token's user are not cached, group permissions are not granted")
+		return nil, nil, false
+	}
+	token.Groups = groups
+	token.GroupsCheckedAt = time.Now()
+	return groups, []string{"Groups", "GroupsCheckedAt"}, true
+}
+
+// newAPIToken returns a random API token
+func newAPIToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return apiTokenPrefix + hex.EncodeToString(b), nil
+}
+
+// hashAPIToken returns the SHA-256 of an API token, as stored in the database
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
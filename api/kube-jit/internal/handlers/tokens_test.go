@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"kube-jit/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveAs calls handler with the given session data
+func serveAs(router *gin.Engine, handler gin.HandlerFunc, sessionData map[string]interface{}, method, target, body string) *httptest.ResponseRecorder {
+	router.Handle(method, strings.Split(target, "?")[0], func(c *gin.Context) {
+		c.Set("sessionData", sessionData)
+		handler(c)
+	})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, target, strings.NewReader(body))
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCreateToken(t *testing.T) {
+	session := map[string]interface{}{"id": "42", "name": "Jane", "email": "jane@example.com", "provider": "fake"}
+
+	t.Run("invalid requests", func(t *testing.T) {
+		for body, expected := range map[string]string{
+			`{"scopes":["submit"]}`:                                "name and scopes are required",
+			`{"name":"ci","scopes":[]}`:                            "name and scopes are required",
+			`{"name":"ci","scopes":["admin"]}`:                     "Invalid scope: admin",
+			`{"name":"ci","scopes":["submit"],"expiresInDays":91}`: "expiresInDays must be between 1 and 90",
+		} {
+			router, mock := setupRouterAndDBMock(t)
+			w := serveAs(router, CreateToken, session, "POST", "/tokens", body)
+			assert.Equal(t, http.StatusBadRequest, w.Code, body)
+			assert.Contains(t, w.Body.String(), expected, body)
+			teardownDBMock(t, mock)
+		}
+	})
+
+	t.Run("token is returned once and stored hashed", func(t *testing.T) {
+		router, mock := setupRouterAndDBMock(t)
+		defer teardownDBMock(t, mock)
+		useGroupCacheTTL(t, 0)
+		groups := []models.Team{{ID: "devs", Name: "Developers"}}
+		useProvider(t, &fakeProvider{name: "fake", groups: groups})
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "api_tokens"`)).
+			WithArgs("ci", sqlmock.AnyArg(), sqlmock.AnyArg(), `["submit","history"]`, "42", "Jane", "jane@example.com", "fake",
+				`[{"id":"devs","name":"Developers"}]`, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		mock.ExpectCommit()
+
+		w := serveAs(router, CreateToken, session, "POST", "/tokens", `{"name":"ci","scopes":["submit","history"]}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp CreateTokenResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.True(t, strings.HasPrefix(resp.Token, apiTokenPrefix))
+		assert.Equal(t, uint(7), resp.APIToken.ID)
+		assert.True(t, strings.HasPrefix(resp.Token, resp.APIToken.Prefix))
+		assert.WithinDuration(t, time.Now().AddDate(0, 0, defaultTokenLifetimeDays), resp.APIToken.ExpiresAt, time.Minute)
+		// Neither the hash nor the groups are returned
+		assert.NotContains(t, w.Body.String(), hashAPIToken(resp.Token))
+		assert.NotContains(t, w.Body.String(), "Developers")
+	})
+
+	t.Run("groups cannot be fetched", func(t *testing.T) {
+		router, mock := setupRouterAndDBMock(t)
+		defer teardownDBMock(t, mock)
+		useGroupCacheTTL(t, 0)
+		useProvider(t, &fakeProvider{name: "fake", groupsErr: errors.New("provider down")})
+
+		w := serveAs(router, CreateToken, session, "POST", "/tokens", `{"name":"ci","scopes":["submit"]}`)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
+
+func TestListTokens(t *testing.T) {
+	query := regexp.QuoteMeta(`SELECT * FROM "api_tokens" WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2 ORDER BY created_at DESC`)
+
+	t.Run("own tokens", func(t *testing.T) {
+		router, mock := setupRouterAndDBMock(t)
+		defer teardownDBMock(t, mock)
+
+		mock.ExpectQuery(query).WithArgs("42", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "token_hash", "scopes"}).
+				AddRow(7, "ci", "secret-hash", `["submit"]`))
+
+		w := serveAs(router, ListTokens, map[string]interface{}{"id": "42"}, "GET", "/tokens", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var tokens []models.APIToken
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+		require.Len(t, tokens, 1)
+		assert.Equal(t, []string{"submit"}, tokens[0].Scopes)
+		assert.NotContains(t, w.Body.String(), "secret-hash")
+	})
+
+	t.Run("tokens of another user require the admin permission", func(t *testing.T) {
+		router, mock := setupRouterAndDBMock(t)
+		defer teardownDBMock(t, mock)
+
+		w := serveAs(router, ListTokens, map[string]interface{}{"id": "42"}, "GET", "/tokens?userID=43", "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("admins list the tokens of another user", func(t *testing.T) {
+		router, mock := setupRouterAndDBMock(t)
+		defer teardownDBMock(t, mock)
+
+		mock.ExpectQuery(query).WithArgs("43", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		w := serveAs(router, ListTokens, map[string]interface{}{"id": "42", "isAdmin": true}, "GET", "/tokens?userID=43", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestRevokeToken(t *testing.T) {
+	t.Run("own token", func(t *testing.T) {
+		router, mock := setupRouterAndDBMock(t)
+		defer teardownDBMock(t, mock)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "api_tokens" SET "revoked_at"=$1 WHERE (id = $2 AND revoked_at IS NULL) AND user_id = $3`)).
+			WithArgs(sqlmock.AnyArg(), 7, "42").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		w := serveAs(router, RevokeToken, map[string]interface{}{"id": "42"}, "POST", "/tokens/revoke", `{"id":7}`)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+
+	t.Run("token of another user or unknown", func(t *testing.T) {
+		router, mock := setupRouterAndDBMock(t)
+		defer teardownDBMock(t, mock)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "api_tokens"`)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		w := serveAs(router, RevokeToken, map[string]interface{}{"id": "42"}, "POST", "/tokens/revoke", `{"id":8}`)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("admins revoke any token", func(t *testing.T) {
+		router, mock := setupRouterAndDBMock(t)
+		defer teardownDBMock(t, mock)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "api_tokens" SET "revoked_at"=$1 WHERE id = $2 AND revoked_at IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), 8).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		w := serveAs(router, RevokeToken, map[string]interface{}{"id": "42", "isAdmin": true}, "POST", "/tokens/revoke", `{"id":8}`)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+
+	t.Run("id is required", func(t *testing.T) {
+		router, mock := setupRouterAndDBMock(t)
+		defer teardownDBMock(t, mock)
+
+		w := serveAs(router, RevokeToken, map[string]interface{}{"id": "42"}, "POST", "/tokens/revoke", `{}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestTokenAuth(t *testing.T) {
+	const raw = "kjit_0123456789abcdef"
+	lookup := regexp.QuoteMeta(`SELECT * FROM "api_tokens" WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > $2 ORDER BY "api_tokens"."id" LIMIT $3`)
+	tokenRows := func(scopes string, lastUsedAt *time.Time) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "user_id", "username", "provider", "scopes", "groups", "groups_checked_at", "expires_at", "last_used_at"}).
+			AddRow(7, "42", "Jane", "github", scopes, `[{"id":"admins","name":"Admins"}]`, time.Now(), time.Now().Add(time.Hour), lastUsedAt)
+	}
+	// staleTokenRows returns a recently used token whose groups were checked more than permissionsTTL ago
+	staleTokenRows := func(scopes string) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "user_id", "username", "provider", "scopes", "groups", "groups_checked_at", "expires_at", "last_used_at"}).
+			AddRow(7, "42", "Jane", "github", scopes, `[{"id":"admins","name":"Admins"}]`, time.Now().Add(-permissionsTTL), time.Now().Add(time.Hour), time.Now())
+	}
+
+	// serveToken calls path, registered as route, with the Authorization header and returns the session data seen by the handler
+	serveToken := func(router *gin.Engine, route, authorization string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		var sessionData map[string]interface{}
+		router.GET(route, TokenAuth(), func(c *gin.Context) {
+			sessionData = GetSessionData(c)
+			c.Status(http.StatusOK)
+		})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", route, nil)
+		req.Header.Set("Authorization", authorization)
+		router.ServeHTTP(w, req)
+		return w, sessionData
+	}
+
+	t.Run("valid token with the route's scope", func(t *testing.T) {
+		router, mock := setupRouterAndDBMock(t)
+		defer teardownDBMock(t, mock)
+		useAdminTeams(t, []models.Team{{ID: "admins", Name: "Admins"}})
+
+		mock.ExpectQuery(lookup).WithArgs(hashAPIToken(raw), sqlmock.AnyArg(), 1).
+			WillReturnRows(tokenRows(`["history"]`, nil))
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "api_tokens" SET "last_used_at"=$1 WHERE "id" = $2`)).
+			WithArgs(sqlmock.AnyArg(), 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		w, sessionData := serveToken(router, "/kube-jit-api/history", "Bearer "+raw)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "42", sessionData["id"])
+		assert.Equal(t, "Jane", sessionData["name"])
+		// Permissions are matched from the token's groups and fresh, so they are not evaluated again
+		assert.Equal(t, true, sessionData["isAdmin"])
+		assert.True(t, permissionsFresh(sessionData))
+		assert.Empty(t, w.Result().Cookies())
+	})
+
+	t.Run("recently used tokens are not updated", func(t *testing.T) {
+		router, mock := setupRouterAndDBMock(t)
+		defer teardownDBMock(t, mock)
+		useAdminTeams(t, nil)
+
+		lastUsedAt := time.Now()
+		mock.ExpectQuery(lookup).WillReturnRows(tokenRows(`["history"]`, &lastUsedAt))
+
+		w, _ := serveToken(router, "/kube-jit-api/history", "Bearer "+raw)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("stale groups are replaced by the user's cached groups", func(t *testing.T) {
+		router, mock := setupRouterAndDBMock(t)
+		defer teardownDBMock(t, mock)
+		useAdminTeams(t, []models.Team{{ID: "admins", Name: "Admins"}})
+		useGroupCacheTTL(t, time.Minute)
+		userGroupCache.Store("github/42", &cachedGroups{
+			Teams:     []models.Team{{ID: "devs", Name: "Developers"}},
+			ExpiresAt: time.Now().Add(time.Minute),
+		})
+
+		mock.ExpectQuery(lookup).WillReturnRows(staleTokenRows(`["history"]`))
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "api_tokens" SET "groups"=$1,"groups_checked_at"=$2 WHERE "id" = $3`)).
+			WithArgs(`[{"id":"devs","name":"Developers"}]`, sqlmock.AnyArg(), 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		w, sessionData := serveToken(router, "/kube-jit-api/history", "Bearer "+raw)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, false, sessionData["isAdmin"])
+	})
+
+	t.Run("stale groups are not granted when the user's groups are not cached", func(t *testing.T) {
+		router, mock := setupRouterAndDBMock(t)
+		defer teardownDBMock(t, mock)
+		useAdminTeams(t, []models.Team{{ID: "admins", Name: "Admins"}})
+		useGroupCacheTTL(t, time.Minute)
+
+		mock.ExpectQuery(lookup).WillReturnRows(staleTokenRows(`["history"]`))
+
+		w, sessionData := serveToken(router, "/kube-jit-api/history", "Bearer "+raw)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "42", sessionData["id"])
+		assert.Equal(t, false, sessionData["isAdmin"])
+	})
+
+	t.Run("approve routes fail when the user's groups are not cached", func(t *testing.T) {
+		router, mock := setupRouterAndDBMock(t)
+		defer teardownDBMock(t, mock)
+		useGroupCacheTTL(t, time.Minute)
+
+		mock.ExpectQuery(lookup).WillReturnRows(staleTokenRows(`["approve"]`))
+
+		w, _ := serveToken(router, "/kube-jit-api/approvals", "Bearer "+raw)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "must be refreshed")
+	})
+
+	t.Run("token without the route's scope", func(t *testing.T) {
+		router, mock := setupRouterAndDBMock(t)
+		defer teardownDBMock(t, mock)
+
+		mock.ExpectQuery(lookup).WillReturnRows(tokenRows(`["history"]`, nil))
+
+		w, _ := serveToken(router, "/kube-jit-api/approvals", "Bearer "+raw)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "API token is missing the approve scope")
+	})
+
+	t.Run("invalid, expired or revoked token", func(t *testing.T) {
+		router, mock := setupRouterAndDBMock(t)
+		defer teardownDBMock(t, mock)
+
+		mock.ExpectQuery(lookup).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		w, _ := serveToken(router, "/kube-jit-api/history", "Bearer kjit_unknown")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("cookie only route", func(t *testing.T) {
+		router, mock := setupRouterAndDBMock(t)
+		defer teardownDBMock(t, mock)
+
+		w, _ := serveToken(router, "/kube-jit-api/tokens", "Bearer "+raw)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("requests without a bearer token are left to the session cookies", func(t *testing.T) {
+		router, mock := setupRouterAndDBMock(t)
+		defer teardownDBMock(t, mock)
+
+		var authenticated bool
+		router.GET("/kube-jit-api/history", TokenAuth(), func(c *gin.Context) {
+			_, authenticated = c.Get("sessionData")
+		})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/kube-jit-api/history", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.False(t, authenticated)
+	})
+}
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "kube_jit_api"
+
+var (
+	// Registry holds all API metrics, it is served by Handler
+	Registry = prometheus.NewRegistry()
+
+	// HTTPRequestDuration observes HTTP request latency by method, route template and status code
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// RequestsSubmittedTotal counts submitted JIT requests by cluster and role
+	RequestsSubmittedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "requests_submitted_total",
+		Help:      "Total number of JIT requests submitted, by cluster and role.",
+	}, []string{"cluster", "role"})
+
+	// RequestsApprovedTotal counts fully approved JIT requests by cluster and role
+	RequestsApprovedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "requests_approved_total",
+		Help:      "Total number of JIT requests fully approved, by cluster and role.",
+	}, []string{"cluster", "role"})
+
+	// RequestsRejectedTotal counts rejected JIT requests by cluster and role
+	RequestsRejectedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "requests_rejected_total",
+		Help:      "Total number of JIT requests rejected, by cluster and role.",
+	}, []string{"cluster", "role"})
+
+	// OAuthRequestDuration observes calls to the OAuth provider by provider and host
+	OAuthRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "oauth",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of calls to the OAuth provider, by provider and host.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"provider", "host"})
+
+	// OAuthRequestErrorsTotal counts failed calls (transport errors or non-2xx) to the OAuth provider
+	OAuthRequestErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "oauth",
+		Name:      "request_errors_total",
+		Help:      "Total number of failed calls to the OAuth provider, by provider and host.",
+	}, []string{"provider", "host"})
+
+	// DynamicClientCacheTotal counts dynamic client lookups by cluster and result (hit, miss, refresh)
+	DynamicClientCacheTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "dynamic_client_cache",
+		Name:      "lookups_total",
+		Help:      "Total number of dynamic client cache lookups, by cluster and result (hit, miss, refresh).",
+	}, []string{"cluster", "result"})
+
+	// GroupCacheTotal counts user group cache lookups by provider and result (hit, miss)
+	GroupCacheTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "group_cache",
+		Name:      "lookups_total",
+		Help:      "Total number of user group cache lookups, by provider and result (hit, miss).",
+	}, []string{"provider", "result"})
+)
+
+// Cache lookup results
+const (
+	CacheHit     = "hit"
+	CacheMiss    = "miss"
+	CacheRefresh = "refresh"
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		RequestsSubmittedTotal,
+		RequestsApprovedTotal,
+		RequestsRejectedTotal,
+		OAuthRequestDuration,
+		OAuthRequestErrorsTotal,
+		DynamicClientCacheTotal,
+		GroupCacheTotal,
+	)
+}
+
+// Handler returns the HTTP handler serving the API metrics. The metrics of a failing collector are left out
+// and counted in promhttp_metric_handler_errors_total, the others are still served
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry, ErrorHandling: promhttp.ContinueOnError})
+}
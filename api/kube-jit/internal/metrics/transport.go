@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// providerKey is the context key holding the provider label of an outgoing OAuth call
+type providerKey struct{}
+
+// WithProvider returns a copy of ctx whose outgoing OAuth calls are labelled with provider,
+// overriding the transport's default label when several providers share one client
+func WithProvider(ctx context.Context, provider string) context.Context {
+	return context.WithValue(ctx, providerKey{}, provider)
+}
+
+// instrumentedTransport records latency and errors of outgoing calls to an OAuth provider
+type instrumentedTransport struct {
+	provider string
+	next     http.RoundTripper
+}
+
+// InstrumentOAuthTransport wraps next (or http.DefaultTransport if nil) to record
+// OAuth provider call latency and errors labelled with the provider and target host
+func InstrumentOAuthTransport(provider string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &instrumentedTransport{provider: provider, next: next}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	host := req.URL.Host
+	provider := t.provider
+	if p, ok := req.Context().Value(providerKey{}).(string); ok && p != "" {
+		provider = p
+	}
+
+	OAuthRequestDuration.WithLabelValues(provider, host).Observe(time.Since(start).Seconds())
+	if err != nil || resp.StatusCode >= http.StatusBadRequest {
+		OAuthRequestErrorsTotal.WithLabelValues(provider, host).Inc()
+	}
+	return resp, err
+}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrumentOAuthTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	host := mustHost(t, server.URL)
+	client := &http.Client{Transport: InstrumentOAuthTransport("test", nil)}
+
+	errorsBefore := testutil.ToFloat64(OAuthRequestErrorsTotal.WithLabelValues("test", host))
+
+	resp, err := client.Get(server.URL + "/ok")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, errorsBefore, testutil.ToFloat64(OAuthRequestErrorsTotal.WithLabelValues("test", host)))
+
+	resp, err = client.Get(server.URL + "/fail")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, errorsBefore+1, testutil.ToFloat64(OAuthRequestErrorsTotal.WithLabelValues("test", host)))
+
+	assert.Equal(t, 1, testutil.CollectAndCount(OAuthRequestDuration, "kube_jit_api_oauth_request_duration_seconds"))
+}
+
+func TestInstrumentOAuthTransport_ProviderFromContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	host := mustHost(t, server.URL)
+	client := &http.Client{Transport: InstrumentOAuthTransport("default", nil)}
+
+	githubBefore := testutil.ToFloat64(OAuthRequestErrorsTotal.WithLabelValues("github", host))
+	defaultBefore := testutil.ToFloat64(OAuthRequestErrorsTotal.WithLabelValues("default", host))
+
+	req, err := http.NewRequestWithContext(WithProvider(context.Background(), "github"), http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, githubBefore+1, testutil.ToFloat64(OAuthRequestErrorsTotal.WithLabelValues("github", host)))
+	assert.Equal(t, defaultBefore, testutil.ToFloat64(OAuthRequestErrorsTotal.WithLabelValues("default", host)))
+}
+
+func TestHandler_ServesRegisteredMetrics(t *testing.T) {
+	RequestsSubmittedTotal.WithLabelValues("cluster-a", "edit").Inc()
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `kube_jit_api_requests_submitted_total{cluster="cluster-a",role="edit"}`)
+	assert.Contains(t, w.Body.String(), "go_goroutines")
+}
+
+func mustHost(t *testing.T, rawURL string) string {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	require.NoError(t, err)
+	return u.Host
+}
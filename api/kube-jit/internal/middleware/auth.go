@@ -11,8 +11,13 @@ import (
 // It retrieves session data from cookies and sets it in the context.
 // If the session data is not found or invalid, it returns an unauthorized response.
 // It also sets user ID and username in the context for logging purposes.
+// Requests already authenticated with an API token are passed through.
 func RequireAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if _, authenticated := c.Get("sessionData"); authenticated {
+			c.Next()
+			return
+		}
 		session := sessions.Default(c)
 		combinedData := session.Get("data")
 		if combinedData == nil {
@@ -187,3 +187,28 @@ func TestRequireAuth(t *testing.T) {
 		})
 	}
 }
+
+func TestRequireAuth_AlreadyAuthenticated(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(sessions.Sessions("mysession", cookie.NewStore([]byte("secret"))))
+	// As set by an API token middleware, without session cookies
+	r.Use(func(c *gin.Context) {
+		c.Set("sessionData", map[string]interface{}{"id": "user123"})
+		c.Next()
+	})
+	r.Use(RequireAuth())
+
+	var sessionData interface{}
+	r.GET("/testauth", func(c *gin.Context) {
+		sessionData, _ = c.Get("sessionData")
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/testauth", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, map[string]interface{}{"id": "user123"}, sessionData)
+}
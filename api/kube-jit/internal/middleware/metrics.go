@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"kube-jit/internal/metrics"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics is a middleware that records request latency and status by route template
+// The route template (e.g. /kube-jit-api/history) keeps label cardinality bounded,
+// requests that match no route are recorded as "unmatched"
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HTTPRequestDuration.WithLabelValues(
+			c.Request.Method,
+			route,
+			strconv.Itoa(c.Writer.Status()),
+		).Observe(time.Since(start).Seconds())
+	}
+}
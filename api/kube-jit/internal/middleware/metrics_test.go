@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"kube-jit/internal/metrics"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics_RecordsRouteTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Metrics())
+	r.GET("/items/:id", func(c *gin.Context) {
+		c.Status(http.StatusTeapot)
+	})
+
+	before := testutil.CollectAndCount(metrics.HTTPRequestDuration)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items/42", nil))
+	assert.Equal(t, http.StatusTeapot, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/does-not-exist", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	assert.Equal(t, before+2, testutil.CollectAndCount(metrics.HTTPRequestDuration))
+	assert.Contains(t, collectedRoutes(t), "/items/:id")
+	assert.Contains(t, collectedRoutes(t), "unmatched")
+}
+
+// collectedRoutes returns the route labels recorded by the HTTP latency histogram
+func collectedRoutes(t *testing.T) []string {
+	t.Helper()
+	families, err := metrics.Registry.Gather()
+	assert.NoError(t, err)
+
+	var routes []string
+	for _, family := range families {
+		if family.GetName() != "kube_jit_api_http_request_duration_seconds" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() == "route" {
+					routes = append(routes, label.GetValue())
+				}
+			}
+		}
+	}
+	return routes
+}
@@ -14,6 +14,9 @@ import (
 
 // SetupMiddleware sets up the middleware for the Gin engine
 func SetupMiddleware(r *gin.Engine) {
+	// Record latency and status of every request
+	r.Use(Metrics())
+
 	// Get allowed origins from env var ALLOW_ORIGINS
 	var allowOrigins []string
 	allowOriginsStr := utils.MustGetEnv("ALLOW_ORIGINS")
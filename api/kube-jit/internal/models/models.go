@@ -20,6 +20,26 @@ type Cluster struct {
 	Name string `json:"name"`
 }
 
+// API permissions granted by API roles
+const (
+	PermissionAdmin       = "admin"            // admin routes and the sessions and API tokens of other users, cannot be limited
+	PermissionReadHistory = "history:read"     // read the requests of all users
+	PermissionApprove     = "requests:approve" // list, approve and reject requests
+)
+
+// ApiPermissions are the permissions API roles can grant
+var ApiPermissions = []string{PermissionAdmin, PermissionReadHistory, PermissionApprove}
+
+// ApiRole represents a role in the API, granting its permissions to the members of its teams
+// The permissions are limited to requests for the listed clusters and roles, if any
+type ApiRole struct {
+	Name        string   `json:"name" yaml:"name"`
+	Permissions []string `json:"permissions" yaml:"permissions"`
+	Clusters    []string `json:"clusters,omitempty" yaml:"clusters"`
+	Roles       []string `json:"roles,omitempty" yaml:"roles"`
+	Teams       []Team   `json:"teams" yaml:"teams"`
+}
+
 // SimpleMessageResponse is a generic response for success/error messages
 type SimpleMessageResponse struct {
 	Message string `json:"message"`
@@ -51,6 +71,9 @@ type RequestData struct {
 	EndDate       time.Time `json:"endDate"`
 	FullyApproved bool      `gorm:"default:false"`
 	Email         string    `json:"email"`
+	SubmittedBy   string    `json:"submittedBy"` // service principal that submitted the request on behalf of the user
+	// BundleID is shared by the requests submitted together for the clusters matching a selector, empty otherwise
+	BundleID string `gorm:"index" json:"bundleID,omitempty"`
 }
 
 // GormModel is a doc-only struct for Swagger
@@ -89,6 +112,44 @@ type RequestNamespace struct {
 	ApproverName string `json:"approverName"`
 }
 
+// Session represents a server-side session, the session cookies only hold a random session ID
+// ID is the SHA-256 of that session ID, so reading the table does not allow taking over a session,
+// and the OAuth access token is encrypted in Data
+type Session struct {
+	ID        string                 `gorm:"primaryKey" json:"id"`
+	UserID    string                 `gorm:"index" json:"userID"`
+	Username  string                 `json:"username"`
+	Email     string                 `json:"email"`
+	Provider  string                 `json:"provider"`
+	UserAgent string                 `json:"userAgent"`
+	ClientIP  string                 `json:"clientIP"`
+	Data      map[string]interface{} `gorm:"type:jsonb;serializer:json" json:"-"`
+	CreatedAt time.Time              `json:"createdAt"`
+	UpdatedAt time.Time              `json:"lastSeenAt"`
+	ExpiresAt time.Time              `gorm:"index" json:"expiresAt"`
+	RevokedAt *time.Time             `json:"revokedAt,omitempty"`
+}
+
+// APIToken represents a personal API token, accepted with an Authorization: Bearer header
+// Only the SHA-256 of the token is stored, Groups are the user's groups when they were last checked at GroupsCheckedAt
+type APIToken struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	Name            string     `json:"name"`
+	Prefix          string     `json:"prefix"`
+	TokenHash       string     `gorm:"uniqueIndex" json:"-"`
+	Scopes          []string   `gorm:"type:jsonb;serializer:json" json:"scopes"`
+	UserID          string     `gorm:"index" json:"userID"`
+	Username        string     `json:"username"`
+	Email           string     `json:"email"`
+	Provider        string     `json:"provider"`
+	Groups          []Team     `gorm:"type:jsonb;serializer:json" json:"-"`
+	GroupsCheckedAt time.Time  `json:"-"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	ExpiresAt       time.Time  `gorm:"index" json:"expiresAt"`
+	LastUsedAt      *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt       *time.Time `json:"revokedAt,omitempty"`
+}
+
 // GitHubTokenResponse represents the response from GitHub's OAuth token endpoint
 type GitHubTokenResponse struct {
 	AccessToken           string `json:"access_token"`
@@ -124,6 +185,22 @@ type AzureUser struct {
 	UserPrincipalName string `json:"userPrincipalName"`
 }
 
+// GitLabUser represents a GitLab user's profile
+type GitLabUser struct {
+	ID        int    `json:"id"`
+	Username  string `json:"username"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+// GitLabGroup represents a GitLab group the user is a member of
+type GitLabGroup struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	FullPath string `json:"full_path"`
+}
+
 // NormalizedUserData represents a normalized user profile structure
 type NormalizedUserData struct {
 	ID        string `json:"id"`
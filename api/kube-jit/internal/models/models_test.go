@@ -106,6 +106,24 @@ func TestGitHubUser_JSON(t *testing.T) {
 	assert.JSONEq(t, expectedJSON, string(jsonData))
 }
 
+func TestGitLabUser_JSON(t *testing.T) {
+	original := GitLabUser{
+		ID:        42,
+		Username:  "glUser",
+		Name:      "GitLab User",
+		Email:     "gl@example.com",
+		AvatarURL: "http://gitlab.com/avatar.png",
+	}
+	jsonData, err := json.Marshal(original)
+	require.NoError(t, err)
+	var unmarshaled GitLabUser
+	err = json.Unmarshal(jsonData, &unmarshaled)
+	require.NoError(t, err)
+	assert.Equal(t, original, unmarshaled)
+	expectedJSON := `{"id":42,"username":"glUser","name":"GitLab User","email":"gl@example.com","avatar_url":"http://gitlab.com/avatar.png"}`
+	assert.JSONEq(t, expectedJSON, string(jsonData))
+}
+
 func TestGoogleUser_JSON(t *testing.T) {
 	original := GoogleUser{
 		ID:            "google123",
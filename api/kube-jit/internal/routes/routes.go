@@ -3,6 +3,7 @@ package routes
 import (
 	"kube-jit/internal/handlers"
 	"kube-jit/internal/middleware"
+	"kube-jit/internal/models"
 	"kube-jit/pkg/sessioncookie"
 
 	_ "kube-jit/docs"
@@ -18,32 +19,56 @@ func SetupRoutes(r *gin.Engine) {
 	// We are using a custoim middleware to split and combine the session cookie
 	apiWithSession := r.Group("/kube-jit-api")
 	apiWithSession.Use(sessioncookie.SplitAndCombineSessionMiddleware())
+	// Authorization: Bearer API tokens are accepted on some routes instead of session cookies
+	apiWithSession.Use(handlers.TokenAuth())
 	apiWithSession.Use(middleware.RequireAuth())
 	// log the user ID and username from the session data
 	apiWithSession.Use(middleware.AccessLogger(handlers.Logger()))
+	// evaluate stale permissions again, so group and configuration changes apply mid-session
+	apiWithSession.Use(handlers.RefreshPermissions())
 	{
 		apiWithSession.GET("/approving-groups", handlers.GetApprovingGroups)
 		apiWithSession.GET("/roles-and-clusters", handlers.GetClustersAndRoles)
-		apiWithSession.GET("/github/profile", handlers.GetGithubProfile)
-		apiWithSession.GET("/google/profile", handlers.GetGoogleProfile)
-		apiWithSession.GET("/azure/profile", handlers.GetAzureProfile)
 		apiWithSession.POST("/submit-request", handlers.SubmitRequest)
 		apiWithSession.GET("/history", handlers.GetRecords)
-		apiWithSession.GET("/approvals", handlers.GetPendingApprovals)
-		apiWithSession.POST("/approve-reject", handlers.ApproveOrRejectRequests)
 		apiWithSession.POST("/permissions", handlers.CommonPermissions)
-		apiWithSession.POST("/admin/clean-expired", handlers.CleanExpiredRequests)
+		apiWithSession.POST("/tokens", handlers.CreateToken)
+		apiWithSession.GET("/tokens", handlers.ListTokens)
+		apiWithSession.POST("/tokens/revoke", handlers.RevokeToken)
+	}
+
+	// Routes that require a permission of the API roles in apiConfig.yaml, or of the admin and platform approver teams
+	approvers := apiWithSession.Group("", handlers.RequirePermission(models.PermissionApprove))
+	{
+		approvers.GET("/approvals", handlers.GetPendingApprovals)
+		approvers.POST("/approve-reject", handlers.ApproveOrRejectRequests)
+	}
+	admin := apiWithSession.Group("/admin", handlers.RequirePermission(models.PermissionAdmin))
+	{
+		admin.POST("/clean-expired", handlers.CleanExpiredRequests)
+		admin.GET("/sessions", handlers.ListSessions)
+		admin.POST("/sessions/revoke", handlers.RevokeSessions)
+	}
+	// Admin routes outside /admin
+	apiWithSession.GET("/clusters/status", handlers.RequirePermission(models.PermissionAdmin), handlers.GetClustersStatus)
+
+	// Provider specific routes, registered for the enabled providers only
+	for _, provider := range handlers.EnabledProviders() {
+		apiWithSession.GET("/"+provider.Name+"/profile", provider.Profile)
+		r.GET("/kube-jit-api/oauth/"+provider.Name+"/callback", provider.Callback)
+		if provider.Login != nil {
+			r.GET("/kube-jit-api/oauth/"+provider.Name+"/login", provider.Login)
+		}
 	}
 
 	// Routes that do NOT require session handling - unauthenticated
 	r.GET("/kube-jit-api/docs/openapi3.yaml", handlers.ServeOpenAPI3)
-	r.GET("/kube-jit-api/oauth/github/callback", handlers.HandleGitHubLogin)
-	r.GET("/kube-jit-api/oauth/google/callback", handlers.HandleGoogleLogin)
-	r.GET("/kube-jit-api/oauth/azure/callback", handlers.HandleAzureLogin)
 	r.GET("/kube-jit-api/healthz", handlers.HealthCheck)
+	r.GET("/kube-jit-api/healthz/config", handlers.ConfigStatus)
 	r.GET("/kube-jit-api/client_id", handlers.GetOauthClientId)
 	r.POST("/k8s-callback", handlers.K8sCallback)
 	r.POST("/kube-jit-api/logout", handlers.Logout)
+	r.POST("/kube-jit-api/oauth/token", handlers.ServicePrincipalToken)
 	r.GET("/kube-jit-api/build-sha", handlers.GetBuildSha)
 	// openapi v2
 	r.GET("/kube-jit-api/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
@@ -8,6 +8,8 @@ import (
 	"strings"
 	"testing"
 
+	"kube-jit/internal/handlers"
+
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
@@ -44,9 +46,11 @@ func Test_UnauthenticatedRoutes_Accessible(t *testing.T) {
 		path   string
 	}{
 		{"GET", "/kube-jit-api/healthz"},
+		{"GET", "/kube-jit-api/healthz/config"},
 		{"GET", "/kube-jit-api/client_id"},
 		{"POST", "/kube-jit-api/logout"},
 		{"GET", "/kube-jit-api/build-sha"},
+		{"POST", "/kube-jit-api/oauth/token"},
 	}
 
 	for _, route := range unauthRoutes {
@@ -67,15 +71,18 @@ func Test_AuthenticatedRoutes_RequireAuth(t *testing.T) {
 	}{
 		{"GET", "/kube-jit-api/approving-groups"},
 		{"GET", "/kube-jit-api/roles-and-clusters"},
-		{"GET", "/kube-jit-api/github/profile"},
-		{"GET", "/kube-jit-api/google/profile"},
-		{"GET", "/kube-jit-api/azure/profile"},
 		{"POST", "/kube-jit-api/submit-request"},
 		{"GET", "/kube-jit-api/history"},
 		{"GET", "/kube-jit-api/approvals"},
 		{"POST", "/kube-jit-api/approve-reject"},
 		{"POST", "/kube-jit-api/permissions"},
 		{"POST", "/kube-jit-api/admin/clean-expired"},
+		{"GET", "/kube-jit-api/admin/sessions"},
+		{"POST", "/kube-jit-api/admin/sessions/revoke"},
+		{"GET", "/kube-jit-api/clusters/status"},
+		{"POST", "/kube-jit-api/tokens"},
+		{"GET", "/kube-jit-api/tokens"},
+		{"POST", "/kube-jit-api/tokens/revoke"},
 	}
 
 	for _, route := range authRoutes {
@@ -87,3 +94,67 @@ func Test_AuthenticatedRoutes_RequireAuth(t *testing.T) {
 		})
 	}
 }
+
+func Test_MetricsNotServedOnPublicListener(t *testing.T) {
+	r := setupTestRouter()
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code, "Metrics are served on METRICS_PORT only")
+}
+
+func Test_CookieOnlyRoutes_RejectAPITokens(t *testing.T) {
+	r := setupTestRouter()
+	cookieOnlyRoutes := []struct {
+		method string
+		path   string
+	}{
+		{"GET", "/kube-jit-api/approving-groups"},
+		{"POST", "/kube-jit-api/admin/clean-expired"},
+		{"POST", "/kube-jit-api/tokens"},
+		{"GET", "/kube-jit-api/tokens"},
+	}
+
+	for _, route := range cookieOnlyRoutes {
+		t.Run(route.method+"_"+strings.ReplaceAll(route.path, "/", "_"), func(t *testing.T) {
+			req, _ := http.NewRequest(route.method, route.path, nil)
+			req.Header.Set("Authorization", "Bearer kjit_token")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusForbidden, w.Code, "Route %s %s should not accept API tokens", route.method, route.path)
+		})
+	}
+}
+
+func Test_ProviderRoutes_RegisteredForEnabledProvidersOnly(t *testing.T) {
+	r := setupTestRouter()
+
+	enabled := map[string]bool{}
+	for _, provider := range handlers.EnabledProviders() {
+		enabled[provider.Name] = true
+	}
+	assert.NotEmpty(t, enabled, "At least one provider should be enabled")
+
+	// Callbacks are checked against the route table since calling them needs a logger
+	registered := map[string]bool{}
+	for _, route := range r.Routes() {
+		registered[route.Method+" "+route.Path] = true
+	}
+
+	for _, name := range []string{"github", "google", "azure", "oidc"} {
+		t.Run(name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/kube-jit-api/"+name+"/profile", nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			callbackRegistered := registered["GET /kube-jit-api/oauth/"+name+"/callback"]
+
+			if enabled[name] {
+				assert.Equal(t, http.StatusUnauthorized, w.Code, "Profile route of %s should require authentication", name)
+				assert.True(t, callbackRegistered, "Callback route of %s should be registered", name)
+			} else {
+				assert.Equal(t, http.StatusNotFound, w.Code, "Profile route of %s should not be registered", name)
+				assert.False(t, callbackRegistered, "Callback route of %s should not be registered", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package tracing
+
+import (
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	gormPluginName = "kube-jit:tracing"
+	gormSpanKey    = "kube-jit:tracing:span"
+)
+
+// GormPlugin creates a span for every GORM operation as a child of the statement context,
+// use db.WithContext(ctx) so queries join the trace of the HTTP request
+type GormPlugin struct{}
+
+// Name implements gorm.Plugin
+func (GormPlugin) Name() string {
+	return gormPluginName
+}
+
+// Initialize implements gorm.Plugin and registers before/after callbacks for each operation
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	registrations := []error{
+		cb.Create().Before("gorm:create").Register(gormPluginName+":before_create", startSpan("create")),
+		cb.Create().After("gorm:create").Register(gormPluginName+":after_create", endSpan),
+		cb.Query().Before("gorm:query").Register(gormPluginName+":before_query", startSpan("query")),
+		cb.Query().After("gorm:query").Register(gormPluginName+":after_query", endSpan),
+		cb.Update().Before("gorm:update").Register(gormPluginName+":before_update", startSpan("update")),
+		cb.Update().After("gorm:update").Register(gormPluginName+":after_update", endSpan),
+		cb.Delete().Before("gorm:delete").Register(gormPluginName+":before_delete", startSpan("delete")),
+		cb.Delete().After("gorm:delete").Register(gormPluginName+":after_delete", endSpan),
+		cb.Row().Before("gorm:row").Register(gormPluginName+":before_row", startSpan("row")),
+		cb.Row().After("gorm:row").Register(gormPluginName+":after_row", endSpan),
+		cb.Raw().Before("gorm:raw").Register(gormPluginName+":before_raw", startSpan("raw")),
+		cb.Raw().After("gorm:raw").Register(gormPluginName+":after_raw", endSpan),
+	}
+	for _, err := range registrations {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startSpan returns a callback starting a client span for the operation
+func startSpan(operation string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		ctx, span := Tracer().Start(tx.Statement.Context, "gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemPostgreSQL,
+				semconv.DBOperationName(operation),
+			),
+		)
+		tx.Statement.Context = ctx
+		tx.InstanceSet(gormSpanKey, span)
+	}
+}
+
+// endSpan ends the span started for the operation, recording the table, statement and error
+func endSpan(tx *gorm.DB) {
+	value, ok := tx.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+	defer span.End()
+
+	span.SetAttributes(
+		semconv.DBCollectionName(tx.Statement.Table),
+		semconv.DBQueryText(tx.Statement.SQL.String()),
+	)
+	if tx.Error != nil && tx.Error != gorm.ErrRecordNotFound {
+		span.RecordError(tx.Error)
+		span.SetStatus(codes.Error, tx.Error.Error())
+	}
+}
@@ -0,0 +1,122 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+)
+
+// ClusterCheck is the outcome of connecting to a cluster
+type ClusterCheck struct {
+	Name      string
+	Reachable bool
+	Version   string // of the API server, when reachable
+	Error     string // why the cluster is unreachable
+}
+
+// checkClusterConnection connects to the API server of a cluster and returns its version
+var checkClusterConnection = func(restConfig *rest.Config) (string, error) {
+	client, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return "", err
+	}
+	info, err := client.ServerVersion()
+	if err != nil {
+		return "", err
+	}
+	return info.GitVersion, nil
+}
+
+// CheckClusters connects to every cluster of s in parallel and reports which ones are reachable, in the order of s.ClusterNames.
+// Each cluster gets timeout to get its credentials and answer
+func CheckClusters(ctx context.Context, s *Settings, timeout time.Duration) []ClusterCheck {
+	checks := make([]ClusterCheck, len(s.ClusterNames))
+	var wg sync.WaitGroup
+	for i, name := range s.ClusterNames {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checks[i] = checkCluster(ctx, s.ClusterConfigs[name], timeout)
+		}()
+	}
+	wg.Wait()
+	return checks
+}
+
+// checkCluster connects to a cluster without caching its client
+func checkCluster(ctx context.Context, cluster ClusterConfig, timeout time.Duration) ClusterCheck {
+	check := ClusterCheck{Name: cluster.Name}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	restConfig, _, err := restConfigFor(ctx, cluster)
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	restConfig.Timeout = timeout
+	version, err := checkClusterConnection(restConfig)
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	check.Reachable, check.Version = true, version
+	return check
+}
+
+// ValidateConfigFile checks a configuration file for the --validate-config mode of the API, writing the outcome to w.
+// With dryRun it also reads the secrets the file refers to and connects to every cluster, with the API's own kube config.
+// It returns false if the file is invalid or a cluster is unreachable
+func ValidateConfigFile(ctx context.Context, w io.Writer, path string, dryRun bool) bool {
+	configData, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(w, "%s: %v\n", path, err)
+		return false
+	}
+	if _, err := parseConfig(configData); err != nil {
+		var errs ConfigErrors
+		if !errors.As(err, &errs) {
+			fmt.Fprintf(w, "%s: %v\n", path, err)
+			return false
+		}
+		for _, configErr := range errs {
+			if configErr.Line > 0 {
+				fmt.Fprintf(w, "%s:%d: %s\n", path, configErr.Line, ConfigError{Field: configErr.Field, Message: configErr.Message})
+			} else {
+				fmt.Fprintf(w, "%s: %s\n", path, configErr)
+			}
+		}
+		fmt.Fprintf(w, "%s: %d errors\n", path, len(errs))
+		return false
+	}
+	fmt.Fprintf(w, "%s: valid\n", path)
+	if !dryRun {
+		return true
+	}
+
+	if localClientset == nil {
+		initLocalClientset()
+	}
+	loaded, err := buildSettings(configData)
+	if err != nil {
+		fmt.Fprintf(w, "%s: %v\n", path, err)
+		return false
+	}
+	ok := true
+	for _, check := range CheckClusters(ctx, loaded, 10*time.Second) {
+		if check.Reachable {
+			fmt.Fprintf(w, "cluster %s: reachable, %s\n", check.Name, check.Version)
+		} else {
+			ok = false
+			fmt.Fprintf(w, "cluster %s: unreachable: %s\n", check.Name, check.Error)
+		}
+	}
+	return ok
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"kube-jit/internal/metrics"
 	"kube-jit/internal/models"
+	"net/http"
 	"sync"
 	"time"
 
@@ -13,6 +15,7 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.uber.org/zap"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/container/v1"
@@ -32,15 +35,16 @@ var dynamicNewForConfig = func(config *rest.Config) (dynamic.Interface, error) {
 type CachedClient struct {
 	Client       dynamic.Interface
 	TokenExpires int64
+	Config       *rest.Config // the client was created with, for other clients of the cluster like discovery
 }
 
 // createDynamicClient creates and returns a dynamic client based on cluster in request
 // It caches the client and token expiration time to avoid creating a new client for each request
 // It also invalidates the JitGroups cache if the token is expired
-// It uses the cluster type to determine how to create the client (GKE, AKS, or generic)
-// It uses the Google Cloud SDK for GKE and Azure SDK for AKS
-// It uses the Kubernetes client-go library for generic clusters
-var createDynamicClient = func(req models.RequestData) dynamic.Interface {
+// It uses the cluster type to determine how to create the client (GKE, AKS, or generic), see restConfigFor
+// If the client cannot be created the cluster is marked unhealthy, and ErrClusterUnavailable is returned
+// without trying again until its retry backoff has passed
+var createDynamicClient = func(req models.RequestData) (dynamic.Interface, error) {
 	// Check if the dynamic client for the cluster is already cached
 	if cached, exists := dynamicClientCache.Load(req.ClusterName); exists {
 		cachedClient := cached.(*CachedClient)
@@ -49,55 +53,93 @@ var createDynamicClient = func(req models.RequestData) dynamic.Interface {
 		// Check if the token is expired
 		if cachedClient.TokenExpires > currentTime {
 			logger.Info("Using cached dynamic client for cluster", zap.String("cluster", req.ClusterName), zap.Int64("expires", cachedClient.TokenExpires))
-			return cachedClient.Client
+			metrics.DynamicClientCacheTotal.WithLabelValues(req.ClusterName, metrics.CacheHit).Inc()
+			return cachedClient.Client, nil
 		}
 
 		logger.Info("Token expired for cluster, refreshing client", zap.String("cluster", req.ClusterName))
+		metrics.DynamicClientCacheTotal.WithLabelValues(req.ClusterName, metrics.CacheRefresh).Inc()
 		InvalidateJitGroupsCache(req.ClusterName) // Invalidate the JitGroups cache
+	} else {
+		metrics.DynamicClientCacheTotal.WithLabelValues(req.ClusterName, metrics.CacheMiss).Inc()
 	}
 
 	// Get the cluster configuration
-	selectedCluster := ClusterConfigs[req.ClusterName]
+	selectedCluster, ok := CurrentSettings().ClusterConfigs[req.ClusterName]
+	if !ok {
+		return nil, fmt.Errorf("unknown cluster %s", req.ClusterName)
+	}
+	if err := clusterUnavailable(req.ClusterName); err != nil {
+		return nil, err
+	}
+	restConfig, tokenExpires, err := restConfigFor(context.Background(), selectedCluster)
+	if err != nil {
+		return nil, recordClusterFailure(req.ClusterName, err)
+	}
+
+	// Trace calls to the cluster API server
+	restConfig.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return otelhttp.NewTransport(rt)
+	})
+
+	// Create the dynamic client
+	dynamicClient, err := dynamicNewForConfig(restConfig)
+	if err != nil {
+		return nil, recordClusterFailure(req.ClusterName, fmt.Errorf("failed to create k8s client: %w", err))
+	}
+	recordClusterSuccess(req.ClusterName)
+
+	// Cache the dynamic client with expiration
+	dynamicClientCache.Store(req.ClusterName, &CachedClient{
+		Client:       dynamicClient,
+		TokenExpires: tokenExpires,
+		Config:       restConfig,
+	})
+	logger.Info("Cached dynamic client for cluster", zap.String("cluster", req.ClusterName))
+	return dynamicClient, nil
+}
 
+// restConfigFor returns the rest config to access a cluster and when its token expires, depending on the cluster type
+// It uses the Google Cloud SDK for GKE, Azure SDK for AKS and IAM authentication for EKS, see restConfigForEKS,
+// a kubeconfig for kubeconfig clusters, see restConfigForKubeconfig, and the configured host, CA and token for generic clusters, see requestServiceAccountToken
+func restConfigFor(ctx context.Context, cluster ClusterConfig) (*rest.Config, int64, error) {
 	var restConfig *rest.Config
-	var err error
 	var tokenExpires int64
 
 	// Use a switch statement to handle different cluster types
-	switch selectedCluster.Type {
+	switch cluster.Type {
 	case "gke": // Google Kubernetes Engine
-		logger.Info("Using Google Cloud SDK to access GKE cluster", zap.String("cluster", req.ClusterName))
+		logger.Info("Using Google Cloud SDK to access GKE cluster", zap.String("cluster", cluster.Name))
 		// GKE-specific logic
-		ctx := context.Background()
 		credentials, err := google.FindDefaultCredentials(ctx, container.CloudPlatformScope)
 		if err != nil {
-			logger.Fatal("Failed to get default credentials", zap.Error(err))
+			return nil, 0, fmt.Errorf("failed to get default credentials: %w", err)
 		}
 		tokenSource := credentials.TokenSource
 
 		client, err := containerapiv1.NewClusterManagerClient(ctx)
 		if err != nil {
-			logger.Fatal("Failed to create GKE client", zap.Error(err))
+			return nil, 0, fmt.Errorf("failed to create GKE client: %w", err)
 		}
 		defer client.Close()
 
-		location := selectedCluster.Region
-		clusterName := fmt.Sprintf("projects/%s/locations/%s/clusters/%s", selectedCluster.ProjectID, location, selectedCluster.Name)
-		cluster, err := client.GetCluster(ctx, &containerpb.GetClusterRequest{Name: clusterName})
+		location := cluster.Region
+		clusterName := fmt.Sprintf("projects/%s/locations/%s/clusters/%s", cluster.ProjectID, location, cluster.Name)
+		gkeCluster, err := client.GetCluster(ctx, &containerpb.GetClusterRequest{Name: clusterName})
 		if err != nil {
-			logger.Fatal("Failed to get GKE cluster details", zap.Error(err))
+			return nil, 0, fmt.Errorf("failed to get GKE cluster details: %w", err)
 		}
 
-		clusterEndpoint := cluster.Endpoint
-		caCertificate := cluster.MasterAuth.ClusterCaCertificate
+		clusterEndpoint := gkeCluster.Endpoint
+		caCertificate := gkeCluster.MasterAuth.ClusterCaCertificate
 		decodedCACertificate, err := base64.StdEncoding.DecodeString(caCertificate)
 		if err != nil {
-			logger.Fatal("Failed to decode CA certificate", zap.Error(err))
+			return nil, 0, fmt.Errorf("failed to decode CA certificate: %w", err)
 		}
 
 		token, err := tokenSource.Token()
 		if err != nil {
-			logger.Fatal("Failed to get OAuth2 token", zap.Error(err))
+			return nil, 0, fmt.Errorf("failed to get OAuth2 token: %w", err)
 		}
 
 		restConfig = &rest.Config{
@@ -111,34 +153,41 @@ var createDynamicClient = func(req models.RequestData) dynamic.Interface {
 		tokenExpires = token.Expiry.Unix() - 300
 
 	case "aks": // Azure Kubernetes Service
-		logger.Info("Using Azure SDK to access AKS cluster", zap.String("cluster", req.ClusterName))
+		logger.Info("Using Azure SDK to access AKS cluster", zap.String("cluster", cluster.Name))
 		// AKS-specific logic
 		cred, err := azidentity.NewDefaultAzureCredential(nil)
 		if err != nil {
-			logger.Fatal("Failed to create Azure credential", zap.Error(err))
+			return nil, 0, fmt.Errorf("failed to create Azure credential: %w", err)
 		}
-		adminClient, err := armcontainerservice.NewManagedClustersClient(selectedCluster.ProjectID, cred, nil)
+		adminClient, err := armcontainerservice.NewManagedClustersClient(cluster.ProjectID, cred, nil)
 		if err != nil {
-			logger.Fatal("Failed to create AKS AdminCredentialsClient", zap.Error(err))
+			return nil, 0, fmt.Errorf("failed to create AKS AdminCredentialsClient: %w", err)
 		}
-		kubeconfigResp, err := adminClient.ListClusterUserCredentials(context.Background(), selectedCluster.Region, selectedCluster.Name, nil)
+		kubeconfigResp, err := adminClient.ListClusterUserCredentials(ctx, cluster.Region, cluster.Name, nil)
 		if err != nil {
-			logger.Fatal("Failed to get AKS cluster user credentials", zap.Error(err))
+			return nil, 0, fmt.Errorf("failed to get AKS cluster user credentials: %w", err)
+		}
+		if len(kubeconfigResp.Kubeconfigs) == 0 {
+			return nil, 0, fmt.Errorf("no kubeconfig in AKS cluster user credentials")
 		}
 		kubeconfigData := kubeconfigResp.Kubeconfigs[0].Value
 		config, err := clientcmd.Load(kubeconfigData)
 		if err != nil {
-			logger.Fatal("Failed to parse kubeconfig", zap.Error(err))
+			return nil, 0, fmt.Errorf("failed to parse kubeconfig: %w", err)
+		}
+		kubeContext, ok := config.Contexts[config.CurrentContext]
+		if !ok || config.Clusters[kubeContext.Cluster] == nil {
+			return nil, 0, fmt.Errorf("no cluster for the current context of the AKS kubeconfig")
 		}
-		cluster := config.Clusters[config.Contexts[config.CurrentContext].Cluster]
-		clusterEndpoint := cluster.Server
-		caCertificate := cluster.CertificateAuthorityData
+		aksCluster := config.Clusters[kubeContext.Cluster]
+		clusterEndpoint := aksCluster.Server
+		caCertificate := aksCluster.CertificateAuthorityData
 
-		token, err := cred.GetToken(context.Background(), policy.TokenRequestOptions{
+		token, err := cred.GetToken(ctx, policy.TokenRequestOptions{
 			Scopes: []string{"6dae42f8-4368-4678-94ff-3960e28e3630/.default"},
 		})
 		if err != nil {
-			logger.Fatal("Failed to get AAD token", zap.Error(err))
+			return nil, 0, fmt.Errorf("failed to get AAD token: %w", err)
 		}
 
 		restConfig = &rest.Config{
@@ -151,38 +200,40 @@ var createDynamicClient = func(req models.RequestData) dynamic.Interface {
 		// Set token expiration time (e.g., 1 hour)
 		tokenExpires = time.Now().Add(1 * time.Hour).Unix()
 
+	case "eks": // Amazon Elastic Kubernetes Service
+		logger.Info("Using AWS IAM authentication to access EKS cluster", zap.String("cluster", cluster.Name))
+		return restConfigForEKS(ctx, cluster)
+
+	case "kubeconfig": // Any cluster, with the credentials of a kubeconfig
+		logger.Info("Using kubeconfig to access cluster", zap.String("cluster", cluster.Name))
+		return restConfigForKubeconfig(ctx, cluster)
+
 	default: // Generic Kubernetes cluster
-		logger.Info("Using generic configuration for cluster", zap.String("cluster", req.ClusterName))
+		logger.Info("Using generic configuration for cluster", zap.String("cluster", cluster.Name))
 		// Generic cluster logic
-		apiServerURL := selectedCluster.Host
-		saToken := selectedCluster.Token
-		caData, err := base64.StdEncoding.DecodeString(selectedCluster.CA)
+		apiServerURL := cluster.Host
+		saToken := cluster.Token
+		caData, err := base64.StdEncoding.DecodeString(cluster.CA)
 		if err != nil {
-			logger.Fatal("Failed to decode CA certificate", zap.Error(err))
+			return nil, 0, fmt.Errorf("failed to decode CA certificate: %w", err)
+		}
+		// Non-GKE clusters don't use token expiration, tokens read from secrets are refreshed by WatchSecrets
+		tokenExpires = time.Now().Add(24 * time.Hour).Unix() // Arbitrary long expiration
+		if cluster.TokenRequest != nil {
+			saToken, tokenExpires, err = requestServiceAccountToken(ctx, *cluster.TokenRequest)
+			if err != nil {
+				return nil, 0, err
+			}
 		}
 		restConfig = &rest.Config{
 			Host:        apiServerURL,
 			BearerToken: saToken,
 			TLSClientConfig: rest.TLSClientConfig{
-				Insecure: selectedCluster.Insecure,
+				Insecure: cluster.Insecure,
 				CAData:   caData,
 			},
 		}
-		// Non-GKE clusters don't use token expiration
-		tokenExpires = time.Now().Add(24 * time.Hour).Unix() // Arbitrary long expiration
-	}
-
-	// Create the dynamic client
-	dynamicClient, err := dynamicNewForConfig(restConfig)
-	if err != nil {
-		logger.Fatal("Failed to create k8s client", zap.Error(err))
 	}
 
-	// Cache the dynamic client with expiration
-	dynamicClientCache.Store(req.ClusterName, &CachedClient{
-		Client:       dynamicClient,
-		TokenExpires: tokenExpires,
-	})
-	logger.Info("Cached dynamic client for cluster", zap.String("cluster", req.ClusterName))
-	return dynamicClient
+	return restConfig, tokenExpires, nil
 }
@@ -8,6 +8,7 @@ import (
 	"kube-jit/internal/models"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/dynamic"
@@ -51,7 +52,8 @@ func TestCreateDynamicClient_CacheReuse(t *testing.T) {
 	defer func() { InvalidateJitGroupsCache = origInvalidate }()
 
 	req := models.RequestData{ClusterName: "test-cluster"}
-	got := createDynamicClient(req)
+	got, err := createDynamicClient(req)
+	require.NoError(t, err)
 	assert.Equal(t, fakeClient, got)
 	assert.False(t, invalidateCalled, "Cache should not be invalidated if not expired")
 }
@@ -78,7 +80,7 @@ func TestCreateDynamicClient_CacheExpired(t *testing.T) {
 	defer func() { InvalidateJitGroupsCache = origInvalidate }()
 
 	// Patch ClusterConfigs for generic cluster
-	ClusterConfigs = map[string]ClusterConfig{
+	original := SetSettings(&Settings{ClusterConfigs: map[string]ClusterConfig{
 		"test-cluster": {
 			Type:     "generic",
 			Host:     "https://fake",
@@ -86,7 +88,8 @@ func TestCreateDynamicClient_CacheExpired(t *testing.T) {
 			CA:       "",
 			Insecure: true,
 		},
-	}
+	}})
+	defer SetSettings(original)
 
 	req := models.RequestData{ClusterName: "test-cluster"}
 	// Patch dynamicNewForConfig to return fakeClient
@@ -94,7 +97,8 @@ func TestCreateDynamicClient_CacheExpired(t *testing.T) {
 	dynamicNewForConfig = func(*rest.Config) (dynamic.Interface, error) { return fakeClient, nil }
 	defer func() { dynamicNewForConfig = origDynamicNewForConfig }()
 
-	got := createDynamicClient(req)
+	got, err := createDynamicClient(req)
+	require.NoError(t, err)
 	assert.Equal(t, fakeClient, got)
 	assert.True(t, invalidateCalled, "Cache should be invalidated if expired")
 }
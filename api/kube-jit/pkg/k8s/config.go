@@ -2,13 +2,18 @@ package k8s
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"kube-jit/internal/models"
 	"kube-jit/pkg/utils"
 	"os"
+	"path/filepath"
+	"time"
 
 	"go.uber.org/zap"
-	"gopkg.in/yaml.v2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -17,15 +22,27 @@ import (
 var (
 	apiNamespace        = utils.MustGetEnv("API_NAMESPACE")
 	localClientset      kubernetes.Interface
+	localDynamicClient  dynamic.Interface
 	runClusterInitAsync = true
 )
 
 // Config represents the configuration for the API
 type Config struct {
-	Clusters              []ClusterConfig `yaml:"clusters"`
-	AllowedRoles          []models.Roles  `yaml:"allowedRoles"`
-	PlatformApproverTeams []models.Team   `yaml:"platformApproverTeams"`
-	AdminTeams            []models.Team   `yaml:"adminTeams"`
+	Clusters              []ClusterConfig          `yaml:"clusters"`
+	AllowedRoles          []models.Roles           `yaml:"allowedRoles"`
+	PlatformApproverTeams []models.Team            `yaml:"platformApproverTeams"`
+	AdminTeams            []models.Team            `yaml:"adminTeams"`
+	ServicePrincipals     []ServicePrincipalConfig `yaml:"servicePrincipals"`
+	ApiRoles              []models.ApiRole         `yaml:"apiRoles"`
+	JitClusters           JitClusterPolicy         `yaml:"jitClusters"`
+}
+
+// JitClusterPolicy is what JitClusters may use of the API's credentials. The author of a JitCluster picks the host
+// they are sent to, so by default JitClusters only use the secrets labelled ClusterCredentialsLabel, verifying TLS
+type JitClusterPolicy struct {
+	AllowInsecure     bool     `yaml:"allowInsecure"`     // allow insecure, skipping the TLS verification of the cluster
+	AllowTokenRequest bool     `yaml:"allowTokenRequest"` // allow tokenRequest, with the serviceAccounts only
+	ServiceAccounts   []string `yaml:"serviceAccounts"`   // service accounts of the API's namespace tokenRequest may use
 }
 
 // ClusterConfig represents the configuration for a cluster
@@ -36,9 +53,41 @@ type ClusterConfig struct {
 	Insecure    bool   `yaml:"insecure"`
 	TokenSecret string `yaml:"tokenSecret"`
 	Token       string `yaml:"token"`
-	Type        string `yaml:"type"`      // e.g., "gke" or "generic" or "aks"
+	Type        string `yaml:"type"`      // e.g., "gke" or "generic" or "aks" or "eks" or "kubeconfig"
 	ProjectID   string `yaml:"projectID"` // GCP project ID for GKE clusters
-	Region      string `yaml:"region"`    // Region for GKE clusters
+	Region      string `yaml:"region"`    // Region for GKE and EKS clusters
+	RoleARN     string `yaml:"roleARN"`   // IAM role assumed to access EKS clusters, optional
+	// Short-lived tokens of generic clusters, instead of the token of tokenSecret
+	TokenRequest *TokenRequestConfig `yaml:"tokenRequest"`
+	// Kubeconfig of kubeconfig clusters, from a secret with the key "kubeconfig" or a mounted file
+	KubeconfigSecret string `yaml:"kubeconfigSecret"`
+	KubeconfigFile   string `yaml:"kubeconfigFile"`
+	Context          string `yaml:"context"` // context of the kubeconfig, defaults to its current context
+	Kubeconfig       []byte `yaml:"-"`
+	// Teams approving or administering the requests for this cluster only, like the global teams of Config
+	PlatformApproverTeams []models.Team `yaml:"platformApproverTeams"`
+	AdminTeams            []models.Team `yaml:"adminTeams"`
+	// Labels of the cluster, e.g. env, region or team
+	Labels map[string]string `yaml:"labels"`
+	// Registered is set on the clusters of JitCluster resources, see WatchJitClusters
+	Registered bool `yaml:"-"`
+}
+
+// TokenRequestConfig mints short-lived tokens of a service account of the API's namespace with the TokenRequest API.
+// The cluster must trust the service account issuer of the API's cluster, unless it is the same cluster
+type TokenRequestConfig struct {
+	ServiceAccount    string   `yaml:"serviceAccount"`
+	Audiences         []string `yaml:"audiences"`         // defaults to the audience of the API's cluster
+	ExpirationSeconds int64    `yaml:"expirationSeconds"` // defaults to 3600, at least 600
+}
+
+// ServicePrincipalConfig represents an API client authenticating with OAuth client credentials
+type ServicePrincipalConfig struct {
+	Name             string   `yaml:"name"`
+	ClientID         string   `yaml:"clientID"`
+	ClientSecretName string   `yaml:"clientSecretName"` // secret in the same namespace as the api, with the client secret in key "clientSecret"
+	ClientSecret     string   `yaml:"-"`
+	Scopes           []string `yaml:"scopes"` // scopes the client may request, e.g. "submit" or "history"
 }
 
 // InitK8sConfig loads clusters, roles and approver teams from configMap into global vars
@@ -54,11 +103,17 @@ func InitK8sConfig() {
 		return
 	}
 
+	CallbackHostOverride = utils.MustGetEnv("CALLBACK_HOST_OVERRIDE")
+
+	initLocalClientset()
+	loadApiConfigAndClusters()
+}
+
+// initLocalClientset creates the clients of the cluster the API runs in, used to read secrets and JitClusters
+func initLocalClientset() {
 	var config *rest.Config
 	var err error
 
-	CallbackHostOverride = utils.MustGetEnv("CALLBACK_HOST_OVERRIDE")
-
 	// Load in-cluster kube config
 	config, err = rest.InClusterConfig()
 	if err != nil {
@@ -79,81 +134,148 @@ func InitK8sConfig() {
 			panic(err.Error())
 		}
 	}
-
-	loadApiConfigAndClusters()
+	if localDynamicClient == nil {
+		localDynamicClient, err = dynamic.NewForConfig(config)
+		if err != nil {
+			panic(err.Error())
+		}
+	}
 }
 
 // loadApiConfigAndClusters loads the API configuration and sets up clusters
 func loadApiConfigAndClusters() {
 	// Load ConfigMap of clusters from local file system
-	configPath := utils.MustGetEnv("CONFIG_MOUNT_PATH")
-	configData, err := os.ReadFile(configPath + "/apiConfig.yaml")
+	configData, err := os.ReadFile(configFile())
 	if err != nil {
 		logger.Fatal("Error reading config file", zap.Error(err))
 	}
 
-	// Parse ConfigMap data to global ApiConfig
-	err = yaml.Unmarshal([]byte(configData), &ApiConfig)
+	loaded, err := buildSettings(configData)
 	if err != nil {
-		logger.Fatal("Error unmarshalling config data", zap.Error(err))
+		logger.Fatal("Error loading config", zap.Error(err))
+	}
+	applySettings(loaded)
+}
+
+// configFile returns the path of apiConfig.yaml
+func configFile() string {
+	return filepath.Join(utils.MustGetEnv("CONFIG_MOUNT_PATH"), "apiConfig.yaml")
+}
+
+// buildSettings parses and validates apiConfig.yaml and reads the secrets it refers to
+func buildSettings(configData []byte) (*Settings, error) {
+	// Parse and validate ConfigMap data
+	config, err := parseConfig(configData)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256(configData)
+	loaded := &Settings{
+		Hash:                  hex.EncodeToString(hash[:]),
+		LoadedAt:              time.Now(),
+		ClusterConfigs:        make(map[string]ClusterConfig),
+		AllowedRoles:          config.AllowedRoles,
+		PlatformApproverTeams: config.PlatformApproverTeams,
+		AdminTeams:            config.AdminTeams,
+		ApiRoles:              config.ApiRoles,
+		ServicePrincipals:     make(map[string]ServicePrincipalConfig),
+		JitClusters:           config.JitClusters,
+	}
+
+	// Get cluster tokens and add to cluster maps
+	for _, cluster := range config.Clusters {
+		logger.Info("Loaded cluster",
+			zap.String("name", cluster.Name),
+			zap.String("type", cluster.Type),
+			zap.Int("platformApproverTeams", len(cluster.PlatformApproverTeams)),
+			zap.Int("adminTeams", len(cluster.AdminTeams)),
+		)
+		if err := readClusterSecrets(&cluster); err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", cluster.Name, err)
+		}
+		loaded.ClusterConfigs[cluster.Name] = cluster
+		loaded.ClusterNames = append(loaded.ClusterNames, cluster.Name)
 	}
 
-	// Get cluster tokens and add to global cluster maps
-	logger.Info("Successfully loaded config for clusters",
-		zap.Strings("clusters", ClusterNames),
-	)
-	for _, cluster := range ApiConfig.Clusters {
-		logger.Info("Loaded cluster", zap.String("name", cluster.Name), zap.String("type", cluster.Type))
-		if cluster.Type == "generic" {
-			cluster.Token = getTokenFromSecret(cluster.TokenSecret)
+	// Get client secrets of service principals, a principal without its secret is skipped
+	for _, principal := range config.ServicePrincipals {
+		secret, err := getClientSecret(principal.ClientSecretName)
+		if err != nil {
+			logger.Error("Error getting client secret, skipping service principal",
+				zap.String("name", principal.Name),
+				zap.String("secret", principal.ClientSecretName),
+				zap.Error(err),
+			)
+			continue
+		}
+		principal.ClientSecret = secret
+		loaded.ServicePrincipals[principal.ClientID] = principal
+	}
+	return loaded, nil
+}
+
+// readClusterSecrets sets the token of a generic cluster or the kubeconfig of a kubeconfig cluster from the secret it refers to,
+// rejecting kubeconfigs with exec plugins that are not allowed, see validateKubeconfigExec
+func readClusterSecrets(cluster *ClusterConfig) error {
+	if cluster.Type == "generic" && cluster.TokenRequest == nil {
+		token, err := getTokenFromSecret(cluster.TokenSecret)
+		if err != nil {
+			return err
+		}
+		cluster.Token = token
+	}
+	if cluster.Type == "kubeconfig" && cluster.KubeconfigSecret != "" {
+		kubeconfig, err := getKubeconfigFromSecret(cluster.KubeconfigSecret)
+		if err != nil {
+			return err
 		}
-		ClusterConfigs[cluster.Name] = cluster
-		ClusterNames = append(ClusterNames, cluster.Name)
-	}
-
-	AllowedRoles = ApiConfig.AllowedRoles
-	PlatformApproverTeams = ApiConfig.PlatformApproverTeams
-	AdminTeams = ApiConfig.AdminTeams
-
-	// Log loaded config
-	logger.Info("Allowed roles loaded", zap.Int("count", len(AllowedRoles)))
-	for _, role := range AllowedRoles {
-		logger.Info("Allowed role", zap.String("name", role.Name))
-	}
-	logger.Info("Approver teams loaded", zap.Int("count", len(PlatformApproverTeams)))
-	for _, team := range PlatformApproverTeams {
-		logger.Info("Approver team", zap.String("name", team.Name), zap.String("id", team.ID))
-	}
-	logger.Info("Admin teams loaded", zap.Int("count", len(AdminTeams)))
-	for _, team := range AdminTeams {
-		logger.Info("Admin team", zap.String("name", team.Name), zap.String("id", team.ID))
-	}
-
-	// Cache dynamic clients for all clusters on startup
-	for _, clusterName := range ClusterNames {
-		req := models.RequestData{ClusterName: clusterName}
-		if runClusterInitAsync { // for production
-			go func(r models.RequestData) {
-				defer func() {
-					if err := recover(); err != nil {
-						logger.Error("Failed to cache dynamic client for cluster", zap.String("cluster", r.ClusterName), zap.Any("error", err))
-					}
-				}()
-				createDynamicClient(r)
-			}(req)
-		} else {
-			// for testing
-			createDynamicClient(req)
+		// A kubeconfig that does not parse is reported when its client is created
+		if config, err := clientcmd.Load(kubeconfig); err == nil {
+			if err := validateKubeconfigExec(config); err != nil {
+				return fmt.Errorf("kubeconfig secret %s: %w", cluster.KubeconfigSecret, err)
+			}
 		}
+		cluster.Kubeconfig = kubeconfig
 	}
+	return nil
 }
 
 // getTokenFromSecret gets and returns the sa token from a k8s secret during init of kube configs
-func getTokenFromSecret(secretName string) string {
+func getTokenFromSecret(secretName string) (string, error) {
 	secret, err := localClientset.CoreV1().Secrets(apiNamespace).Get(context.TODO(), secretName, metav1.GetOptions{})
 	if err != nil {
-		logger.Error("Error getting secret", zap.String("secret", secretName), zap.Error(err))
-		panic(err.Error())
+		return "", fmt.Errorf("error getting token secret %s: %w", secretName, err)
+	}
+	token := string(secret.Data["token"])
+	if token == "" {
+		return "", fmt.Errorf("secret %s has no token key", secretName)
+	}
+	return token, nil
+}
+
+// getKubeconfigFromSecret gets and returns the kubeconfig of a kubeconfig cluster from a k8s secret
+func getKubeconfigFromSecret(secretName string) ([]byte, error) {
+	secret, err := localClientset.CoreV1().Secrets(apiNamespace).Get(context.TODO(), secretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error getting kubeconfig secret %s: %w", secretName, err)
+	}
+	kubeconfig := secret.Data["kubeconfig"]
+	if len(kubeconfig) == 0 {
+		return nil, fmt.Errorf("secret %s has no kubeconfig key", secretName)
+	}
+	return kubeconfig, nil
+}
+
+// getClientSecret gets and returns the client secret of a service principal from a k8s secret
+func getClientSecret(secretName string) (string, error) {
+	secret, err := localClientset.CoreV1().Secrets(apiNamespace).Get(context.TODO(), secretName, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	clientSecret := string(secret.Data["clientSecret"])
+	if clientSecret == "" {
+		return "", fmt.Errorf("secret %s has no clientSecret key", secretName)
 	}
-	return string(secret.Data["token"])
+	return clientSecret, nil
 }
@@ -17,6 +17,16 @@ func init() {
 	logger = zap.NewNop()
 }
 
+// useEmptySettings starts the test without configuration and restores the previous one afterwards
+func useEmptySettings(t *testing.T) {
+	t.Helper()
+	original := SetSettings(&Settings{
+		ClusterConfigs:    make(map[string]ClusterConfig),
+		ServicePrincipals: make(map[string]ServicePrincipalConfig),
+	})
+	t.Cleanup(func() { SetSettings(original) })
+}
+
 func TestInitK8sConfig_LoadsConfigAndSecrets(t *testing.T) {
 	// Setup temp config file
 	tmpDir := t.TempDir()
@@ -60,11 +70,7 @@ adminTeams:
 	apiNamespace = "default"
 
 	// Clear global state
-	ClusterConfigs = map[string]ClusterConfig{}
-	ClusterNames = []string{}
-	AllowedRoles = nil
-	PlatformApproverTeams = nil
-	AdminTeams = nil
+	useEmptySettings(t)
 
 	// Run
 	runClusterInitAsync = false
@@ -72,25 +78,67 @@ adminTeams:
 	InitK8sConfig()
 
 	// Assertions
-	assert.Contains(t, ClusterConfigs, "test-cluster")
-	assert.Equal(t, "fake-token", ClusterConfigs["test-cluster"].Token)
-	assert.Equal(t, []string{"test-cluster"}, ClusterNames)
-	assert.Equal(t, "admin", AllowedRoles[0].Name)
-	assert.Equal(t, "team1", PlatformApproverTeams[0].Name)
-	assert.Equal(t, "team2", AdminTeams[0].Name)
+	current := CurrentSettings()
+	assert.Equal(t, int64(1), current.Version)
+	assert.Contains(t, current.ClusterConfigs, "test-cluster")
+	assert.Equal(t, "fake-token", current.ClusterConfigs["test-cluster"].Token)
+	assert.Equal(t, []string{"test-cluster"}, current.ClusterNames)
+	assert.Equal(t, "admin", current.AllowedRoles[0].Name)
+	assert.Equal(t, "team1", current.PlatformApproverTeams[0].Name)
+	assert.Equal(t, "team2", current.AdminTeams[0].Name)
 }
 
 func TestGetTokenFromSecret_Error(t *testing.T) {
 	// Setup fake clientset with no secret
-	clientset := fake.NewSimpleClientset()
+	clientset := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "empty-secret", Namespace: "default"},
+	})
 	localClientset = clientset
 	apiNamespace = "default"
 
-	defer func() {
-		if r := recover(); r == nil {
-			t.Errorf("Expected panic when secret not found")
-		}
-	}()
+	_, err := getTokenFromSecret("nonexistent-secret")
+	assert.ErrorContains(t, err, "error getting token secret nonexistent-secret")
+
+	_, err = getTokenFromSecret("empty-secret")
+	assert.ErrorContains(t, err, "secret empty-secret has no token key")
+}
+
+func TestLoadApiConfigAndClusters_ServicePrincipals(t *testing.T) {
+	tmpDir := t.TempDir()
+	configYaml := `
+servicePrincipals:
+  - name: incident-bot
+    clientID: incident-bot-id
+    clientSecretName: incident-bot-secret
+    scopes: ["submit"]
+  - name: missing-secret-bot
+    clientID: missing-secret-id
+    clientSecretName: nonexistent-secret
+    scopes: ["submit"]
+`
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "apiConfig.yaml"), []byte(configYaml), 0644))
+	t.Setenv("CONFIG_MOUNT_PATH", tmpDir)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "incident-bot-secret",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			"clientSecret": []byte("s3cret"),
+		},
+	}
+	localClientset = fake.NewSimpleClientset(secret)
+	apiNamespace = "default"
+	useEmptySettings(t)
+
+	loadApiConfigAndClusters()
 
-	getTokenFromSecret("nonexistent-secret")
+	// The principal without a secret is skipped
+	principals := CurrentSettings().ServicePrincipals
+	require.Len(t, principals, 1)
+	principal := principals["incident-bot-id"]
+	assert.Equal(t, "incident-bot", principal.Name)
+	assert.Equal(t, "s3cret", principal.ClientSecret)
+	assert.Equal(t, []string{"submit"}, principal.Scopes)
 }
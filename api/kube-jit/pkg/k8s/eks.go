@@ -0,0 +1,178 @@
+package k8s
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"go.uber.org/zap"
+	"k8s.io/client-go/rest"
+)
+
+// EKS tokens are presigned STS GetCallerIdentity URLs, like the ones of aws-iam-authenticator.
+// EKS accepts them for 15 minutes after they are signed
+const (
+	eksTokenPrefix     = "k8s-aws-v1."
+	eksTokenLifetime   = 15 * time.Minute
+	eksClusterIDHeader = "x-k8s-aws-id"
+	awsRoleSessionName = "kube-jit-api"
+	// emptyPayloadHash is the SHA-256 of an empty request body
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// awsBaseEndpoint replaces the endpoints of the AWS services when set, for tests
+var awsBaseEndpoint string
+
+var awsHTTPClient = awshttp.NewBuildableClient().WithTimeout(30 * time.Second)
+
+// restConfigForEKS discovers the endpoint and CA of an EKS cluster with the EKS API and returns a rest config
+// with an IAM authentication token, and when the token must be refreshed.
+// The API's AWS credentials are used, or the ones of cluster.RoleARN if set, see awsConfig
+func restConfigForEKS(ctx context.Context, cluster ClusterConfig) (*rest.Config, int64, error) {
+	cfg, err := awsConfig(ctx, cluster.Region, cluster.RoleARN)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get AWS credentials: %w", err)
+	}
+
+	eksCluster, err := describeEKSCluster(ctx, creds, cluster.Name, cluster.Region)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get EKS cluster details: %w", err)
+	}
+	caCertificate, err := base64.StdEncoding.DecodeString(eksCluster.CertificateAuthority.Data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decode CA certificate: %w", err)
+	}
+
+	now := time.Now()
+	token, err := eksToken(ctx, cfg, cluster.Name)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to generate EKS token: %w", err)
+	}
+	restConfig := &rest.Config{
+		Host:        eksCluster.Endpoint,
+		BearerToken: token,
+		TLSClientConfig: rest.TLSClientConfig{
+			CAData: caCertificate,
+		},
+	}
+	// Refresh the token a minute before it expires, or before the credentials it was signed with do
+	expires := now.Add(eksTokenLifetime)
+	if creds.CanExpire && creds.Expires.Before(expires) {
+		expires = creds.Expires
+	}
+	return restConfig, expires.Add(-time.Minute).Unix(), nil
+}
+
+// awsConfig loads the AWS config of the API for a region with the default credential chain of the SDK:
+// environment variables, shared config files, IRSA (AWS_WEB_IDENTITY_TOKEN_FILE and AWS_ROLE_ARN),
+// EKS Pod Identity or the EC2 instance role. When roleARN is set, that role is assumed with these credentials
+func awsConfig(ctx context.Context, region, roleARN string) (aws.Config, error) {
+	options := []func(*config.LoadOptions) error{
+		config.WithRegion(region),
+		config.WithHTTPClient(awsHTTPClient),
+	}
+	if awsBaseEndpoint != "" {
+		options = append(options, config.WithBaseEndpoint(awsBaseEndpoint))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	if roleARN != "" {
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), roleARN, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = awsRoleSessionName
+		})
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+	return cfg, nil
+}
+
+// eksEndpoint returns the regional endpoint of the EKS API
+func eksEndpoint(region string) string {
+	if awsBaseEndpoint != "" {
+		return awsBaseEndpoint
+	}
+	if strings.HasPrefix(region, "cn-") {
+		return fmt.Sprintf("https://eks.%s.amazonaws.com.cn", region)
+	}
+	return fmt.Sprintf("https://eks.%s.amazonaws.com", region)
+}
+
+// eksCluster holds the fields of an EKS DescribeCluster response needed to connect to the cluster
+type eksCluster struct {
+	Endpoint             string `json:"endpoint"`
+	Status               string `json:"status"`
+	CertificateAuthority struct {
+		Data string `json:"data"`
+	} `json:"certificateAuthority"`
+}
+
+// describeEKSCluster gets the endpoint and CA of an EKS cluster with the DescribeCluster call,
+// signed with the SigV4 signer of the SDK
+func describeEKSCluster(ctx context.Context, creds aws.Credentials, name, region string) (*eksCluster, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, eksEndpoint(region)+"/clusters/"+url.PathEscape(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := v4.NewSigner().SignHTTP(ctx, creds, req, emptyPayloadHash, "eks", region, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	resp, err := awsHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		logger.Debug("EKS request failed", zap.String("url", req.URL.Host+req.URL.Path), zap.Int("status", resp.StatusCode), zap.ByteString("body", body))
+		return nil, fmt.Errorf("%s %s returned %d: %s", req.Method, req.URL.Host+req.URL.Path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var described struct {
+		Cluster eksCluster `json:"cluster"`
+	}
+	if err := json.Unmarshal(body, &described); err != nil {
+		return nil, err
+	}
+	if described.Cluster.Endpoint == "" {
+		return nil, fmt.Errorf("EKS cluster %s has no endpoint, its status is %s", name, described.Cluster.Status)
+	}
+	return &described.Cluster, nil
+}
+
+// eksToken returns a bearer token for an EKS cluster: a presigned STS GetCallerIdentity URL
+// with the cluster name in a signed header, that EKS calls to find out who the caller is
+func eksToken(ctx context.Context, cfg aws.Config, clusterName string) (string, error) {
+	presigner := sts.NewPresignClient(sts.NewFromConfig(cfg))
+	presigned, err := presigner.PresignGetCallerIdentity(ctx, &sts.GetCallerIdentityInput{}, func(o *sts.PresignOptions) {
+		o.ClientOptions = append(o.ClientOptions, sts.WithAPIOptions(
+			smithyhttp.AddHeaderValue(eksClusterIDHeader, clusterName),
+			smithyhttp.AddHeaderValue("X-Amz-Expires", "60"),
+		))
+	})
+	if err != nil {
+		return "", err
+	}
+	return eksTokenPrefix + base64.RawURLEncoding.EncodeToString([]byte(presigned.URL)), nil
+}
@@ -0,0 +1,154 @@
+package k8s
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAWS serves the EKS DescribeCluster and STS AssumeRole(WithWebIdentity) calls, recording the requests.
+// The AWS config of the environment is ignored, and the SDK does not look for EC2 instance credentials
+func fakeAWS(t *testing.T) *[]*http.Request {
+	t.Helper()
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		requests = append(requests, r)
+		credentials := func(action, prefix string) {
+			expiration := time.Now().Add(10 * time.Minute).UTC().Format(time.RFC3339)
+			w.Header().Set("Content-Type", "text/xml")
+			fmt.Fprintf(w, `<%sResponse><%sResult><Credentials><AccessKeyId>%sAKID</AccessKeyId><SecretAccessKey>secret</SecretAccessKey>`+
+				`<SessionToken>%s-token</SessionToken><Expiration>%s</Expiration></Credentials></%sResult></%sResponse>`,
+				action, action, prefix, prefix, expiration, action, action)
+		}
+		switch {
+		case r.URL.Path == "/clusters/prod":
+			fmt.Fprint(w, `{"cluster":{"name":"prod","endpoint":"https://prod.eks.example.com","status":"ACTIVE","certificateAuthority":{"data":"dGVzdA=="}}}`)
+		case r.Form.Get("Action") == "AssumeRole":
+			credentials("AssumeRole", "ROLE")
+		case r.Form.Get("Action") == "AssumeRoleWithWebIdentity":
+			credentials("AssumeRoleWithWebIdentity", "IRSA")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"No cluster found for name: `+strings.TrimPrefix(r.URL.Path, "/clusters/")+`."}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", "")
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "")
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", "")
+
+	origEndpoint := awsBaseEndpoint
+	awsBaseEndpoint = server.URL
+	t.Cleanup(func() { awsBaseEndpoint = origEndpoint })
+	return &requests
+}
+
+// decodeEKSToken returns the presigned STS URL of an EKS token
+func decodeEKSToken(t *testing.T, token string) *url.URL {
+	t.Helper()
+	require.True(t, strings.HasPrefix(token, eksTokenPrefix))
+	presigned, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(token, eksTokenPrefix))
+	require.NoError(t, err)
+	u, err := url.Parse(string(presigned))
+	require.NoError(t, err)
+	return u
+}
+
+func TestRestConfigForEKS(t *testing.T) {
+	requests := fakeAWS(t)
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+
+	restConfig, tokenExpires, err := restConfigFor(context.Background(), ClusterConfig{Name: "prod", Type: "eks", Region: "eu-west-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://prod.eks.example.com", restConfig.Host)
+	assert.Equal(t, []byte("test"), restConfig.CAData)
+	assert.WithinDuration(t, time.Now().Add(14*time.Minute), time.Unix(tokenExpires, 0), 2*time.Second)
+
+	require.Len(t, *requests, 1)
+	assert.Contains(t, (*requests)[0].Header.Get("Authorization"), "Credential=AKIDEXAMPLE/")
+	assert.Contains(t, (*requests)[0].Header.Get("Authorization"), "/eu-west-1/eks/aws4_request, SignedHeaders=")
+
+	presigned := decodeEKSToken(t, restConfig.BearerToken)
+	query := presigned.Query()
+	assert.Equal(t, "GetCallerIdentity", query.Get("Action"))
+	assert.Equal(t, "60", query.Get("X-Amz-Expires"))
+	assert.Contains(t, strings.Split(query.Get("X-Amz-SignedHeaders"), ";"), "x-k8s-aws-id")
+	assert.True(t, strings.HasPrefix(query.Get("X-Amz-Credential"), "AKIDEXAMPLE/"))
+	assert.True(t, strings.HasSuffix(query.Get("X-Amz-Credential"), "/eu-west-1/sts/aws4_request"))
+	assert.Empty(t, query.Get("X-Amz-Security-Token"))
+	assert.Len(t, query.Get("X-Amz-Signature"), 64)
+}
+
+func TestRestConfigForEKS_AssumedRoles(t *testing.T) {
+	requests := fakeAWS(t)
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("web-identity-token"), 0o600))
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", tokenFile)
+	t.Setenv("AWS_ROLE_ARN", "arn:aws:iam::111111111111:role/kube-jit-api")
+
+	restConfig, tokenExpires, err := restConfigFor(context.Background(), ClusterConfig{
+		Name:    "prod",
+		Type:    "eks",
+		Region:  "eu-west-1",
+		RoleARN: "arn:aws:iam::222222222222:role/kube-jit",
+	})
+	require.NoError(t, err)
+
+	// IRSA credentials assume the role of the cluster, which signs the calls to EKS
+	require.Len(t, *requests, 3)
+	webIdentity := (*requests)[0].Form
+	assert.Equal(t, "AssumeRoleWithWebIdentity", webIdentity.Get("Action"))
+	assert.Equal(t, "web-identity-token", webIdentity.Get("WebIdentityToken"))
+	assert.Equal(t, "arn:aws:iam::111111111111:role/kube-jit-api", webIdentity.Get("RoleArn"))
+	assert.Empty(t, (*requests)[0].Header.Get("Authorization"))
+	assert.Equal(t, "arn:aws:iam::222222222222:role/kube-jit", (*requests)[1].Form.Get("RoleArn"))
+	assert.Equal(t, "kube-jit-api", (*requests)[1].Form.Get("RoleSessionName"))
+	assert.Contains(t, (*requests)[1].Header.Get("Authorization"), "Credential=IRSAAKID/")
+	assert.Equal(t, "IRSA-token", (*requests)[1].Header.Get("X-Amz-Security-Token"))
+	assert.Contains(t, (*requests)[2].Header.Get("Authorization"), "Credential=ROLEAKID/")
+	assert.Equal(t, "ROLE-token", (*requests)[2].Header.Get("X-Amz-Security-Token"))
+
+	query := decodeEKSToken(t, restConfig.BearerToken).Query()
+	assert.True(t, strings.HasPrefix(query.Get("X-Amz-Credential"), "ROLEAKID/"))
+	assert.Equal(t, "ROLE-token", query.Get("X-Amz-Security-Token"))
+	// The token is refreshed before the assumed credentials expire
+	assert.WithinDuration(t, time.Now().Add(9*time.Minute), time.Unix(tokenExpires, 0), 2*time.Second)
+}
+
+func TestRestConfigForEKS_Errors(t *testing.T) {
+	fakeAWS(t)
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+
+	_, _, err := restConfigFor(context.Background(), ClusterConfig{Name: "prod", Type: "eks", Region: "eu-west-1"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to get AWS credentials")
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	_, _, err = restConfigFor(context.Background(), ClusterConfig{Name: "missing", Type: "eks", Region: "eu-west-1"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to get EKS cluster details: GET")
+	assert.Contains(t, err.Error(), `returned 404: {"message":"No cluster found for name: missing."}`)
+}
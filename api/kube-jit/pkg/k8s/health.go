@@ -0,0 +1,107 @@
+package k8s
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrClusterUnavailable is returned for a cluster whose client could not be created, until its next retry
+var ErrClusterUnavailable = errors.New("cluster unavailable")
+
+// Backoff between retries of a cluster whose client could not be created, doubled on every failure
+var (
+	clusterRetryBackoff    = 10 * time.Second
+	clusterMaxRetryBackoff = 5 * time.Minute
+)
+
+// ClusterHealth reports whether a client can be created for a cluster
+type ClusterHealth struct {
+	Name    string     `json:"name" example:"prod"`
+	Healthy bool       `json:"healthy" example:"false"`
+	Error   string     `json:"error,omitempty" example:"failed to get GKE cluster details: permission denied"`
+	RetryAt *time.Time `json:"retryAt,omitempty"` // when the client is created again, for unhealthy clusters
+}
+
+// clusterFailure is the last failure to create the client of a cluster
+type clusterFailure struct {
+	failures int
+	err      error
+	retryAt  time.Time
+}
+
+var clusterFailures = struct {
+	sync.Mutex
+	byCluster map[string]*clusterFailure
+}{byCluster: make(map[string]*clusterFailure)}
+
+// clusterUnavailable returns ErrClusterUnavailable while a failed cluster waits for its retry, and nil once it can be retried
+func clusterUnavailable(clusterName string) error {
+	clusterFailures.Lock()
+	defer clusterFailures.Unlock()
+	failure, ok := clusterFailures.byCluster[clusterName]
+	if !ok || !time.Now().Before(failure.retryAt) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s, retrying at %s: %v", ErrClusterUnavailable, clusterName, failure.retryAt.Format(time.RFC3339), failure.err)
+}
+
+// recordClusterFailure marks a cluster unhealthy and schedules its retry with an exponential backoff
+func recordClusterFailure(clusterName string, err error) error {
+	clusterFailures.Lock()
+	defer clusterFailures.Unlock()
+	failure, ok := clusterFailures.byCluster[clusterName]
+	if !ok {
+		failure = &clusterFailure{}
+		clusterFailures.byCluster[clusterName] = failure
+	}
+	failure.failures++
+	failure.err = err
+	backoff := clusterRetryBackoff << min(failure.failures-1, 10)
+	backoff = min(backoff, clusterMaxRetryBackoff)
+	failure.retryAt = time.Now().Add(backoff)
+	logger.Error("Failed to create client for cluster, marking it unhealthy",
+		zap.String("cluster", clusterName),
+		zap.Int("failures", failure.failures),
+		zap.Duration("retryIn", backoff),
+		zap.Error(err),
+	)
+	return fmt.Errorf("%w: %s: %v", ErrClusterUnavailable, clusterName, err)
+}
+
+// recordClusterSuccess marks a cluster healthy
+func recordClusterSuccess(clusterName string) {
+	clusterFailures.Lock()
+	defer clusterFailures.Unlock()
+	if _, ok := clusterFailures.byCluster[clusterName]; ok {
+		logger.Info("Created client for cluster, marking it healthy", zap.String("cluster", clusterName))
+		delete(clusterFailures.byCluster, clusterName)
+	}
+}
+
+// forgetClusterFailure marks a cluster healthy without logging, when its configuration changed
+func forgetClusterFailure(clusterName string) {
+	clusterFailures.Lock()
+	defer clusterFailures.Unlock()
+	delete(clusterFailures.byCluster, clusterName)
+}
+
+// ClustersHealth returns the health of the given clusters, in the same order
+func ClustersHealth(clusterNames []string) []ClusterHealth {
+	clusterFailures.Lock()
+	defer clusterFailures.Unlock()
+	health := make([]ClusterHealth, len(clusterNames))
+	for i, name := range clusterNames {
+		health[i] = ClusterHealth{Name: name, Healthy: true}
+		if failure, ok := clusterFailures.byCluster[name]; ok {
+			retryAt := failure.retryAt
+			health[i].Healthy = false
+			health[i].Error = failure.err.Error()
+			health[i].RetryAt = &retryAt
+		}
+	}
+	return health
+}
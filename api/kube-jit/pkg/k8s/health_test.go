@@ -0,0 +1,87 @@
+package k8s
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"kube-jit/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/rest"
+)
+
+func TestCreateDynamicClient_UnhealthyCluster(t *testing.T) {
+	dynamicClientCache = sync.Map{}
+	t.Cleanup(func() { forgetClusterFailure("broken-cluster") })
+	original := SetSettings(&Settings{
+		ClusterNames: []string{"broken-cluster", "other-cluster"},
+		ClusterConfigs: map[string]ClusterConfig{
+			"broken-cluster": {Name: "broken-cluster", Type: "generic", Host: "https://fake", CA: "not base64!"},
+			"other-cluster":  {Name: "other-cluster", Type: "generic", Host: "https://fake"},
+		},
+	})
+	defer SetSettings(original)
+	origDynamicNewForConfig := dynamicNewForConfig
+	dynamicNewForConfig = func(*rest.Config) (dynamic.Interface, error) {
+		return fake.NewSimpleDynamicClient(runtime.NewScheme()), nil
+	}
+	defer func() { dynamicNewForConfig = origDynamicNewForConfig }()
+
+	// A client error marks the cluster unhealthy instead of exiting
+	_, err := createDynamicClient(models.RequestData{ClusterName: "broken-cluster"})
+	require.ErrorIs(t, err, ErrClusterUnavailable)
+	assert.Contains(t, err.Error(), "failed to decode CA certificate")
+
+	health := ClustersHealth([]string{"broken-cluster", "other-cluster"})
+	assert.False(t, health[0].Healthy)
+	assert.Contains(t, health[0].Error, "failed to decode CA certificate")
+	require.NotNil(t, health[0].RetryAt)
+	firstRetry := *health[0].RetryAt
+	assert.WithinDuration(t, time.Now().Add(clusterRetryBackoff), firstRetry, time.Second)
+	assert.Equal(t, ClusterHealth{Name: "other-cluster", Healthy: true}, health[1])
+
+	// Other clusters are not affected
+	client, err := createDynamicClient(models.RequestData{ClusterName: "other-cluster"})
+	require.NoError(t, err)
+	assert.NotNil(t, client)
+
+	// Until its retry the cluster is not tried again
+	_, err = createDynamicClient(models.RequestData{ClusterName: "broken-cluster"})
+	require.ErrorIs(t, err, ErrClusterUnavailable)
+	assert.Contains(t, err.Error(), "retrying at")
+	assert.Equal(t, firstRetry, *ClustersHealth([]string{"broken-cluster"})[0].RetryAt)
+
+	// A failed retry doubles the backoff
+	clusterFailures.Lock()
+	clusterFailures.byCluster["broken-cluster"].retryAt = time.Now()
+	clusterFailures.Unlock()
+	_, err = createDynamicClient(models.RequestData{ClusterName: "broken-cluster"})
+	require.ErrorIs(t, err, ErrClusterUnavailable)
+	assert.WithinDuration(t, time.Now().Add(2*clusterRetryBackoff), *ClustersHealth([]string{"broken-cluster"})[0].RetryAt, time.Second)
+
+	// Fixing the configuration creates its client without waiting for the retry
+	runClusterInitAsync = false
+	defer func() { runClusterInitAsync = true }()
+	applySettings(&Settings{
+		ClusterNames: []string{"broken-cluster"},
+		ClusterConfigs: map[string]ClusterConfig{
+			"broken-cluster": {Name: "broken-cluster", Type: "generic", Host: "https://fake"},
+		},
+	})
+	assert.True(t, ClustersHealth([]string{"broken-cluster"})[0].Healthy)
+	_, cached := dynamicClientCache.Load("broken-cluster")
+	assert.True(t, cached)
+}
+
+func TestCreateDynamicClient_UnknownCluster(t *testing.T) {
+	_, err := createDynamicClient(models.RequestData{ClusterName: "nonexistent"})
+	require.Error(t, err)
+	assert.False(t, errors.Is(err, ErrClusterUnavailable))
+	assert.True(t, ClustersHealth([]string{"nonexistent"})[0].Healthy)
+}
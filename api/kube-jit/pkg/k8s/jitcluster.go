@@ -0,0 +1,271 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+	yaml "gopkg.in/yaml.v3"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+// jitClusterResource is the JitCluster custom resource of the API chart, registering a cluster without editing apiConfig.yaml
+var jitClusterResource = schema.GroupVersionResource{Group: "jit.kubejit.io", Version: "v1", Resource: "jitclusters"}
+
+// jitClusterResync is how often every JitCluster is evaluated again, so its status follows changes of apiConfig.yaml
+var jitClusterResync = 5 * time.Minute
+
+// jitClusterAccepted is the condition reporting whether the API uses a JitCluster
+const jitClusterAccepted = "Accepted"
+
+// jitClusterUnsupportedFields are the fields of ClusterConfig a JitCluster cannot set: its name is the name of the resource,
+// and credentials come from secrets of the API's namespace rather than inline or from the API's file system
+var jitClusterUnsupportedFields = []string{"name", "token", "kubeconfigFile"}
+
+// registeredClusters are the valid JitClusters by name, guarded by settingsUpdate. They are added to the settings by applySettings
+var registeredClusters = make(map[string]ClusterConfig)
+
+// jitClusterStatus is the status of a JitCluster written by the API
+type jitClusterStatus struct {
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// WatchJitClusters registers the clusters of the JitCluster resources of the API's namespace until ctx is done,
+// so clusters are added, changed and removed without a restart. The outcome is reported in their Accepted condition
+func WatchJitClusters(ctx context.Context) {
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(localDynamicClient, jitClusterResync, apiNamespace, nil)
+	informer := factory.ForResource(jitClusterResource).Informer()
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj any) {
+			if jitCluster, ok := obj.(*unstructured.Unstructured); ok {
+				registerJitCluster(ctx, jitCluster)
+			}
+		},
+		UpdateFunc: func(_, obj any) {
+			if jitCluster, ok := obj.(*unstructured.Unstructured); ok {
+				registerJitCluster(ctx, jitCluster)
+			}
+		},
+		DeleteFunc: func(obj any) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if jitCluster, ok := obj.(*unstructured.Unstructured); ok {
+				unregisterJitCluster(jitCluster.GetName())
+			}
+		},
+	})
+	if err != nil {
+		logger.Error("Failed to watch JitClusters, only the clusters of the config file are used", zap.Error(err))
+		return
+	}
+	logger.Info("Watching JitClusters", zap.String("namespace", apiNamespace))
+	factory.Start(ctx.Done())
+	<-ctx.Done()
+	factory.Shutdown()
+}
+
+// registerJitCluster validates a JitCluster, checks it against the jitClusters policy of the API config,
+// reads its secrets and swaps it in when it changed. An invalid JitCluster keeps its previous valid spec in use,
+// like an invalid config file, while a JitCluster the policy does not allow is dropped
+func registerJitCluster(ctx context.Context, jitCluster *unstructured.Unstructured) {
+	name := jitCluster.GetName()
+	cluster, err := jitClusterConfig(jitCluster)
+	var notAllowed ConfigErrors
+	if err == nil {
+		notAllowed, err = jitClusterAllowed(cluster, CurrentSettings().JitClusters)
+	}
+	if err == nil && notAllowed == nil {
+		err = readClusterSecrets(&cluster)
+	}
+
+	settingsUpdate.Lock()
+	accepted, reason, message := true, "Registered", "The cluster is in use"
+	switch {
+	case notAllowed != nil:
+		logger.Error("JitCluster not allowed", zap.String("cluster", name), zap.Error(notAllowed))
+		accepted, reason, message = false, "NotAllowed", notAllowed.Error()
+		// The policy or the labels of the secrets changed since its previous spec was registered
+		if _, ok := registeredClusters[name]; ok {
+			delete(registeredClusters, name)
+			applySettings(CurrentSettings())
+		}
+	case err != nil:
+		logger.Error("Invalid JitCluster", zap.String("cluster", name), zap.Error(err))
+		accepted, reason, message = false, "Invalid", err.Error()
+		if _, ok := registeredClusters[name]; ok {
+			message += ", its previous spec stays in use"
+		}
+	default:
+		if previous, ok := registeredClusters[name]; !ok || !reflect.DeepEqual(previous, cluster) {
+			logger.Info("JitCluster added or changed", zap.String("cluster", name))
+			registeredClusters[name] = cluster
+			applySettings(CurrentSettings())
+		}
+		if current := CurrentSettings().ClusterConfigs[name]; !current.Registered {
+			accepted, reason = false, "NameConflict"
+			message = fmt.Sprintf("cluster %s is defined in the config file, which takes precedence", name)
+		}
+	}
+	settingsUpdate.Unlock()
+
+	setJitClusterCondition(ctx, jitCluster, accepted, reason, message)
+}
+
+// unregisterJitCluster removes the cluster of a deleted JitCluster
+func unregisterJitCluster(name string) {
+	settingsUpdate.Lock()
+	defer settingsUpdate.Unlock()
+	if _, ok := registeredClusters[name]; !ok {
+		return
+	}
+	logger.Info("JitCluster deleted", zap.String("cluster", name))
+	delete(registeredClusters, name)
+	applySettings(CurrentSettings())
+}
+
+// jitClusterConfig decodes the spec of a JitCluster into the ClusterConfig of the cluster it registers and validates it
+// like a cluster of apiConfig.yaml. The CRD validates JitClusters on admission, this catches what its schema cannot check
+func jitClusterConfig(jitCluster *unstructured.Unstructured) (ClusterConfig, error) {
+	var cluster ClusterConfig
+	spec, _, err := unstructured.NestedMap(jitCluster.Object, "spec")
+	if err != nil {
+		return cluster, fmt.Errorf("invalid spec: %w", err)
+	}
+	// ClusterConfig has yaml tags only, the spec goes through yaml to use them
+	specYAML, err := yaml.Marshal(spec)
+	if err != nil {
+		return cluster, fmt.Errorf("invalid spec: %w", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(specYAML))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&cluster); err != nil {
+		return cluster, fmt.Errorf("invalid spec: %w", err)
+	}
+	cluster.Name, cluster.Registered = jitCluster.GetName(), true
+
+	// The lines of the spec encoded above mean nothing to the author of the JitCluster, errors are reported by field only
+	v := configValidator{root: &yaml.Node{}}
+	for _, field := range jitClusterUnsupportedFields {
+		if _, ok := spec[field]; ok {
+			v.addf([]any{"spec", field}, "%s is not supported by JitClusters", field)
+		}
+	}
+	v.validateCluster([]any{"spec"}, cluster)
+	if len(v.errs) > 0 {
+		return cluster, v.errs
+	}
+	return cluster, nil
+}
+
+// check returns the fields of the cluster of a JitCluster the policy does not allow, nil if it allows them all
+func (p JitClusterPolicy) check(cluster ClusterConfig) ConfigErrors {
+	v := configValidator{root: &yaml.Node{}}
+	if cluster.Insecure && !p.AllowInsecure {
+		v.addf([]any{"spec", "insecure"}, "insecure is not allowed by jitClusters.allowInsecure of the API config")
+	}
+	if cluster.TokenRequest != nil {
+		if !p.AllowTokenRequest {
+			v.addf([]any{"spec", "tokenRequest"}, "tokenRequest is not allowed by jitClusters.allowTokenRequest of the API config")
+		} else if !slices.Contains(p.ServiceAccounts, cluster.TokenRequest.ServiceAccount) {
+			v.addf([]any{"spec", "tokenRequest", "serviceAccount"}, "service account %s is not in jitClusters.serviceAccounts of the API config",
+				cluster.TokenRequest.ServiceAccount)
+		}
+	}
+	return v.errs
+}
+
+// jitClusterAllowed returns what the policy does not allow in the cluster of a JitCluster, with the secrets it refers to
+// that are not labelled ClusterCredentialsLabel, so a JitCluster cannot send the other secrets of the API's namespace to its host.
+// The error is a failure to read the secrets
+func jitClusterAllowed(cluster ClusterConfig, policy JitClusterPolicy) (ConfigErrors, error) {
+	notAllowed := policy.check(cluster)
+	v := configValidator{root: &yaml.Node{}}
+	for _, ref := range []struct{ field, secret string }{
+		{"tokenSecret", cluster.TokenSecret},
+		{"kubeconfigSecret", cluster.KubeconfigSecret},
+	} {
+		if ref.secret == "" {
+			continue
+		}
+		secret, err := localClientset.CoreV1().Secrets(apiNamespace).Get(context.TODO(), ref.secret, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("error getting %s %s: %w", ref.field, ref.secret, err)
+		}
+		if secret.Labels[ClusterCredentialsLabel] != "true" {
+			v.addf([]any{"spec", ref.field}, "secret %s is not labelled %s: \"true\"", ref.secret, ClusterCredentialsLabel)
+		}
+	}
+	return append(notAllowed, v.errs...), nil
+}
+
+// withRegisteredClusters returns a copy of s with the clusters of apiConfig.yaml followed by the registered JitClusters, sorted by name.
+// A cluster of the file takes precedence over a JitCluster of the same name
+func withRegisteredClusters(s *Settings) *Settings {
+	merged := *s
+	merged.ClusterNames = nil
+	merged.ClusterConfigs = make(map[string]ClusterConfig, len(s.ClusterConfigs)+len(registeredClusters))
+	for _, name := range s.ClusterNames {
+		if cluster := s.ClusterConfigs[name]; !cluster.Registered {
+			merged.ClusterNames = append(merged.ClusterNames, name)
+			merged.ClusterConfigs[name] = cluster
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(registeredClusters)) {
+		// A reloaded policy applies at once, the JitCluster reports it on its next resync
+		if notAllowed := s.JitClusters.check(registeredClusters[name]); notAllowed != nil {
+			logger.Warn("JitCluster not allowed by the config, skipping it", zap.String("cluster", name), zap.Error(notAllowed))
+			continue
+		}
+		if _, defined := merged.ClusterConfigs[name]; !defined {
+			merged.ClusterNames = append(merged.ClusterNames, name)
+			merged.ClusterConfigs[name] = registeredClusters[name]
+		}
+	}
+	return &merged
+}
+
+// setJitClusterCondition writes the Accepted condition of a JitCluster when it changed.
+// A failed write is only logged, the condition is written again on the next resync
+func setJitClusterCondition(ctx context.Context, jitCluster *unstructured.Unstructured, accepted bool, reason, message string) {
+	var status jitClusterStatus
+	if current, ok, _ := unstructured.NestedMap(jitCluster.Object, "status"); ok {
+		_ = runtime.DefaultUnstructuredConverter.FromUnstructured(current, &status)
+	}
+	condition := metav1.Condition{
+		Type:               jitClusterAccepted,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: jitCluster.GetGeneration(),
+	}
+	if accepted {
+		condition.Status = metav1.ConditionTrue
+	}
+	if !apimeta.SetStatusCondition(&status.Conditions, condition) {
+		return
+	}
+
+	statusObject, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&status)
+	if err != nil {
+		logger.Error("Failed to encode JitCluster status", zap.String("cluster", jitCluster.GetName()), zap.Error(err))
+		return
+	}
+	updated := jitCluster.DeepCopy() // objects of the informer's cache must not be modified
+	updated.Object["status"] = statusObject
+	_, err = localDynamicClient.Resource(jitClusterResource).Namespace(jitCluster.GetNamespace()).UpdateStatus(ctx, updated, metav1.UpdateOptions{})
+	if err != nil {
+		logger.Warn("Failed to update JitCluster status", zap.String("cluster", jitCluster.GetName()), zap.Error(err))
+	}
+}
@@ -0,0 +1,261 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+// newJitCluster returns a JitCluster of the API's namespace
+func newJitCluster(name string, spec map[string]any) *unstructured.Unstructured {
+	jitCluster := &unstructured.Unstructured{Object: map[string]any{"spec": spec}}
+	jitCluster.SetAPIVersion(jitGroupVersion)
+	jitCluster.SetKind("JitCluster")
+	jitCluster.SetName(name)
+	jitCluster.SetNamespace("default")
+	jitCluster.SetGeneration(1)
+	return jitCluster
+}
+
+// setupJitClusters injects a fake local dynamic client serving the JitClusters
+func setupJitClusters(t *testing.T, jitClusters ...runtime.Object) {
+	t.Helper()
+	localDynamicClient = dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{jitClusterResource: "JitClusterList"}, jitClusters...)
+	registeredClusters = make(map[string]ClusterConfig)
+	t.Cleanup(func() { registeredClusters = make(map[string]ClusterConfig) })
+}
+
+// acceptedCondition returns the Accepted condition the API wrote on a JitCluster
+func acceptedCondition(t *testing.T, name string) *metav1.Condition {
+	t.Helper()
+	jitCluster, err := localDynamicClient.Resource(jitClusterResource).Namespace("default").Get(context.Background(), name, metav1.GetOptions{})
+	require.NoError(t, err)
+	conditions, _, _ := unstructured.NestedSlice(jitCluster.Object, "status", "conditions")
+	for _, condition := range conditions {
+		var accepted metav1.Condition
+		require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(condition.(map[string]any), &accepted))
+		if accepted.Type == jitClusterAccepted {
+			return &accepted
+		}
+	}
+	return nil
+}
+
+func TestWatchJitClusters(t *testing.T) {
+	writeConfig := setupReload(t)
+	writeConfig("clusters:" + clusterYaml("cluster-a"))
+	_, err := ReloadConfig()
+	require.NoError(t, err)
+	// JitClusters only use the secrets labelled as cluster credentials
+	_, err = localClientset.CoreV1().Secrets("default").Create(context.Background(), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "jit-secret", Namespace: "default", Labels: map[string]string{ClusterCredentialsLabel: "true"}},
+		Data:       map[string][]byte{"token": []byte("jit-token")},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	genericSpec := map[string]any{
+		"type":        "generic",
+		"host":        "https://fake",
+		"tokenSecret": "jit-secret",
+		"labels":      map[string]any{"env": "prod"},
+	}
+	setupJitClusters(t,
+		newJitCluster("cluster-b", genericSpec),
+		newJitCluster("cluster-a", genericSpec),
+		newJitCluster("broken", map[string]any{"type": "generic", "token": "inline", "tokenSecret": "jit-secret"}),
+		newJitCluster("unlabelled", map[string]any{"type": "generic", "host": "https://fake", "tokenSecret": "test-secret"}),
+	)
+	// The watch is stopped and waited for, so its handlers do not run into the settings of later tests
+	ctx, cancel := context.WithCancel(context.Background())
+	watchDone := make(chan struct{})
+	go func() {
+		defer close(watchDone)
+		WatchJitClusters(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-watchDone
+	})
+
+	// Valid JitClusters are added after the clusters of the file, which win a name conflict
+	assert.Eventually(t, func() bool {
+		return len(CurrentSettings().ClusterNames) == 2 && acceptedCondition(t, "broken") != nil && acceptedCondition(t, "cluster-a") != nil &&
+			acceptedCondition(t, "unlabelled") != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"cluster-a", "cluster-b"}, CurrentSettings().ClusterNames)
+	clusterB := CurrentSettings().ClusterConfigs["cluster-b"]
+	assert.True(t, clusterB.Registered)
+	assert.Equal(t, "jit-token", clusterB.Token)
+	assert.Equal(t, map[string]string{"env": "prod"}, clusterB.Labels)
+	assert.False(t, CurrentSettings().ClusterConfigs["cluster-a"].Registered)
+	_, cached := dynamicClientCache.Load("cluster-b")
+	assert.True(t, cached)
+
+	condition := acceptedCondition(t, "cluster-b")
+	require.NotNil(t, condition)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status)
+	assert.Equal(t, int64(1), condition.ObservedGeneration)
+	condition = acceptedCondition(t, "cluster-a")
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, "NameConflict", condition.Reason)
+	condition = acceptedCondition(t, "broken")
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, "Invalid", condition.Reason)
+	assert.Equal(t, "spec.token: token is not supported by JitClusters; spec: host is required", condition.Message)
+	condition = acceptedCondition(t, "unlabelled")
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, "NotAllowed", condition.Reason)
+	assert.Equal(t, `spec.tokenSecret: secret test-secret is not labelled jit.kubejit.io/cluster-credentials: "true"`, condition.Message)
+
+	// The JitCluster is used once the file no longer defines the cluster
+	writeConfig("clusters: []")
+	_, err = ReloadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cluster-a", "cluster-b"}, CurrentSettings().ClusterNames)
+	assert.True(t, CurrentSettings().ClusterConfigs["cluster-a"].Registered)
+
+	// Deleting a JitCluster removes its cluster
+	err = localDynamicClient.Resource(jitClusterResource).Namespace("default").Delete(ctx, "cluster-b", metav1.DeleteOptions{})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return len(CurrentSettings().ClusterNames) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"cluster-a"}, CurrentSettings().ClusterNames)
+	_, cached = dynamicClientCache.Load("cluster-b")
+	assert.False(t, cached)
+}
+
+func TestJitClusterConfig(t *testing.T) {
+	testCases := []struct {
+		name     string
+		spec     map[string]any
+		expected string
+	}{
+		{
+			name: "valid",
+			spec: map[string]any{"type": "eks", "region": "eu-west-1", "labels": map[string]any{"env": "prod", "region": "eu-west-1"}},
+		},
+		{
+			name:     "unsupported fields",
+			spec:     map[string]any{"type": "kubeconfig", "kubeconfigFile": "/etc/passwd", "name": "other"},
+			expected: "spec.name: name is not supported by JitClusters; spec.kubeconfigFile: kubeconfigFile is not supported by JitClusters",
+		},
+		{
+			name:     "unknown field",
+			spec:     map[string]any{"type": "gke", "projectId": "my-project"},
+			expected: "invalid spec: yaml: unmarshal errors:\n  line 1: field projectId not found in type k8s.ClusterConfig",
+		},
+		{
+			name:     "invalid label",
+			spec:     map[string]any{"type": "eks", "region": "eu-west-1", "labels": map[string]any{"env": "prod/eu"}},
+			expected: `spec.labels.env: invalid label value "prod/eu": a valid label must be an empty string or consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character (e.g. 'MyValue',  or 'my_value',  or '12345', regex used for validation is '(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])?')`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cluster, err := jitClusterConfig(newJitCluster("prod", tc.spec))
+			if tc.expected == "" {
+				require.NoError(t, err)
+				assert.Equal(t, "prod", cluster.Name)
+				assert.True(t, cluster.Registered)
+				return
+			}
+			assert.EqualError(t, err, tc.expected)
+		})
+	}
+}
+
+func TestJitClusterPolicy(t *testing.T) {
+	tokenRequest := &TokenRequestConfig{ServiceAccount: "kube-jit-staging"}
+	testCases := []struct {
+		name     string
+		policy   JitClusterPolicy
+		cluster  ClusterConfig
+		expected string
+	}{
+		{
+			name:    "secret only",
+			cluster: ClusterConfig{Type: "generic", TokenSecret: "jit-secret"},
+		},
+		{
+			name:     "insecure not allowed",
+			cluster:  ClusterConfig{Type: "generic", Insecure: true, TokenSecret: "jit-secret"},
+			expected: "spec.insecure: insecure is not allowed by jitClusters.allowInsecure of the API config",
+		},
+		{
+			name:    "insecure allowed",
+			policy:  JitClusterPolicy{AllowInsecure: true},
+			cluster: ClusterConfig{Type: "generic", Insecure: true, TokenSecret: "jit-secret"},
+		},
+		{
+			name:     "tokenRequest not allowed",
+			policy:   JitClusterPolicy{ServiceAccounts: []string{"kube-jit-staging"}},
+			cluster:  ClusterConfig{Type: "generic", TokenRequest: tokenRequest},
+			expected: "spec.tokenRequest: tokenRequest is not allowed by jitClusters.allowTokenRequest of the API config",
+		},
+		{
+			name:     "service account not allowed",
+			policy:   JitClusterPolicy{AllowTokenRequest: true, ServiceAccounts: []string{"kube-jit-prod"}},
+			cluster:  ClusterConfig{Type: "generic", TokenRequest: tokenRequest},
+			expected: "spec.tokenRequest.serviceAccount: service account kube-jit-staging is not in jitClusters.serviceAccounts of the API config",
+		},
+		{
+			name:    "service account allowed",
+			policy:  JitClusterPolicy{AllowTokenRequest: true, ServiceAccounts: []string{"kube-jit-staging"}},
+			cluster: ClusterConfig{Type: "generic", TokenRequest: tokenRequest},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			notAllowed := tc.policy.check(tc.cluster)
+			if tc.expected == "" {
+				assert.Nil(t, notAllowed)
+				return
+			}
+			assert.EqualError(t, notAllowed, tc.expected)
+		})
+	}
+}
+
+func TestRegisterJitCluster_NotAllowed(t *testing.T) {
+	writeConfig := setupReload(t)
+	writeConfig("jitClusters:\n  allowInsecure: true\n")
+	_, err := ReloadConfig()
+	require.NoError(t, err)
+	_, err = localClientset.CoreV1().Secrets("default").Create(context.Background(), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "jit-secret", Namespace: "default", Labels: map[string]string{ClusterCredentialsLabel: "true"}},
+		Data:       map[string][]byte{"token": []byte("jit-token")},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	jitCluster := newJitCluster("staging", map[string]any{"type": "generic", "host": "https://fake", "insecure": true, "tokenSecret": "jit-secret"})
+	setupJitClusters(t, jitCluster)
+	registerJitCluster(context.Background(), jitCluster)
+	assert.Equal(t, []string{"staging"}, CurrentSettings().ClusterNames)
+
+	// A reloaded policy drops the cluster at once
+	writeConfig("jitClusters: {}\n")
+	_, err = ReloadConfig()
+	require.NoError(t, err)
+	assert.Empty(t, CurrentSettings().ClusterNames)
+
+	// and its registration on the next resync, rather than keeping its previous spec
+	registerJitCluster(context.Background(), jitCluster)
+	assert.Empty(t, registeredClusters)
+	condition := acceptedCondition(t, "staging")
+	require.NotNil(t, condition)
+	assert.Equal(t, "NotAllowed", condition.Reason)
+	assert.Equal(t, "spec.insecure: insecure is not allowed by jitClusters.allowInsecure of the API config", condition.Message)
+}
@@ -49,9 +49,11 @@ var GetJitGroups = func(clusterName string) (*unstructured.Unstructured, error)
 	jitGroups, err := fetchJitGroupsFromCluster(clusterName)
 	if err != nil {
 		logger.Error("Error fetching JitGroups for cluster", zap.String("cluster", clusterName), zap.Error(err))
-		return nil, fmt.Errorf("failed to fetch JitGroups for cluster %s", clusterName)
+		return nil, err
 	}
 
+	recordJitGroups(clusterName, jitGroups)
+
 	// Cache the JitGroups with a 10-minute expiration
 	expiration := time.Now().Add(10 * time.Minute).Unix()
 	jitGroupsCache.Store(clusterName, &JitGroupsCache{
@@ -66,7 +68,10 @@ var GetJitGroups = func(clusterName string) (*unstructured.Unstructured, error)
 // It uses the dynamic client to query the JitGroups CRD
 // It returns the JitGroups object or an error if fetching fails
 func fetchJitGroupsFromCluster(clusterName string) (*unstructured.Unstructured, error) {
-	dynamicClient := createDynamicClient(models.RequestData{ClusterName: clusterName})
+	dynamicClient, err := createDynamicClient(models.RequestData{ClusterName: clusterName})
+	if err != nil {
+		return nil, err
+	}
 
 	// Query the JitGroups CRD
 	jitGroups, err := dynamicClient.Resource(schema.GroupVersionResource{
@@ -94,7 +99,7 @@ var InvalidateJitGroupsCache = func(clusterName string) {
 var ValidateNamespaces = func(clusterName string, namespaces []string) (map[string]struct{ GroupID, GroupName string }, error) {
 	jitGroups, err := GetJitGroups(clusterName)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching JitGroups: %v", err)
+		return nil, fmt.Errorf("error fetching JitGroups: %w", err)
 	}
 
 	namespaceAnnotations := make(map[string]struct{ GroupID, GroupName string })
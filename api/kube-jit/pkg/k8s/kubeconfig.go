@@ -0,0 +1,147 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// execPluginTimeout is the time an exec credential plugin gets to return credentials
+var execPluginTimeout = 30 * time.Second
+
+// restConfigForKubeconfig returns the rest config of a context of a kubeconfig, from a secret or a mounted file, and when its credentials expire.
+// Client certificates and tokens are used as they are; an exec credential plugin is run here rather than by client-go,
+// so the client is recreated when the credentials it returned expire. Only the plugins of execAllowedCommands are run
+func restConfigForKubeconfig(ctx context.Context, cluster ClusterConfig) (*rest.Config, int64, error) {
+	var config *clientcmdapi.Config
+	var err error
+	if cluster.KubeconfigFile != "" {
+		// Read on every refresh, so a rotated mount is picked up. Relative paths are resolved against the file
+		config, err = clientcmd.LoadFromFile(cluster.KubeconfigFile)
+		if err == nil {
+			err = clientcmd.ResolveLocalPaths(config)
+		}
+	} else {
+		config, err = clientcmd.Load(cluster.Kubeconfig)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to parse kubeconfig: %w", err)
+	}
+	if err := validateKubeconfigExec(config); err != nil {
+		return nil, 0, err
+	}
+	restConfig, err := clientcmd.NewNonInteractiveClientConfig(*config, cluster.Context, &clientcmd.ConfigOverrides{}, nil).ClientConfig()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to load kubeconfig context: %w", err)
+	}
+
+	// Credentials without an expiry, like generic clusters
+	tokenExpires := time.Now().Add(24 * time.Hour).Unix()
+	if restConfig.ExecProvider != nil {
+		ctx, cancel := context.WithTimeout(ctx, execPluginTimeout)
+		defer cancel()
+		credentials, err := runExecPlugin(ctx, restConfig)
+		if err != nil {
+			return nil, 0, err
+		}
+		restConfig.ExecProvider = nil
+		restConfig.BearerToken = credentials.Token
+		if credentials.ClientCertificateData != "" {
+			restConfig.CertData = []byte(credentials.ClientCertificateData)
+			restConfig.KeyData = []byte(credentials.ClientKeyData)
+		}
+		if credentials.ExpirationTimestamp != nil {
+			// Refresh the credentials a minute before they expire
+			tokenExpires = credentials.ExpirationTimestamp.Add(-time.Minute).Unix()
+		}
+	}
+	return restConfig, tokenExpires, nil
+}
+
+// execCredential is the ExecCredential object exchanged with exec credential plugins,
+// the fields used here are the same in client.authentication.k8s.io/v1 and v1beta1
+type execCredential struct {
+	APIVersion string                `json:"apiVersion"`
+	Kind       string                `json:"kind"`
+	Spec       execCredentialSpec    `json:"spec"`
+	Status     *execCredentialStatus `json:"status,omitempty"`
+}
+
+type execCredentialSpec struct {
+	Interactive bool         `json:"interactive"`
+	Cluster     *execCluster `json:"cluster,omitempty"` // only with provideClusterInfo
+}
+
+// execCluster is the cluster the plugin returns credentials for
+type execCluster struct {
+	Server                   string `json:"server"`
+	TLSServerName            string `json:"tls-server-name,omitempty"`
+	InsecureSkipTLSVerify    bool   `json:"insecure-skip-tls-verify,omitempty"`
+	CertificateAuthorityData []byte `json:"certificate-authority-data,omitempty"`
+}
+
+type execCredentialStatus struct {
+	ExpirationTimestamp   *time.Time `json:"expirationTimestamp,omitempty"`
+	Token                 string     `json:"token,omitempty"`
+	ClientCertificateData string     `json:"clientCertificateData,omitempty"`
+	ClientKeyData         string     `json:"clientKeyData,omitempty"`
+}
+
+// runExecPlugin runs the exec credential plugin of restConfig non-interactively and returns the credentials it printed
+func runExecPlugin(ctx context.Context, restConfig *rest.Config) (*execCredentialStatus, error) {
+	execConfig := restConfig.ExecProvider
+	if execConfig.InteractiveMode == clientcmdapi.AlwaysExecInteractiveMode {
+		return nil, fmt.Errorf("exec plugin %s requires an interactive terminal", execConfig.Command)
+	}
+
+	input := execCredential{APIVersion: execConfig.APIVersion, Kind: "ExecCredential"}
+	if execConfig.ProvideClusterInfo {
+		input.Spec.Cluster = &execCluster{
+			Server:                   restConfig.Host,
+			TLSServerName:            restConfig.ServerName,
+			InsecureSkipTLSVerify:    restConfig.Insecure,
+			CertificateAuthorityData: restConfig.CAData,
+		}
+	}
+	inputJSON, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, execConfig.Command, execConfig.Args...)
+	cmd.Env = append(os.Environ(), "KUBERNETES_EXEC_INFO="+string(inputJSON))
+	for _, env := range execConfig.Env {
+		cmd.Env = append(cmd.Env, env.Name+"="+env.Value)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	logger.Debug("Running exec credential plugin", zap.String("command", execConfig.Command))
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("exec plugin %s failed: %w: %s", execConfig.Command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var output execCredential
+	if err := json.Unmarshal(stdout.Bytes(), &output); err != nil {
+		return nil, fmt.Errorf("exec plugin %s returned an invalid ExecCredential: %w", execConfig.Command, err)
+	}
+	if output.APIVersion != execConfig.APIVersion {
+		return nil, fmt.Errorf("exec plugin %s returned apiVersion %q, expected %q", execConfig.Command, output.APIVersion, execConfig.APIVersion)
+	}
+	if output.Status == nil || (output.Status.Token == "" && output.Status.ClientCertificateData == "") {
+		return nil, fmt.Errorf("exec plugin %s returned no credentials", execConfig.Command)
+	}
+	if output.Status.ClientCertificateData != "" && output.Status.ClientKeyData == "" {
+		return nil, fmt.Errorf("exec plugin %s returned a client certificate without its key", execConfig.Command)
+	}
+	return output.Status, nil
+}
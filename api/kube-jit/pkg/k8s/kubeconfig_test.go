@@ -0,0 +1,233 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// allowExecCommands adds commands to execAllowedCommands for the duration of the test
+func allowExecCommands(t *testing.T, commands ...string) {
+	t.Helper()
+	original := execAllowedCommands
+	execAllowedCommands = append(slices.Clone(original), commands...)
+	t.Cleanup(func() { execAllowedCommands = original })
+}
+
+// execKubeconfig returns a kubeconfig whose user runs the exec plugin script, which is allowed
+func execKubeconfig(t *testing.T, script string, interactiveMode string) []byte {
+	t.Helper()
+	plugin := filepath.Join(t.TempDir(), "plugin.sh")
+	require.NoError(t, os.WriteFile(plugin, []byte("#!/bin/sh\n"+script), 0o755))
+	allowExecCommands(t, plugin)
+	return []byte(`apiVersion: v1
+kind: Config
+current-context: prod
+clusters:
+  - name: prod
+    cluster:
+      server: https://prod.example.com
+      certificate-authority-data: dGVzdA==
+contexts:
+  - name: prod
+    context:
+      cluster: prod
+      user: plugin
+users:
+  - name: plugin
+    user:
+      exec:
+        apiVersion: client.authentication.k8s.io/v1
+        command: ` + plugin + `
+        args: ["--cluster", "prod"]
+        env:
+          - name: PLUGIN_ENV
+            value: from-kubeconfig
+        provideClusterInfo: true
+        interactiveMode: ` + interactiveMode + `
+`)
+}
+
+func TestRestConfigForKubeconfig_ExecPlugin(t *testing.T) {
+	expiration := time.Now().Add(10 * time.Minute).UTC().Truncate(time.Second)
+	infoFile := filepath.Join(t.TempDir(), "exec-info.json")
+	kubeconfig := execKubeconfig(t, `echo "$KUBERNETES_EXEC_INFO" > `+infoFile+`
+echo "{\"apiVersion\":\"client.authentication.k8s.io/v1\",\"kind\":\"ExecCredential\",\"status\":{\"token\":\"exec-token-$2-$PLUGIN_ENV\",\"expirationTimestamp\":\"`+expiration.Format(time.RFC3339)+`\"}}"
+`, "Never")
+
+	restConfig, tokenExpires, err := restConfigFor(context.Background(), ClusterConfig{Name: "prod", Type: "kubeconfig", Kubeconfig: kubeconfig})
+	require.NoError(t, err)
+	assert.Equal(t, "https://prod.example.com", restConfig.Host)
+	assert.Equal(t, []byte("test"), restConfig.CAData)
+	assert.Nil(t, restConfig.ExecProvider, "the plugin is not run again by client-go")
+	assert.Equal(t, "exec-token-prod-from-kubeconfig", restConfig.BearerToken)
+	// The client is recreated a minute before the credentials expire
+	assert.Equal(t, expiration.Add(-time.Minute).Unix(), tokenExpires)
+
+	// The plugin gets the cluster it returns credentials for
+	info, err := os.ReadFile(infoFile)
+	require.NoError(t, err)
+	var input execCredential
+	require.NoError(t, json.Unmarshal(info, &input))
+	assert.Equal(t, "client.authentication.k8s.io/v1", input.APIVersion)
+	assert.False(t, input.Spec.Interactive)
+	require.NotNil(t, input.Spec.Cluster)
+	assert.Equal(t, "https://prod.example.com", input.Spec.Cluster.Server)
+	assert.Equal(t, []byte("test"), input.Spec.Cluster.CertificateAuthorityData)
+}
+
+func TestRestConfigForKubeconfig_ExecPluginNotAllowed(t *testing.T) {
+	ranFile := filepath.Join(t.TempDir(), "ran")
+	kubeconfig := execKubeconfig(t, "touch "+ranFile+"\nexit 1\n", "Never")
+	original := execAllowedCommands
+	execAllowedCommands = []string{"kubelogin"}
+	t.Cleanup(func() { execAllowedCommands = original })
+
+	_, _, err := restConfigFor(context.Background(), ClusterConfig{Name: "prod", Type: "kubeconfig", Kubeconfig: kubeconfig})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "plugin.sh of user plugin is not allowed, add it to KUBECONFIG_EXEC_ALLOWED_COMMANDS")
+	assert.NoFileExists(t, ranFile, "the plugin is not run")
+}
+
+func TestRestConfigForKubeconfig_ClientCertificateFile(t *testing.T) {
+	dir := t.TempDir()
+	kubeconfigFile := filepath.Join(dir, "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfigFile, []byte(`apiVersion: v1
+kind: Config
+current-context: prod
+clusters:
+  - name: prod
+    cluster:
+      server: https://prod.example.com
+  - name: staging
+    cluster:
+      server: https://staging.example.com
+contexts:
+  - name: prod
+    context: {cluster: prod, user: admin}
+  - name: staging
+    context: {cluster: staging, user: admin}
+users:
+  - name: admin
+    user:
+      client-certificate: certs/admin.crt
+      client-key: certs/admin.key
+`), 0o600))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "certs"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "certs/admin.crt"), []byte("cert"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "certs/admin.key"), []byte("key"), 0o600))
+
+	restConfig, tokenExpires, err := restConfigFor(context.Background(), ClusterConfig{Name: "staging", Type: "kubeconfig", KubeconfigFile: kubeconfigFile, Context: "staging"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://staging.example.com", restConfig.Host)
+	// Paths are relative to the kubeconfig
+	assert.Equal(t, filepath.Join(dir, "certs/admin.crt"), restConfig.CertFile)
+	assert.Equal(t, filepath.Join(dir, "certs/admin.key"), restConfig.KeyFile)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), time.Unix(tokenExpires, 0), 2*time.Second)
+}
+
+func TestRestConfigForKubeconfig_Errors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		cluster  ClusterConfig
+		expected string
+	}{
+		{
+			name:     "failing plugin",
+			cluster:  ClusterConfig{Kubeconfig: execKubeconfig(t, "echo 'not logged in' >&2\nexit 1\n", "Never")},
+			expected: "failed: exit status 1: not logged in",
+		},
+		{
+			name:     "plugin without credentials",
+			cluster:  ClusterConfig{Kubeconfig: execKubeconfig(t, `echo '{"apiVersion":"client.authentication.k8s.io/v1","kind":"ExecCredential","status":{}}'`, "Never")},
+			expected: "returned no credentials",
+		},
+		{
+			name:     "plugin of another version",
+			cluster:  ClusterConfig{Kubeconfig: execKubeconfig(t, `echo '{"apiVersion":"client.authentication.k8s.io/v1beta1","kind":"ExecCredential","status":{"token":"t"}}'`, "Never")},
+			expected: `returned apiVersion "client.authentication.k8s.io/v1beta1", expected "client.authentication.k8s.io/v1"`,
+		},
+		{
+			name:     "interactive plugin",
+			cluster:  ClusterConfig{Kubeconfig: execKubeconfig(t, "exit 0\n", "Always")},
+			expected: "requires an interactive terminal",
+		},
+		{
+			name:     "unknown context",
+			cluster:  ClusterConfig{Kubeconfig: execKubeconfig(t, "exit 0\n", "Never"), Context: "dev"},
+			expected: "failed to load kubeconfig context: invalid configuration: [context was not found for specified context: dev",
+		},
+		{
+			name:     "invalid kubeconfig",
+			cluster:  ClusterConfig{Kubeconfig: []byte("clusters: {")},
+			expected: "failed to parse kubeconfig",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.cluster.Name, tc.cluster.Type = "prod", "kubeconfig"
+			_, _, err := restConfigFor(context.Background(), tc.cluster)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expected)
+		})
+	}
+}
+
+func TestGetKubeconfigFromSecret(t *testing.T) {
+	apiNamespace = "default"
+	localClientset = fake.NewSimpleClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "prod-kubeconfig", Namespace: "default"},
+			Data:       map[string][]byte{"kubeconfig": []byte("apiVersion: v1")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "token-only", Namespace: "default"},
+			Data:       map[string][]byte{"token": []byte("token")},
+		},
+	)
+
+	kubeconfig, err := getKubeconfigFromSecret("prod-kubeconfig")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("apiVersion: v1"), kubeconfig)
+
+	_, err = getKubeconfigFromSecret("token-only")
+	assert.EqualError(t, err, "secret token-only has no kubeconfig key")
+	_, err = getKubeconfigFromSecret("missing")
+	assert.ErrorContains(t, err, "error getting kubeconfig secret missing")
+}
+
+func TestReadClusterSecrets_ExecPlugin(t *testing.T) {
+	apiNamespace = "default"
+	kubeconfig := []byte(`apiVersion: v1
+kind: Config
+users:
+  - name: oidc
+    user:
+      exec:
+        apiVersion: client.authentication.k8s.io/v1
+        command: kubelogin
+`)
+	localClientset = fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "prod-kubeconfig", Namespace: "default"},
+		Data:       map[string][]byte{"kubeconfig": kubeconfig},
+	})
+
+	cluster := ClusterConfig{Name: "prod", Type: "kubeconfig", KubeconfigSecret: "prod-kubeconfig"}
+	err := readClusterSecrets(&cluster)
+	assert.EqualError(t, err, "kubeconfig secret prod-kubeconfig: exec plugin kubelogin of user oidc is not allowed, add it to KUBECONFIG_EXEC_ALLOWED_COMMANDS")
+
+	allowExecCommands(t, "kubelogin")
+	require.NoError(t, readClusterSecrets(&cluster))
+	assert.Equal(t, kubeconfig, cluster.Kubeconfig)
+}
@@ -0,0 +1,48 @@
+package k8s
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// permissionsVersion changes whenever the configuration user permissions are matched against changes,
+// the admin and platform approver teams or the JitGroups of a cluster
+var permissionsVersion atomic.Int64
+
+// jitGroupsFingerprints holds the groups last fetched for each cluster, to detect JitGroups changes
+var jitGroupsFingerprints sync.Map
+
+// PermissionsVersion returns the current permissions configuration version
+// Permissions evaluated against an older version must be evaluated again
+func PermissionsVersion() int64 {
+	return permissionsVersion.Load()
+}
+
+// InvalidatePermissions changes the permissions configuration version
+func InvalidatePermissions() {
+	permissionsVersion.Add(1)
+}
+
+// recordJitGroups invalidates permissions when the groups of a cluster differ from the ones last fetched
+func recordJitGroups(clusterName string, jitGroups *unstructured.Unstructured) {
+	fingerprint, err := jitGroupsFingerprint(jitGroups)
+	if err != nil {
+		return
+	}
+	previous, loaded := jitGroupsFingerprints.Swap(clusterName, fingerprint)
+	if loaded && previous.(string) != fingerprint {
+		logger.Info("JitGroups changed for cluster, invalidating permissions", zap.String("cluster", clusterName))
+		InvalidatePermissions()
+	}
+}
+
+// jitGroupsFingerprint returns the groups of a JitGroupCache object, serialized to be compared
+func jitGroupsFingerprint(jitGroups *unstructured.Unstructured) (string, error) {
+	groups, _, _ := unstructured.NestedSlice(jitGroups.Object, "spec", "groups")
+	fingerprint, err := json.Marshal(groups)
+	return string(fingerprint), err
+}
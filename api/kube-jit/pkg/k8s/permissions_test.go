@@ -0,0 +1,23 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestRecordJitGroups(t *testing.T) {
+	logger = zap.NewNop()
+	t.Cleanup(func() { jitGroupsFingerprints.Delete("fingerprint-cluster") })
+
+	version := PermissionsVersion()
+	recordJitGroups("fingerprint-cluster", makeFakeJitGroups([]string{"ns1"}))
+	assert.Equal(t, version, PermissionsVersion(), "the first fetch is not a change")
+
+	recordJitGroups("fingerprint-cluster", makeFakeJitGroups([]string{"ns1"}))
+	assert.Equal(t, version, PermissionsVersion(), "unchanged groups")
+
+	recordJitGroups("fingerprint-cluster", makeFakeJitGroups([]string{"ns1", "ns2"}))
+	assert.Equal(t, version+1, PermissionsVersion(), "changed groups")
+}
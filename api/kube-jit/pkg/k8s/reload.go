@@ -0,0 +1,56 @@
+package k8s
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// WatchConfig reloads apiConfig.yaml whenever its contents change, checking every interval until ctx is done.
+// ConfigMap volumes are updated by swapping a symlink, so the file is polled rather than watched for events
+func WatchConfig(ctx context.Context, interval time.Duration) {
+	logger.Info("Watching config file for changes", zap.String("file", configFile()), zap.Duration("interval", interval))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := ReloadConfig(); err != nil {
+				logger.Error("Failed to reload config, keeping the current one", zap.Error(err))
+			}
+		}
+	}
+}
+
+// ReloadConfig loads apiConfig.yaml again if its contents changed and swaps it in, reporting whether it did.
+// An invalid file is not applied, the current configuration stays in use and the error is reported by CurrentConfigStatus
+func ReloadConfig() (reloaded bool, err error) {
+	settingsUpdate.Lock()
+	defer settingsUpdate.Unlock()
+	configData, err := os.ReadFile(configFile())
+	if err != nil {
+		setReloadError(err)
+		return false, fmt.Errorf("error reading config file: %w", err)
+	}
+	hash := sha256.Sum256(configData)
+	if hex.EncodeToString(hash[:]) == CurrentSettings().Hash {
+		return false, nil
+	}
+
+	loaded, err := buildSettings(configData)
+	if err != nil {
+		setReloadError(err)
+		return false, err
+	}
+	logger.Info("Config file changed, applying it", zap.String("hash", loaded.Hash))
+	applySettings(loaded)
+	setReloadError(nil)
+	return true, nil
+}
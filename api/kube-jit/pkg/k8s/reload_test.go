@@ -0,0 +1,136 @@
+package k8s
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+const reloadClusterYaml = `
+  - name: %s
+    host: https://fake
+    insecure: true
+    tokenSecret: test-secret
+    type: generic`
+
+// setupReload points CONFIG_MOUNT_PATH at a temp dir and returns a function writing apiConfig.yaml to it
+func setupReload(t *testing.T) func(config string) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	t.Setenv("CONFIG_MOUNT_PATH", tmpDir)
+
+	localClientset = fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-secret", Namespace: "default"},
+		Data:       map[string][]byte{"token": []byte("fake-token")},
+	})
+	apiNamespace = "default"
+
+	runClusterInitAsync = false
+	t.Cleanup(func() { runClusterInitAsync = true })
+	origDynamicNewForConfig := dynamicNewForConfig
+	dynamicNewForConfig = func(*rest.Config) (dynamic.Interface, error) {
+		return dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), nil
+	}
+	t.Cleanup(func() { dynamicNewForConfig = origDynamicNewForConfig })
+	dynamicClientCache = sync.Map{}
+	setReloadError(nil)
+	useEmptySettings(t)
+
+	return func(config string) {
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "apiConfig.yaml"), []byte(config), 0644))
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	writeConfig := setupReload(t)
+
+	writeConfig("clusters:" + clusterYaml("cluster-a"))
+	reloaded, err := ReloadConfig()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.Equal(t, int64(1), CurrentSettings().Version)
+	assert.Equal(t, []string{"cluster-a"}, CurrentSettings().ClusterNames)
+
+	// Unchanged file is not reloaded
+	reloaded, err = ReloadConfig()
+	require.NoError(t, err)
+	assert.False(t, reloaded)
+	assert.Equal(t, int64(1), CurrentSettings().Version)
+
+	// Adding a cluster creates its client
+	writeConfig("clusters:" + clusterYaml("cluster-a") + clusterYaml("cluster-b"))
+	reloaded, err = ReloadConfig()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.Equal(t, int64(2), CurrentSettings().Version)
+	assert.Equal(t, []string{"cluster-a", "cluster-b"}, CurrentSettings().ClusterNames)
+	_, cached := dynamicClientCache.Load("cluster-b")
+	assert.True(t, cached)
+
+	// Removing a cluster drops its client
+	writeConfig("clusters:" + clusterYaml("cluster-b"))
+	_, err = ReloadConfig()
+	require.NoError(t, err)
+	_, cached = dynamicClientCache.Load("cluster-a")
+	assert.False(t, cached)
+	_, cached = dynamicClientCache.Load("cluster-b")
+	assert.True(t, cached)
+
+	status := CurrentConfigStatus()
+	assert.Equal(t, int64(3), status.Version)
+	assert.Equal(t, 1, status.Clusters)
+	assert.Empty(t, status.LastError)
+}
+
+func TestReloadConfig_InvalidKeepsCurrent(t *testing.T) {
+	writeConfig := setupReload(t)
+
+	writeConfig("clusters:" + clusterYaml("cluster-a"))
+	_, err := ReloadConfig()
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name   string
+		config string
+	}{
+		{"invalid yaml", "clusters: [\n"},
+		{"duplicate cluster", "clusters:" + clusterYaml("cluster-a") + clusterYaml("cluster-a")},
+		{"missing token secret", "clusters:\n  - name: cluster-c\n    type: generic\n    tokenSecret: nonexistent"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			writeConfig(tc.config)
+			reloaded, err := ReloadConfig()
+			assert.Error(t, err)
+			assert.False(t, reloaded)
+			assert.Equal(t, []string{"cluster-a"}, CurrentSettings().ClusterNames)
+
+			status := CurrentConfigStatus()
+			assert.Equal(t, int64(1), status.Version)
+			assert.NotEmpty(t, status.LastError)
+		})
+	}
+
+	// A valid file clears the error
+	writeConfig("clusters:" + clusterYaml("cluster-b"))
+	_, err = ReloadConfig()
+	require.NoError(t, err)
+	assert.Empty(t, CurrentConfigStatus().LastError)
+}
+
+// clusterYaml returns a generic cluster entry of apiConfig.yaml
+func clusterYaml(name string) string {
+	return fmt.Sprintf(reloadClusterYaml, name)
+}
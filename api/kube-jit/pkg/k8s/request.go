@@ -7,12 +7,24 @@ import (
 	"kube-jit/pkg/utils"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
 	"go.uber.org/zap"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
+// callbackGracePeriod keeps the signed callback URL valid after the request end date,
+// so the controller can report the final Expired/Revoked status
+const callbackGracePeriod = 1 * time.Hour
+
+// annotationTraceParent carries the W3C trace context into the JitRequest,
+// so the operator's reconcile spans join the trace of the approval
+const annotationTraceParent = "jit.kubejit.io/traceparent"
+
 var (
 	gvr = schema.GroupVersionResource{
 		Group:    "jit.kubejit.io",
@@ -25,13 +37,24 @@ var (
 // It uses the dynamic client to create the object
 // It takes the request data and approver name as input
 // It generates a signed URL for the callback and sets the start and end times
+// It propagates the trace context of ctx to the operator with a JitRequest annotation
 // It returns an error if the creation fails
-var CreateK8sObject = func(req models.RequestData, approverName string) error {
+var CreateK8sObject = func(ctx context.Context, req models.RequestData, approverName string) error {
+	ctx, span := otel.Tracer("kube-jit").Start(ctx, "k8s.CreateJitRequest")
+	defer span.End()
+	span.SetAttributes(
+		attribute.Int("jit.request_id", int(req.ID)),
+		attribute.String("jit.cluster", req.ClusterName),
+		attribute.String("jit.role", req.RoleName),
+	)
+
 	// Generate signed URL for callback
 	callbackBaseURL := CallbackHostOverride + "/k8s-callback"
-	signedURL, err := utils.GenerateSignedURL(callbackBaseURL, req.EndDate)
+	signedURL, err := utils.GenerateSignedURL(callbackBaseURL, req.EndDate.Add(callbackGracePeriod))
 	if err != nil {
 		logger.Error("Failed to generate signed URL", zap.Error(err))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to generate signed URL")
 		return err
 	}
 
@@ -45,14 +68,24 @@ var CreateK8sObject = func(req models.RequestData, approverName string) error {
 		users[i] = u
 	}
 
+	// Inject the trace context for the operator
+	metadata := map[string]interface{}{
+		"name": fmt.Sprintf("jit-%d", req.ID),
+	}
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if traceParent := carrier.Get("traceparent"); traceParent != "" {
+		metadata["annotations"] = map[string]interface{}{
+			annotationTraceParent: traceParent,
+		}
+	}
+
 	// jitRequest payload
 	jitRequest := &unstructured.Unstructured{
 		Object: map[string]interface{}{
 			"apiVersion": "jit.kubejit.io/v1",
 			"kind":       "JitRequest",
-			"metadata": map[string]interface{}{
-				"name": fmt.Sprintf("jit-%d", req.ID),
-			},
+			"metadata":   metadata,
 			"spec": map[string]interface{}{
 				"user":           req.Username,
 				"approver":       approverName,
@@ -70,13 +103,21 @@ var CreateK8sObject = func(req models.RequestData, approverName string) error {
 	}
 
 	// Create client for selected cluster
-	dynamicClient := createDynamicClient(req)
+	dynamicClient, err := createDynamicClient(req)
+	if err != nil {
+		logger.Error("Error creating client for request", zap.Uint("requestID", req.ID), zap.String("cluster", req.ClusterName), zap.Error(err))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "cluster unavailable")
+		return err
+	}
 
 	// Create jitRequest
 	logger.Info("Creating k8s object for request", zap.Uint("requestID", req.ID))
-	_, err = dynamicClient.Resource(gvr).Create(context.TODO(), jitRequest, metav1.CreateOptions{})
+	_, err = dynamicClient.Resource(gvr).Create(ctx, jitRequest, metav1.CreateOptions{})
 	if err != nil {
 		logger.Error("Error creating k8s object for request", zap.Uint("requestID", req.ID), zap.Error(err))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to create JitRequest")
 		return err
 	}
 	logger.Info("Successfully created k8s object for request", zap.Uint("requestID", req.ID))
@@ -1,6 +1,7 @@
 package k8s
 
 import (
+	"context"
 	"errors"
 	"testing"
 	"time"
@@ -10,6 +11,10 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/fake"
@@ -31,8 +36,8 @@ func TestCreateK8sObject_Success(t *testing.T) {
 
 	scheme := runtime.NewScheme()
 	fakeClient := fake.NewSimpleDynamicClient(scheme)
-	createDynamicClient = func(req models.RequestData) dynamic.Interface {
-		return fakeClient
+	createDynamicClient = func(req models.RequestData) (dynamic.Interface, error) {
+		return fakeClient, nil
 	}
 
 	req := models.RequestData{
@@ -46,7 +51,7 @@ func TestCreateK8sObject_Success(t *testing.T) {
 		EndDate:       time.Now().Add(time.Hour),
 	}
 
-	err := CreateK8sObject(req, "approver")
+	err := CreateK8sObject(context.Background(), req, "approver")
 	require.NoError(t, err)
 }
 
@@ -59,7 +64,7 @@ func TestCreateK8sObject_SignedUrlError(t *testing.T) {
 	}
 
 	req := models.RequestData{}
-	err := CreateK8sObject(req, "approver")
+	err := CreateK8sObject(context.Background(), req, "approver")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "sign error")
 }
@@ -83,21 +88,21 @@ func TestCreateK8sObject_CreateError(t *testing.T) {
 		return true, nil, errors.New("create error")
 	})
 
-	createDynamicClient = func(req models.RequestData) dynamic.Interface {
-		return fakeClient
+	createDynamicClient = func(req models.RequestData) (dynamic.Interface, error) {
+		return fakeClient, nil
 	}
 
 	req := models.RequestData{}
-	err := CreateK8sObject(req, "approver")
+	err := CreateK8sObject(context.Background(), req, "approver")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "create error")
 }
 
 func TestCreateDynamicClient_Patch(t *testing.T) {
 	origCreateDynamicClient := createDynamicClient
-	createDynamicClient = func(req models.RequestData) dynamic.Interface {
+	createDynamicClient = func(req models.RequestData) (dynamic.Interface, error) {
 		// Mock implementation
-		return nil
+		return nil, nil
 	}
 	defer func() { createDynamicClient = origCreateDynamicClient }()
 }
@@ -106,3 +111,79 @@ func TestCreateDynamicClient_Patch(t *testing.T) {
 var GenerateSignedURL = func(base string, expiry time.Time) (string, error) {
 	return "http://signed-url", nil
 }
+
+func TestCreateK8sObject_SignedUrlOutlivesEndDate(t *testing.T) {
+	origCreateDynamicClient := createDynamicClient
+	origGenerateSignedURL := utils.GenerateSignedURL
+	defer func() {
+		createDynamicClient = origCreateDynamicClient
+		utils.GenerateSignedURL = origGenerateSignedURL
+	}()
+
+	var signedExpiry time.Time
+	utils.GenerateSignedURL = func(base string, expiry time.Time) (string, error) {
+		signedExpiry = expiry
+		return "http://signed-url", nil
+	}
+
+	scheme := runtime.NewScheme()
+	fakeClient := fake.NewSimpleDynamicClient(scheme)
+	createDynamicClient = func(req models.RequestData) (dynamic.Interface, error) {
+		return fakeClient, nil
+	}
+
+	endDate := time.Now().Add(time.Hour)
+	req := models.RequestData{
+		Username:   "alice",
+		RoleName:   "admin",
+		Namespaces: []string{"ns1"},
+		StartDate:  time.Now(),
+		EndDate:    endDate,
+	}
+
+	err := CreateK8sObject(context.Background(), req, "approver")
+	require.NoError(t, err)
+	assert.Equal(t, endDate.Add(callbackGracePeriod), signedExpiry, "callback URL should stay valid for the final Expired callback")
+}
+
+func TestCreateK8sObject_PropagatesTraceContext(t *testing.T) {
+	origCreateDynamicClient := createDynamicClient
+	origGenerateSignedURL := utils.GenerateSignedURL
+	origPropagator := otel.GetTextMapPropagator()
+	defer func() {
+		createDynamicClient = origCreateDynamicClient
+		utils.GenerateSignedURL = origGenerateSignedURL
+		otel.SetTextMapPropagator(origPropagator)
+	}()
+
+	utils.GenerateSignedURL = func(base string, expiry time.Time) (string, error) {
+		return "http://signed-url", nil
+	}
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	scheme := runtime.NewScheme()
+	fakeClient := fake.NewSimpleDynamicClient(scheme)
+	createDynamicClient = func(req models.RequestData) (dynamic.Interface, error) {
+		return fakeClient, nil
+	}
+
+	provider := sdktrace.NewTracerProvider()
+	ctx, span := provider.Tracer("test").Start(context.Background(), "approve")
+	defer span.End()
+
+	req := models.RequestData{
+		Username:   "alice",
+		RoleName:   "admin",
+		Namespaces: []string{"ns1"},
+		StartDate:  time.Now(),
+		EndDate:    time.Now().Add(time.Hour),
+	}
+	req.ID = 7
+
+	require.NoError(t, CreateK8sObject(ctx, req, "approver"))
+
+	obj, err := fakeClient.Resource(gvr).Get(context.Background(), "jit-7", metav1.GetOptions{})
+	require.NoError(t, err)
+	traceParent := obj.GetAnnotations()[annotationTraceParent]
+	assert.Contains(t, traceParent, span.SpanContext().TraceID().String(), "JitRequest should carry the caller's trace ID")
+}
@@ -0,0 +1,123 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"time"
+
+	"go.uber.org/zap"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// defaultTokenRequestExpiration is the lifetime of tokens minted for a TokenRequestConfig without expirationSeconds
+const defaultTokenRequestExpiration int64 = 3600
+
+// ClusterCredentialsLabel marks the secrets of cluster tokens and kubeconfigs watched by WatchSecrets, with the value "true"
+const ClusterCredentialsLabel = "jit.kubejit.io/cluster-credentials"
+
+// WatchSecrets refreshes the clients of clusters when the secrets of their token or kubeconfig change, until ctx is done.
+// The secrets of the API's namespace labelled with ClusterCredentialsLabel are watched with an informer, so a rotated token
+// is used without waiting for a reload, and the other secrets of the namespace are not cached
+func WatchSecrets(ctx context.Context) {
+	factory := informers.NewSharedInformerFactoryWithOptions(localClientset, 0,
+		informers.WithNamespace(apiNamespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = ClusterCredentialsLabel + "=true"
+		}),
+	)
+	informer := factory.Core().V1().Secrets().Informer()
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		// The secrets listed when the informer starts are added too, they are ignored if unchanged
+		AddFunc: func(obj any) {
+			if secret, ok := obj.(*corev1.Secret); ok {
+				refreshClusterSecret(secret)
+			}
+		},
+		UpdateFunc: func(_, obj any) {
+			if secret, ok := obj.(*corev1.Secret); ok {
+				refreshClusterSecret(secret)
+			}
+		},
+	})
+	if err != nil {
+		logger.Error("Failed to watch secrets, rotated cluster tokens apply on the next config reload", zap.Error(err))
+		return
+	}
+	logger.Info("Watching cluster secrets for changes", zap.String("namespace", apiNamespace))
+	factory.Start(ctx.Done())
+	<-ctx.Done()
+	factory.Shutdown()
+}
+
+// refreshClusterSecret swaps in the token or kubeconfig of a changed secret for the clusters referring to it,
+// their clients are recreated by applySettings
+func refreshClusterSecret(secret *corev1.Secret) {
+	settingsUpdate.Lock()
+	defer settingsUpdate.Unlock()
+
+	current := CurrentSettings()
+	var next *Settings
+	for _, name := range current.ClusterNames {
+		cluster := current.ClusterConfigs[name]
+		switch {
+		case cluster.Type == "generic" && cluster.TokenRequest == nil && cluster.TokenSecret == secret.Name:
+			token := string(secret.Data["token"])
+			if token == "" || token == cluster.Token {
+				continue
+			}
+			cluster.Token = token
+		case cluster.Type == "kubeconfig" && cluster.KubeconfigSecret != "" && cluster.KubeconfigSecret == secret.Name:
+			kubeconfig := secret.Data["kubeconfig"]
+			if len(kubeconfig) == 0 || bytes.Equal(kubeconfig, cluster.Kubeconfig) {
+				continue
+			}
+			cluster.Kubeconfig = kubeconfig
+		default:
+			continue
+		}
+		logger.Info("Secret of cluster changed, refreshing its client", zap.String("cluster", name), zap.String("secret", secret.Name))
+		if next == nil {
+			copied := *current
+			copied.ClusterConfigs = maps.Clone(current.ClusterConfigs)
+			next = &copied
+		}
+		next.ClusterConfigs[name] = cluster
+		if cluster.Registered {
+			registeredClusters[name] = cluster
+		}
+	}
+	if next != nil {
+		applySettings(next)
+	}
+}
+
+// requestServiceAccountToken mints a token of a service account of the API's namespace with the TokenRequest API.
+// It returns the token and when to mint a new one, after 80% of its lifetime like the kubelet does
+var requestServiceAccountToken = func(ctx context.Context, tokenRequest TokenRequestConfig) (string, int64, error) {
+	expirationSeconds := tokenRequest.ExpirationSeconds
+	if expirationSeconds == 0 {
+		expirationSeconds = defaultTokenRequestExpiration
+	}
+	issuedAt := time.Now()
+	resp, err := localClientset.CoreV1().ServiceAccounts(apiNamespace).CreateToken(ctx, tokenRequest.ServiceAccount, &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			Audiences:         tokenRequest.Audiences,
+			ExpirationSeconds: &expirationSeconds,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to request a token for service account %s: %w", tokenRequest.ServiceAccount, err)
+	}
+	if resp.Status.Token == "" {
+		return "", 0, fmt.Errorf("empty token for service account %s", tokenRequest.ServiceAccount)
+	}
+	// The API server can shorten the lifetime, the expiration it returns is the one that counts
+	lifetime := resp.Status.ExpirationTimestamp.Sub(issuedAt)
+	return resp.Status.Token, issuedAt.Add(lifetime * 4 / 5).Unix(), nil
+}
@@ -0,0 +1,120 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestWatchSecrets(t *testing.T) {
+	writeConfig := setupReload(t)
+	// Record the token each client is created with
+	tokens := make(chan string, 10)
+	dynamicNewForConfig = func(restConfig *rest.Config) (dynamic.Interface, error) {
+		tokens <- restConfig.BearerToken
+		return dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), nil
+	}
+	writeConfig("clusters:" + clusterYaml("cluster-a"))
+	_, err := ReloadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "fake-token", <-tokens)
+	version := CurrentSettings().Version
+
+	// The watch is stopped and waited for, so its handlers do not run into the settings of later tests
+	ctx, cancel := context.WithCancel(context.Background())
+	watchDone := make(chan struct{})
+	go func() {
+		defer close(watchDone)
+		WatchSecrets(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-watchDone
+	})
+
+	labels := map[string]string{ClusterCredentialsLabel: "true"}
+	// Other secrets and unchanged tokens are ignored
+	_, err = localClientset.CoreV1().Secrets("default").Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "other-secret", Namespace: "default", Labels: labels},
+		Data:       map[string][]byte{"token": []byte("other-token")},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Secrets without the label are not watched
+	_, err = localClientset.CoreV1().Secrets("default").Update(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-secret", Namespace: "default"},
+		Data:       map[string][]byte{"token": []byte("unlabelled-token")},
+	}, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	assert.Never(t, func() bool {
+		return CurrentSettings().ClusterConfigs["cluster-a"].Token != "fake-token"
+	}, 200*time.Millisecond, 10*time.Millisecond)
+
+	// A rotated token recreates the client of the cluster
+	_, err = localClientset.CoreV1().Secrets("default").Update(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-secret", Namespace: "default", Labels: labels},
+		Data:       map[string][]byte{"token": []byte("rotated-token")},
+	}, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return CurrentSettings().ClusterConfigs["cluster-a"].Token == "rotated-token"
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "rotated-token", <-tokens)
+	assert.Equal(t, version+1, CurrentSettings().Version)
+	assert.Empty(t, tokens, "the client is created once")
+}
+
+func TestRequestServiceAccountToken(t *testing.T) {
+	apiNamespace = "default"
+	clientset := fake.NewSimpleClientset()
+	var requested *authenticationv1.TokenRequest
+	clientset.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		createAction := action.(k8stesting.CreateAction)
+		if createAction.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+		requested = createAction.GetObject().(*authenticationv1.TokenRequest)
+		if createAction.(k8stesting.CreateActionImpl).Name == "missing" {
+			return true, nil, errors.New(`serviceaccounts "missing" not found`)
+		}
+		return true, &authenticationv1.TokenRequest{Status: authenticationv1.TokenRequestStatus{
+			Token:               "minted-token",
+			ExpirationTimestamp: metav1.NewTime(time.Now().Add(time.Duration(*requested.Spec.ExpirationSeconds) * time.Second)),
+		}}, nil
+	})
+	localClientset = clientset
+
+	restConfig, tokenExpires, err := restConfigFor(context.Background(), ClusterConfig{
+		Name:         "cluster-a",
+		Type:         "generic",
+		Host:         "https://fake",
+		TokenRequest: &TokenRequestConfig{ServiceAccount: "kube-jit-remote", Audiences: []string{"cluster-a"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "minted-token", restConfig.BearerToken)
+	assert.Equal(t, []string{"cluster-a"}, requested.Spec.Audiences)
+	assert.Equal(t, defaultTokenRequestExpiration, *requested.Spec.ExpirationSeconds)
+	// Minted again after 80% of the token's lifetime
+	assert.WithinDuration(t, time.Now().Add(48*time.Minute), time.Unix(tokenExpires, 0), 2*time.Second)
+
+	_, _, err = requestServiceAccountToken(context.Background(), TokenRequestConfig{ServiceAccount: "kube-jit-remote", ExpirationSeconds: 600})
+	require.NoError(t, err)
+	assert.Equal(t, int64(600), *requested.Spec.ExpirationSeconds)
+
+	_, _, err = requestServiceAccountToken(context.Background(), TokenRequestConfig{ServiceAccount: "missing"})
+	assert.EqualError(t, err, `failed to request a token for service account missing: serviceaccounts "missing" not found`)
+}
@@ -0,0 +1,27 @@
+package k8s
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// SelectClusters returns the clusters whose labels match a label selector, like "env=prod,region in (eu,us)",
+// in the order of the settings. A selector matching every cluster, like an empty one, is an error
+func SelectClusters(selector string) ([]string, error) {
+	parsed, err := labels.Parse(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cluster selector: %w", err)
+	}
+	if parsed.Empty() {
+		return nil, fmt.Errorf("cluster selector %q matches every cluster", selector)
+	}
+	current := CurrentSettings()
+	var clusterNames []string
+	for _, name := range current.ClusterNames {
+		if parsed.Matches(labels.Set(current.ClusterConfigs[name].Labels)) {
+			clusterNames = append(clusterNames, name)
+		}
+	}
+	return clusterNames, nil
+}
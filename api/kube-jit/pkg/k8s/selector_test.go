@@ -0,0 +1,41 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectClusters(t *testing.T) {
+	useEmptySettings(t)
+	SetSettings(&Settings{
+		ClusterNames: []string{"prod-eu", "prod-us", "staging-eu", "legacy"},
+		ClusterConfigs: map[string]ClusterConfig{
+			"prod-eu":    {Name: "prod-eu", Labels: map[string]string{"env": "prod", "region": "eu"}},
+			"prod-us":    {Name: "prod-us", Labels: map[string]string{"env": "prod", "region": "us"}},
+			"staging-eu": {Name: "staging-eu", Labels: map[string]string{"env": "staging", "region": "eu"}},
+			"legacy":     {Name: "legacy"},
+		},
+	})
+
+	testCases := []struct {
+		selector string
+		expected []string
+	}{
+		{selector: "env=prod", expected: []string{"prod-eu", "prod-us"}},
+		{selector: "region in (eu),env!=prod", expected: []string{"staging-eu"}},
+		{selector: "!env", expected: []string{"legacy"}},
+		{selector: "env=dev", expected: nil},
+	}
+	for _, tc := range testCases {
+		clusterNames, err := SelectClusters(tc.selector)
+		require.NoError(t, err, tc.selector)
+		assert.Equal(t, tc.expected, clusterNames, tc.selector)
+	}
+
+	_, err := SelectClusters("env in prod")
+	assert.ErrorContains(t, err, "invalid cluster selector: ")
+	_, err = SelectClusters("")
+	assert.EqualError(t, err, `cluster selector "" matches every cluster`)
+}
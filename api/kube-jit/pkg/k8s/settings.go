@@ -0,0 +1,176 @@
+package k8s
+
+import (
+	"kube-jit/internal/models"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Settings is the configuration loaded from apiConfig.yaml. It is swapped as a whole when the file
+// changes, so read it once with CurrentSettings and use that snapshot for the rest of a request.
+// Settings must not be modified once stored
+type Settings struct {
+	Version               int64     // incremented on every load
+	Hash                  string    // SHA-256 of apiConfig.yaml
+	LoadedAt              time.Time // when it was loaded
+	ClusterNames          []string
+	ClusterConfigs        map[string]ClusterConfig
+	AllowedRoles          []models.Roles
+	PlatformApproverTeams []models.Team
+	AdminTeams            []models.Team
+	ApiRoles              []models.ApiRole
+	ServicePrincipals     map[string]ServicePrincipalConfig // keyed by client ID
+	JitClusters           JitClusterPolicy
+}
+
+// ConfigStatus reports the configuration in use and the outcome of the last reload
+type ConfigStatus struct {
+	Version     int64      `json:"version" example:"3"`
+	Hash        string     `json:"hash" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	LoadedAt    time.Time  `json:"loadedAt"`
+	Clusters    int        `json:"clusters" example:"2"`
+	LastError   string     `json:"lastError,omitempty" example:"duplicate cluster name prod"`
+	LastErrorAt *time.Time `json:"lastErrorAt,omitempty"`
+}
+
+var (
+	settings atomic.Pointer[Settings]
+
+	// settingsUpdate serializes the changes of the settings by config reloads and secret changes,
+	// so a change based on the current settings does not undo another one
+	settingsUpdate sync.Mutex
+
+	// reloadStatus holds the last reload error, kept until a reload succeeds
+	reloadStatus struct {
+		sync.Mutex
+		err   error
+		errAt time.Time
+	}
+)
+
+func init() {
+	settings.Store(&Settings{
+		ClusterConfigs:    make(map[string]ClusterConfig),
+		ServicePrincipals: make(map[string]ServicePrincipalConfig),
+	})
+}
+
+// CurrentSettings returns the configuration in use
+func CurrentSettings() *Settings {
+	return settings.Load()
+}
+
+// SetSettings replaces the configuration in use and invalidates permissions evaluated against the previous one.
+// It is used by tests, configuration files go through applySettings
+func SetSettings(s *Settings) *Settings {
+	previous := settings.Swap(s)
+	InvalidatePermissions()
+	return previous
+}
+
+// CurrentConfigStatus returns the version of the configuration in use and the last reload error
+func CurrentConfigStatus() ConfigStatus {
+	current := CurrentSettings()
+	status := ConfigStatus{
+		Version:  current.Version,
+		Hash:     current.Hash,
+		LoadedAt: current.LoadedAt,
+		Clusters: len(current.ClusterNames),
+	}
+	reloadStatus.Lock()
+	defer reloadStatus.Unlock()
+	if reloadStatus.err != nil {
+		status.LastError = reloadStatus.err.Error()
+		errAt := reloadStatus.errAt
+		status.LastErrorAt = &errAt
+	}
+	return status
+}
+
+// setReloadError records the outcome of a reload, nil clears the last error
+func setReloadError(err error) {
+	reloadStatus.Lock()
+	defer reloadStatus.Unlock()
+	reloadStatus.err = err
+	reloadStatus.errAt = time.Now()
+}
+
+// applySettings swaps in loaded settings, with the registered JitClusters. The dynamic clients and JitGroups of removed clusters,
+// or of clusters whose connection changed, are dropped, and clients of new clusters are created
+func applySettings(next *Settings) {
+	next = withRegisteredClusters(next)
+	previous := CurrentSettings()
+	next.Version = previous.Version + 1
+	SetSettings(next)
+
+	for name, cluster := range previous.ClusterConfigs {
+		if nextCluster, ok := next.ClusterConfigs[name]; !ok || !sameConnection(cluster, nextCluster) {
+			logger.Info("Cluster removed or changed, dropping its client", zap.String("cluster", name))
+			dynamicClientCache.Delete(name)
+			forgetClusterFailure(name) // it failed with the previous configuration
+			InvalidateJitGroupsCache(name)
+			jitGroupsFingerprints.Delete(name)
+		}
+	}
+
+	logSettings(next)
+
+	// Cache dynamic clients for the clusters without one
+	for _, clusterName := range next.ClusterNames {
+		if _, cached := dynamicClientCache.Load(clusterName); cached {
+			continue
+		}
+		// A cluster whose client cannot be created is marked unhealthy, and retried on its next use
+		req := models.RequestData{ClusterName: clusterName}
+		if runClusterInitAsync { // for production
+			go func(r models.RequestData) {
+				_, _ = createDynamicClient(r)
+			}(req)
+		} else {
+			// for testing
+			_, _ = createDynamicClient(req)
+		}
+	}
+}
+
+// sameConnection reports whether two configurations of a cluster connect to it the same way,
+// changes to its teams, labels or source need no new client
+func sameConnection(a, b ClusterConfig) bool {
+	a.PlatformApproverTeams, a.AdminTeams, a.Labels, a.Registered = nil, nil, nil, false
+	b.PlatformApproverTeams, b.AdminTeams, b.Labels, b.Registered = nil, nil, nil, false
+	return reflect.DeepEqual(a, b)
+}
+
+// logSettings logs the loaded configuration
+func logSettings(s *Settings) {
+	logger.Info("Successfully loaded config", zap.Int64("version", s.Version), zap.Strings("clusters", s.ClusterNames))
+	logger.Info("Allowed roles loaded", zap.Int("count", len(s.AllowedRoles)))
+	for _, role := range s.AllowedRoles {
+		logger.Info("Allowed role", zap.String("name", role.Name))
+	}
+	logger.Info("Approver teams loaded", zap.Int("count", len(s.PlatformApproverTeams)))
+	for _, team := range s.PlatformApproverTeams {
+		logger.Info("Approver team", zap.String("name", team.Name), zap.String("id", team.ID))
+	}
+	logger.Info("Admin teams loaded", zap.Int("count", len(s.AdminTeams)))
+	for _, team := range s.AdminTeams {
+		logger.Info("Admin team", zap.String("name", team.Name), zap.String("id", team.ID))
+	}
+	logger.Info("API roles loaded", zap.Int("count", len(s.ApiRoles)))
+	for _, role := range s.ApiRoles {
+		logger.Info("API role",
+			zap.String("name", role.Name),
+			zap.Strings("permissions", role.Permissions),
+			zap.Strings("clusters", role.Clusters),
+			zap.Strings("roles", role.Roles),
+		)
+	}
+	logger.Info("Service principals loaded", zap.Int("count", len(s.ServicePrincipals)))
+	for _, principal := range s.ServicePrincipals {
+		logger.Info("Service principal", zap.String("name", principal.Name), zap.Strings("scopes", principal.Scopes))
+	}
+}
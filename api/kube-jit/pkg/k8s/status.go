@@ -0,0 +1,214 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"kube-jit/internal/models"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+)
+
+// jitGroupVersion is the API group version of the operator's CRDs
+const jitGroupVersion = "jit.kubejit.io/v1"
+
+// operatorVersionLabel is set on the CRDs by the operator chart
+const operatorVersionLabel = "app.kubernetes.io/version"
+
+var crdResource = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+
+// clusterProbeTimeout is the time a cluster gets to answer all the calls of a probe
+var clusterProbeTimeout = 10 * time.Second
+
+// clusterStatuses holds the last ClusterStatus of each cluster
+var clusterStatuses sync.Map
+
+// ClusterStatus is the outcome of the last probe of a cluster, see RunClusterProber
+type ClusterStatus struct {
+	Name             string                  `json:"name" example:"prod"`
+	Reachable        bool                    `json:"reachable" example:"true"`
+	Error            string                  `json:"error,omitempty" example:"the server has asked for the client to provide credentials"`
+	ServerVersion    string                  `json:"serverVersion,omitempty" example:"v1.30.1"`
+	TokenExpiresAt   *time.Time              `json:"tokenExpiresAt,omitempty"` // of the cached client, it is refreshed when it expires
+	JitRequestCRD    bool                    `json:"jitRequestCRD" example:"true"`
+	JitGroupCacheCRD bool                    `json:"jitGroupCacheCRD" example:"true"`
+	OperatorVersion  string                  `json:"operatorVersion,omitempty" example:"1.4.0"` // from the app.kubernetes.io/version label of the CRDs
+	JitGroupCache    *JitGroupCacheFreshness `json:"jitGroupCache,omitempty"`
+	CheckedAt        *time.Time              `json:"checkedAt,omitempty"` // empty until the cluster is first probed
+}
+
+// JitGroupCacheFreshness describes the JitGroupCache object the operator keeps up to date with the namespaces of a cluster
+type JitGroupCacheFreshness struct {
+	Groups    int        `json:"groups" example:"12"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`   // last write to the object
+	InSync    bool       `json:"inSync" example:"true"` // false while the API still serves groups it cached before the last write
+}
+
+// newDiscoveryClient creates the discovery client a cluster is probed with
+var newDiscoveryClient = func(restConfig *rest.Config) (discovery.DiscoveryInterface, error) {
+	return discovery.NewDiscoveryClientForConfig(restConfig)
+}
+
+// RunClusterProber probes every cluster now and then every interval until ctx is done, see ClusterStatuses
+func RunClusterProber(ctx context.Context, interval time.Duration) {
+	logger.Info("Probing clusters in the background", zap.Duration("interval", interval))
+	ProbeClusters(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ProbeClusters(ctx)
+		}
+	}
+}
+
+// ProbeClusters probes every configured cluster in parallel and stores their status,
+// forgetting the status of clusters removed from the configuration
+func ProbeClusters(ctx context.Context) {
+	s := CurrentSettings()
+	var wg sync.WaitGroup
+	for _, name := range s.ClusterNames {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status := probeCluster(ctx, name)
+			if !status.Reachable {
+				logger.Warn("Cluster probe failed", zap.String("cluster", name), zap.String("error", status.Error))
+			}
+			clusterStatuses.Store(name, status)
+		}()
+	}
+	wg.Wait()
+
+	clusterStatuses.Range(func(key, _ any) bool {
+		if _, ok := s.ClusterConfigs[key.(string)]; !ok {
+			clusterStatuses.Delete(key)
+		}
+		return true
+	})
+}
+
+// ClusterStatuses returns the last status of every configured cluster, in the order of the configuration
+func ClusterStatuses() []ClusterStatus {
+	names := CurrentSettings().ClusterNames
+	statuses := make([]ClusterStatus, len(names))
+	for i, name := range names {
+		statuses[i] = ClusterStatus{Name: name}
+		if status, ok := clusterStatuses.Load(name); ok {
+			statuses[i] = status.(ClusterStatus)
+		}
+	}
+	return statuses
+}
+
+// probeCluster checks a cluster with its cached client: whether its API server answers,
+// whether the operator's CRDs are installed and which version, and how fresh its JitGroupCache is
+func probeCluster(ctx context.Context, clusterName string) ClusterStatus {
+	now := time.Now()
+	status := ClusterStatus{Name: clusterName, CheckedAt: &now}
+	ctx, cancel := context.WithTimeout(ctx, clusterProbeTimeout)
+	defer cancel()
+
+	dynamicClient, err := createDynamicClient(models.RequestData{ClusterName: clusterName})
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	cached, ok := dynamicClientCache.Load(clusterName)
+	if !ok || cached.(*CachedClient).Config == nil {
+		status.Error = "no cached client for cluster"
+		return status
+	}
+	cachedClient := cached.(*CachedClient)
+	tokenExpiresAt := time.Unix(cachedClient.TokenExpires, 0)
+	status.TokenExpiresAt = &tokenExpiresAt
+
+	restConfig := rest.CopyConfig(cachedClient.Config)
+	restConfig.Timeout = clusterProbeTimeout
+	discoveryClient, err := newDiscoveryClient(restConfig)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	version, err := discoveryClient.ServerVersion()
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Reachable, status.ServerVersion = true, version.GitVersion
+
+	// Discovery needs no permissions, unlike reading the CRDs
+	resources, err := discoveryClient.ServerResourcesForGroupVersion(jitGroupVersion)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			status.Error = fmt.Sprintf("failed to discover %s resources: %v", jitGroupVersion, err)
+		}
+		return status
+	}
+	for _, resource := range resources.APIResources {
+		switch resource.Name {
+		case "jitrequests":
+			status.JitRequestCRD = true
+		case "jitgroupcaches":
+			status.JitGroupCacheCRD = true
+		}
+	}
+
+	// The operator version is informational, it is left empty without permission to read CRDs
+	for _, resource := range []string{"jitrequests", "jitgroupcaches"} {
+		crd, err := dynamicClient.Resource(crdResource).Get(ctx, resource+".jit.kubejit.io", metav1.GetOptions{})
+		if err == nil && crd.GetLabels()[operatorVersionLabel] != "" {
+			status.OperatorVersion = crd.GetLabels()[operatorVersionLabel]
+			break
+		}
+	}
+
+	if status.JitGroupCacheCRD {
+		jitGroups, err := dynamicClient.Resource(schema.GroupVersionResource{
+			Group:    "jit.kubejit.io",
+			Version:  "v1",
+			Resource: "jitgroupcaches",
+		}).Get(ctx, "jitgroupcache", metav1.GetOptions{})
+		if err != nil {
+			status.Error = fmt.Sprintf("failed to get JitGroupCache: %v", err)
+			return status
+		}
+		status.JitGroupCache = jitGroupCacheFreshness(clusterName, jitGroups)
+	}
+	return status
+}
+
+// jitGroupCacheFreshness reports the groups of a JitGroupCache object, when it was last written
+// and whether the API's cached copy of its groups is the same
+func jitGroupCacheFreshness(clusterName string, jitGroups *unstructured.Unstructured) *JitGroupCacheFreshness {
+	groups, _, _ := unstructured.NestedSlice(jitGroups.Object, "spec", "groups")
+	freshness := &JitGroupCacheFreshness{Groups: len(groups), InSync: true}
+
+	updatedAt := jitGroups.GetCreationTimestamp().Time
+	for _, entry := range jitGroups.GetManagedFields() {
+		if entry.Time != nil && entry.Time.After(updatedAt) {
+			updatedAt = entry.Time.Time
+		}
+	}
+	if !updatedAt.IsZero() {
+		freshness.UpdatedAt = &updatedAt
+	}
+
+	// An expired copy is fetched again on its next use
+	if cached, ok := jitGroupsCache.Load(clusterName); ok && cached.(*JitGroupsCache).ExpiresAt > time.Now().Unix() {
+		current, err := jitGroupsFingerprint(jitGroups)
+		previous, previousErr := jitGroupsFingerprint(cached.(*JitGroupsCache).JitGroups)
+		freshness.InSync = err == nil && previousErr == nil && current == previous
+	}
+	return freshness
+}
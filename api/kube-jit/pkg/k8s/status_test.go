@@ -0,0 +1,167 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// setupProbe caches a client for each cluster, with the objects of the cluster and the resources its discovery returns
+func setupProbe(t *testing.T, clusters map[string][]runtime.Object, resources map[string][]*metav1.APIResourceList, versionErr error) {
+	t.Helper()
+	// The package state is swapped under the settings lock, as applySettings reads it with the lock held
+	settingsUpdate.Lock()
+	defer settingsUpdate.Unlock()
+	dynamicClientCache = sync.Map{}
+	clusterStatuses = sync.Map{}
+	settings := &Settings{ClusterConfigs: make(map[string]ClusterConfig)}
+	for _, name := range []string{"cluster-a", "cluster-b"} {
+		if objects, ok := clusters[name]; ok {
+			settings.ClusterNames = append(settings.ClusterNames, name)
+			settings.ClusterConfigs[name] = ClusterConfig{Name: name, Type: "generic", Host: "https://" + name}
+			dynamicClientCache.Store(name, &CachedClient{
+				Client:       fake.NewSimpleDynamicClient(runtime.NewScheme(), objects...),
+				TokenExpires: time.Now().Add(time.Hour).Unix(),
+				Config:       &rest.Config{Host: "https://" + name},
+			})
+		}
+	}
+	original := SetSettings(settings)
+
+	origNewDiscoveryClient := newDiscoveryClient
+	newDiscoveryClient = func(restConfig *rest.Config) (discovery.DiscoveryInterface, error) {
+		fakeDiscovery := &fakediscovery.FakeDiscovery{
+			Fake:               &k8stesting.Fake{Resources: resources[restConfig.Host[len("https://"):]]},
+			FakedServerVersion: &version.Info{GitVersion: "v1.30.1"},
+		}
+		if versionErr != nil {
+			fakeDiscovery.AddReactor("get", "version", func(k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, versionErr
+			})
+		}
+		return fakeDiscovery, nil
+	}
+	t.Cleanup(func() {
+		settingsUpdate.Lock()
+		defer settingsUpdate.Unlock()
+		SetSettings(original)
+		newDiscoveryClient = origNewDiscoveryClient
+		dynamicClientCache = sync.Map{}
+		clusterStatuses = sync.Map{}
+		jitGroupsCache = sync.Map{}
+	})
+}
+
+func operatorCRD(resource string) *unstructured.Unstructured {
+	crd := &unstructured.Unstructured{}
+	crd.SetAPIVersion("apiextensions.k8s.io/v1")
+	crd.SetKind("CustomResourceDefinition")
+	crd.SetName(resource + ".jit.kubejit.io")
+	crd.SetLabels(map[string]string{operatorVersionLabel: "1.4.0"})
+	return crd
+}
+
+func jitGroupCacheObject(updatedAt time.Time, namespaces ...string) *unstructured.Unstructured {
+	jitGroups := makeFakeJitGroups(namespaces)
+	jitGroups.SetAPIVersion(jitGroupVersion)
+	jitGroups.SetKind("JitGroupCache")
+	jitGroups.SetName("jitgroupcache")
+	jitGroups.SetCreationTimestamp(metav1.NewTime(updatedAt.Add(-time.Hour)))
+	jitGroups.SetManagedFields([]metav1.ManagedFieldsEntry{
+		{Manager: "manager", Time: &metav1.Time{Time: updatedAt}},
+	})
+	return jitGroups
+}
+
+var operatorResources = []*metav1.APIResourceList{{
+	GroupVersion: jitGroupVersion,
+	APIResources: []metav1.APIResource{{Name: "jitrequests"}, {Name: "jitgroupcaches"}},
+}}
+
+func TestProbeClusters(t *testing.T) {
+	updatedAt := time.Now().Add(-5 * time.Minute).Truncate(time.Second)
+	setupProbe(t, map[string][]runtime.Object{
+		"cluster-a": {operatorCRD("jitrequests"), operatorCRD("jitgroupcaches"), jitGroupCacheObject(updatedAt, "ns1", "ns2")},
+		"cluster-b": {},
+	}, map[string][]*metav1.APIResourceList{"cluster-a": operatorResources}, nil)
+
+	// The API still serves the groups it cached before the last write
+	jitGroupsCache.Store("cluster-a", &JitGroupsCache{
+		JitGroups: makeFakeJitGroups([]string{"ns1"}),
+		ExpiresAt: time.Now().Add(time.Minute).Unix(),
+	})
+
+	assert.Nil(t, ClusterStatuses()[0].CheckedAt, "clusters are not probed yet")
+	ProbeClusters(context.Background())
+	statuses := ClusterStatuses()
+	require.Len(t, statuses, 2)
+
+	a := statuses[0]
+	assert.Equal(t, "cluster-a", a.Name)
+	assert.True(t, a.Reachable)
+	assert.Empty(t, a.Error)
+	assert.Equal(t, "v1.30.1", a.ServerVersion)
+	require.NotNil(t, a.TokenExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *a.TokenExpiresAt, 2*time.Second)
+	assert.True(t, a.JitRequestCRD)
+	assert.True(t, a.JitGroupCacheCRD)
+	assert.Equal(t, "1.4.0", a.OperatorVersion)
+	require.NotNil(t, a.JitGroupCache)
+	assert.Equal(t, 2, a.JitGroupCache.Groups)
+	assert.Equal(t, updatedAt, *a.JitGroupCache.UpdatedAt)
+	assert.False(t, a.JitGroupCache.InSync)
+	assert.NotNil(t, a.CheckedAt)
+
+	// A cluster without the operator is reachable, without its CRDs
+	b := statuses[1]
+	assert.True(t, b.Reachable)
+	assert.Empty(t, b.Error)
+	assert.False(t, b.JitRequestCRD)
+	assert.False(t, b.JitGroupCacheCRD)
+	assert.Empty(t, b.OperatorVersion)
+	assert.Nil(t, b.JitGroupCache)
+
+	// Once the API cached the same groups they are in sync
+	jitGroupsCache.Store("cluster-a", &JitGroupsCache{
+		JitGroups: makeFakeJitGroups([]string{"ns1", "ns2"}),
+		ExpiresAt: time.Now().Add(time.Minute).Unix(),
+	})
+	ProbeClusters(context.Background())
+	assert.True(t, ClusterStatuses()[0].JitGroupCache.InSync)
+
+	// Removed clusters are forgotten
+	SetSettings(&Settings{
+		ClusterNames:   []string{"cluster-b"},
+		ClusterConfigs: map[string]ClusterConfig{"cluster-b": {Name: "cluster-b", Type: "generic"}},
+	})
+	ProbeClusters(context.Background())
+	_, found := clusterStatuses.Load("cluster-a")
+	assert.False(t, found)
+	require.Len(t, ClusterStatuses(), 1)
+	assert.Equal(t, "cluster-b", ClusterStatuses()[0].Name)
+}
+
+func TestProbeClusters_Unreachable(t *testing.T) {
+	setupProbe(t, map[string][]runtime.Object{"cluster-a": {}}, nil, errors.New("connection refused"))
+
+	ProbeClusters(context.Background())
+	status := ClusterStatuses()[0]
+	assert.False(t, status.Reachable)
+	assert.Equal(t, "connection refused", status.Error)
+	assert.NotNil(t, status.TokenExpiresAt)
+	assert.NotNil(t, status.CheckedAt)
+}
@@ -0,0 +1,373 @@
+package k8s
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"kube-jit/internal/models"
+	"maps"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/util/validation"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// ClusterTypes are the supported values of a cluster's type
+var ClusterTypes = []string{"generic", "gke", "aks", "eks", "kubeconfig"}
+
+// execAllowedCommands are the exec credential plugins kubeconfig clusters may run, from the comma separated KUBECONFIG_EXEC_ALLOWED_COMMANDS.
+// Commands are matched exactly as written in the kubeconfig, e.g. "kubelogin" or "/usr/local/bin/kubelogin"; none are allowed by default
+var execAllowedCommands = splitCommands(os.Getenv("KUBECONFIG_EXEC_ALLOWED_COMMANDS"))
+
+// ConfigError is a mistake in apiConfig.yaml, reported at the line of the field it is about
+type ConfigError struct {
+	Line    int    // 0 when the line is unknown
+	Field   string // e.g. clusters[1].ca, empty when the error is about the file
+	Message string
+}
+
+func (e ConfigError) Error() string {
+	var b strings.Builder
+	if e.Line > 0 {
+		fmt.Fprintf(&b, "line %d: ", e.Line)
+	}
+	if e.Field != "" {
+		b.WriteString(e.Field + ": ")
+	}
+	b.WriteString(e.Message)
+	return b.String()
+}
+
+// ConfigErrors are all the mistakes found in apiConfig.yaml
+type ConfigErrors []ConfigError
+
+func (e ConfigErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// yamlErrorLine matches the line prefix of yaml.v3 decoding errors
+var yamlErrorLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// parseConfig decodes apiConfig.yaml and validates it, returning ConfigErrors with every mistake found.
+// Unknown fields are mistakes, like in the chart, so a typo is not silently ignored
+func parseConfig(configData []byte) (Config, error) {
+	var config Config
+	var root yaml.Node
+	if err := yaml.Unmarshal(configData, &root); err != nil {
+		return config, ConfigErrors{yamlError(err.Error())}
+	}
+	if root.Kind == 0 { // empty file
+		return config, nil
+	}
+
+	var errs ConfigErrors
+	decoder := yaml.NewDecoder(bytes.NewReader(configData))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil {
+		// Fields of the wrong type are skipped, the rest of the file is decoded and validated
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return config, ConfigErrors{yamlError(err.Error())}
+		}
+		for _, message := range typeErr.Errors {
+			errs = append(errs, yamlError(message))
+		}
+	}
+
+	v := configValidator{root: &root, errs: errs}
+	v.validate(config)
+	if len(v.errs) > 0 {
+		slices.SortStableFunc(v.errs, func(a, b ConfigError) int { return a.Line - b.Line })
+		return config, v.errs
+	}
+	return config, nil
+}
+
+// yamlError turns an error message of yaml.v3 into a ConfigError with its line
+func yamlError(message string) ConfigError {
+	if match := yamlErrorLine.FindStringSubmatch(message); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		return ConfigError{Line: line, Message: match[2]}
+	}
+	return ConfigError{Message: strings.TrimPrefix(message, "yaml: ")}
+}
+
+// identityProviders are the identity providers enabled in the API, see SetIdentityProviders
+var identityProviders []string
+
+// SetIdentityProviders sets the identity providers enabled in the API. With several of them, team IDs
+// must be qualified with their provider, e.g. gitlab:123, as the IDs of different providers can collide
+func SetIdentityProviders(providers []string) {
+	identityProviders = providers
+}
+
+// configValidator collects the mistakes of a decoded Config, looking up their lines in the yaml nodes
+type configValidator struct {
+	root *yaml.Node
+	errs ConfigErrors
+}
+
+// addf records a mistake for the field at path, made of mapping keys and sequence indexes
+func (v *configValidator) addf(path []any, format string, args ...any) {
+	v.errs = append(v.errs, ConfigError{
+		Line:    lineOf(v.root, path),
+		Field:   fieldName(path),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *configValidator) validate(config Config) {
+	clusterNames := make(map[string]bool)
+	for i, cluster := range config.Clusters {
+		path := []any{"clusters", i}
+		if cluster.Name == "" {
+			v.addf(path, "name is required")
+		} else if clusterNames[cluster.Name] {
+			v.addf(append(path, "name"), "duplicate cluster name %s", cluster.Name)
+		}
+		clusterNames[cluster.Name] = true
+		v.validateCluster(path, cluster)
+	}
+
+	roleNames := make(map[string]bool)
+	for i, role := range config.AllowedRoles {
+		path := []any{"allowedRoles", i}
+		if role.Name == "" {
+			v.addf(path, "name is required")
+		} else if roleNames[role.Name] {
+			v.addf(append(path, "name"), "duplicate role %s", role.Name)
+		}
+		roleNames[role.Name] = true
+	}
+
+	v.validateTeams([]any{"platformApproverTeams"}, config.PlatformApproverTeams)
+	v.validateTeams([]any{"adminTeams"}, config.AdminTeams)
+
+	clientIDs := make(map[string]bool)
+	for i, principal := range config.ServicePrincipals {
+		path := []any{"servicePrincipals", i}
+		v.required(path, map[string]string{
+			"name":             principal.Name,
+			"clientID":         principal.ClientID,
+			"clientSecretName": principal.ClientSecretName,
+		})
+		if principal.ClientID != "" && clientIDs[principal.ClientID] {
+			v.addf(append(path, "clientID"), "duplicate clientID %s", principal.ClientID)
+		}
+		clientIDs[principal.ClientID] = true
+	}
+
+	for i, role := range config.ApiRoles {
+		v.validateApiRole([]any{"apiRoles", i}, role, clusterNames)
+	}
+
+	if len(config.JitClusters.ServiceAccounts) > 0 && !config.JitClusters.AllowTokenRequest {
+		v.addf([]any{"jitClusters", "serviceAccounts"}, "serviceAccounts are only used with allowTokenRequest")
+	}
+}
+
+// validateCluster checks the fields a cluster of its type needs to create a client
+func (v *configValidator) validateCluster(path []any, cluster ClusterConfig) {
+	switch cluster.Type {
+	case "generic":
+		if cluster.TokenRequest == nil {
+			v.required(path, map[string]string{"host": cluster.Host, "tokenSecret": cluster.TokenSecret})
+		} else {
+			v.required(path, map[string]string{"host": cluster.Host})
+			v.validateTokenRequest(append(slices.Clone(path), "tokenRequest"), *cluster.TokenRequest)
+			if cluster.TokenSecret != "" {
+				v.addf(append(path, "tokenSecret"), "tokenSecret cannot be used with tokenRequest")
+			}
+		}
+		if cluster.Host != "" {
+			if u, err := url.Parse(cluster.Host); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+				v.addf(append(path, "host"), "host must be an http(s) URL, got %q", cluster.Host)
+			}
+		}
+		if _, err := base64.StdEncoding.DecodeString(cluster.CA); err != nil {
+			v.addf(append(path, "ca"), "ca must be a base64 encoded certificate: %v", err)
+		}
+	case "gke":
+		v.required(path, map[string]string{"projectID": cluster.ProjectID, "region": cluster.Region})
+	case "aks":
+		// The subscription ID and resource group of AKS clusters are set in projectID and region
+		v.required(path, map[string]string{"projectID": cluster.ProjectID, "region": cluster.Region})
+	case "eks":
+		v.required(path, map[string]string{"region": cluster.Region})
+		if cluster.RoleARN != "" && !strings.HasPrefix(cluster.RoleARN, "arn:aws") {
+			v.addf(append(path, "roleARN"), "roleARN must be an IAM role ARN, got %q", cluster.RoleARN)
+		}
+	case "kubeconfig":
+		if (cluster.KubeconfigSecret == "") == (cluster.KubeconfigFile == "") {
+			v.addf(path, "one of kubeconfigSecret or kubeconfigFile is required")
+		}
+	case "":
+		v.addf(path, "type is required, one of %s", strings.Join(ClusterTypes, ", "))
+	default:
+		v.addf(append(path, "type"), "unknown cluster type %q, expected one of %s", cluster.Type, strings.Join(ClusterTypes, ", "))
+	}
+	if cluster.TokenRequest != nil && cluster.Type != "generic" {
+		v.addf(append(slices.Clone(path), "tokenRequest"), "tokenRequest is only supported by generic clusters")
+	}
+	v.validateTeams(append(slices.Clone(path), "platformApproverTeams"), cluster.PlatformApproverTeams)
+	v.validateTeams(append(slices.Clone(path), "adminTeams"), cluster.AdminTeams)
+	v.validateLabels(append(slices.Clone(path), "labels"), cluster.Labels)
+}
+
+// validateKubeconfigExec checks that the exec credential plugins of all the users of a kubeconfig are in execAllowedCommands,
+// as the API runs them with its own environment
+func validateKubeconfigExec(config *clientcmdapi.Config) error {
+	for _, name := range slices.Sorted(maps.Keys(config.AuthInfos)) {
+		if exec := config.AuthInfos[name].Exec; exec != nil && !slices.Contains(execAllowedCommands, exec.Command) {
+			return fmt.Errorf("exec plugin %s of user %s is not allowed, add it to KUBECONFIG_EXEC_ALLOWED_COMMANDS", exec.Command, name)
+		}
+	}
+	return nil
+}
+
+// splitCommands splits a comma separated list of commands, ignoring empty entries
+func splitCommands(list string) []string {
+	var commands []string
+	for _, command := range strings.Split(list, ",") {
+		if command = strings.TrimSpace(command); command != "" {
+			commands = append(commands, command)
+		}
+	}
+	return commands
+}
+
+// validateLabels checks that the labels of a cluster are valid Kubernetes labels, so they can be matched by label selectors
+func (v *configValidator) validateLabels(path []any, labels map[string]string) {
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			v.addf(append(slices.Clone(path), key), "invalid label key %q: %s", key, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(labels[key]); len(errs) > 0 {
+			v.addf(append(slices.Clone(path), key), "invalid label value %q: %s", labels[key], strings.Join(errs, "; "))
+		}
+	}
+}
+
+// validateTokenRequest checks the service account and lifetime of the tokens minted for a cluster
+func (v *configValidator) validateTokenRequest(path []any, tokenRequest TokenRequestConfig) {
+	v.required(path, map[string]string{"serviceAccount": tokenRequest.ServiceAccount})
+	if tokenRequest.ExpirationSeconds != 0 && tokenRequest.ExpirationSeconds < 600 {
+		v.addf(append(path, "expirationSeconds"), "expirationSeconds must be at least 600, got %d", tokenRequest.ExpirationSeconds)
+	}
+}
+
+// validateApiRole checks the permissions of an API role and the clusters it is limited to
+func (v *configValidator) validateApiRole(path []any, role models.ApiRole, clusterNames map[string]bool) {
+	if role.Name == "" {
+		v.addf(path, "name is required")
+	}
+	if len(role.Permissions) == 0 {
+		v.addf(path, "permissions are required")
+	}
+	for j, permission := range role.Permissions {
+		switch {
+		case !slices.Contains(models.ApiPermissions, permission):
+			v.addf(append(slices.Clone(path), "permissions", j), "unknown permission %q, expected one of %s", permission, strings.Join(models.ApiPermissions, ", "))
+		case permission == models.PermissionAdmin && (len(role.Clusters) > 0 || len(role.Roles) > 0):
+			v.addf(append(slices.Clone(path), "permissions", j), "the admin permission cannot be limited to clusters or roles")
+		}
+	}
+	for j, cluster := range role.Clusters {
+		if !clusterNames[cluster] {
+			v.addf(append(slices.Clone(path), "clusters", j), "unknown cluster %s", cluster)
+		}
+	}
+	v.validateTeams(append(slices.Clone(path), "teams"), role.Teams)
+}
+
+// validateTeams checks that teams have the ID and name they are matched on,
+// and that IDs are qualified with their provider when several providers are enabled
+func (v *configValidator) validateTeams(path []any, teams []models.Team) {
+	for i, team := range teams {
+		v.required(append(slices.Clone(path), i), map[string]string{"id": team.ID, "name": team.Name})
+		if team.ID != "" && len(identityProviders) > 1 && !providerQualified(team.ID) {
+			v.addf(append(slices.Clone(path), i, "id"), "id %s must be qualified with its provider when several identity providers are enabled, e.g. %s:%s",
+				team.ID, identityProviders[0], team.ID)
+		}
+	}
+}
+
+// providerQualified reports whether id starts with an enabled identity provider, e.g. gitlab:123
+func providerQualified(id string) bool {
+	provider, _, ok := strings.Cut(id, ":")
+	return ok && slices.Contains(identityProviders, provider)
+}
+
+// required records the empty fields of the item at path, in a stable order
+func (v *configValidator) required(path []any, fields map[string]string) {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if fields[name] == "" {
+			v.addf(path, "%s is required", name)
+		}
+	}
+}
+
+// lineOf returns the line of the node at path, or of its closest parent when the path does not exist
+func lineOf(root *yaml.Node, path []any) int {
+	node := root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	line := node.Line
+	for _, element := range path {
+		var next *yaml.Node
+		switch key := element.(type) {
+		case string:
+			if node.Kind == yaml.MappingNode {
+				for i := 0; i+1 < len(node.Content); i += 2 {
+					if node.Content[i].Value == key {
+						next = node.Content[i+1]
+						break
+					}
+				}
+			}
+		case int:
+			if node.Kind == yaml.SequenceNode && key < len(node.Content) {
+				next = node.Content[key]
+			}
+		}
+		if next == nil {
+			return line
+		}
+		node, line = next, next.Line
+	}
+	return line
+}
+
+// fieldName formats a path like clusters[1].ca
+func fieldName(path []any) string {
+	var b strings.Builder
+	for _, element := range path {
+		switch key := element.(type) {
+		case string:
+			if b.Len() > 0 {
+				b.WriteString(".")
+			}
+			b.WriteString(key)
+		case int:
+			fmt.Fprintf(&b, "[%d]", key)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,261 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/rest"
+)
+
+func TestParseConfig(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   string
+		expected []string
+	}{
+		{
+			name: "valid",
+			config: `
+clusters:
+  - name: prod
+    type: generic
+    host: https://prod.example.com
+    ca: dGVzdA==
+    tokenSecret: prod-token
+  - name: gke-prod
+    type: gke
+    projectID: my-project
+    region: europe-west2
+  - name: eks-prod
+    type: eks
+    region: eu-west-1
+    roleARN: arn:aws:iam::111111111111:role/kube-jit
+  - name: on-prem
+    type: kubeconfig
+    kubeconfigSecret: on-prem-kubeconfig
+    context: jit
+  - name: staging
+    type: generic
+    host: https://staging.example.com
+    tokenRequest:
+      serviceAccount: kube-jit-staging
+      audiences: ["https://staging.example.com"]
+      expirationSeconds: 3600
+apiRoles:
+  - name: prod-admin
+    permissions: ["requests:approve"]
+    clusters: ["prod"]
+    teams:
+      - name: SRE
+        id: sre
+`,
+		},
+		{name: "empty file", config: ""},
+		{
+			name: "mistakes in clusters",
+			config: `
+clusters:
+  - name: prod
+    type: generic
+    host: prod.example.com
+    ca: not base64!
+  - name: prod
+    type: openshift
+  - type: gke
+  - name: eks-prod
+    type: eks
+    roleARN: kube-jit
+  - name: on-prem
+    type: kubeconfig
+`,
+			expected: []string{
+				"line 3: clusters[0]: tokenSecret is required",
+				"line 5: clusters[0].host: host must be an http(s) URL, got \"prod.example.com\"",
+				"line 6: clusters[0].ca: ca must be a base64 encoded certificate: illegal base64 data at input byte 3",
+				"line 7: clusters[1].name: duplicate cluster name prod",
+				"line 8: clusters[1].type: unknown cluster type \"openshift\", expected one of generic, gke, aks, eks, kubeconfig",
+				"line 9: clusters[2]: name is required",
+				"line 9: clusters[2]: projectID is required",
+				"line 9: clusters[2]: region is required",
+				"line 10: clusters[3]: region is required",
+				"line 12: clusters[3].roleARN: roleARN must be an IAM role ARN, got \"kube-jit\"",
+				"line 13: clusters[4]: one of kubeconfigSecret or kubeconfigFile is required",
+			},
+		},
+		{
+			name: "mistakes in token requests",
+			config: `
+clusters:
+  - name: prod
+    type: generic
+    host: https://prod.example.com
+    tokenSecret: prod-token
+    tokenRequest:
+      expirationSeconds: 60
+  - name: gke-prod
+    type: gke
+    projectID: my-project
+    region: europe-west2
+    tokenRequest:
+      serviceAccount: kube-jit
+`,
+			expected: []string{
+				"line 6: clusters[0].tokenSecret: tokenSecret cannot be used with tokenRequest",
+				"line 8: clusters[0].tokenRequest: serviceAccount is required",
+				"line 8: clusters[0].tokenRequest.expirationSeconds: expirationSeconds must be at least 600, got 60",
+				"line 14: clusters[1].tokenRequest: tokenRequest is only supported by generic clusters",
+			},
+		},
+		{
+			name: "unknown fields and wrong types",
+			config: `
+clusters:
+  - name: prod
+    type: generic
+    host: https://prod.example.com
+    tokensecret: prod-token
+allowedRoles: edit
+`,
+			expected: []string{
+				"line 3: clusters[0]: tokenSecret is required",
+				"line 6: field tokensecret not found in type k8s.ClusterConfig",
+				"line 7: cannot unmarshal !!str `edit` into []models.Roles",
+			},
+		},
+		{
+			name: "mistakes in teams and roles",
+			config: `
+adminTeams:
+  - name: Admins
+apiRoles:
+  - name: auditor
+    permissions: ["history:write"]
+  - name: prod-admin
+    permissions: ["admin"]
+    clusters: ["prod"]
+servicePrincipals:
+  - name: bot
+    clientID: bot
+`,
+			expected: []string{
+				"line 3: adminTeams[0]: id is required",
+				"line 6: apiRoles[0].permissions[0]: unknown permission \"history:write\", expected one of admin, history:read, requests:approve",
+				"line 8: apiRoles[1].permissions[0]: the admin permission cannot be limited to clusters or roles",
+				"line 9: apiRoles[1].clusters[0]: unknown cluster prod",
+				"line 11: servicePrincipals[0]: clientSecretName is required",
+			},
+		},
+		{
+			name: "jitClusters service accounts without tokenRequest",
+			config: `jitClusters:
+  serviceAccounts: ["kube-jit-staging"]
+`,
+			expected: []string{"line 2: jitClusters.serviceAccounts: serviceAccounts are only used with allowTokenRequest"},
+		},
+		{
+			name:     "invalid yaml",
+			config:   "clusters: [\n",
+			expected: []string{"line 1: did not find expected node content"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseConfig([]byte(tc.config))
+			if len(tc.expected) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			var errs ConfigErrors
+			require.True(t, errors.As(err, &errs), "expected ConfigErrors, got %v", err)
+			messages := make([]string, len(errs))
+			for i, configErr := range errs {
+				messages[i] = configErr.Error()
+			}
+			assert.Equal(t, tc.expected, messages)
+		})
+	}
+}
+
+func TestParseConfigWithSeveralProviders(t *testing.T) {
+	original := identityProviders
+	SetIdentityProviders([]string{"github", "gitlab"})
+	t.Cleanup(func() { SetIdentityProviders(original) })
+
+	_, err := parseConfig([]byte(`
+adminTeams:
+  - name: Admins
+    id: gitlab:123
+  - name: Platform
+    id: "123"
+  - name: Sales
+    id: azure:123
+`))
+	var errs ConfigErrors
+	require.True(t, errors.As(err, &errs), "expected ConfigErrors, got %v", err)
+	messages := make([]string, len(errs))
+	for i, configErr := range errs {
+		messages[i] = configErr.Error()
+	}
+	assert.Equal(t, []string{
+		"line 6: adminTeams[1].id: id 123 must be qualified with its provider when several identity providers are enabled, e.g. github:123",
+		"line 8: adminTeams[2].id: id azure:123 must be qualified with its provider when several identity providers are enabled, e.g. github:azure:123",
+	}, messages)
+}
+
+func TestValidateConfigFile(t *testing.T) {
+	writeConfig := setupReload(t)
+	path := filepath.Join(os.Getenv("CONFIG_MOUNT_PATH"), "apiConfig.yaml")
+
+	origCheckClusterConnection := checkClusterConnection
+	checkClusterConnection = func(restConfig *rest.Config) (string, error) {
+		if restConfig.Host == "https://down" {
+			return "", errors.New("connection refused")
+		}
+		return "v1.30.1", nil
+	}
+	t.Cleanup(func() { checkClusterConnection = origCheckClusterConnection })
+
+	t.Run("invalid file", func(t *testing.T) {
+		writeConfig("clusters:\n  - name: prod\n    type: openshift\n")
+		var out bytes.Buffer
+		assert.False(t, ValidateConfigFile(context.Background(), &out, path, false))
+		assert.Equal(t, path+`:3: clusters[0].type: unknown cluster type "openshift", expected one of generic, gke, aks, eks, kubeconfig`+"\n"+path+": 1 errors\n", out.String())
+	})
+
+	t.Run("valid file", func(t *testing.T) {
+		writeConfig("clusters:" + clusterYaml("cluster-a"))
+		var out bytes.Buffer
+		assert.True(t, ValidateConfigFile(context.Background(), &out, path, false))
+		assert.Equal(t, path+": valid\n", out.String())
+	})
+
+	t.Run("dry run reports reachable clusters", func(t *testing.T) {
+		writeConfig("clusters:" + clusterYaml("cluster-a") + `
+  - name: cluster-b
+    host: https://down
+    tokenSecret: test-secret
+    type: generic`)
+		var out bytes.Buffer
+		assert.False(t, ValidateConfigFile(context.Background(), &out, path, true))
+		assert.Equal(t, path+": valid\ncluster cluster-a: reachable, v1.30.1\ncluster cluster-b: unreachable: connection refused\n", out.String())
+	})
+
+	t.Run("dry run with a missing secret", func(t *testing.T) {
+		writeConfig("clusters:\n  - name: cluster-c\n    host: https://fake\n    type: generic\n    tokenSecret: nonexistent\n")
+		var out bytes.Buffer
+		assert.False(t, ValidateConfigFile(context.Background(), &out, path, true))
+		assert.Contains(t, out.String(), "cluster cluster-c: error getting token secret nonexistent")
+	})
+}
+
+func TestSplitCommands(t *testing.T) {
+	assert.Equal(t, []string{"kubelogin", "/usr/local/bin/gke-gcloud-auth-plugin"}, splitCommands(" kubelogin, ,/usr/local/bin/gke-gcloud-auth-plugin,"))
+	assert.Empty(t, splitCommands(""))
+}
@@ -35,7 +35,38 @@ func SplitAndCombineSessionMiddleware() gin.HandlerFunc {
 // CombineSessionData combines session data from multiple cookies
 // into a single session object. It reads the cookies with the session prefix,
 // decodes the data, and sets it in the session.
+// When a Store is used, the cookies only hold the session ID and the data is loaded from the store.
 func CombineSessionData(c *gin.Context) {
+	decodedData, ok := readSessionCookies(c)
+	if !ok {
+		return
+	}
+
+	if store != nil {
+		sessionData, ok := loadServerSession(c, decodedData)
+		if ok {
+			session := sessions.Default(c)
+			session.Set("data", sessionData)
+		}
+		return
+	}
+
+	// Deserialize the JSON string into a map
+	var sessionData map[string]interface{}
+	err := json.Unmarshal([]byte(decodedData), &sessionData)
+	if err != nil {
+		logger.Error("Failed to deserialize session data", zap.Error(err))
+		return
+	}
+
+	// Set the combined session data in the session
+	session := sessions.Default(c)
+	session.Set("data", sessionData)
+}
+
+// readSessionCookies combines and decodes the session cookies, returning the JSON they hold
+// It returns false if there are no session cookies or they are invalid
+func readSessionCookies(c *gin.Context) (string, bool) {
 	var combinedData strings.Builder
 
 	// Iterate through cookies with the session prefix
@@ -47,34 +78,24 @@ func CombineSessionData(c *gin.Context) {
 		}
 		combinedData.WriteString(chunk)
 	}
+	if combinedData.Len() == 0 {
+		return "", false
+	}
 
 	// Decode the combined session data
-	if combinedData.Len() > 0 {
-		var decodedData string
-		err := decodeSessionData("session_data", combinedData.String(), &decodedData)
-		if err != nil {
-			logger.Error("Failed to decode session data", zap.Error(err))
-			return
-		}
-
-		// Check if the decoded data is valid JSON
-		if !json.Valid([]byte(decodedData)) {
-			logger.Error("Decoded session data is not valid JSON")
-			return
-		}
-
-		// Deserialize the JSON string into a map
-		var sessionData map[string]interface{}
-		err = json.Unmarshal([]byte(decodedData), &sessionData)
-		if err != nil {
-			logger.Error("Failed to deserialize session data", zap.Error(err))
-			return
-		}
+	var decodedData string
+	err := decodeSessionData("session_data", combinedData.String(), &decodedData)
+	if err != nil {
+		logger.Error("Failed to decode session data", zap.Error(err))
+		return "", false
+	}
 
-		// Set the combined session data in the session
-		session := sessions.Default(c)
-		session.Set("data", sessionData)
+	// Check if the decoded data is valid JSON
+	if !json.Valid([]byte(decodedData)) {
+		logger.Error("Decoded session data is not valid JSON")
+		return "", false
 	}
+	return decodedData, true
 }
 
 // SplitSessionData splits session data into multiple cookies if necessary
@@ -94,8 +115,17 @@ func SplitSessionData(c *gin.Context) {
 		return
 	}
 
+	// With a Store the cookies only reference the server-side session
+	cookieData := string(sessionDataJSON)
+	if store != nil {
+		var ok bool
+		if cookieData, ok = saveServerSession(c, sessionDataJSON); !ok {
+			return
+		}
+	}
+
 	// Encode the session data
-	encodedData, err := encodeSessionData("session_data", string(sessionDataJSON))
+	encodedData, err := encodeSessionData("session_data", cookieData)
 	if err != nil {
 		logger.Error("Failed to encode session data", zap.Error(err))
 		return
@@ -0,0 +1,153 @@
+package sessioncookie
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// SessionTTL is how long a session lasts without activity, matching the session cookie MaxAge
+const SessionTTL = time.Hour
+
+// touchInterval is how often an unchanged server-side session has its expiry extended
+const touchInterval = 5 * time.Minute
+
+// ErrNoSession is returned by Store.Load for unknown, expired or revoked sessions
+var ErrNoSession = errors.New("session not found, expired or revoked")
+
+// Store keeps session data server side, the session cookies then only hold an opaque session ID
+type Store interface {
+	// Load returns the data and expiry of an active session
+	Load(ctx context.Context, id string) (map[string]interface{}, time.Time, error)
+	// Save creates or updates a session
+	Save(ctx context.Context, id string, data map[string]interface{}, expiresAt time.Time, meta Metadata) error
+	// Revoke ends a session, later loads return ErrNoSession
+	Revoke(ctx context.Context, id string) error
+}
+
+// Metadata describes the client of a session, it is shown when listing sessions
+type Metadata struct {
+	UserAgent string
+	ClientIP  string
+}
+
+// serverSession is the content of the session cookies when a Store is used
+type serverSession struct {
+	ID string `json:"sid"`
+}
+
+// Context keys of the loaded server-side session
+const (
+	sessionIDKey      = "sessionID"
+	sessionJSONKey    = "sessionDataJSON"
+	sessionExpiresKey = "sessionExpiresAt"
+)
+
+var store Store
+
+// UseStore enables server-side sessions in s, nil goes back to keeping the session data in the cookies
+func UseStore(s Store) {
+	store = s
+}
+
+// ServerSideEnabled reports whether session data is kept in a Store
+func ServerSideEnabled() bool {
+	return store != nil
+}
+
+// CurrentSessionID returns the ID of the server-side session loaded for the request
+func CurrentSessionID(c *gin.Context) string {
+	return c.GetString(sessionIDKey)
+}
+
+// loadServerSession loads the session referenced by the decoded cookie data
+func loadServerSession(c *gin.Context, decodedData string) (map[string]interface{}, bool) {
+	var ref serverSession
+	if err := json.Unmarshal([]byte(decodedData), &ref); err != nil || ref.ID == "" {
+		// Cookies holding the session data are not accepted once sessions are server side,
+		// they could not be revoked
+		logger.Warn("Session cookie does not reference a server-side session")
+		return nil, false
+	}
+
+	data, expiresAt, err := store.Load(c.Request.Context(), ref.ID)
+	if err != nil {
+		if !errors.Is(err, ErrNoSession) {
+			logger.Error("Failed to load server-side session", zap.Error(err))
+		}
+		return nil, false
+	}
+
+	dataJSON, _ := json.Marshal(data)
+	c.Set(sessionIDKey, ref.ID)
+	c.Set(sessionJSONKey, string(dataJSON))
+	c.Set(sessionExpiresKey, expiresAt)
+	return data, true
+}
+
+// saveServerSession saves the session data in the store and returns the cookie content referencing it
+// A new session ID is created on login, unchanged sessions are only saved to extend their expiry
+func saveServerSession(c *gin.Context, sessionDataJSON []byte) (string, bool) {
+	var data map[string]interface{}
+	if err := json.Unmarshal(sessionDataJSON, &data); err != nil {
+		logger.Error("Failed to deserialize session data", zap.Error(err))
+		return "", false
+	}
+
+	id := CurrentSessionID(c)
+	if id == "" {
+		var err error
+		if id, err = newSessionID(); err != nil {
+			logger.Error("Failed to generate session ID", zap.Error(err))
+			return "", false
+		}
+	}
+
+	now := time.Now()
+	expiresAt, _ := c.Get(sessionExpiresKey)
+	unchanged := c.GetString(sessionJSONKey) == string(sessionDataJSON)
+	if exp, ok := expiresAt.(time.Time); !unchanged || !ok || exp.Sub(now) < SessionTTL-touchInterval {
+		meta := Metadata{UserAgent: c.Request.UserAgent(), ClientIP: c.ClientIP()}
+		if err := store.Save(c.Request.Context(), id, data, now.Add(SessionTTL), meta); err != nil {
+			logger.Error("Failed to save server-side session", zap.Error(err))
+			return "", false
+		}
+		c.Set(sessionIDKey, id)
+		c.Set(sessionJSONKey, string(sessionDataJSON))
+		c.Set(sessionExpiresKey, now.Add(SessionTTL))
+	}
+
+	ref, _ := json.Marshal(serverSession{ID: id})
+	return string(ref), true
+}
+
+// RevokeSession revokes the server-side session referenced by the request's session cookies, if any
+func RevokeSession(c *gin.Context) error {
+	if store == nil {
+		return nil
+	}
+	decodedData, ok := readSessionCookies(c)
+	if !ok {
+		return nil
+	}
+	var ref serverSession
+	if err := json.Unmarshal([]byte(decodedData), &ref); err != nil || ref.ID == "" {
+		return nil
+	}
+	return store.Revoke(c.Request.Context(), ref.ID)
+}
+
+// newSessionID returns a random, opaque session ID
+func newSessionID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,170 @@
+package sessioncookie
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// memoryStore is an in memory Store counting saves
+type memoryStore struct {
+	sessions map[string]map[string]interface{}
+	expires  map[string]time.Time
+	revoked  map[string]bool
+	saves    int
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		sessions: map[string]map[string]interface{}{},
+		expires:  map[string]time.Time{},
+		revoked:  map[string]bool{},
+	}
+}
+
+func (s *memoryStore) Load(_ context.Context, id string) (map[string]interface{}, time.Time, error) {
+	data, ok := s.sessions[id]
+	if !ok || s.revoked[id] || time.Now().After(s.expires[id]) {
+		return nil, time.Time{}, ErrNoSession
+	}
+	return data, s.expires[id], nil
+}
+
+func (s *memoryStore) Save(_ context.Context, id string, data map[string]interface{}, expiresAt time.Time, _ Metadata) error {
+	s.saves++
+	s.sessions[id] = data
+	s.expires[id] = expiresAt
+	return nil
+}
+
+func (s *memoryStore) Revoke(_ context.Context, id string) error {
+	s.revoked[id] = true
+	return nil
+}
+
+func useMemoryStore(t *testing.T) *memoryStore {
+	t.Helper()
+	os.Setenv("HMAC_SECRET", "a-valid-32-byte-hmac-secret-key")
+	InitLogger(zap.NewNop())
+	s := newMemoryStore()
+	UseStore(s)
+	t.Cleanup(func() { UseStore(nil) })
+	return s
+}
+
+// newStoreRouter serves /login, setting the session data, and /data returning it
+// /data optionally changes the session data with the change query parameter
+func newStoreRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(sessions.Sessions("mysession", cookie.NewStore([]byte("secret"))))
+	r.GET("/login", func(c *gin.Context) {
+		sessions.Default(c).Set("data", map[string]interface{}{"id": "42", "token": "oauth-token"})
+		SplitSessionData(c)
+	})
+	r.GET("/logout", func(c *gin.Context) {
+		if err := RevokeSession(c); err != nil {
+			c.Status(http.StatusInternalServerError)
+		}
+	})
+	r.GET("/data", SplitAndCombineSessionMiddleware(), func(c *gin.Context) {
+		data, ok := sessions.Default(c).Get("data").(map[string]interface{})
+		if !ok {
+			c.Status(http.StatusUnauthorized)
+			return
+		}
+		if change := c.Query("change"); change != "" {
+			data["isAdmin"] = change == "admin"
+		}
+		c.JSON(http.StatusOK, data)
+	})
+	return r
+}
+
+func serve(r *gin.Engine, path string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestServerSideSessions(t *testing.T) {
+	s := useMemoryStore(t)
+	r := newStoreRouter()
+
+	login := serve(r, "/login", nil)
+	cookies := login.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, 1, s.saves)
+
+	// The cookie only references the session, the token stays server side
+	var decoded string
+	require.NoError(t, decodeSessionData("session_data", cookies[0].Value, &decoded))
+	var ref serverSession
+	require.NoError(t, json.Unmarshal([]byte(decoded), &ref))
+	assert.NotEmpty(t, ref.ID)
+	assert.NotContains(t, decoded, "oauth-token")
+	assert.Equal(t, "oauth-token", s.sessions[ref.ID]["token"])
+
+	t.Run("data is loaded from the store", func(t *testing.T) {
+		w := serve(r, "/data", cookies)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"token":"oauth-token"`)
+		// An unchanged, recently saved session is not saved again
+		assert.Equal(t, 1, s.saves)
+	})
+
+	t.Run("changes are saved to the same session", func(t *testing.T) {
+		w := serve(r, "/data?change=admin", cookies)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 2, s.saves)
+		assert.Equal(t, true, s.sessions[ref.ID]["isAdmin"])
+		assert.Len(t, s.sessions, 1)
+	})
+
+	t.Run("expiry is extended once the touch interval has passed", func(t *testing.T) {
+		s.expires[ref.ID] = time.Now().Add(SessionTTL - touchInterval - time.Minute)
+		serve(r, "/data", cookies)
+		assert.Equal(t, 3, s.saves)
+		assert.WithinDuration(t, time.Now().Add(SessionTTL), s.expires[ref.ID], time.Minute)
+	})
+
+	t.Run("revoked sessions are rejected", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve(r, "/logout", cookies).Code)
+		assert.True(t, s.revoked[ref.ID])
+
+		w := serve(r, "/data", cookies)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestServerSideSessions_RejectsDataCookies(t *testing.T) {
+	os.Setenv("HMAC_SECRET", "a-valid-32-byte-hmac-secret-key")
+	InitLogger(zap.NewNop())
+
+	// A cookie holding the session data, as written without a store
+	encoded, err := encodeSessionData("session_data", `{"id":"42","isAdmin":true}`)
+	require.NoError(t, err)
+	cookies := []*http.Cookie{{Name: SessionPrefix + "0", Value: encoded}}
+
+	UseStore(nil)
+	r := newStoreRouter()
+	require.Equal(t, http.StatusOK, serve(r, "/data", cookies).Code)
+
+	useMemoryStore(t)
+	assert.Equal(t, http.StatusUnauthorized, serve(r, "/data", cookies).Code)
+}
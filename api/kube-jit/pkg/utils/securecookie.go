@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"crypto/sha256"
 	"sync"
 
 	"github.com/gorilla/securecookie"
@@ -9,6 +10,9 @@ import (
 var (
 	secureCookieInstance *securecookie.SecureCookie
 	once                 sync.Once // Ensure that the SecureCookie instance is created only once
+
+	tokenCipherInstance *securecookie.SecureCookie
+	tokenCipherOnce     sync.Once
 )
 
 // SecureCookie returns a singleton instance of securecookie.SecureCookie
@@ -23,3 +27,18 @@ func SecureCookie() *securecookie.SecureCookie {
 	})
 	return secureCookieInstance
 }
+
+// TokenCipher returns a singleton securecookie.SecureCookie which encrypts and authenticates values,
+// with keys derived from the HMAC_SECRET environment variable. It keeps OAuth access tokens out of
+// the sessions table in clear text. Values don't expire, the sessions table has its own expiry
+func TokenCipher() *securecookie.SecureCookie {
+	tokenCipherOnce.Do(func() {
+		secret := MustGetEnv("HMAC_SECRET")
+		hashKey := sha256.Sum256([]byte("kube-jit token hash key:" + secret))
+		blockKey := sha256.Sum256([]byte("kube-jit token block key:" + secret))
+		tokenCipherInstance = securecookie.New(hashKey[:], blockKey[:])
+		tokenCipherInstance.MaxLength(16384)
+		tokenCipherInstance.MaxAge(0)
+	})
+	return tokenCipherInstance
+}
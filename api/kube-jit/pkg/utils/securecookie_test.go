@@ -1,7 +1,9 @@
 package utils
 
 import (
+	"encoding/base64"
 	"os"
+	"strings"
 	"sync"
 	"testing"
 
@@ -36,3 +38,28 @@ func TestSecureCookie_EncodeDecode(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, value["foo"], decoded["foo"])
 }
+
+func TestTokenCipher_EncryptsValues(t *testing.T) {
+	os.Setenv("HMAC_SECRET", "test-secret-key")
+	// Reset singleton for test
+	tokenCipherInstance = nil
+	tokenCipherOnce = *new(sync.Once)
+
+	encoded, err := TokenCipher().Encode("token", "gho_secret-access-token")
+	assert.NoError(t, err)
+	// The encoded value is base64("date|base64(value)|mac"), the value must not hold the token in clear text
+	outer, err := base64.URLEncoding.DecodeString(encoded)
+	assert.NoError(t, err)
+	value, err := base64.URLEncoding.DecodeString(strings.Split(string(outer), "|")[1])
+	assert.NoError(t, err)
+	assert.NotContains(t, string(value), "secret-access-token")
+
+	var decoded string
+	assert.NoError(t, TokenCipher().Decode("token", encoded, &decoded))
+	assert.Equal(t, "gho_secret-access-token", decoded)
+
+	// Values encrypted with the session cookie keys are rejected
+	signed, err := SecureCookie().Encode("token", "gho_secret-access-token")
+	assert.NoError(t, err)
+	assert.Error(t, TokenCipher().Decode("token", signed, &decoded))
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"os"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -26,3 +27,21 @@ func GetEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// GetEnvDuration reads a duration (e.g. "5m") from an environment variable or returns a default value if not set
+// It logs fatal and exits, like MustGetEnv, if the value is not a valid duration
+func GetEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		if logger != nil {
+			logger.Fatal("Invalid duration in environment variable", zap.String("key", key), zap.String("value", value))
+		} else {
+			panic("Invalid duration in environment variable: " + key)
+		}
+	}
+	return d
+}
@@ -3,6 +3,7 @@ package utils
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -37,3 +38,22 @@ func TestMustGetEnv_PanicsIfUnset(t *testing.T) {
 	}()
 	_ = MustGetEnv("FOO")
 }
+
+func TestGetEnvDuration(t *testing.T) {
+	os.Unsetenv("FOO")
+	assert.Equal(t, time.Minute, GetEnvDuration("FOO", time.Minute))
+
+	os.Setenv("FOO", "90s")
+	defer os.Unsetenv("FOO")
+	assert.Equal(t, 90*time.Second, GetEnvDuration("FOO", time.Minute))
+
+	os.Setenv("FOO", "0")
+	assert.Equal(t, time.Duration(0), GetEnvDuration("FOO", time.Minute))
+}
+
+func TestGetEnvDuration_PanicsIfInvalid(t *testing.T) {
+	os.Setenv("FOO", "soon")
+	defer os.Unsetenv("FOO")
+	logger = nil // Ensure panic, not os.Exit
+	assert.Panics(t, func() { GetEnvDuration("FOO", time.Minute) })
+}
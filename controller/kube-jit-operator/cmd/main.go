@@ -17,12 +17,14 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -43,6 +45,8 @@ import (
 	"kube-jit-operator/internal/config"
 	"kube-jit-operator/internal/controller"
 	"kube-jit-operator/internal/groupCache"
+	"kube-jit-operator/internal/metrics"
+	"kube-jit-operator/internal/tracing"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -69,12 +73,18 @@ func main() {
 	var enableHTTP2 bool
 	var tlsOpts []func(*tls.Config)
 	var configurationName string
+	var sweepInterval time.Duration
+	var sweepDryRun bool
 	flag.StringVar(
 		&configurationName,
 		"configuration-name",
 		"kube-jit-operator-default",
 		"name of the KubeJitConfig",
 	)
+	flag.DurationVar(&sweepInterval, "rolebinding-sweep-interval", 10*time.Minute,
+		"How often to sweep for expired or orphaned JIT RoleBindings. Set to 0 to disable the sweeper.")
+	flag.BoolVar(&sweepDryRun, "rolebinding-sweep-dry-run", false,
+		"If set, the RoleBinding sweeper only logs and counts the RoleBindings it would delete.")
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
@@ -254,6 +264,20 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "JitGroupCache")
 		os.Exit(1)
 	}
+	if sweepInterval > 0 {
+		if err = mgr.Add(&controller.RoleBindingSweeper{
+			Client:   mgr.GetClient(),
+			Interval: sweepInterval,
+			DryRun:   sweepDryRun,
+		}); err != nil {
+			setupLog.Error(err, "unable to add RoleBinding sweeper to manager")
+			os.Exit(1)
+		}
+	}
+	if err = metrics.RegisterStateCollector(mgr.GetClient(), controller.AnnotationExpiry); err != nil {
+		setupLog.Error(err, "unable to register state metrics collector")
+		os.Exit(1)
+	}
 	// +kubebuilder:scaffold:builder
 
 	if metricsCertWatcher != nil {
@@ -281,9 +305,20 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Spans are exported only if an OTLP endpoint is configured with OTEL_EXPORTER_OTLP_ENDPOINT
+	shutdownTracer, err := tracing.InitTracer(context.Background())
+	if err != nil {
+		setupLog.Error(err, "unable to initialize tracing")
+		os.Exit(1)
+	}
+
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
+		_ = shutdownTracer(context.Background())
 		os.Exit(1)
 	}
+	if err := shutdownTracer(context.Background()); err != nil {
+		setupLog.Error(err, "unable to flush traces")
+	}
 }
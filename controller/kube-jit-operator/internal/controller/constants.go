@@ -5,7 +5,13 @@ const (
 	StatusRejected        = "Rejected"
 	StatusPending         = "Pending"
 	StatusSucceeded       = "Succeeded"
+	StatusRevoked         = "Revoked"
+	StatusExpired         = "Expired"
+	StatusCancelled       = "Cancelled"
 	EventValidationFailed = "ValidationFailed"
 	UnauthorizedApi       = "UnauthorisedApi"
 	Skipped               = "Skipped"
+	AnnotationExpiry      = "jit.kubejit.io/expiry"
+	JitRequestFinalizer   = "jit.kubejit.io/finalizer"
+	AnnotationTraceParent = "jit.kubejit.io/traceparent"
 )
@@ -4,12 +4,14 @@ import (
 	"context"
 	"fmt"
 	jitv1 "kube-jit-operator/api/v1"
+	"kube-jit-operator/internal/metrics"
 	"kube-jit-operator/utils"
 	"time"
 
 	"github.com/go-logr/logr"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
 // handleRejected rejects ticket and deletes a JitRequest
@@ -61,6 +63,7 @@ func (r *JitRequestReconciler) preApproveRequest(ctx context.Context, l logr.Log
 	// invalid start time, reject
 	errMsg := fmt.Errorf("start time %s must be after current time", jitRequest.Spec.StartTime.Time)
 	l.Error(errMsg, "start time validation failed")
+	metrics.ValidationRejectionsTotal.WithLabelValues(metrics.RejectReasonInvalidStartTime).Inc()
 
 	// record event
 	r.raiseEvent(jitRequest, "Warning", EventValidationFailed, errMsg.Error())
@@ -118,6 +121,8 @@ func (r *JitRequestReconciler) handlePreApproved(ctx context.Context, l logr.Log
 	if err := r.updateStatus(ctx, jitRequest, StatusSucceeded, "Access granted until end time"); err != nil {
 		return ctrl.Result{}, err
 	}
+	metrics.GrantDurationSeconds.WithLabelValues(jitRequest.Spec.ClusterRole).
+		Observe(jitRequest.Status.EndTime.Sub(jitRequest.Status.StartTime.Time).Seconds())
 
 	// callback to api
 	if err := r.callbackToApi(ctx, jitRequest); err != nil {
@@ -145,15 +150,61 @@ func (r *JitRequestReconciler) handleCleanup(ctx context.Context, l logr.Logger,
 	return ctrl.Result{}, nil
 }
 
-// handleFetchError cleans-up owned objects (role bindings) on deleted JitRequests
-func (r *JitRequestReconciler) handleFetchError(ctx context.Context, l logr.Logger, err error, jitRequest *jitv1.JitRequest) (ctrl.Result, error) {
+// handleFetchError handles errors fetching a JitRequest, role bindings of deleted
+// JitRequests are cleaned up by the finalizer in handleDeletion
+func (r *JitRequestReconciler) handleFetchError(ctx context.Context, l logr.Logger, err error) (ctrl.Result, error) {
 	if apierrors.IsNotFound(err) {
-		l.Info("JitRequest resource not found. Deleting managed objects.")
-		if err := r.deleteOwnedObjects(ctx, jitRequest); err != nil {
-			return ctrl.Result{}, err
-		}
+		l.Info("JitRequest resource not found. Ignoring since object must be deleted.")
 		return ctrl.Result{}, nil
 	}
 	l.Error(err, "failed to get JitRequest")
 	return ctrl.Result{}, err
 }
+
+// handleDeletion revokes access for a JitRequest being deleted, it removes role bindings
+// in every namespace, sends a final callback to the API and then removes the finalizer
+func (r *JitRequestReconciler) handleDeletion(ctx context.Context, l logr.Logger, jitRequest *jitv1.JitRequest) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(jitRequest, JitRequestFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	l.Info("JitRequest is being deleted, revoking access")
+	if err := r.deleteOwnedObjects(ctx, jitRequest); err != nil {
+		l.Error(err, "failed to delete role bindings for JitRequest")
+		r.raiseEvent(jitRequest, "Warning", "FailedRevoke", fmt.Sprintf("Error: %s", err))
+		return ctrl.Result{}, err
+	}
+
+	// Rejected requests have already been called back with their final status,
+	// requests deleted before their access was granted are Cancelled
+	if jitRequest.Status.State != StatusRejected {
+		status, message := StatusCancelled, "Request deleted before access was granted"
+		if jitRequest.Status.State == StatusSucceeded {
+			status, message = StatusRevoked, "Access revoked before end time"
+			if !jitRequest.Spec.EndTime.Time.After(time.Now()) {
+				status, message = StatusExpired, "Access expired at end time"
+			}
+		}
+		jitRequest.Status.State = status
+		jitRequest.Status.Message = message
+
+		r.raiseEvent(jitRequest, "Normal", status, fmt.Sprintf("%s\nTicket: %s", message, jitRequest.Spec.TicketID))
+
+		// callback to api, a failed callback must not block deletion
+		if err := r.callbackToApi(ctx, jitRequest); err != nil {
+			l.Error(err, "Failed to callback (final status) to API, but proceeding with deletion", "status", status)
+			r.raiseEvent(jitRequest, "Warning", "FailedCallback", fmt.Sprintf("Error: %s", err))
+		}
+	}
+
+	controllerutil.RemoveFinalizer(jitRequest, JitRequestFinalizer)
+	if err := r.Update(ctx, jitRequest); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		l.Error(err, "failed to remove finalizer from JitRequest")
+		return ctrl.Result{}, err
+	}
+	l.Info("Revoked access and removed finalizer", "name", jitRequest.Name)
+	return ctrl.Result{}, nil
+}
@@ -21,16 +21,21 @@ import (
 	"os"
 	"strings"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	jitv1 "kube-jit-operator/api/v1"
+	"kube-jit-operator/internal/tracing"
 	"kube-jit-operator/utils"
 )
 
@@ -50,13 +55,41 @@ type JitRequestReconciler struct {
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is the main loop for reconciling a JitRequest
-func (r *JitRequestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *JitRequestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
 	l := logf.FromContext(ctx)
 
 	// Fetch the JitRequest instance
 	jitRequest, err := r.fetchJitRequest(ctx, req.NamespacedName)
 	if err != nil {
-		return r.handleFetchError(ctx, l, err, jitRequest)
+		return r.handleFetchError(ctx, l, err)
+	}
+
+	// Join the trace started by the API when the request was approved
+	ctx = tracing.ContextFromTraceParent(ctx, jitRequest.Annotations[AnnotationTraceParent])
+	ctx, span := tracing.Tracer().Start(ctx, "JitRequest.Reconcile", trace.WithAttributes(
+		attribute.String("jit.name", jitRequest.Name),
+		attribute.String("jit.ticket_id", jitRequest.Spec.TicketID),
+		attribute.String("jit.state", jitRequest.Status.State),
+	))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	// Revoke access before the JitRequest is removed
+	if !jitRequest.DeletionTimestamp.IsZero() {
+		return r.handleDeletion(ctx, l, jitRequest)
+	}
+
+	// Ensure the finalizer is set so deletion always runs the revoke path
+	if controllerutil.AddFinalizer(jitRequest, JitRequestFinalizer) {
+		if err := r.Update(ctx, jitRequest); err != nil {
+			l.Error(err, "failed to add finalizer to JitRequest")
+			return ctrl.Result{}, err
+		}
 	}
 
 	// Fetch operator config
@@ -0,0 +1,266 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	jitv1 "kube-jit-operator/api/v1"
+	"kube-jit-operator/test/utils"
+)
+
+const (
+	DeletionNamespace = "kube-jit-deletion-test"
+)
+
+var _ = Describe("JitRequest Deletion", Ordered, Label("integration"), func() {
+	var callbacks *callbackRecorder
+	var server *httptest.Server
+
+	BeforeAll(func() {
+		By("removing manager config")
+		cmd := exec.Command("kubectl", "delete", "kjitcfg", TestJitConfig)
+		_, _ = utils.Run(cmd)
+
+		By("removing deletion namespace")
+		cmd = exec.Command("kubectl", "delete", "ns", DeletionNamespace)
+		_, _ = utils.Run(cmd)
+
+		By("creating deletion namespace")
+		err := utils.CreateNamespace(ctx, k8sClient, DeletionNamespace)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("creating the operator KubeJitConfig")
+		err = utils.CreateJitConfig(ctx, k8sClient, utils.ValidClusterRole, DeletionNamespace)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("starting the callback API")
+		callbacks = &callbackRecorder{}
+		server = httptest.NewServer(callbacks)
+	})
+
+	AfterAll(func() {
+		server.Close()
+
+		By("removing deletion namespace")
+		cmd := exec.Command("kubectl", "delete", "ns", DeletionNamespace)
+		_, _ = utils.Run(cmd)
+
+		By("removing manager config")
+		cmd = exec.Command("kubectl", "delete", "kjitcfg", TestJitConfig)
+		_, _ = utils.Run(cmd)
+	})
+
+	Context("When a JitRequest with role bindings is deleted before its end time", func() {
+		It("should remove the role bindings and finalizer and call back Revoked", func() {
+			By("Creating the JitRequest")
+			jitRequest, err := createDeletionJitRequest("deletion-revoked", "revoked-1", utils.ValidClusterRole, server.URL, 2*time.Second, time.Hour)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Waiting for the JitRequest to succeed")
+			err = utils.CheckJitStatus(ctx, k8sClient, jitRequest, utils.StatusSucceeded)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Checking the RoleBinding exists")
+			err = utils.CheckRoleBindingExists(ctx, k8sClient, DeletionNamespace, "deletion-revoked-jit")
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Checking the JitRequest has the finalizer")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: jitRequest.Name}, jitRequest)).To(Succeed())
+			Expect(jitRequest.Finalizers).To(ContainElement(JitRequestFinalizer))
+
+			By("Deleting the JitRequest")
+			Expect(k8sClient.Delete(ctx, jitRequest)).To(Succeed())
+
+			By("Checking the RoleBinding is removed")
+			err = utils.CheckRoleBindingRemoved(ctx, k8sClient, DeletionNamespace, "deletion-revoked-jit")
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Checking the finalizer is removed and the JitRequest is gone")
+			err = utils.CheckJitRemoved(ctx, k8sClient, jitRequest.Name)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Checking the Revoked callback is sent")
+			Eventually(func() []string {
+				return callbacks.statuses("revoked-1")
+			}, "30s", "1s").Should(ContainElement(StatusRevoked))
+		})
+	})
+
+	Context("When a JitRequest reaches its end time", func() {
+		It("should remove the role bindings and call back Expired", func() {
+			By("Creating the JitRequest")
+			jitRequest, err := createDeletionJitRequest("deletion-expired", "expired-1", utils.ValidClusterRole, server.URL, 2*time.Second, 15*time.Second)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Waiting for the JitRequest to succeed")
+			err = utils.CheckJitStatus(ctx, k8sClient, jitRequest, utils.StatusSucceeded)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Checking the RoleBinding is removed at end time")
+			err = utils.CheckRoleBindingRemoved(ctx, k8sClient, DeletionNamespace, "deletion-expired-jit")
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Checking the JitRequest is removed")
+			err = utils.CheckJitRemoved(ctx, k8sClient, jitRequest.Name)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Checking the Expired callback is sent")
+			Eventually(func() []string {
+				return callbacks.statuses("expired-1")
+			}, "30s", "1s").Should(ContainElement(StatusExpired))
+		})
+	})
+
+	Context("When a JitRequest is deleted before its access is granted", func() {
+		It("should call back Cancelled rather than Revoked", func() {
+			By("Creating a JitRequest starting in an hour")
+			jitRequest, err := createDeletionJitRequest("deletion-cancelled", "cancelled-1", utils.ValidClusterRole, server.URL, time.Hour, time.Hour)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Waiting for the JitRequest to be pending")
+			err = utils.CheckJitStatus(ctx, k8sClient, jitRequest, StatusPending)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Deleting the JitRequest")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: jitRequest.Name}, jitRequest)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, jitRequest)).To(Succeed())
+			err = utils.CheckJitRemoved(ctx, k8sClient, jitRequest.Name)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Checking only the Cancelled callback is sent")
+			Eventually(func() []string {
+				return callbacks.statuses("cancelled-1")
+			}, "30s", "1s").Should(ContainElement(StatusCancelled))
+			Consistently(func() []string {
+				return callbacks.statuses("cancelled-1")
+			}, "10s", "1s").Should(Equal([]string{StatusCancelled}))
+		})
+	})
+
+	Context("When a rejected JitRequest is deleted", func() {
+		It("should not send a final callback after the rejection", func() {
+			By("Creating a JitRequest with a cluster role that is not allowed")
+			jitRequest, err := createDeletionJitRequest("deletion-rejected", "rejected-1", "cluster-admin", server.URL, 2*time.Second, time.Hour)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Checking the JitRequest is removed")
+			err = utils.CheckJitRemoved(ctx, k8sClient, jitRequest.Name)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Checking only the Rejected callback is sent")
+			Eventually(func() []string {
+				return callbacks.statuses("rejected-1")
+			}, "30s", "1s").Should(ContainElement(StatusRejected))
+			Consistently(func() []string {
+				return callbacks.statuses("rejected-1")
+			}, "10s", "1s").Should(Equal([]string{StatusRejected}))
+		})
+	})
+
+	Context("When reconciling a JitRequest that is not found", func() {
+		It("should not delete role bindings owned by that JitRequest", func() {
+			By("Creating a RoleBinding owned by a missing JitRequest")
+			err := utils.CreateJitRoleBinding(ctx, k8sClient, "deletion-notfound-jit", DeletionNamespace, nil, "deletion-notfound")
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Reconciling the missing JitRequest")
+			reconciler := &JitRequestReconciler{
+				Client:   k8sClient,
+				Scheme:   scheme.Scheme,
+				Recorder: record.NewFakeRecorder(10),
+			}
+			result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "deletion-notfound"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(ctrl.Result{}))
+
+			By("Checking the RoleBinding still exists")
+			Consistently(func() error {
+				return k8sClient.Get(ctx, types.NamespacedName{Name: "deletion-notfound-jit", Namespace: DeletionNamespace}, &rbacv1.RoleBinding{})
+			}, "10s", "1s").Should(Succeed())
+		})
+	})
+})
+
+// callbackRecorder records the statuses of JitRequest callbacks by ticket ID
+type callbackRecorder struct {
+	mu       sync.Mutex
+	received map[string][]string
+}
+
+func (c *callbackRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	payload := map[string]string{}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.received == nil {
+		c.received = map[string][]string{}
+	}
+	c.received[payload["ticketID"]] = append(c.received[payload["ticketID"]], payload["status"])
+	w.WriteHeader(http.StatusOK)
+}
+
+// statuses returns the callback statuses received for a ticket ID in order
+func (c *callbackRecorder) statuses(ticketID string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.received[ticketID]...)
+}
+
+// createDeletionJitRequest creates a JitRequest starting in startIn in the deletion namespace
+func createDeletionJitRequest(name, ticketID, clusterRole, callbackURL string, startIn, duration time.Duration) (*jitv1.JitRequest, error) {
+	startTime := time.Now().Add(startIn)
+	jitRequest := &jitv1.JitRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: jitv1.JitRequestSpec{
+			ClusterRole:   clusterRole,
+			Requestee:     "master-chief",
+			Justification: "deletion test",
+			Approver:      "captain-keys",
+			UserEmails:    []string{"master-chief@unsc.com"},
+			Email:         "master-chief@unsc.com",
+			TicketID:      ticketID,
+			CallbackURL:   callbackURL,
+			Namespaces:    []string{DeletionNamespace},
+			StartTime:     metav1.NewTime(startTime),
+			EndTime:       metav1.NewTime(startTime.Add(duration)),
+		},
+	}
+
+	return jitRequest, k8sClient.Create(ctx, jitRequest)
+}
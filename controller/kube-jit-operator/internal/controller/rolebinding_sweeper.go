@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	jitv1 "kube-jit-operator/api/v1"
+	"kube-jit-operator/internal/metrics"
+)
+
+const (
+	sweepReasonExpired  = "expired"
+	sweepReasonOrphaned = "orphaned"
+)
+
+// RoleBindingSweeper periodically removes JIT RoleBindings that outlived their JitRequest.
+// It is added to the manager as a runnable and catches bindings missed by
+// deleteOwnedObjects, i.e. when the operator was down while a JitRequest was deleted.
+type RoleBindingSweeper struct {
+	client.Client
+	Interval time.Duration
+	DryRun   bool
+}
+
+// Start runs a sweep on start-up and then on every interval until the context is cancelled
+func (s *RoleBindingSweeper) Start(ctx context.Context) error {
+	l := logf.FromContext(ctx).WithName("rolebinding-sweeper")
+	l.Info("Starting RoleBinding sweeper", "interval", s.Interval, "dryRun", s.DryRun)
+
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Sweep(ctx, l); err != nil {
+			l.Error(err, "RoleBinding sweep failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			l.Info("Stopping RoleBinding sweeper")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection ensures only the leader sweeps RoleBindings
+func (s *RoleBindingSweeper) NeedLeaderElection() bool {
+	return true
+}
+
+// Sweep lists RoleBindings with the JIT expiry annotation in all namespaces and
+// deletes those that are expired or whose owner JitRequest no longer exists
+func (s *RoleBindingSweeper) Sweep(ctx context.Context, l logr.Logger) error {
+	roleBindings := &rbacv1.RoleBindingList{}
+	if err := s.List(ctx, roleBindings); err != nil {
+		metrics.SweeperErrorsTotal.Inc()
+		return fmt.Errorf("failed to list RoleBindings: %w", err)
+	}
+
+	now := time.Now()
+	for i := range roleBindings.Items {
+		roleBinding := &roleBindings.Items[i]
+		expiry, ok := roleBinding.Annotations[AnnotationExpiry]
+		if !ok {
+			continue
+		}
+
+		reason, err := s.sweepReason(ctx, roleBinding, expiry, now)
+		if err != nil {
+			metrics.SweeperErrorsTotal.Inc()
+			l.Error(err, "Failed to evaluate RoleBinding", "namespace", roleBinding.Namespace, "name", roleBinding.Name)
+			continue
+		}
+		if reason == "" {
+			continue
+		}
+
+		if s.DryRun {
+			l.Info("Dry-run: would delete RoleBinding", "namespace", roleBinding.Namespace, "name", roleBinding.Name, "reason", reason)
+		} else {
+			if err := s.Delete(ctx, roleBinding); err != nil && !apierrors.IsNotFound(err) {
+				metrics.SweeperErrorsTotal.Inc()
+				l.Error(err, "Failed to delete RoleBinding", "namespace", roleBinding.Namespace, "name", roleBinding.Name)
+				continue
+			}
+			l.Info("Deleted RoleBinding", "namespace", roleBinding.Namespace, "name", roleBinding.Name, "reason", reason)
+		}
+		metrics.SweeperRoleBindingsDeletedTotal.WithLabelValues(reason, strconv.FormatBool(s.DryRun)).Inc()
+	}
+
+	metrics.SweeperRunsTotal.Inc()
+	metrics.SweeperLastRunTimestamp.SetToCurrentTime()
+	return nil
+}
+
+// sweepReason returns why a RoleBinding should be removed, or an empty string if it should be kept
+func (s *RoleBindingSweeper) sweepReason(ctx context.Context, roleBinding *rbacv1.RoleBinding, expiry string, now time.Time) (string, error) {
+	expiryTime, err := time.Parse(time.RFC3339, expiry)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s annotation %q: %w", AnnotationExpiry, expiry, err)
+	}
+	if !expiryTime.After(now) {
+		return sweepReasonExpired, nil
+	}
+
+	for _, ownerRef := range roleBinding.OwnerReferences {
+		if ownerRef.Kind != "JitRequest" {
+			continue
+		}
+		jitRequest := &jitv1.JitRequest{}
+		if err := s.Get(ctx, types.NamespacedName{Name: ownerRef.Name}, jitRequest); err != nil {
+			if apierrors.IsNotFound(err) {
+				return sweepReasonOrphaned, nil
+			}
+			return "", err
+		}
+		// A JitRequest re-created with the same name does not own this binding
+		if jitRequest.UID != ownerRef.UID {
+			return sweepReasonOrphaned, nil
+		}
+	}
+
+	return "", nil
+}
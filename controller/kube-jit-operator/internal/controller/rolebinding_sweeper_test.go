@@ -0,0 +1,79 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"os/exec"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"kube-jit-operator/test/utils"
+)
+
+const (
+	SweeperNamespace = "kube-jit-sweeper-test"
+)
+
+var _ = Describe("RoleBinding Sweeper", Ordered, Label("integration"), func() {
+
+	BeforeAll(func() {
+		By("removing sweeper namespace")
+		cmd := exec.Command("kubectl", "delete", "ns", SweeperNamespace)
+		_, _ = utils.Run(cmd)
+
+		By("creating sweeper namespace")
+		err := utils.CreateNamespace(ctx, k8sClient, SweeperNamespace)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterAll(func() {
+		By("removing sweeper namespace")
+		cmd := exec.Command("kubectl", "delete", "ns", SweeperNamespace)
+		_, _ = utils.Run(cmd)
+	})
+
+	Context("When a JIT RoleBinding has passed its expiry", func() {
+		It("should be removed by the sweeper", func() {
+			By("Creating an expired RoleBinding")
+			err := utils.CreateJitRoleBinding(ctx, k8sClient, "expired-jit", SweeperNamespace, expiryAnnotation(time.Now().Add(-time.Minute)), "")
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Checking the RoleBinding is eventually removed")
+			err = utils.CheckRoleBindingRemoved(ctx, k8sClient, SweeperNamespace, "expired-jit")
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("When a JIT RoleBinding is owned by a JitRequest that no longer exists", func() {
+		It("should be removed by the sweeper", func() {
+			By("Creating an orphaned RoleBinding")
+			err := utils.CreateJitRoleBinding(ctx, k8sClient, "orphaned-jit", SweeperNamespace, expiryAnnotation(time.Now().Add(time.Hour)), "missing-jit-request")
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Checking the RoleBinding is eventually removed")
+			err = utils.CheckRoleBindingRemoved(ctx, k8sClient, SweeperNamespace, "orphaned-jit")
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+})
+
+// expiryAnnotation returns the annotations of a JIT RoleBinding expiring at expiry
+func expiryAnnotation(expiry time.Time) map[string]string {
+	return map[string]string{AnnotationExpiry: expiry.Format(time.RFC3339)}
+}
@@ -23,6 +23,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -117,6 +118,12 @@ var _ = BeforeSuite(func() {
 			Scheme: k8sManager.GetScheme(),
 		}).SetupWithManager(k8sManager, TestJitConfig, "/tmp/jit-test")
 		Expect(err).ToNot(HaveOccurred())
+
+		err = k8sManager.Add(&RoleBindingSweeper{
+			Client:   k8sManager.GetClient(),
+			Interval: 5 * time.Second,
+		})
+		Expect(err).ToNot(HaveOccurred())
 	}
 
 	go func() {
@@ -6,9 +6,12 @@ import (
 	"encoding/json"
 	"fmt"
 	jitv1 "kube-jit-operator/api/v1"
+	"kube-jit-operator/internal/metrics"
 	"net/http"
 
 	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 
@@ -31,13 +34,19 @@ func (r *JitRequestReconciler) fetchJitRequest(ctx context.Context, namespacedNa
 	return jitRequest, err
 }
 
-// callbackToApi calls back to API with rejected status
-func (r *JitRequestReconciler) callbackToApi(ctx context.Context, jitRequest *jitv1.JitRequest) error {
+// callbackToApi calls back to API with the JitRequest's current status
+func (r *JitRequestReconciler) callbackToApi(ctx context.Context, jitRequest *jitv1.JitRequest) (err error) {
 	l := log.FromContext(ctx)
 
 	// Prepare data to send back to API
 	message := jitRequest.Status.Message
 	status := jitRequest.Status.State
+
+	defer func() {
+		if err != nil {
+			metrics.CallbackFailuresTotal.WithLabelValues(status).Inc()
+		}
+	}()
 	ticketID := jitRequest.Spec.TicketID
 	callback := jitRequest.Spec.CallbackURL
 
@@ -54,13 +63,16 @@ func (r *JitRequestReconciler) callbackToApi(ctx context.Context, jitRequest *ji
 	}
 
 	// Send HTTP POST request to callback URL
-	req, err := http.NewRequest("POST", callback, bytes.NewBuffer(payloadBytes))
+	req, err := http.NewRequestWithContext(ctx, "POST", callback, bytes.NewBuffer(payloadBytes))
 	if err != nil {
 		l.Error(err, "Failed to create HTTP request")
 		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
+	// Propagate the reconcile trace so the API callback handler joins it
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
 	client := &http.Client{
 		Timeout: 60 * time.Second,
 	}
@@ -128,6 +140,7 @@ func (r *JitRequestReconciler) raiseEvent(obj client.Object, eventType, reason,
 // rejectInvalidNamespace rejects an invalid namespace
 func (r *JitRequestReconciler) rejectInvalidNamespace(ctx context.Context, l logr.Logger, jitRequest *jitv1.JitRequest, namespace, err string) (ctrl.Result, error) {
 	errorMsg := fmt.Sprintf("Namespace(s) %s not validated | Error: %s", namespace, err)
+	metrics.ValidationRejectionsTotal.WithLabelValues(metrics.RejectReasonInvalidNamespace).Inc()
 	r.raiseEvent(jitRequest, "Warning", EventValidationFailed, errorMsg)
 	if err := r.updateStatus(ctx, jitRequest, StatusRejected, errorMsg); err != nil {
 		l.Error(err, "failed to update status to Rejected")
@@ -139,6 +152,7 @@ func (r *JitRequestReconciler) rejectInvalidNamespace(ctx context.Context, l log
 // rejectInvalidRole rejects an invalid cluster role
 func (r *JitRequestReconciler) rejectInvalidRole(ctx context.Context, l logr.Logger, jitRequest *jitv1.JitRequest) (ctrl.Result, error) {
 	errorMsg := fmt.Sprintf("ClusterRole '%s' is not allowed", jitRequest.Spec.ClusterRole)
+	metrics.ValidationRejectionsTotal.WithLabelValues(metrics.RejectReasonInvalidRole).Inc()
 	r.raiseEvent(jitRequest, "Warning", EventValidationFailed, errorMsg)
 	if err := r.updateStatus(ctx, jitRequest, StatusRejected, errorMsg); err != nil {
 		l.Error(err, "failed to update status to Rejected")
@@ -158,7 +172,7 @@ func (r *JitRequestReconciler) rejectInvalidRole(ctx context.Context, l logr.Log
 // 	return ctrl.Result{}, nil
 // }
 
-// deleteOwnedObjects deletes role binding(s) in case of k8s GC failed to delete
+// deleteOwnedObjects deletes role binding(s) owned by a JitRequest in each of its namespaces
 func (r *JitRequestReconciler) deleteOwnedObjects(ctx context.Context, jitRequest *jitv1.JitRequest) error {
 	for _, namespace := range jitRequest.Spec.Namespaces {
 		roleBindings := &rbacv1.RoleBindingList{}
@@ -208,7 +222,7 @@ func (r *JitRequestReconciler) createRoleBinding(ctx context.Context, jitRequest
 				Name:      fmt.Sprintf("%s-jit", jitRequest.Name),
 				Namespace: namespace,
 				Annotations: map[string]string{
-					"jit.kubejit.io/expiry": jitRequest.Spec.EndTime.Time.Format(time.RFC3339),
+					AnnotationExpiry: jitRequest.Spec.EndTime.Time.Format(time.RFC3339),
 				},
 			},
 			Subjects: subjects,
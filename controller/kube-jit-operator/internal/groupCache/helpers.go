@@ -4,6 +4,7 @@ import (
 	"context"
 
 	v1 "kube-jit-operator/api/v1"
+	"kube-jit-operator/internal/metrics"
 
 	corev1 "k8s.io/api/core/v1"
 
@@ -120,6 +121,7 @@ func (r *JitGroupCacheReconciler) updateJitGroupCache(ctx context.Context, jitGr
 		l.Error(err, "Failed to update JitGroupCache")
 		return ctrl.Result{}, err
 	}
+	metrics.JitGroupCacheGroups.Set(float64(len(jitGroupCache.Spec.Groups)))
 	return ctrl.Result{}, nil
 }
 
@@ -147,7 +149,7 @@ func (r *JitGroupCacheReconciler) RebuildJitGroupCache(ctx context.Context, l lo
 		}
 	}
 
-	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
 		// Always fetch the latest version before updating
 		jitGroupCache := &v1.JitGroupCache{}
 		err := r.Get(ctx, client.ObjectKey{Name: JitGroupCacheName}, jitGroupCache)
@@ -170,4 +172,9 @@ func (r *JitGroupCacheReconciler) RebuildJitGroupCache(ctx context.Context, l lo
 		jitGroupCache.Spec.Groups = groups
 		return r.Update(ctx, jitGroupCache)
 	})
+	if err != nil {
+		return err
+	}
+	metrics.JitGroupCacheGroups.Set(float64(len(groups)))
+	return nil
 }
@@ -0,0 +1,123 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Validation rejection reasons
+const (
+	RejectReasonInvalidRole      = "invalid_role"
+	RejectReasonInvalidNamespace = "invalid_namespace"
+	RejectReasonInvalidStartTime = "invalid_start_time"
+)
+
+const metricsNamespace = "kube_jit"
+
+var (
+	// SweeperRunsTotal counts completed RoleBinding sweeps
+	SweeperRunsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "rolebinding_sweeper",
+		Name:      "runs_total",
+		Help:      "Total number of RoleBinding sweeps run.",
+	})
+
+	// SweeperErrorsTotal counts errors hit while sweeping RoleBindings
+	SweeperErrorsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "rolebinding_sweeper",
+		Name:      "errors_total",
+		Help:      "Total number of errors encountered while sweeping RoleBindings.",
+	})
+
+	// SweeperRoleBindingsDeletedTotal counts RoleBindings removed (or that would be removed in dry-run) by reason
+	SweeperRoleBindingsDeletedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "rolebinding_sweeper",
+		Name:      "rolebindings_deleted_total",
+		Help:      "Total number of JIT RoleBindings removed by the sweeper, by reason and dry-run mode.",
+	}, []string{"reason", "dry_run"})
+
+	// SweeperLastRunTimestamp records the unix time of the last completed sweep
+	SweeperLastRunTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "rolebinding_sweeper",
+		Name:      "last_run_timestamp_seconds",
+		Help:      "Unix timestamp of the last completed RoleBinding sweep.",
+	})
+
+	// GrantDurationSeconds observes the requested duration of granted access by cluster role
+	GrantDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "grant_duration_seconds",
+		Help:      "Duration between start and end time of granted JIT access, by cluster role.",
+		Buckets: []float64{
+			(15 * time.Minute).Seconds(),
+			(30 * time.Minute).Seconds(),
+			time.Hour.Seconds(),
+			(2 * time.Hour).Seconds(),
+			(4 * time.Hour).Seconds(),
+			(8 * time.Hour).Seconds(),
+			(12 * time.Hour).Seconds(),
+			(24 * time.Hour).Seconds(),
+			(48 * time.Hour).Seconds(),
+			(7 * 24 * time.Hour).Seconds(),
+		},
+	}, []string{"cluster_role"})
+
+	// ValidationRejectionsTotal counts JitRequests rejected by validation, by reason
+	ValidationRejectionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "validation_rejections_total",
+		Help:      "Total number of JitRequests rejected by validation, by reason.",
+	}, []string{"reason"})
+
+	// CallbackFailuresTotal counts failed callbacks to the API, by the status being reported
+	CallbackFailuresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "callback_failures_total",
+		Help:      "Total number of failed status callbacks to the API, by reported status.",
+	}, []string{"status"})
+
+	// JitGroupCacheGroups records the number of groups held in the JitGroupCache
+	JitGroupCacheGroups = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "group_cache",
+		Name:      "groups",
+		Help:      "Number of namespace groups in the JitGroupCache.",
+	})
+)
+
+// init registers the custom metrics with the controller-runtime registry
+// so they are served on the manager's metrics endpoint
+func init() {
+	metrics.Registry.MustRegister(
+		SweeperRunsTotal,
+		SweeperErrorsTotal,
+		SweeperRoleBindingsDeletedTotal,
+		SweeperLastRunTimestamp,
+		GrantDurationSeconds,
+		ValidationRejectionsTotal,
+		CallbackFailuresTotal,
+		JitGroupCacheGroups,
+	)
+}
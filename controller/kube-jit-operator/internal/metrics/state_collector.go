@@ -0,0 +1,135 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	jitv1 "kube-jit-operator/api/v1"
+)
+
+// collectTimeout bounds the cache reads done on each scrape
+const collectTimeout = 10 * time.Second
+
+var (
+	activeGrantsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "active_grants"),
+		"Number of active JIT RoleBindings, by cluster role and namespace.",
+		[]string{"cluster_role", "namespace"}, nil,
+	)
+	jitRequestsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "jitrequests"),
+		"Number of JitRequests, by state.",
+		[]string{"state"}, nil,
+	)
+	jitRequestOldestAgeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "jitrequest_oldest_age_seconds"),
+		"Age of the oldest JitRequest in each state, used to alert on stuck requests.",
+		[]string{"state"}, nil,
+	)
+)
+
+// stateCollector reports gauges computed from the manager's cache on every scrape,
+// so values are correct after an operator restart without tracking increments
+type stateCollector struct {
+	reader          client.Reader
+	grantAnnotation string
+}
+
+// RegisterStateCollector registers the active grants and JitRequest state gauges with the
+// controller-runtime registry. RoleBindings carrying grantAnnotation are counted as active grants.
+func RegisterStateCollector(reader client.Reader, grantAnnotation string) error {
+	return metrics.Registry.Register(&stateCollector{
+		reader:          reader,
+		grantAnnotation: grantAnnotation,
+	})
+}
+
+// Describe implements prometheus.Collector
+func (c *stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- activeGrantsDesc
+	ch <- jitRequestsDesc
+	ch <- jitRequestOldestAgeDesc
+}
+
+// Collect implements prometheus.Collector, metrics that can't be read from the cache are
+// reported as invalid so the scrape fails instead of the gauges silently disappearing
+func (c *stateCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	c.collectActiveGrants(ctx, ch)
+	c.collectJitRequests(ctx, ch)
+}
+
+// collectActiveGrants counts annotated RoleBindings by cluster role and namespace
+func (c *stateCollector) collectActiveGrants(ctx context.Context, ch chan<- prometheus.Metric) {
+	roleBindings := &rbacv1.RoleBindingList{}
+	if err := c.reader.List(ctx, roleBindings); err != nil {
+		ch <- prometheus.NewInvalidMetric(activeGrantsDesc, err)
+		return
+	}
+
+	type grantKey struct{ clusterRole, namespace string }
+	grants := map[grantKey]int{}
+	for _, roleBinding := range roleBindings.Items {
+		if _, ok := roleBinding.Annotations[c.grantAnnotation]; !ok {
+			continue
+		}
+		grants[grantKey{roleBinding.RoleRef.Name, roleBinding.Namespace}]++
+	}
+
+	for key, count := range grants {
+		ch <- prometheus.MustNewConstMetric(activeGrantsDesc, prometheus.GaugeValue, float64(count), key.clusterRole, key.namespace)
+	}
+}
+
+// collectJitRequests counts JitRequests and the age of the oldest one by state
+func (c *stateCollector) collectJitRequests(ctx context.Context, ch chan<- prometheus.Metric) {
+	jitRequests := &jitv1.JitRequestList{}
+	if err := c.reader.List(ctx, jitRequests); err != nil {
+		ch <- prometheus.NewInvalidMetric(jitRequestsDesc, err)
+		ch <- prometheus.NewInvalidMetric(jitRequestOldestAgeDesc, err)
+		return
+	}
+
+	now := time.Now()
+	counts := map[string]int{}
+	oldest := map[string]time.Time{}
+	for _, jitRequest := range jitRequests.Items {
+		state := jitRequest.Status.State
+		if state == "" {
+			state = "New"
+		}
+		counts[state]++
+		created := jitRequest.CreationTimestamp.Time
+		if first, ok := oldest[state]; !ok || created.Before(first) {
+			oldest[state] = created
+		}
+	}
+
+	for state, count := range counts {
+		ch <- prometheus.MustNewConstMetric(jitRequestsDesc, prometheus.GaugeValue, float64(count), state)
+		ch <- prometheus.MustNewConstMetric(jitRequestOldestAgeDesc, prometheus.GaugeValue, now.Sub(oldest[state]).Seconds(), state)
+	}
+}
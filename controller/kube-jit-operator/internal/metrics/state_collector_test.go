@@ -0,0 +1,115 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	jitv1 "kube-jit-operator/api/v1"
+)
+
+const testGrantAnnotation = "jit.kubejit.io/expiry"
+
+var _ = Describe("State Collector", func() {
+	var builder *fake.ClientBuilder
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(rbacv1.AddToScheme(scheme)).To(Succeed())
+		Expect(jitv1.AddToScheme(scheme)).To(Succeed())
+
+		builder = fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			grantRoleBinding("grant-a", "ns-a", "edit", true),
+			grantRoleBinding("grant-b", "ns-a", "edit", true),
+			grantRoleBinding("grant-c", "ns-b", "view", true),
+			grantRoleBinding("not-a-grant", "ns-b", "view", false),
+			stateJitRequest("jit-a", "Succeeded"),
+			stateJitRequest("jit-b", "Succeeded"),
+			stateJitRequest("jit-c", ""),
+		)
+	})
+
+	Context("When the cache can be read", func() {
+		It("should report active grants and JitRequests by state", func() {
+			collector := &stateCollector{reader: builder.Build(), grantAnnotation: testGrantAnnotation}
+
+			expected := `
+# HELP kube_jit_active_grants Number of active JIT RoleBindings, by cluster role and namespace.
+# TYPE kube_jit_active_grants gauge
+kube_jit_active_grants{cluster_role="edit",namespace="ns-a"} 2
+kube_jit_active_grants{cluster_role="view",namespace="ns-b"} 1
+# HELP kube_jit_jitrequests Number of JitRequests, by state.
+# TYPE kube_jit_jitrequests gauge
+kube_jit_jitrequests{state="New"} 1
+kube_jit_jitrequests{state="Succeeded"} 2
+`
+			err := testutil.CollectAndCompare(collector, strings.NewReader(expected), "kube_jit_active_grants", "kube_jit_jitrequests")
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("When listing JitRequests fails", func() {
+		It("should report the JitRequest metrics as invalid", func() {
+			reader := builder.WithInterceptorFuncs(interceptor.Funcs{
+				List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+					if _, ok := list.(*jitv1.JitRequestList); ok {
+						return errors.New("cache not synced")
+					}
+					return c.List(ctx, list, opts...)
+				},
+			}).Build()
+			collector := &stateCollector{reader: reader, grantAnnotation: testGrantAnnotation}
+
+			err := testutil.CollectAndCompare(collector, strings.NewReader(""), "kube_jit_jitrequests")
+			Expect(err).To(MatchError(ContainSubstring("cache not synced")))
+			Expect(err).NotTo(MatchError(ContainSubstring("kube_jit_active_grants")))
+		})
+	})
+})
+
+// grantRoleBinding returns a RoleBinding for clusterRole, annotated as a JIT grant when annotated is set
+func grantRoleBinding(name, namespace, clusterRole string, annotated bool) *rbacv1.RoleBinding {
+	roleBinding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: clusterRole},
+	}
+	if annotated {
+		roleBinding.Annotations = map[string]string{testGrantAnnotation: "2030-01-01T00:00:00Z"}
+	}
+	return roleBinding
+}
+
+// stateJitRequest returns a JitRequest in state
+func stateJitRequest(name, state string) *jitv1.JitRequest {
+	return &jitv1.JitRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     jitv1.JitRequestStatus{State: state},
+	}
+}
@@ -210,6 +210,46 @@ func CreateNamespace(ctx context.Context, k8sClient client.Client, namespace str
 	return nil
 }
 
+// CreateJitRoleBinding creates a RoleBinding carrying the given JIT annotations,
+// optionally owned by a JitRequest that does not need to exist
+func CreateJitRoleBinding(ctx context.Context, k8sClient client.Client, name, namespace string, annotations map[string]string, owner string) error {
+	roleBinding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Annotations: annotations,
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind: rbacv1.UserKind,
+				Name: "sweeper@test.com",
+			},
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     "edit",
+		},
+	}
+
+	if owner != "" {
+		roleBinding.OwnerReferences = []metav1.OwnerReference{
+			{
+				APIVersion: jitv1.GroupVersion.String(),
+				Kind:       "JitRequest",
+				Name:       owner,
+				UID:        types.UID("00000000-0000-0000-0000-000000000000"),
+			},
+		}
+	}
+
+	if err := k8sClient.Create(ctx, roleBinding); err != nil {
+		return fmt.Errorf("failed to create RoleBinding: %w", err)
+	}
+
+	return nil
+}
+
 // CreateJitConfig creates a KubeJitConfig
 func CreateJitConfig(ctx context.Context, k8sClient client.Client, clusterRole, namespace string) error {
 
@@ -240,6 +240,33 @@ describe('ApproveTabPane', () => {
         expect(rejectButton).toBeDisabled();
     });
 
+    it('selects the requests of a bundle together', async () => {
+        mockedAxios.get.mockResolvedValue({
+            data: {
+                pendingRequests: [
+                    { ...mockPendingRequests[0], bundleID: 'bundle-1' },
+                    { ...mockPendingRequests[1], bundleID: 'bundle-1' },
+                    { ...mockPendingRequests[1], ID: 3, username: 'user.three' },
+                ],
+            },
+        });
+        render(<ApproveTabPane {...baseProps} />);
+        await waitFor(() => expect(screen.getByTestId('request-table')).toBeInTheDocument());
+
+        const checkbox1 = screen.getByTestId('checkbox-1') as HTMLInputElement;
+        const checkbox2 = screen.getByTestId('checkbox-2') as HTMLInputElement;
+        const checkbox3 = screen.getByTestId('checkbox-3') as HTMLInputElement;
+
+        fireEvent.click(checkbox2);
+        expect(checkbox1.checked).toBe(true);
+        expect(checkbox2.checked).toBe(true);
+        expect(checkbox3.checked).toBe(false);
+
+        fireEvent.click(checkbox1);
+        expect(checkbox1.checked).toBe(false);
+        expect(checkbox2.checked).toBe(false);
+    });
+
     it('handles approving selected requests', async () => {
         render(<ApproveTabPane {...baseProps} />);
         await screen.findByTestId('request-table'); 
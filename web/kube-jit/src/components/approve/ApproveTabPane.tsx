@@ -41,11 +41,16 @@ const ApproveTabPane = ({ userId, username, setLoadingInCard }: ApproveTabPanePr
         }
     }, [setLoadingInCard]);
 
+    // The requests of a bundle are selected together, to approve or reject the bundle at once
     const handleSelectRequest = (id: number) => {
+        const request = pendingRequests.find(pending => pending.ID === id);
+        const ids = request?.bundleID
+            ? pendingRequests.filter(pending => pending.bundleID === request.bundleID).map(pending => pending.ID)
+            : [id];
         setSelectedRequests(prevSelected =>
             prevSelected.includes(id)
-                ? prevSelected.filter(requestId => requestId !== id)
-                : [...prevSelected, id]
+                ? prevSelected.filter(requestId => !ids.includes(requestId))
+                : [...prevSelected, ...ids.filter(requestId => !prevSelected.includes(requestId))]
         );
     };
 
@@ -122,6 +127,7 @@ const ApproveTabPane = ({ userId, username, setLoadingInCard }: ApproveTabPanePr
                     <p className="form-subtitle mb-0">
                         Approve or reject one or many access requests.<br />
                         <br /><strong>Select requests:</strong> Click on the checkbox next to each request to select it.<br />
+                        <br /><strong>Bundles:</strong> Requests submitted for several clusters at once are grouped in a bundle and selected together.<br />
                         <br /><strong>Approve/Reject:</strong> Click the "Approve" or "Reject" button to approve or reject the selected requests.<br />
                         <br />
                     </p>
@@ -101,4 +101,41 @@ describe('RequestTabPane', () => {
       expect(screen.getByText('Request submitted!')).toBeInTheDocument();
     });
   });
+
+  it('submits a cluster selector for a bundle of requests', async () => {
+    mockedAxios.get = vi.fn().mockResolvedValue({
+      data: {
+        roles: [{ name: 'edit' }],
+        clusters: ['eu-1', 'dev-1', 'us-1'],
+        clusterLabels: { 'eu-1': { env: 'prod' }, 'dev-1': { env: 'dev' }, 'us-1': { env: 'prod' } },
+      },
+    });
+    render(<RequestTabPane {...defaultProps} />);
+
+    const selectorInput = await screen.findByLabelText('Cluster Selector');
+    fireEvent.change(selectorInput, { target: { value: 'env=prod' } });
+    expect(screen.getByText('A request will be submitted for each of: eu-1, us-1')).toBeInTheDocument();
+
+    fireEvent.change(screen.getByPlaceholderText('Enter email address(es)'), { target: { value: 'user@example.com' } });
+    fireEvent.keyDown(screen.getByPlaceholderText('Enter email address(es)'), { key: 'Enter', code: 'Enter' });
+    fireEvent.change(screen.getByPlaceholderText('Enter namespace(s)'), { target: { value: 'ns1' } });
+    fireEvent.keyDown(screen.getByPlaceholderText('Enter namespace(s)'), { key: 'Enter', code: 'Enter' });
+    fireEvent.change(screen.getByPlaceholderText('Enter a reason or reference (max 100 chars)'), { target: { value: 'rollout' } });
+    fireEvent.keyDown(screen.getByLabelText('Role'), { key: 'ArrowDown' });
+    fireEvent.click(await screen.findByText('edit'));
+    fireEvent.change(screen.getByLabelText('startDate'), { target: { value: '2025-05-10T10:00' } });
+    fireEvent.change(screen.getByLabelText('endDate'), { target: { value: '2025-05-10T12:00' } });
+
+    fireEvent.click(screen.getByRole('button', { name: /submit request/i }));
+    mockedAxios.post = vi.fn().mockResolvedValue({ data: { message: 'Requests submitted successfully for clusters eu-1, us-1' } });
+    fireEvent.click(await screen.findByRole('button', { name: /confirm/i }));
+
+    await waitFor(() => {
+      expect(mockedAxios.post).toHaveBeenCalledWith(
+        expect.stringContaining('/submit-request'),
+        expect.objectContaining({ cluster: null, clusterSelector: 'env=prod' }),
+        expect.anything()
+      );
+    });
+  });
 });
\ No newline at end of file
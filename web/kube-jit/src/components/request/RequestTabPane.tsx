@@ -10,11 +10,18 @@ import config from '../../config/config';
 import yaml from 'js-yaml';
 import { Prism as SyntaxHighlighter } from 'react-syntax-highlighter';
 import { oneLight } from 'react-syntax-highlighter/dist/esm/styles/prism';
+import { matchClusters } from './clusterSelector';
 
 type Role = {
     name: string;
 };
 
+type ClusterHealth = {
+    name: string;
+    healthy: boolean;
+    error?: string;
+};
+
 type OptionType = {
     value: string | number;
     label: string;
@@ -32,6 +39,9 @@ type RequestTabPaneProps = {
 const RequestTabPane = ({ username, userId, setLoadingInCard, setActiveTab, setOriginTab }: RequestTabPaneProps) => {
     const [roles, setRoles] = useState<Role[]>([]);
     const [clusters, setClusters] = useState<string[]>([]);
+    const [unavailableClusters, setUnavailableClusters] = useState<string[]>([]);
+    const [clusterLabels, setClusterLabels] = useState<Record<string, Record<string, string>>>({});
+    const [clusterSelector, setClusterSelector] = useState<string>('');
     const [showModal, setShowModal] = useState(false);
     const [selectedRole, setSelectedRole] = useState<SingleValue<OptionType>>(null);
     const [selectedCluster, setSelectedCluster] = useState<SingleValue<OptionType>>(null);
@@ -58,6 +68,11 @@ const RequestTabPane = ({ username, userId, setLoadingInCard, setActiveTab, setO
                 });
                 setRoles(response.data.roles);
                 setClusters(response.data.clusters);
+                // Clusters the API cannot reach are listed but cannot be selected
+                setUnavailableClusters((response.data.clusterHealth || [])
+                    .filter((health: ClusterHealth) => !health.healthy)
+                    .map((health: ClusterHealth) => health.name));
+                setClusterLabels(response.data.clusterLabels || {});
             } catch (error) {
                 console.error('Error fetching roles and clusters:', error);
             }
@@ -66,6 +81,9 @@ const RequestTabPane = ({ username, userId, setLoadingInCard, setActiveTab, setO
         fetchRoles();
     }, []);
 
+    // Clusters matching the selector, a request is submitted for each of them as one bundle
+    const selectedClusters = clusterSelector ? matchClusters(clusterSelector, clusters, clusterLabels) : null;
+
     // Handle namespace tags
     const handleNsTagsChange = (tags: ReactTag[]) => {
         setNamespaces(tags.map((tag: ReactTag) => tag.text));
@@ -95,7 +113,8 @@ const RequestTabPane = ({ username, userId, setLoadingInCard, setActiveTab, setO
         const payload = {
             justification,
             users,
-            cluster: selectedCluster ? { name: selectedCluster.label } : null,
+            cluster: !clusterSelector && selectedCluster ? { name: selectedCluster.label } : null,
+            clusterSelector: clusterSelector || undefined,
             namespaces,
             role: selectedRole ? { name: selectedRole.label } : null,
             requestorId: userId.toString(),
@@ -115,6 +134,7 @@ const RequestTabPane = ({ username, userId, setLoadingInCard, setActiveTab, setO
             setErrorMessage(''); // Clear any previous error message
             setSelectedRole(null); // Clear the selected role
             setSelectedCluster(null); // Clear the cluster
+            setClusterSelector(''); // Clear the cluster selector
             setNamespaces([]); // Clear the namespace
             // Clear the namespace and email tags
             if (nsInputTagRef.current) {
@@ -221,6 +241,7 @@ const RequestTabPane = ({ username, userId, setLoadingInCard, setActiveTab, setO
     const handleClearForm = () => {
         setSelectedRole(null);
         setSelectedCluster(null);
+        setClusterSelector('');
         setNamespaces([]);
         setUsers([]);
         setJustification('');
@@ -326,6 +347,8 @@ const RequestTabPane = ({ username, userId, setLoadingInCard, setActiveTab, setO
                                         value: cluster,
                                         label: cluster
                                     }))}
+                                    isOptionDisabled={(option) => unavailableClusters.includes(String(option.value))}
+                                    isDisabled={!!clusterSelector}
                                     isSearchable
                                     onChange={(selectedOption) => {
                                         setSelectedCluster(selectedOption);
@@ -335,6 +358,36 @@ const RequestTabPane = ({ username, userId, setLoadingInCard, setActiveTab, setO
                                 />
                                 {clusterError && <Form.Text className="text-danger">{clusterError}</Form.Text>}
                             </Form.Group>
+                            {/* Cluster selector field, offered when clusters have labels */}
+                            {Object.keys(clusterLabels).length > 0 && (
+                                <Form.Group controlId="clusterSelector" className="mb-3">
+                                    <Form.Label>Cluster Selector</Form.Label>
+                                    <Form.Control
+                                        type="text"
+                                        name="clusterSelector"
+                                        placeholder="Or select clusters by labels, e.g. env=prod,region in (eu,us)"
+                                        value={clusterSelector}
+                                        onChange={(e) => {
+                                            setClusterSelector(e.target.value);
+                                            setSelectedCluster(null);
+                                            setClusterError('');
+                                        }}
+                                    />
+                                    {clusterSelector && selectedClusters === null && (
+                                        <Form.Text className="text-danger">Invalid cluster selector</Form.Text>
+                                    )}
+                                    {selectedClusters && selectedClusters.length === 0 && (
+                                        <Form.Text className="text-danger">No cluster matches the selector</Form.Text>
+                                    )}
+                                    {selectedClusters && selectedClusters.length > 0 && (
+                                        <Form.Text className="text-muted">
+                                            A request will be submitted for each of: {selectedClusters
+                                                .map(cluster => unavailableClusters.includes(cluster) ? `${cluster} (unavailable)` : cluster)
+                                                .join(', ')}
+                                        </Form.Text>
+                                    )}
+                                </Form.Group>
+                            )}
                             <Form.Group className="mb-3" controlId="namespace">
                                 <Form.Label>Namespace(s)</Form.Label>
                                 <InputTag
@@ -456,7 +509,7 @@ const RequestTabPane = ({ username, userId, setLoadingInCard, setActiveTab, setO
                                 className="w-100 submit-button"
                                 disabled={
                                     !selectedRole ||
-                                    !selectedCluster ||
+                                    (clusterSelector ? !selectedClusters || selectedClusters.length < 1 : !selectedCluster) ||
                                     users.length < 1 ||
                                     namespaces.length < 1 ||
                                     !justification ||
@@ -485,6 +538,9 @@ const RequestTabPane = ({ username, userId, setLoadingInCard, setActiveTab, setO
                                 <ul className="info-box-list">
                                     <li><strong>User Emails:</strong> Enter the email addresses you are requesting access for (use comma/enter/space for a new email).</li>
                                     <li><strong>Cluster:</strong> Select the cluster you are requesting access for.</li>
+                                    {Object.keys(clusterLabels).length > 0 && (
+                                        <li><strong>Cluster Selector:</strong> Instead of a cluster, enter labels like <code>env=prod</code> to request the same access on every matching cluster. The requests are approved together as one bundle.</li>
+                                    )}
                                     <li><strong>Namespaces:</strong> Enter the Namespaces you are requesting access for (use comma/enter/space for a new namespace).</li>
                                     <li><strong>Justification:</strong> Enter the reason/ticket reference for the access request.</li>
                                     <li><strong>Role:</strong> Select the role you are requesting access for.</li>
@@ -532,7 +588,15 @@ cluster: dev-cluster`}
                             ))}
                         </div>
                         <div>
-                            <strong>Cluster:</strong> {selectedCluster ? selectedCluster.label : 'Not selected'}
+                            {clusterSelector ? (
+                                <>
+                                    <strong>Clusters ({clusterSelector}):</strong> {selectedClusters ? selectedClusters.join(', ') : 'None'}
+                                </>
+                            ) : (
+                                <>
+                                    <strong>Cluster:</strong> {selectedCluster ? selectedCluster.label : 'Not selected'}
+                                </>
+                            )}
                         </div>
                         <div>
                             <strong>Namespaces:</strong>
@@ -0,0 +1,29 @@
+import { describe, it, expect } from 'vitest';
+import { matchClusters } from './clusterSelector';
+
+const clusters = ['eu-1', 'dev-1', 'us-1', 'unlabeled'];
+const clusterLabels = {
+  'eu-1': { env: 'prod', region: 'eu' },
+  'dev-1': { env: 'dev', region: 'eu' },
+  'us-1': { env: 'prod', region: 'us', team: 'payments' },
+};
+
+describe('matchClusters', () => {
+  it('matches equality requirements', () => {
+    expect(matchClusters('env=prod', clusters, clusterLabels)).toEqual(['eu-1', 'us-1']);
+    expect(matchClusters('env==prod, region=eu', clusters, clusterLabels)).toEqual(['eu-1']);
+    expect(matchClusters('env!=prod', clusters, clusterLabels)).toEqual(['dev-1', 'unlabeled']);
+  });
+
+  it('matches set and existence requirements', () => {
+    expect(matchClusters('region in (eu, us),env notin (dev)', clusters, clusterLabels)).toEqual(['eu-1', 'us-1']);
+    expect(matchClusters('team', clusters, clusterLabels)).toEqual(['us-1']);
+    expect(matchClusters('!region', clusters, clusterLabels)).toEqual(['unlabeled']);
+  });
+
+  it('rejects empty and invalid selectors', () => {
+    expect(matchClusters('', clusters, clusterLabels)).toBeNull();
+    expect(matchClusters('env=prod,', clusters, clusterLabels)).toBeNull();
+    expect(matchClusters('env in prod', clusters, clusterLabels)).toBeNull();
+  });
+});
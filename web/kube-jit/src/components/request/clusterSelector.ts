@@ -0,0 +1,72 @@
+// Matches cluster labels against a Kubernetes label selector, like "env=prod,region in (eu,us)",
+// to preview the clusters of a request bundle. The API evaluates the selector again on submit.
+
+type Requirement = (labels: Record<string, string>) => boolean;
+
+const key = String.raw`[A-Za-z0-9][-A-Za-z0-9_./]*`;
+const value = String.raw`[A-Za-z0-9]?[-A-Za-z0-9_.]*`;
+const setPattern = new RegExp(String.raw`^(${key})\s+(in|notin)\s+\(\s*(${value}(?:\s*,\s*${value})*)\s*\)$`);
+const equalityPattern = new RegExp(String.raw`^(${key})\s*(==|=|!=)\s*(${value})$`);
+const existsPattern = new RegExp(String.raw`^(!?)\s*(${key})$`);
+
+// Splits a selector on the commas outside of parentheses
+const splitRequirements = (selector: string): string[] => {
+    const parts: string[] = [];
+    let depth = 0;
+    let current = '';
+    for (const char of selector) {
+        if (char === '(') depth++;
+        if (char === ')') depth--;
+        if (char === ',' && depth === 0) {
+            parts.push(current.trim());
+            current = '';
+        } else {
+            current += char;
+        }
+    }
+    parts.push(current.trim());
+    return parts;
+};
+
+const parseRequirement = (requirement: string): Requirement | null => {
+    let match = setPattern.exec(requirement);
+    if (match) {
+        const [, name, operator, values] = match;
+        const allowed = values.split(',').map(v => v.trim());
+        return operator === 'in'
+            ? labels => name in labels && allowed.includes(labels[name])
+            : labels => !(name in labels) || !allowed.includes(labels[name]);
+    }
+    match = equalityPattern.exec(requirement);
+    if (match) {
+        const [, name, operator, expected] = match;
+        return operator === '!='
+            ? labels => labels[name] !== expected
+            : labels => labels[name] === expected;
+    }
+    match = existsPattern.exec(requirement);
+    if (match) {
+        const [, not, name] = match;
+        return not ? labels => !(name in labels) : labels => name in labels;
+    }
+    return null;
+};
+
+// Returns the clusters whose labels match the selector in their order, or null when the selector is invalid
+export const matchClusters = (
+    selector: string,
+    clusters: string[],
+    clusterLabels: Record<string, Record<string, string>>
+): string[] | null => {
+    if (!selector.trim()) return null;
+    const requirements: Requirement[] = [];
+    for (const part of splitRequirements(selector)) {
+        const requirement = parseRequirement(part);
+        if (!requirement) return null;
+        requirements.push(requirement);
+    }
+    return clusters.filter(cluster => {
+        const labels = clusterLabels[cluster] || {};
+        return requirements.every(requirement => requirement(labels));
+    });
+};
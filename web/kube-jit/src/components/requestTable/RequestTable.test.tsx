@@ -107,6 +107,25 @@ describe('RequestTable', () => {
     expect(screen.queryByText('prod-cluster')).not.toBeInTheDocument();
   });
 
+  it('groups the requests of a bundle under a bundle row', () => {
+    render(
+      <RequestTable
+        {...baseProps}
+        mode="pending"
+        requests={[
+          { ...pendingRequests[0], bundleID: 'bundle-1', clusterName: 'eu-1' },
+          { ...pendingRequests[0], ID: 3, username: 'carol', clusterName: 'other-cluster' },
+          { ...pendingRequests[0], ID: 2, bundleID: 'bundle-1', clusterName: 'us-1' },
+        ]}
+      />
+    );
+    expect(screen.getByText('Bundle of 2 requests for clusters eu-1, us-1')).toBeInTheDocument();
+    const clusterCells = screen.getAllByRole('row').slice(1).map(row => row.textContent);
+    expect(clusterCells[1]).toContain('eu-1');
+    expect(clusterCells[2]).toContain('us-1');
+    expect(clusterCells[3]).toContain('other-cluster');
+  });
+
   it('calls setVariant when toggling dark mode', () => {
     render(
       <RequestTable
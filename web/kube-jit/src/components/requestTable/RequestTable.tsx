@@ -14,6 +14,25 @@ type RequestTableProps = {
     setVariant: (variant: 'light' | 'dark') => void;
 };
 
+// Moves the requests of a bundle next to its first request, to show them together under a bundle row
+const groupBundles = <T extends { bundleID?: string }>(requests: T[]): T[] => {
+    const bundles = new Map<string, T[]>();
+    requests.forEach(request => {
+        if (request.bundleID) {
+            bundles.set(request.bundleID, [...(bundles.get(request.bundleID) || []), request]);
+        }
+    });
+    const grouped: T[] = [];
+    requests.forEach(request => {
+        if (!request.bundleID) {
+            grouped.push(request);
+        } else if (bundles.get(request.bundleID)?.[0] === request) {
+            grouped.push(...(bundles.get(request.bundleID) || []));
+        }
+    });
+    return grouped;
+};
+
 const RequestTable: React.FC<RequestTableProps> = ({ mode, requests, selectable, selectedRequests, handleSelectRequest, variant, setVariant }) => {
     const [filters, setFilters] = useState({
         username: '',
@@ -65,6 +84,28 @@ const RequestTable: React.FC<RequestTableProps> = ({ mode, requests, selectable,
         }
     });
 
+    const groupedRequests = groupBundles<Request | PendingRequest>(filteredRequests);
+    const columnCount = mode === 'pending' ? (selectable ? 10 : 9) : 13;
+
+    // A bundle row precedes the requests of a bundle, listing their clusters
+    const bundleRow = (request: Request | PendingRequest, index: number) => {
+        if (!request.bundleID || (index > 0 && groupedRequests[index - 1].bundleID === request.bundleID)) {
+            return null;
+        }
+        const bundle = groupedRequests.filter(other => other.bundleID === request.bundleID);
+        if (bundle.length < 2) {
+            return null;
+        }
+        return (
+            <tr className="bundle-row">
+                <td colSpan={columnCount}>
+                    <i className="bi bi-collection me-2"></i>
+                    Bundle of {bundle.length} requests for clusters {bundle.map(other => other.clusterName).join(', ')}
+                </td>
+            </tr>
+        );
+    };
+
     const exportToCSV = () => {
         const headers = [
             'ID',
@@ -306,131 +347,137 @@ const RequestTable: React.FC<RequestTableProps> = ({ mode, requests, selectable,
                                 </tr>
                             </thead>
                             <tbody>
-                                {filteredRequests.map(request => {
+                                {groupedRequests.map((request, index) => {
                                     if (mode === 'pending') {
                                         const pendingRequest = request as PendingRequest;
                                         return (
-                                            <tr key={pendingRequest.ID}>
-                                                {selectable && (
+                                            <React.Fragment key={pendingRequest.ID}>
+                                                {bundleRow(pendingRequest, index)}
+                                                <tr className={pendingRequest.bundleID ? 'bundle-member' : undefined}>
+                                                    {selectable && (
+                                                        <td>
+                                                            <label className="select-checkbox-label">
+                                                                <input
+                                                                    id="select-checkbox"
+                                                                    type="checkbox"
+                                                                    checked={selectedRequests.includes(pendingRequest.ID)}
+                                                                    onChange={() => handleSelectRequest(pendingRequest.ID)}
+                                                                    className="select-checkbox"
+                                                                />
+                                                                <span className="custom-checkbox"></span>
+                                                            </label>
+                                                        </td>
+                                                    )}
+                                                    <td>{pendingRequest.ID}</td>
                                                     <td>
-                                                        <label className="select-checkbox-label">
-                                                            <input
-                                                                id="select-checkbox"
-                                                                type="checkbox"
-                                                                checked={selectedRequests.includes(pendingRequest.ID)}
-                                                                onChange={() => handleSelectRequest(pendingRequest.ID)}
-                                                                className="select-checkbox"
-                                                            />
-                                                            <span className="custom-checkbox"></span>
-                                                        </label>
-                                                    </td>
-                                                )}
-                                                <td>{pendingRequest.ID}</td>
-                                                <td>
-                                                    {new Date(pendingRequest.startDate).toLocaleString(undefined, {
-                                                        year: 'numeric',
-                                                        month: 'numeric',
-                                                        day: 'numeric',
-                                                        hour: 'numeric',
-                                                        minute: 'numeric',
-                                                    })}{' '}
-                                                    -{' '}
-                                                    {new Date(pendingRequest.endDate).toLocaleString(undefined, {
-                                                        year: 'numeric',
-                                                        month: 'numeric',
-                                                        day: 'numeric',
-                                                        hour: 'numeric',
-                                                        minute: 'numeric',
-                                                    })}
-                                                </td>
-                                                <td>{pendingRequest.username}</td>
-                                                <td>{pendingRequest.users.join(', ')}</td>
-                                                <td>{pendingRequest.clusterName}</td>
-                                                <td>{Array.isArray(pendingRequest.namespaces) ? pendingRequest.namespaces.join(', ') : pendingRequest.namespaces}</td>
-                                                <td>{pendingRequest.justification}</td>
-                                                <td>{pendingRequest.roleName}</td>
-                                                <td>
-                                                    {new Date(pendingRequest.CreatedAt).toLocaleString(undefined, {
+                                                        {new Date(pendingRequest.startDate).toLocaleString(undefined, {
                                                             year: 'numeric',
                                                             month: 'numeric',
                                                             day: 'numeric',
                                                             hour: 'numeric',
                                                             minute: 'numeric',
-                                                    })}
-                                                </td>
-                                            </tr>
+                                                        })}{' '}
+                                                        -{' '}
+                                                        {new Date(pendingRequest.endDate).toLocaleString(undefined, {
+                                                            year: 'numeric',
+                                                            month: 'numeric',
+                                                            day: 'numeric',
+                                                            hour: 'numeric',
+                                                            minute: 'numeric',
+                                                        })}
+                                                    </td>
+                                                    <td>{pendingRequest.username}</td>
+                                                    <td>{pendingRequest.users.join(', ')}</td>
+                                                    <td>{pendingRequest.clusterName}</td>
+                                                    <td>{Array.isArray(pendingRequest.namespaces) ? pendingRequest.namespaces.join(', ') : pendingRequest.namespaces}</td>
+                                                    <td>{pendingRequest.justification}</td>
+                                                    <td>{pendingRequest.roleName}</td>
+                                                    <td>
+                                                        {new Date(pendingRequest.CreatedAt).toLocaleString(undefined, {
+                                                                year: 'numeric',
+                                                                month: 'numeric',
+                                                                day: 'numeric',
+                                                                hour: 'numeric',
+                                                                minute: 'numeric',
+                                                        })}
+                                                    </td>
+                                                </tr>
+                                            </React.Fragment>
                                         );
                                     } else {
                                         const historicalRequest = request as Request;
                                         return (
-                                            <tr key={historicalRequest.ID}>
-                                                <td>{historicalRequest.ID}</td>
-                                                <td>
-                                                    {new Date(historicalRequest.startDate).toLocaleString(undefined, {
-                                                        year: 'numeric',
-                                                        month: 'numeric',
-                                                        day: 'numeric',
-                                                        hour: 'numeric',
-                                                        minute: 'numeric',
-                                                    })}{' '}
-                                                    -{' '}
-                                                    {new Date(historicalRequest.endDate).toLocaleString(undefined, {
-                                                        year: 'numeric',
-                                                        month: 'numeric',
-                                                        day: 'numeric',
-                                                        hour: 'numeric',
-                                                        minute: 'numeric',
-                                                    })}
-                                                </td>
-                                                <td>{historicalRequest.username}</td>
-                                                <td>{historicalRequest.approverNames ? historicalRequest.approverNames.join(', ') : 'N/A'}</td>
-                                                {mode === 'history' && (
-                                                    <td className="namespace-approvals-col">
-                                                        {historicalRequest.namespaceApprovals && historicalRequest.namespaceApprovals.length > 0 ? (
-                                                            <ul style={{ paddingLeft: 16, marginBottom: 0 }}>
-                                                                {historicalRequest.namespaceApprovals.map((ns, idx) => {
-                                                                    let statusIcon = '🟡'; // Default: pending
-                                                                    if (ns.approved === true && ns.approverName) statusIcon = '✅';
-                                                                    else if (ns.approved === false && ns.approverName) statusIcon = '❌';
-                                                                    return (
-                                                                        <li key={idx}>
-                                                                            <strong>{ns.namespace}</strong>
-                                                                            {' '}(<span style={{ color: '#888' }}>{ns.groupName}</span>)
-                                                                            :
-                                                                            {ns.approverName ? (
-                                                                                <div style={{ display: 'block', marginLeft: 0 }}>
-                                                                                    {ns.approverName} {statusIcon}
-                                                                                </div>
-                                                                            ) : (
-                                                                                <div style={{ display: 'block', marginLeft: 0 }}>
-                                                                                    Pending {statusIcon}
-                                                                                </div>
-                                                                            )}
-                                                                        </li>
-                                                                    );
-                                                                })}
-                                                            </ul>
-                                                        ) : (
-                                                            'N/A'
-                                                        )}
-                                                    </td>
-                                                )}
-                                                <td>{historicalRequest.users.join(', ')}</td>
-                                                <td>{historicalRequest.clusterName}</td>
-                                                <td>{historicalRequest.namespaces ? historicalRequest.namespaces.join(', ') : 'N/A'}</td>
-                                                <td>{historicalRequest.justification}</td>
-                                                <td>{historicalRequest.roleName}</td>
-                                                <td>{new Date(historicalRequest.CreatedAt).toLocaleString(undefined, {
+                                            <React.Fragment key={historicalRequest.ID}>
+                                                {bundleRow(historicalRequest, index)}
+                                                <tr className={historicalRequest.bundleID ? 'bundle-member' : undefined}>
+                                                    <td>{historicalRequest.ID}</td>
+                                                    <td>
+                                                        {new Date(historicalRequest.startDate).toLocaleString(undefined, {
                                                             year: 'numeric',
                                                             month: 'numeric',
                                                             day: 'numeric',
                                                             hour: 'numeric',
                                                             minute: 'numeric',
-                                                    })}
-                                                </td>
-                                                <td>{historicalRequest.status}</td>
-                                                <td>{historicalRequest.notes}</td>
-                                            </tr>
+                                                        })}{' '}
+                                                        -{' '}
+                                                        {new Date(historicalRequest.endDate).toLocaleString(undefined, {
+                                                            year: 'numeric',
+                                                            month: 'numeric',
+                                                            day: 'numeric',
+                                                            hour: 'numeric',
+                                                            minute: 'numeric',
+                                                        })}
+                                                    </td>
+                                                    <td>{historicalRequest.username}</td>
+                                                    <td>{historicalRequest.approverNames ? historicalRequest.approverNames.join(', ') : 'N/A'}</td>
+                                                    {mode === 'history' && (
+                                                        <td className="namespace-approvals-col">
+                                                            {historicalRequest.namespaceApprovals && historicalRequest.namespaceApprovals.length > 0 ? (
+                                                                <ul style={{ paddingLeft: 16, marginBottom: 0 }}>
+                                                                    {historicalRequest.namespaceApprovals.map((ns, idx) => {
+                                                                        let statusIcon = '🟡'; // Default: pending
+                                                                        if (ns.approved === true && ns.approverName) statusIcon = '✅';
+                                                                        else if (ns.approved === false && ns.approverName) statusIcon = '❌';
+                                                                        return (
+                                                                            <li key={idx}>
+                                                                                <strong>{ns.namespace}</strong>
+                                                                                {' '}(<span style={{ color: '#888' }}>{ns.groupName}</span>)
+                                                                                :
+                                                                                {ns.approverName ? (
+                                                                                    <div style={{ display: 'block', marginLeft: 0 }}>
+                                                                                        {ns.approverName} {statusIcon}
+                                                                                    </div>
+                                                                                ) : (
+                                                                                    <div style={{ display: 'block', marginLeft: 0 }}>
+                                                                                        Pending {statusIcon}
+                                                                                    </div>
+                                                                                )}
+                                                                            </li>
+                                                                        );
+                                                                    })}
+                                                                </ul>
+                                                            ) : (
+                                                                'N/A'
+                                                            )}
+                                                        </td>
+                                                    )}
+                                                    <td>{historicalRequest.users.join(', ')}</td>
+                                                    <td>{historicalRequest.clusterName}</td>
+                                                    <td>{historicalRequest.namespaces ? historicalRequest.namespaces.join(', ') : 'N/A'}</td>
+                                                    <td>{historicalRequest.justification}</td>
+                                                    <td>{historicalRequest.roleName}</td>
+                                                    <td>{new Date(historicalRequest.CreatedAt).toLocaleString(undefined, {
+                                                                year: 'numeric',
+                                                                month: 'numeric',
+                                                                day: 'numeric',
+                                                                hour: 'numeric',
+                                                                minute: 'numeric',
+                                                        })}
+                                                    </td>
+                                                    <td>{historicalRequest.status}</td>
+                                                    <td>{historicalRequest.notes}</td>
+                                                </tr>
+                                            </React.Fragment>
                                         );
                                     }
                                 })}
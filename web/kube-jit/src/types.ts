@@ -17,6 +17,7 @@ export type Request = {
     approverNames: string[];
     notes: string;
     namespaceApprovals?: NamespaceApprovalInfo[];
+    bundleID?: string; // shared by the requests submitted together with a cluster selector
 };
 
 export type ApiResponse = {
@@ -47,6 +48,7 @@ export type PendingRequest = {
     groupIDs: string[];
     approvedList: boolean[];
     CreatedAt: string;
+    bundleID?: string; // shared by the requests submitted together with a cluster selector
 };
 
 export type NamespaceApprovalInfo = {